@@ -33,6 +33,49 @@ _alias_repository = repository_rule(
 
 _DEFAULT_NAME = "bazeldnf"
 
+def _sanitize(name):
+    return name.replace(":", "__").replace("+", "__plus__").replace("~", "__tilde__").replace("^", "__caret__")
+
+_HEX_DIGITS = "0123456789abcdef"
+
+_BASE64_ALPHABET = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+def _hex_to_base64(value):
+    """Converts a hex encoded checksum to the base64 encoding used by subresource integrity values."""
+    value = value.lower()
+    data = [_HEX_DIGITS.index(value[i]) * 16 + _HEX_DIGITS.index(value[i + 1]) for i in range(0, len(value) - 1, 2)]
+    encoded = ""
+    for i in range(0, len(data), 3):
+        chunk = data[i:i + 3]
+        bits = 0
+        for byte in chunk + [0] * (3 - len(chunk)):
+            bits = bits * 256 + byte
+        for j in range(4):
+            if j > len(chunk):
+                encoded += "="
+            else:
+                encoded += _BASE64_ALPHABET[(bits >> (18 - 6 * j)) & 63]
+    return encoded
+
+def _bazeldnf_lock_file_rpms(lock_file, lock_file_json):
+    """Converts the packages of a lockfile written by `bazeldnf rpmtree --lockfile` to rpm entries."""
+    rpms = []
+    for pkg in lock_file_json["packages"]:
+        checksum_type, _, checksum = pkg["checksum"].partition(":")
+        rpm_id = "%s-%s:%s-%s.%s" % (pkg["name"], pkg.get("epoch") or "0", pkg["version"], pkg["release"], pkg["arch"])
+        rpm = {
+            "name": pkg.get("alias") or _sanitize(rpm_id),
+            "urls": pkg["urls"],
+        }
+        if checksum_type == "sha256":
+            rpm["sha256"] = checksum
+        elif checksum_type in ["sha1", "sha384", "sha512"]:
+            rpm["integrity"] = "%s-%s" % (checksum_type, _hex_to_base64(checksum))
+        else:
+            fail("unsupported checksum type %s for %s in %s" % (checksum_type, pkg["name"], lock_file))
+        rpms.append(rpm)
+    return rpms
+
 def _handle_lock_file(lock_file, module_ctx):
     content = module_ctx.read(lock_file)
     lock_file_json = json.decode(content)
@@ -40,7 +83,11 @@ def _handle_lock_file(lock_file, module_ctx):
 
     rpms = []
 
-    for rpm in lock_file_json.get("rpms", []):
+    lock_file_rpms = lock_file_json.get("rpms", [])
+    if "packages" in lock_file_json:
+        lock_file_rpms = _bazeldnf_lock_file_rpms(lock_file, lock_file_json)
+
+    for rpm in lock_file_rpms:
         rpm_name = rpm.pop("name", None)
         if not rpm_name:
             urls = rpm.get("urls", [])
@@ -93,6 +140,7 @@ def _toolchain_extension(module_ctx):
                 urls = rpm.urls,
                 sha256 = rpm.sha256,
                 integrity = rpm.integrity,
+                canonical_id = rpm.canonical_id,
             )
 
             if mod.is_root and legacy:
@@ -153,6 +201,10 @@ _It is a security risk to omit the checksum as remote files can change._
 At best omitting this field will make your build non-hermetic.
 It is optional to make development easier but either this attribute or
 `sha256` should be set before shipping.
+"""),
+        "canonical_id": attr.string(doc = """\
+Canonical ID of the file downloaded, used by the repository cache and the \
+remote downloader to identify the file independent of its URLs.
 """),
     },
     doc = "Allows registering a Bazel repository wrapping an RPM file",
@@ -190,6 +242,11 @@ The lock file content is as:
         ]
     }
 ```
+
+Lockfiles written by `bazeldnf rpmtree --lockfile` can be used directly. Their \
+packages are exposed without running bazeldnf, so the build can never drift \
+from the lockfile. `bazeldnf rpmtree --lockfile` also accepts lock files in the format above \
+and migrates them, keeping the names of the proxy repository and the RPMs.
 """,
             allow_single_file = [".json"],
         ),
@@ -0,0 +1,27 @@
+"""
+Helpers to turn rpmtrees into images with rules_oci.
+
+This file is not part of defs.bzl since it requires rules_oci. bzlmod workspaces get it as a dependency of
+bazeldnf, WORKSPACE based setups have to load rules_oci themselves.
+"""
+
+load("@rules_oci//oci:defs.bzl", "oci_image")
+
+def rpmtree_oci_image(name, rpmtrees, base = None, **kwargs):
+    """Assembles an oci_image with one layer per rpmtree.
+
+    rpmtree creates uncompressed tar archives, for which rules_oci picks the media type
+    application/vnd.oci.image.layer.v1.tar based on their extension.
+
+    Args:
+        name: The name of the oci_image target.
+        rpmtrees: The rpmtree targets which should become layers, in order.
+        base: An optional base image. Without a base image the rpmtrees are put into a scratch image.
+        **kwargs: Additional arguments like entrypoint, env or labels which are passed on to oci_image.
+    """
+    oci_image(
+        name = name,
+        base = base,
+        tars = rpmtrees,
+        **kwargs
+    )
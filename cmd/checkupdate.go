@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rmohr/bazeldnf/cmd/template"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/lockfile"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/rmohr/bazeldnf/pkg/updates"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type checkUpdateOpts struct {
+	repofiles []string
+	lockfile  string
+	trees     []string
+	json      bool
+}
+
+var checkupdateopts = checkUpdateOpts{}
+
+func NewCheckUpdateCmd() *cobra.Command {
+
+	checkUpdateCmd := &cobra.Command{
+		Use:   "check-update",
+		Short: "Lists upgrades available for the packages of a lockfile",
+		Long: `Fetches fresh repository metadata and lists the packages of the lockfile for which newer versions are available.
+Obsoleted packages are reported together with the package replacing them and the advisories fixed by each upgrade are attached.
+Neither the lockfile nor any bazel files are modified, which makes it suitable for scheduled CI jobs.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			lock, err := lockfile.Load(checkupdateopts.lockfile)
+			if err != nil {
+				return err
+			}
+			locked := lock.Packages
+			if len(checkupdateopts.trees) > 0 {
+				locked = []bazeldnf.LockedPackage{}
+				for _, tree := range checkupdateopts.trees {
+					pkgs, exists := lockfile.TreePackages(lock, tree)
+					if !exists {
+						return fmt.Errorf("lockfile %s contains no rpmtree %s", checkupdateopts.lockfile, tree)
+					}
+					locked = append(locked, pkgs...)
+				}
+			}
+			repos, err := loadRepoFiles(checkupdateopts.repofiles)
+			if err != nil {
+				return err
+			}
+			available, updateinfos, err := loadFreshPackages(repos, locked)
+			if err != nil {
+				return err
+			}
+			found := updates.Find(locked, available, updateinfos)
+			if checkupdateopts.json {
+				return template.RenderUpdatesJSON(os.Stdout, found)
+			}
+			if len(found) == 0 {
+				logrus.Info("All locked packages are up to date.")
+				return nil
+			}
+			return template.RenderUpdates(os.Stdout, found)
+		},
+	}
+
+	checkUpdateCmd.Flags().StringArrayVarP(&checkupdateopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file or directory like repos.d/. Can be specified multiple times, later files override repositories with the same name")
+	checkUpdateCmd.Flags().StringVar(&checkupdateopts.lockfile, "lockfile", "bazeldnf-lock.json", "lockfile with the packages to check")
+	checkUpdateCmd.Flags().StringArrayVar(&checkupdateopts.trees, "tree", []string{}, "only check the packages of this rpmtree. Can be specified multiple times")
+	checkUpdateCmd.Flags().BoolVar(&checkupdateopts.json, "json", false, "print the upgrades as JSON")
+	return checkUpdateCmd
+}
+
+// loadFreshPackages fetches fresh metadata including advisories for all enabled repositories of the architectures
+// of the locked packages and returns their packages and advisories
+func loadFreshPackages(repos *bazeldnf.Repositories, locked []bazeldnf.LockedPackage) ([]*api.Package, map[string]*api.Updateinfo, error) {
+	arches := map[string]bool{}
+	for _, pkg := range locked {
+		if pkg.Arch != "noarch" {
+			arches[pkg.Arch] = true
+		}
+	}
+	selected := []bazeldnf.Repository{}
+	for _, r := range repos.Repositories {
+		if r.Disabled {
+			continue
+		}
+		serves := len(arches) == 0
+		for arch := range arches {
+			serves = serves || r.ServesArch(arch)
+		}
+		if serves {
+			selected = append(selected, r)
+		}
+	}
+	cacheDir, err := cacheDir(repos)
+	if err != nil {
+		return nil, nil, err
+	}
+	getter, err := newGetter()
+	if err != nil {
+		return nil, nil, err
+	}
+	cacheHelper := &repo.CacheHelper{CacheDir: cacheDir}
+	fetcher := &repo.RepoFetcherImpl{
+		Repos:       selected,
+		Getter:      getter,
+		CacheHelper: cacheHelper,
+		FileTypes:   []string{api.UpdateinfoFileType},
+	}
+	if err := fetcher.Fetch(); err != nil {
+		return nil, nil, err
+	}
+	available := []*api.Package{}
+	updateinfos := map[string]*api.Updateinfo{}
+	for i := range selected {
+		primary, err := cacheHelper.CurrentPrimary(&selected[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		for j := range primary.Packages {
+			available = append(available, &primary.Packages[j])
+		}
+		if updateinfos[selected[i].Name], err = cacheHelper.CurrentUpdateinfo(&selected[i]); err != nil {
+			return nil, nil, err
+		}
+	}
+	return available, updateinfos, nil
+}
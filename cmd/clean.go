@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type cleanOpts struct {
+	repofiles []string
+	metadata  bool
+	packages  bool
+	repos     []string
+	olderThan string
+}
+
+var cleanopts = cleanOpts{}
+
+func NewCleanCmd() *cobra.Command {
+
+	cleanCmd := &cobra.Command{
+		Use:   "clean",
+		Short: "Removes files from the cache",
+		Long: `Removes cached repository metadata and package data. Without filters the whole cache is cleaned, --metadata
+and --packages restrict cleaning to one kind of file, --repo to single repositories and --older-than to repositories
+which were not refreshed for the given time, like 30d or 12h. Cached snapshots are cleaned too, unless --snapshot
+selects a single one.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter := repo.CleanFilter{
+				Metadata: cleanopts.metadata,
+				Packages: cleanopts.packages,
+				Repos:    cleanopts.repos,
+			}
+			if cleanopts.olderThan != "" {
+				olderThan, err := repo.ParseAge(cleanopts.olderThan)
+				if err != nil {
+					return err
+				}
+				filter.OlderThan = olderThan
+			}
+			// the repository files are only needed for their cacheDir, so missing ones are fine
+			existing := []string{}
+			for _, file := range cleanopts.repofiles {
+				if _, err := os.Stat(file); err == nil {
+					existing = append(existing, file)
+				}
+			}
+			repos := &bazeldnf.Repositories{}
+			if len(existing) > 0 {
+				var err error
+				if repos, err = repo.LoadRepoFiles(existing); err != nil {
+					return err
+				}
+			}
+			cacheDir, err := cacheDir(repos)
+			if err != nil {
+				return err
+			}
+			result, err := repo.Clean(cacheDir, filter)
+			if err != nil {
+				return err
+			}
+			logrus.Infof("Removed %s from %s.", result, cacheDir)
+			return nil
+		},
+	}
+
+	cleanCmd.Flags().StringArrayVarP(&cleanopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file or directory like repos.d/ which may configure the cache directory. Can be specified multiple times")
+	cleanCmd.Flags().BoolVar(&cleanopts.metadata, "metadata", false, "remove cached repository metadata like repomd.xml, primary.xml and updateinfo.xml")
+	cleanCmd.Flags().BoolVar(&cleanopts.packages, "packages", false, "remove data cached for single packages, like the sha256 sums computed by --rehash-sha256")
+	cleanCmd.Flags().StringArrayVar(&cleanopts.repos, "repo", []string{}, "only clean the cache of this repository. Can be specified multiple times")
+	cleanCmd.Flags().StringVar(&cleanopts.olderThan, "older-than", "", "only clean repositories whose metadata was not refreshed for this long, e.g. 30d or 12h")
+	return cleanCmd
+}
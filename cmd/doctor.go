@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rmohr/bazeldnf/cmd/template"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/bazel"
+	"github.com/rmohr/bazeldnf/pkg/doctor"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/spf13/cobra"
+)
+
+type doctorOpts struct {
+	repofiles []string
+	lockfile  string
+	workspace string
+	fromMacro string
+	offline   bool
+	verbose   bool
+}
+
+var doctoropts = doctorOpts{}
+
+func NewDoctorCmd() *cobra.Command {
+
+	doctorCmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnoses the repository configuration, mirrors, cache, gpg keys and lockfile",
+		Long: `Checks the environment end-to-end and prints what has to be fixed: the validity of the repository files, the reachability and latency of all mirrors,
+the health and size of the metadata cache, the gpg keys of the repositories and whether the lockfile is intact and matches the rpm rules of the WORKSPACE or macro file.
+The lockfile and the WORKSPACE file are skipped if they don't exist at their default location. The command fails if any check reports an error.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// failed checks are no usage errors
+			cmd.SilenceUsage = true
+			findings := []doctor.Finding{}
+			repos, err := loadRepoFiles(doctoropts.repofiles)
+			if err != nil {
+				findings = append(findings, doctor.Finding{Check: "config", Severity: doctor.Error, Subject: "repofile", Message: err.Error()})
+				return renderFindings(findings)
+			}
+			findings = append(findings, doctor.CheckConfig(repos)...)
+			cacheDir, err := cacheDir(repos)
+			if err != nil {
+				return err
+			}
+			cacheHelper := &repo.CacheHelper{CacheDir: cacheDir}
+			findings = append(findings, doctor.CheckCache(cacheDir, repos)...)
+			if !doctoropts.offline {
+				getter, err := newGetter()
+				if err != nil {
+					return err
+				}
+				findings = append(findings, doctor.CheckMirrors(getter, repos, cacheHelper)...)
+				findings = append(findings, doctor.CheckGPGKeys(getter, repos)...)
+			}
+			if requested(cmd, "lockfile", doctoropts.lockfile) {
+				lock, lockFindings := doctor.CheckLockfile(doctoropts.lockfile, repos, cacheHelper)
+				findings = append(findings, lockFindings...)
+				if lock != nil {
+					findings = append(findings, checkWorkspace(cmd, lock)...)
+				}
+			}
+			return renderFindings(findings)
+		},
+	}
+
+	doctorCmd.Flags().StringArrayVarP(&doctoropts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file or directory like repos.d/ (can be specified multiple times, later files override repositories with the same name)")
+	doctorCmd.Flags().StringVar(&doctoropts.lockfile, "lockfile", "bazeldnf-lock.json", "lockfile to check")
+	doctorCmd.Flags().StringVarP(&doctoropts.workspace, "workspace", "w", "WORKSPACE", "Bazel workspace file whose rpm rules are compared with the lockfile")
+	doctorCmd.Flags().StringVarP(&doctoropts.fromMacro, "from-macro", "", "", "Tells bazeldnf to read the RPMs from a macro in the given bzl file instead of the WORKSPACE file. The expected format is: macroFile%defName")
+	doctorCmd.Flags().BoolVar(&doctoropts.offline, "offline", false, "skip the checks which need network access, like probing mirrors and downloading gpg keys")
+	doctorCmd.Flags().BoolVarP(&doctoropts.verbose, "verbose", "v", false, "also print the checks which passed")
+	return doctorCmd
+}
+
+// requested returns true if the file was given explicitly or exists at its default location
+func requested(cmd *cobra.Command, flag string, path string) bool {
+	if cmd.Flags().Changed(flag) {
+		return true
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// checkWorkspace compares the rpm rules of the WORKSPACE or the macro file with the lockfile
+func checkWorkspace(cmd *cobra.Command, lock *bazeldnf.Lockfile) []doctor.Finding {
+	workspaceError := func(subject string, err error) []doctor.Finding {
+		return []doctor.Finding{{Check: "workspace", Severity: doctor.Error, Subject: subject, Message: err.Error()}}
+	}
+	if doctoropts.fromMacro != "" {
+		bzl, defname, err := bazel.ParseMacro(doctoropts.fromMacro)
+		if err != nil {
+			return workspaceError(doctoropts.fromMacro, fmt.Errorf("failed to parse from-macro expression %q: %v", doctoropts.fromMacro, err))
+		}
+		bzlfile, err := bazel.LoadBzl(bzl)
+		if err != nil {
+			return workspaceError(bzl, err)
+		}
+		return doctor.CheckWorkspace(doctoropts.fromMacro, lock, bazel.GetBzlfileRPMs(bzlfile, defname))
+	}
+	if !requested(cmd, "workspace", doctoropts.workspace) {
+		return nil
+	}
+	workspace, err := bazel.LoadWorkspace(doctoropts.workspace)
+	if err != nil {
+		return workspaceError(doctoropts.workspace, err)
+	}
+	return doctor.CheckWorkspace(doctoropts.workspace, lock, bazel.GetWorkspaceRPMs(workspace))
+}
+
+// renderFindings prints the findings and fails if any of them is an error
+func renderFindings(findings []doctor.Finding) error {
+	if err := template.RenderFindings(os.Stdout, findings, doctoropts.verbose); err != nil {
+		return err
+	}
+	if errors := doctor.Count(findings, doctor.Error); errors > 0 {
+		return fmt.Errorf("doctor found %d errors", errors)
+	}
+	return nil
+}
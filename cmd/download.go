@@ -0,0 +1,79 @@
+package main
+
+import (
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/lockfile"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type downloadOpts struct {
+	repofiles []string
+	lockfile  string
+	keyring   string
+	trees     []string
+	outputDir string
+}
+
+var downloadopts = downloadOpts{}
+
+func NewDownloadCmd() *cobra.Command {
+
+	downloadCmd := &cobra.Command{
+		Use:   "download",
+		Short: "Downloads exactly the RPMs recorded in a lockfile",
+		Long: `Downloads the RPMs recorded in a lockfile and verifies their checksums. No metadata is fetched and nothing
+is resolved, which makes it suitable for Bazel repository rules: the downloaded RPMs can never drift from the lockfile,
+even if the repositories move on. Repository files are only read if --repofile is given, the RPMs are then checked
+against the gpgkey and gpgcheck settings of the repositories they were locked from, like verify does.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if downloadopts.keyring != "" {
+				if err := lockfile.VerifySignature(downloadopts.lockfile, downloadopts.keyring); err != nil {
+					return err
+				}
+			}
+			lock, err := lockfile.Load(downloadopts.lockfile)
+			if err != nil {
+				return err
+			}
+			pkgs, err := lockedTreePackages(downloadopts.lockfile, lock, downloadopts.trees)
+			if err != nil {
+				return err
+			}
+			getter, err := newGetter()
+			if err != nil {
+				return err
+			}
+			var verifySignatures func(pkg bazeldnf.LockedPackage, file string) error
+			if len(downloadopts.repofiles) > 0 || len(rootopts.distroRepos) > 0 {
+				repos, err := loadRepoFiles(downloadopts.repofiles)
+				if err != nil {
+					return err
+				}
+				cacheDir, err := cacheDir(repos)
+				if err != nil {
+					return err
+				}
+				policy, err := newSignaturePolicy(getter, repos, &repo.CacheHelper{CacheDir: cacheDir})
+				if err != nil {
+					return err
+				}
+				verifySignatures = policy.verifyLocked
+			}
+			files, err := repo.DownloadLocked(getter, pkgs, downloadopts.outputDir, verifySignatures)
+			if err != nil {
+				return err
+			}
+			logrus.Infof("Downloaded %d locked RPMs to %s.", len(files), downloadopts.outputDir)
+			return nil
+		},
+	}
+
+	downloadCmd.Flags().StringArrayVarP(&downloadopts.repofiles, "repofile", "r", []string{}, "repository information file or directory like repos.d/ whose signature settings the RPMs are checked against. Can be specified multiple times")
+	downloadCmd.Flags().StringVar(&downloadopts.lockfile, "lockfile", "bazeldnf-lock.json", "lockfile with the RPMs to download")
+	downloadCmd.Flags().StringVar(&downloadopts.keyring, "lockfile-keyring", "", "armored keyring which must have signed the lockfile")
+	downloadCmd.Flags().StringArrayVar(&downloadopts.trees, "tree", []string{}, "only download the RPMs of this rpmtree. Can be specified multiple times")
+	downloadCmd.Flags().StringVarP(&downloadopts.outputDir, "output-dir", "o", ".", "directory the RPMs are written to")
+	return downloadCmd
+}
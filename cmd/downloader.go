@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rmohr/bazeldnf/pkg/bazel"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type downloaderConfigOpts struct {
+	workspace string
+	fromMacro string
+	rewriteTo string
+	allowOnly bool
+	output    string
+}
+
+var downloaderconfigopts = downloaderConfigOpts{}
+
+func NewDownloaderConfigCmd() *cobra.Command {
+
+	downloaderConfigCmd := &cobra.Command{
+		Use:   "downloader-config",
+		Short: "Writes a Bazel downloader config for all RPM downloads",
+		Long: `Writes a config for Bazel's --experimental_downloader_config. With --rewrite-to, all RPM downloads are
+redirected to <url>/<host>/<path>, e.g. to a remote cache or an internal mirror. With --allow-only-rpm-hosts, only
+the hosts the rpm rules download from are allowed and Bazel blocks downloads from all other hosts.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (downloaderconfigopts.rewriteTo == "") == !downloaderconfigopts.allowOnly {
+				return fmt.Errorf("exactly one of --rewrite-to and --allow-only-rpm-hosts is required")
+			}
+			var rpms []*bazel.RPMRule
+			if downloaderconfigopts.fromMacro == "" {
+				workspace, err := bazel.LoadWorkspace(downloaderconfigopts.workspace)
+				if err != nil {
+					return err
+				}
+				rpms = bazel.GetWorkspaceRPMs(workspace)
+			} else {
+				bzl, defName, err := bazel.ParseMacro(downloaderconfigopts.fromMacro)
+				if err != nil {
+					return err
+				}
+				bzlfile, err := bazel.LoadBzl(bzl)
+				if err != nil {
+					return err
+				}
+				rpms = bazel.GetBzlfileRPMs(bzlfile, defName)
+			}
+			var config string
+			var err error
+			if downloaderconfigopts.allowOnly {
+				config, err = bazel.DownloaderAllowlist(rpms)
+			} else {
+				config, err = bazel.DownloaderConfig(rpms, downloaderconfigopts.rewriteTo)
+			}
+			if err != nil {
+				return err
+			}
+			if downloaderconfigopts.output == "" {
+				fmt.Print(config)
+				return nil
+			}
+			logrus.Infof("Writing downloader config for %d rpms to %s.", len(rpms), downloaderconfigopts.output)
+			return os.WriteFile(downloaderconfigopts.output, []byte(config), 0666)
+		},
+	}
+
+	downloaderConfigCmd.Flags().StringVarP(&downloaderconfigopts.workspace, "workspace", "w", "WORKSPACE", "Bazel workspace file")
+	downloaderConfigCmd.Flags().StringVarP(&downloaderconfigopts.fromMacro, "from-macro", "", "", "Tells bazeldnf to read the RPMs from a macro in the given bzl file instead of the WORKSPACE file. The expected format is: macroFile%defName")
+	downloaderConfigCmd.Flags().StringVar(&downloaderconfigopts.rewriteTo, "rewrite-to", "", "rewrite all RPM downloads to <url>/<host>/<path>")
+	downloaderConfigCmd.Flags().BoolVar(&downloaderconfigopts.allowOnly, "allow-only-rpm-hosts", false, "allow only the hosts of the RPM downloads, Bazel then blocks downloads from all other hosts")
+	downloaderConfigCmd.Flags().StringVarP(&downloaderconfigopts.output, "output", "o", "", "write the config to this file instead of stdout")
+	return downloaderConfigCmd
+}
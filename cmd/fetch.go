@@ -1,7 +1,6 @@
 package main
 
 import (
-	"github.com/rmohr/bazeldnf/pkg/repo"
 	"github.com/spf13/cobra"
 )
 
@@ -18,14 +17,22 @@ func NewFetchCmd() *cobra.Command {
 		Short: "Update repo metadata",
 		Long:  `Update repo metadata`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			repos, err := repo.LoadRepoFiles(fetchopts.repofiles)
+			repos, err := loadRepoFiles(fetchopts.repofiles)
 			if err != nil {
 				return err
 			}
-			return repo.NewRemoteRepoFetcher(repos.Repositories, ".bazeldnf").Fetch()
+			cacheDir, err := cacheDir(repos)
+			if err != nil {
+				return err
+			}
+			fetcher, err := newRepoFetcher(repos.Repositories, cacheDir)
+			if err != nil {
+				return err
+			}
+			return fetcher.Fetch()
 		},
 	}
 
-	fetchCmd.Flags().StringArrayVarP(&fetchopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file. Can be specified multiple times")
+	fetchCmd.Flags().StringArrayVarP(&fetchopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file or directory like repos.d/. Can be specified multiple times, later files override repositories with the same name")
 	return fetchCmd
 }
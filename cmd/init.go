@@ -1,6 +1,7 @@
 package main
 
 import (
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
 	"github.com/rmohr/bazeldnf/pkg/repo"
 	"github.com/spf13/cobra"
 )
@@ -9,6 +10,11 @@ type InitOpts struct {
 	arch string
 	fc   string
 	out  string
+
+	countries     []string
+	protocols     []string
+	maxMirrors    int
+	preferMirrors []string
 }
 
 var initopts = InitOpts{}
@@ -20,13 +26,26 @@ func NewInitCmd() *cobra.Command {
 		Short: "Create basic repo.yaml files for fedora releases",
 		Long:  `Create proper repo information with release- and update repos for fedora releases`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return repo.NewRemoteInit(initopts.fc, initopts.arch, initopts.out).Init()
+			repoInit := repo.NewRemoteInit(initopts.fc, initopts.arch, initopts.out)
+			if len(initopts.countries) > 0 || len(initopts.protocols) > 0 || initopts.maxMirrors > 0 || len(initopts.preferMirrors) > 0 {
+				repoInit.MetalinkFilter = &bazeldnf.MetalinkFilter{
+					Countries:  initopts.countries,
+					Protocols:  initopts.protocols,
+					MaxMirrors: initopts.maxMirrors,
+					Prefer:     initopts.preferMirrors,
+				}
+			}
+			return repoInit.Init()
 		},
 	}
 
-	initCmd.Flags().StringVarP(&initopts.arch, "arch", "a", "x86_64", "target architecture")
+	initCmd.Flags().VarP(newArchValue("x86_64", &initopts.arch), "arch", "a", "target architecture, GOARCH names like amd64 or arm64 are accepted too")
 	initCmd.Flags().StringVar(&initopts.fc, "fc", "", "target fedora core release")
 	initCmd.Flags().StringVarP(&initopts.out, "output", "o", "repo.yaml", "where to write the repository information")
+	initCmd.Flags().StringArrayVar(&initopts.countries, "country", []string{}, "only use metalink mirrors located in the given country code, can be repeated")
+	initCmd.Flags().StringArrayVar(&initopts.protocols, "protocol", []string{}, "only use metalink mirrors serving the given protocol, defaults to https")
+	initCmd.Flags().IntVar(&initopts.maxMirrors, "max-mirrors", 0, "maximum number of metalink mirrors to try")
+	initCmd.Flags().StringArrayVar(&initopts.preferMirrors, "prefer-mirror", []string{}, "regular expression matching metalink mirrors which should be tried first, can be repeated")
 	err := initCmd.MarkFlagRequired("fc")
 	if err != nil {
 		panic(err)
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/rmohr/bazeldnf/pkg/sat"
+	"github.com/sirupsen/logrus"
+)
+
+// resolveInteractively lets the user decide on the terminal which package should provide capabilities with
+// multiple candidates. The decisions are added to the preferences of repos and persisted in the last repofile.
+func resolveInteractively(involved []*api.Package, matched []string, repos *bazeldnf.Repositories, repofiles []string) error {
+	if !isTerminal(os.Stdin) {
+		return fmt.Errorf("interactive mode requires a terminal on stdin")
+	}
+	alternatives := sat.FindAlternatives(involved, matched, repos.Preferences)
+	if len(alternatives) == 0 {
+		logrus.Info("No alternatives to decide on.")
+		return nil
+	}
+	decisions, err := pickAlternatives(os.Stdin, os.Stderr, alternatives)
+	if err != nil {
+		return err
+	}
+	if len(decisions) == 0 {
+		return nil
+	}
+	return persistDecisions(decisions, repos, repofiles)
+}
+
+// resolveFailureInteractively shows why resolving failed and lets the user decide on the capabilities with
+// multiple candidates which the packages with unresolvable requirements depend on, including the ones which
+// already have a preference, since the preference may cause the conflict. If no requirement is unresolvable, all
+// capabilities of the requested packages are offered. The decisions are persisted like the ones of
+// resolveInteractively. It returns true if the decisions changed the preferences and resolving should be attempted
+// again.
+func resolveFailureInteractively(resolveErr error, solver *sat.Resolver, involved []*api.Package, matched []string, repos *bazeldnf.Repositories, repofiles []string) (bool, error) {
+	if !isTerminal(os.Stdin) {
+		return false, fmt.Errorf("interactive mode requires a terminal on stdin")
+	}
+	fmt.Fprintf(os.Stderr, "Resolving failed: %v\n", resolveErr)
+	for _, requirement := range solver.Unresolvable() {
+		fmt.Fprintf(os.Stderr, "  %s\n", requirement)
+	}
+	var alternatives []sat.Alternative
+	if failing := solver.UnresolvablePackages(); len(failing) > 0 {
+		alternatives = sat.FindReachableAlternatives(involved, failing)
+	} else {
+		alternatives = sat.FindAlternatives(involved, matched, nil)
+	}
+	if len(alternatives) == 0 {
+		logrus.Info("No alternatives to decide on.")
+		return false, nil
+	}
+	decisions, err := pickAlternatives(os.Stdin, os.Stderr, alternatives)
+	if err != nil {
+		return false, err
+	}
+	if len(decisions) == 0 {
+		return false, nil
+	}
+	if !changesPreferences(decisions, repos.Preferences) {
+		logrus.Info("The decisions don't change the preferences, not resolving again.")
+		return false, nil
+	}
+	return true, persistDecisions(decisions, repos, repofiles)
+}
+
+// changesPreferences returns true if one of the decisions differs from the existing preferences
+func changesPreferences(decisions map[string]string, preferences map[string]string) bool {
+	for capability, pkg := range decisions {
+		if preferred, exists := preferences[capability]; !exists || preferred != pkg {
+			return true
+		}
+	}
+	return false
+}
+
+// persistDecisions adds the decisions to the preferences of repos and writes them to the last repofile, whose
+// preferences override the ones of all other repofiles
+func persistDecisions(decisions map[string]string, repos *bazeldnf.Repositories, repofiles []string) error {
+	if repos.Preferences == nil {
+		repos.Preferences = map[string]string{}
+	}
+	for capability, pkg := range decisions {
+		repos.Preferences[capability] = pkg
+	}
+	file, err := repo.LastRepoFile(repofiles)
+	if err != nil {
+		return err
+	}
+	if file == "" {
+		logrus.Warn("No repository file in use, decisions will not be persisted.")
+		return nil
+	}
+	logrus.Infof("Persisting %d decisions in %s.", len(decisions), file)
+	return repo.AddPreferences(file, decisions)
+}
+
+// pickAlternatives presents every alternative and reads the choice of the user. An empty answer leaves the
+// decision to the resolver.
+func pickAlternatives(in io.Reader, out io.Writer, alternatives []sat.Alternative) (map[string]string, error) {
+	decisions := map[string]string{}
+	reader := bufio.NewReader(in)
+	for _, alternative := range alternatives {
+		fmt.Fprintf(out, "%s can be provided by:\n", alternative.Capability)
+		for i, pkg := range alternative.Packages {
+			fmt.Fprintf(out, "  %d) %s\n", i+1, pkg)
+		}
+		for {
+			fmt.Fprintf(out, "Pick a package [1-%d, empty to let the resolver decide]: ", len(alternative.Packages))
+			line, err := reader.ReadString('\n')
+			if err != nil && err != io.EOF {
+				return nil, fmt.Errorf("failed to read choice: %v", err)
+			}
+			line = strings.TrimSpace(line)
+			if line == "" {
+				if err == io.EOF {
+					return decisions, nil
+				}
+				break
+			}
+			choice, convErr := strconv.Atoi(line)
+			if convErr != nil || choice < 1 || choice > len(alternative.Packages) {
+				fmt.Fprintf(out, "Invalid choice %q.\n", line)
+				if err == io.EOF {
+					return decisions, nil
+				}
+				continue
+			}
+			decisions[alternative.Capability] = alternative.Packages[choice-1]
+			break
+		}
+	}
+	return decisions, nil
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestChangesPreferences(t *testing.T) {
+	g := NewGomegaWithT(t)
+	preferences := map[string]string{"curl": "curl-minimal"}
+	g.Expect(changesPreferences(map[string]string{"curl": "curl-minimal"}, preferences)).To(BeFalse())
+	g.Expect(changesPreferences(map[string]string{"curl": "curl"}, preferences)).To(BeTrue())
+	g.Expect(changesPreferences(map[string]string{"libcurl": "libcurl-minimal"}, preferences)).To(BeTrue())
+	g.Expect(changesPreferences(map[string]string{"curl": "curl-minimal"}, nil)).To(BeTrue())
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/lockfile"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/sirupsen/logrus"
+)
+
+// updateLockfile records the packages of the rpmtree together with the snapshots of the repositories they were
+// resolved from in the lockfile. The URLs of the packages are rewritten with the rewriter, if one is given.
+func updateLockfile(path string, name string, repos *bazeldnf.Repositories, cacheDir string, arch string, install []*api.Package, rewriter *repo.URLRewriter) error {
+	lock, err := lockfile.LoadOrCreate(path)
+	if err != nil {
+		return err
+	}
+	snapshots, err := (&repo.CacheHelper{CacheDir: cacheDir}).CurrentSnapshots(repos, arch)
+	if err != nil {
+		return err
+	}
+	for _, change := range lockfile.SetRepositories(lock, snapshots) {
+		logrus.Info(change.String())
+	}
+	if err := lockfile.SetTree(lock, name, install); err != nil {
+		return err
+	}
+	if rewriter != nil {
+		lockfile.RewriteTreeURLs(lock, name, rewriter.Rewrite)
+	}
+	lock.Snapshot = rootopts.snapshot
+	logrus.Infof("Writing lockfile %s.", path)
+	return lockfile.Write(path, lock)
+}
+
+// lockedSnapshot returns the snapshot date to resolve against. Without --snapshot the rpmtree stays pinned to the
+// snapshot recorded in the lockfile, a different --snapshot moves the lockfile to it.
+func lockedSnapshot(path string, requested string) (string, error) {
+	lock, err := lockfile.LoadOrCreate(path)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case lock.Snapshot == "" || requested == lock.Snapshot:
+		return requested, nil
+	case requested == "":
+		logrus.Infof("Resolving against the snapshot of %s recorded in %s.", lock.Snapshot, path)
+		return lock.Snapshot, nil
+	default:
+		logrus.Infof("Moving lockfile %s from the snapshot of %s to %s.", path, lock.Snapshot, requested)
+		return requested, nil
+	}
+}
+
+// lockedVersions returns the versions of the packages of the rpmtree which should be kept. They are taken from
+// the lockfile if it knows the rpmtree and from the current rpmtree rule otherwise. Packages which should be
+// updated are left out.
+func lockedVersions(path string, name string, treePackages map[string]string, update []string) (map[string]string, error) {
+	locked := map[string]string{}
+	for pkg, version := range treePackages {
+		locked[pkg] = version
+	}
+	if path != "" {
+		lock, err := lockfile.LoadOrCreate(path)
+		if err != nil {
+			return nil, err
+		}
+		if pkgs, exists := lockfile.TreePackages(lock, name); exists {
+			locked = map[string]string{}
+			for _, pkg := range pkgs {
+				version := api.Version{Epoch: pkg.Epoch, Ver: pkg.Version, Rel: pkg.Release}
+				locked[pkg.Name] = version.String()
+			}
+		}
+	}
+	for _, pkg := range update {
+		delete(locked, pkg)
+	}
+	logrus.Infof("Preferring %d locked versions.", len(locked))
+	return locked, nil
+}
+
+// reportRepositoryChanges fetches fresh metadata for all repositories of the lockfile and reports the ones which
+// moved on since they were locked
+func reportRepositoryChanges(path string, repos *bazeldnf.Repositories) error {
+	lock, err := lockfile.Load(path)
+	if err != nil {
+		return err
+	}
+	cacheDir, err := cacheDir(repos)
+	if err != nil {
+		return err
+	}
+	lockedRepos := []bazeldnf.Repository{}
+	for _, locked := range lock.Repositories {
+		found := false
+		for _, r := range repos.Repositories {
+			if r.Name == locked.Name {
+				lockedRepos = append(lockedRepos, r)
+				found = true
+				break
+			}
+		}
+		if !found {
+			logrus.Warnf("Locked repository %s is not configured anymore", locked.Name)
+		}
+	}
+	fetcher, err := newRepoFetcher(lockedRepos, cacheDir)
+	if err != nil {
+		return err
+	}
+	if err := fetcher.Fetch(); err != nil {
+		return err
+	}
+	cacheHelper := &repo.CacheHelper{CacheDir: cacheDir}
+	snapshots := []*bazeldnf.LockedRepository{}
+	for i := range lockedRepos {
+		snapshot, err := cacheHelper.Snapshot(&lockedRepos[i])
+		if err != nil {
+			return err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	changes := lockfile.ChangedRepositories(lock, snapshots)
+	for _, change := range changes {
+		logrus.Warn(change.String())
+	}
+	if len(changes) == 0 {
+		logrus.Info("All locked repositories are up to date.")
+	}
+	return nil
+}
+
+// lockedTreePackages returns the packages of the given rpmtrees of the lockfile, or all locked packages if no
+// rpmtrees are given
+func lockedTreePackages(path string, lock *bazeldnf.Lockfile, trees []string) ([]bazeldnf.LockedPackage, error) {
+	if len(trees) == 0 {
+		return lock.Packages, nil
+	}
+	pkgs := []bazeldnf.LockedPackage{}
+	seen := map[string]bool{}
+	for _, tree := range trees {
+		treePkgs, exists := lockfile.TreePackages(lock, tree)
+		if !exists {
+			return nil, fmt.Errorf("lockfile %s contains no rpmtree %s", path, tree)
+		}
+		for _, pkg := range treePkgs {
+			if !seen[pkg.ID()] {
+				seen[pkg.ID()] = true
+				pkgs = append(pkgs, pkg)
+			}
+		}
+	}
+	return pkgs, nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/lockfile"
+)
+
+func TestLockedSnapshot(t *testing.T) {
+	g := NewGomegaWithT(t)
+	path := filepath.Join(t.TempDir(), "bazeldnf-lock.json")
+
+	snapshot, err := lockedSnapshot(path, "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(snapshot).To(BeEmpty())
+
+	g.Expect(lockfile.Write(path, &bazeldnf.Lockfile{Snapshot: "2024-11-01"})).To(Succeed())
+	snapshot, err = lockedSnapshot(path, "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(snapshot).To(Equal("2024-11-01"))
+
+	snapshot, err = lockedSnapshot(path, "2024-12-01")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(snapshot).To(Equal("2024-12-01"))
+}
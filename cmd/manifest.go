@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/manifest"
+)
+
+// requestedPackages returns the packages of the target in the manifest file followed by the packages given as
+// arguments, together with the annotations of the manifest packages
+func requestedPackages(path string, target string, args []string) ([]string, map[string]bazeldnf.PackageAnnotations, error) {
+	if path == "" {
+		if len(args) == 0 {
+			return nil, nil, fmt.Errorf("no packages given, pass them as arguments or with --manifest")
+		}
+		return args, nil, nil
+	}
+	m, err := manifest.Load(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	packages, err := manifest.Packages(m, target)
+	if err != nil {
+		return nil, nil, err
+	}
+	annotations, err := manifest.Annotations(m, target)
+	if err != nil {
+		return nil, nil, err
+	}
+	packages = append(packages, args...)
+	if len(packages) == 0 {
+		return nil, nil, fmt.Errorf("no packages given, neither as arguments nor in manifest %s", path)
+	}
+	return packages, annotations, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/lockfile"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/rmohr/bazeldnf/pkg/rpm"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type providesOpts struct {
+	lockfile string
+	keyring  string
+	trees    []string
+	rpmDir   string
+	output   string
+}
+
+var providesopts = providesOpts{}
+
+func NewProvidesCmd() *cobra.Command {
+
+	providesCmd := &cobra.Command{
+		Use:   "provides",
+		Short: "Writes a map of everything the locked RPMs provide",
+		Long: `Writes a JSON map of every capability, shared library soname and file path to the locked packages providing it.
+The map is read from the headers of the locked RPMs, which are downloaded and verified like with the download command.
+Build tooling can validate its assumptions with it, e.g. that libcrypto.so.3 comes from openssl-libs, without reading repository metadata.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if providesopts.keyring != "" {
+				if err := lockfile.VerifySignature(providesopts.lockfile, providesopts.keyring); err != nil {
+					return err
+				}
+			}
+			lock, err := lockfile.Load(providesopts.lockfile)
+			if err != nil {
+				return err
+			}
+			pkgs, err := lockedTreePackages(providesopts.lockfile, lock, providesopts.trees)
+			if err != nil {
+				return err
+			}
+			rpmDir := providesopts.rpmDir
+			if rpmDir == "" {
+				if rpmDir, err = os.MkdirTemp("", "bazeldnf-provides"); err != nil {
+					return err
+				}
+				defer os.RemoveAll(rpmDir)
+			}
+			getter, err := newGetter()
+			if err != nil {
+				return err
+			}
+			files, err := repo.DownloadLocked(getter, pkgs, rpmDir, nil)
+			if err != nil {
+				return err
+			}
+			provides := lockfile.NewProvidesMap()
+			for i, file := range files {
+				if err := addRPMProvides(provides, pkgs[i], file); err != nil {
+					return err
+				}
+			}
+			data, err := json.MarshalIndent(provides, "", "  ")
+			if err != nil {
+				return err
+			}
+			data = append(data, '\n')
+			if providesopts.output == "" {
+				_, err = os.Stdout.Write(data)
+				return err
+			}
+			logrus.Infof("Writing provides of %d locked RPMs to %s.", len(pkgs), providesopts.output)
+			return os.WriteFile(providesopts.output, data, 0666)
+		},
+	}
+
+	providesCmd.Flags().StringVar(&providesopts.lockfile, "lockfile", "bazeldnf-lock.json", "lockfile with the RPMs to map")
+	providesCmd.Flags().StringVar(&providesopts.keyring, "lockfile-keyring", "", "armored keyring which must have signed the lockfile")
+	providesCmd.Flags().StringArrayVar(&providesopts.trees, "tree", []string{}, "only map the RPMs of this rpmtree. Can be specified multiple times")
+	providesCmd.Flags().StringVar(&providesopts.rpmDir, "rpm-dir", "", "keep the downloaded RPMs in this directory and reuse the ones already present, instead of a temporary directory")
+	providesCmd.Flags().StringVarP(&providesopts.output, "output", "o", "", "write the map to this file instead of stdout")
+	return providesCmd
+}
+
+// addRPMProvides reads the capabilities and files of the locked RPM into the map
+func addRPMProvides(provides *lockfile.ProvidesMap, pkg bazeldnf.LockedPackage, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	capabilities, files, err := rpm.ReadProvides(f)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", pkg.ID(), err)
+	}
+	provides.Add(pkg, capabilities, files)
+	return nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/cmd/template"
+	"github.com/rmohr/bazeldnf/pkg/advisory"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/lockfile"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type queryOpts struct {
+	repofiles []string
+	arch      string
+	version   string
+	lockfile  string
+	fromFile  string
+}
+
+var queryopts = queryOpts{}
+
+func NewQueryCmd() *cobra.Command {
+	queryCmd := &cobra.Command{
+		Use:   "query",
+		Short: "Query information from the repository metadata",
+	}
+	queryCmd.PersistentFlags().StringArrayVarP(&queryopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file or directory like repos.d/. Can be specified multiple times, later files override repositories with the same name")
+	queryCmd.PersistentFlags().VarP(newArchValue("x86_64", &queryopts.arch), "arch", "a", "target architecture, GOARCH names like amd64 or arm64 are accepted too")
+	queryCmd.AddCommand(newQueryAdvisoriesCmd())
+	queryCmd.AddCommand(newQueryWhatprovidesCmd())
+	return queryCmd
+}
+
+func newQueryAdvisoriesCmd() *cobra.Command {
+	advisoriesCmd := &cobra.Command{
+		Use:   "advisories <package>",
+		Short: "List advisories affecting a package",
+		Long: `List the advisories of the repositories which contain fixes for the given package, including CVE ids and the minimum fixed version.
+If a version is given directly or via a lockfile, only advisories which are not fixed in that version are listed.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if queryopts.version != "" && queryopts.lockfile != "" {
+				return fmt.Errorf("--version and --lockfile can't be used together")
+			}
+			var version *api.Version
+			if queryopts.version != "" {
+				v := template.ParseVersion(queryopts.version)
+				version = &v
+			} else if queryopts.lockfile != "" {
+				var err error
+				if version, err = lockedVersion(queryopts.lockfile, name, queryopts.arch); err != nil {
+					return err
+				}
+			}
+			repos, err := loadRepoFiles(queryopts.repofiles)
+			if err != nil {
+				return err
+			}
+			if err := refreshIfForced(repos); err != nil {
+				return err
+			}
+			updateinfos, err := loadUpdateinfos(repos, queryopts.arch)
+			if err != nil {
+				return err
+			}
+			return template.RenderAdvisories(os.Stdout, advisory.Find(updateinfos, name, queryopts.arch, version))
+		},
+	}
+	advisoriesCmd.Flags().StringVar(&queryopts.version, "version", "", "only list advisories which are not fixed in this [epoch:]version-release")
+	advisoriesCmd.Flags().StringVar(&queryopts.lockfile, "lockfile", "", "only list advisories which are not fixed in the locked version of the package")
+	return advisoriesCmd
+}
+
+func newQueryWhatprovidesCmd() *cobra.Command {
+	whatprovidesCmd := &cobra.Command{
+		Use:   "whatprovides [paths]",
+		Short: "Map files to the packages containing them",
+		Long: `Map files to the packages of the repositories which contain them, in one pass over the file lists.
+With --from-file, all absolute paths found in the given file are mapped, which allows passing e.g. the output of ldd or a strace log directly.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			paths := args
+			if queryopts.fromFile != "" {
+				f, err := os.Open(queryopts.fromFile)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				extracted, err := extractPaths(f)
+				if err != nil {
+					return fmt.Errorf("failed to read paths from %s: %v", queryopts.fromFile, err)
+				}
+				paths = append(paths, extracted...)
+			}
+			if len(paths) == 0 {
+				return fmt.Errorf("no paths given")
+			}
+			repos, err := loadRepoFiles(queryopts.repofiles)
+			if err != nil {
+				return err
+			}
+			if err := refreshIfForced(repos); err != nil {
+				return err
+			}
+			owners, err := fileOwners(repos, queryopts.arch, paths)
+			if err != nil {
+				return err
+			}
+			return template.RenderFileOwners(os.Stdout, owners)
+		},
+	}
+	whatprovidesCmd.Flags().StringVarP(&queryopts.fromFile, "from-file", "f", "", "map all absolute paths found in this file, e.g. the output of ldd or strace")
+	return whatprovidesCmd
+}
+
+// extractPaths returns all absolute paths contained in the text, in order of their first occurrence
+func extractPaths(reader io.Reader) ([]string, error) {
+	paths := []string{}
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		for _, field := range strings.Fields(scanner.Text()) {
+			// quoted paths may be glued to a call like in execve("/usr/bin/bash",
+			if i := strings.IndexAny(field, `"'`); i > 0 {
+				field = field[i:]
+			}
+			field = strings.Trim(field, `"',;:()[]{}<>`)
+			if !strings.HasPrefix(field, "/") {
+				continue
+			}
+			field = path.Clean(field)
+			if seen[field] {
+				continue
+			}
+			seen[field] = true
+			paths = append(paths, field)
+		}
+	}
+	return paths, scanner.Err()
+}
+
+// usrMergedPath returns the path below /usr for paths in directories which are symlinks into /usr on usrmerged
+// distributions
+func usrMergedPath(p string) (string, bool) {
+	for _, dir := range []string{"/bin/", "/sbin/", "/lib/", "/lib64/"} {
+		if strings.HasPrefix(p, dir) {
+			return "/usr" + p, true
+		}
+	}
+	return "", false
+}
+
+// fileOwners maps all paths to the packages of the repositories of the given architecture which contain them,
+// fetching the file lists first if they are not cached yet
+func fileOwners(repos *bazeldnf.Repositories, arch string, paths []string) ([]template.FileOwner, error) {
+	cacheDir, err := cacheDir(repos)
+	if err != nil {
+		return nil, err
+	}
+	lookup := append([]string{}, paths...)
+	for _, p := range paths {
+		if merged, ok := usrMergedPath(p); ok {
+			lookup = append(lookup, merged)
+		}
+	}
+	cacheHelper := &repo.CacheHelper{CacheDir: cacheDir}
+	packages := map[string][]string{}
+	for i, r := range repos.Repositories {
+		if !r.ServesArch(arch) {
+			continue
+		}
+		owners, err := cacheHelper.FileOwners(&repos.Repositories[i], []string{arch, "noarch"}, lookup)
+		if err != nil {
+			logrus.Infof("Fetching file lists of %s.", r.Name)
+			if err := fetchFileTypes(r, cacheHelper, api.FilelistsFileType); err != nil {
+				return nil, err
+			}
+			if owners, err = cacheHelper.FileOwners(&repos.Repositories[i], []string{arch, "noarch"}, lookup); err != nil {
+				return nil, err
+			}
+		}
+		for p, pkgs := range owners {
+			for _, pkg := range pkgs {
+				packages[p] = append(packages[p], fmt.Sprintf("%s/%s.%s", r.Name, pkg.String(), pkg.Arch))
+			}
+		}
+	}
+	result := []template.FileOwner{}
+	for _, p := range paths {
+		owner := template.FileOwner{Path: p, Packages: packages[p]}
+		if merged, ok := usrMergedPath(p); ok && len(owner.Packages) == 0 {
+			owner.Packages = packages[merged]
+		}
+		result = append(result, owner)
+	}
+	return result, nil
+}
+
+// fetchFileTypes fetches the given metadata files which the cached repomd.xml of the repository references
+func fetchFileTypes(r bazeldnf.Repository, cacheHelper *repo.CacheHelper, fileTypes ...string) error {
+	getter, err := newGetter()
+	if err != nil {
+		return err
+	}
+	fetcher := &repo.RepoFetcherImpl{
+		Repos:       []bazeldnf.Repository{r},
+		Getter:      getter,
+		CacheHelper: cacheHelper,
+	}
+	return fetcher.FetchReferencedFiles(fileTypes...)
+}
+
+// lockedVersion returns the version of the named package in the lockfile
+func lockedVersion(path string, name string, arch string) (*api.Version, error) {
+	lock, err := lockfile.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range lock.Packages {
+		if pkg.Name == name && (pkg.Arch == arch || pkg.Arch == "noarch") {
+			return &api.Version{Epoch: pkg.Epoch, Ver: pkg.Version, Rel: pkg.Release}, nil
+		}
+	}
+	return nil, fmt.Errorf("package %s is not part of lockfile %s", name, path)
+}
+
+// loadUpdateinfos returns the cached advisories of all repositories of the given architecture, fetching them
+// first if they are not cached yet
+func loadUpdateinfos(repos *bazeldnf.Repositories, arch string) (map[string]*api.Updateinfo, error) {
+	cacheDir, err := cacheDir(repos)
+	if err != nil {
+		return nil, err
+	}
+	cacheHelper := &repo.CacheHelper{CacheDir: cacheDir}
+	updateinfos := map[string]*api.Updateinfo{}
+	for i, r := range repos.Repositories {
+		if !r.ServesArch(arch) {
+			continue
+		}
+		updateinfo, err := cacheHelper.CurrentUpdateinfo(&repos.Repositories[i])
+		if err != nil {
+			logrus.Infof("Fetching advisories of %s.", r.Name)
+			if err := fetchFileTypes(r, cacheHelper, api.UpdateinfoFileType); err != nil {
+				return nil, err
+			}
+			if updateinfo, err = cacheHelper.CurrentUpdateinfo(&repos.Repositories[i]); err != nil {
+				return nil, err
+			}
+		}
+		updateinfos[r.Name] = updateinfo
+	}
+	return updateinfos, nil
+}
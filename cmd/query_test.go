@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestExtractPaths(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "empty input",
+			input: "",
+			want:  []string{},
+		},
+		{
+			name: "ldd output",
+			input: "\tlinux-vdso.so.1 (0x00007ffd)\n" +
+				"\tlibc.so.6 => /lib64/libc.so.6 (0x00007f12)\n" +
+				"\t/lib64/ld-linux-x86-64.so.2 (0x00007f34)\n",
+			want: []string{"/lib64/libc.so.6", "/lib64/ld-linux-x86-64.so.2"},
+		},
+		{
+			name:  "strace log",
+			input: `openat(AT_FDCWD, "/etc/ld.so.cache", O_RDONLY|O_CLOEXEC) = 3` + "\n" + `execve("/usr/bin/bash", ["bash"], 0x7ffd) = 0`,
+			want:  []string{"/etc/ld.so.cache", "/usr/bin/bash"},
+		},
+		{
+			name:  "paths are cleaned and deduplicated",
+			input: "/usr/lib64//libz.so.1 /usr/lib64/../lib64/libz.so.1\n/usr/lib64/libz.so.1",
+			want:  []string{"/usr/lib64/libz.so.1"},
+		},
+		{
+			name:  "relative paths and urls are ignored",
+			input: "lib/libz.so.1 https://example.com/libz.so.1 ./bin/bash",
+			want:  []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			paths, err := extractPaths(strings.NewReader(tt.input))
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(paths).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestUsrMergedPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		want   string
+		merged bool
+	}{
+		{path: "/bin/bash", want: "/usr/bin/bash", merged: true},
+		{path: "/sbin/ldconfig", want: "/usr/sbin/ldconfig", merged: true},
+		{path: "/lib/libc.so.6", want: "/usr/lib/libc.so.6", merged: true},
+		{path: "/lib64/libc.so.6", want: "/usr/lib64/libc.so.6", merged: true},
+		{path: "/usr/bin/bash"},
+		{path: "/etc/passwd"},
+		{path: "/libexec/helper"},
+		{path: "/bin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			merged, ok := usrMergedPath(tt.path)
+			g.Expect(ok).To(Equal(tt.merged))
+			g.Expect(merged).To(Equal(tt.want))
+		})
+	}
+}
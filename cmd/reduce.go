@@ -8,19 +8,19 @@ import (
 	"github.com/rmohr/bazeldnf/pkg/api"
 	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
 	"github.com/rmohr/bazeldnf/pkg/reducer"
-	"github.com/rmohr/bazeldnf/pkg/repo"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 type reduceOpts struct {
-	in         []string
-	repofiles  []string
-	out        string
-	lang       string
-	nobest     bool
-	arch       string
-	baseSystem string
+	in               []string
+	repofiles        []string
+	out              string
+	lang             string
+	nobest           bool
+	arch             string
+	baseSystem       string
+	allowForeignArch bool
 }
 
 var reduceopts = reduceOpts{}
@@ -38,12 +38,22 @@ which allow reducing huge rpm repos to a smaller problem set for debugging, remo
 			repos := &bazeldnf.Repositories{}
 			if len(reduceopts.in) == 0 {
 				var err error
-				repos, err = repo.LoadRepoFiles(reduceopts.repofiles)
+				repos, err = loadRepoFiles(reduceopts.repofiles)
 				if err != nil {
 					return err
 				}
+				if err := refreshIfForced(repos); err != nil {
+					return err
+				}
+			}
+			cacheDir, err := cacheDir(repos)
+			if err != nil {
+				return err
+			}
+			repo := reducer.NewRepoReducer(repos, reduceopts.in, reduceopts.lang, reduceopts.baseSystem, reduceopts.arch, cacheDir)
+			if reduceopts.allowForeignArch {
+				repo.SetAllowForeignArch()
 			}
-			repo := reducer.NewRepoReducer(repos, reduceopts.in, reduceopts.lang, reduceopts.baseSystem, reduceopts.arch, ".bazeldnf")
 			logrus.Info("Loading packages.")
 			if err := repo.Load(); err != nil {
 				return err
@@ -71,11 +81,12 @@ which allow reducing huge rpm repos to a smaller problem set for debugging, remo
 	}
 
 	reduceCmd.Flags().StringArrayVarP(&reduceopts.in, "input", "i", nil, "primary.xml of the repository")
+	reduceCmd.Flags().BoolVar(&reduceopts.allowForeignArch, "allow-foreign-arch", false, "also consider the i686 or armv7hl compatibility packages of x86_64 or aarch64 repositories, which are dropped by default, for multilib installations")
 	reduceCmd.Flags().StringVarP(&reduceopts.out, "output", "o", "debug.xml", "where to write the repository file")
 	reduceCmd.Flags().StringVar(&reduceopts.baseSystem, "basesystem", "fedora-release-container", "base system to use (e.g. fedora-release-server, centos-stream-release, ...)")
-	reduceCmd.Flags().StringVarP(&reduceopts.arch, "arch", "a", "x86_64", "target architecture")
+	reduceCmd.Flags().VarP(newArchValue("x86_64", &reduceopts.arch), "arch", "a", "target architecture, GOARCH names like amd64 or arm64 are accepted too")
 	reduceCmd.Flags().BoolVarP(&reduceopts.nobest, "nobest", "n", false, "allow picking versions which are not the newest")
-	reduceCmd.Flags().StringArrayVarP(&reduceopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file. Can be specified multiple times. Will be used by default if no explicit inputs are provided.")
+	reduceCmd.Flags().StringArrayVarP(&reduceopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file or directory like repos.d/. Can be specified multiple times, later files override repositories with the same name. Will be used by default if no explicit inputs are provided.")
 	// deprecated options
 	reduceCmd.Flags().StringVarP(&reduceopts.baseSystem, "fedora-base-system", "f", "fedora-release-container", "base system to use (e.g. fedora-release-server, centos-stream-release, ...)")
 	reduceCmd.Flags().MarkDeprecated("fedora-base-system", "use --basesystem instead")
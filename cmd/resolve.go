@@ -4,9 +4,10 @@ import (
 	"os"
 
 	"github.com/rmohr/bazeldnf/cmd/template"
+	"github.com/rmohr/bazeldnf/pkg/api"
 	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/progress"
 	"github.com/rmohr/bazeldnf/pkg/reducer"
-	"github.com/rmohr/bazeldnf/pkg/repo"
 	"github.com/rmohr/bazeldnf/pkg/sat"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -20,6 +21,15 @@ type resolveOpts struct {
 	baseSystem       string
 	repofiles        []string
 	forceIgnoreRegex []string
+	interactive      bool
+	maxDownloadSize  string
+	maxInstalledSize string
+	weakDeps         bool
+	allowForeignArch bool
+	requirementsFile string
+	portfolio        int
+	manifest         string
+	target           string
 }
 
 var resolveopts = resolveOpts{}
@@ -30,43 +40,105 @@ func NewResolveCmd() *cobra.Command {
 		Use:   "resolve",
 		Short: "resolves depencencies of the given packages",
 		Long:  `resolves dependencies of the given packages with the assumption of a SCRATCH container as install target`,
-		Args:  cobra.MinimumNArgs(1),
-		RunE: func(cmd *cobra.Command, required []string) error {
+		RunE: func(cmd *cobra.Command, args []string) error {
+			required, _, err := requestedPackages(resolveopts.manifest, resolveopts.target, args)
+			if err != nil {
+				return err
+			}
+			maxDownloadSize, err := template.ParseQuantity(resolveopts.maxDownloadSize)
+			if err != nil {
+				return err
+			}
+			maxInstalledSize, err := template.ParseQuantity(resolveopts.maxInstalledSize)
+			if err != nil {
+				return err
+			}
 			repos := &bazeldnf.Repositories{}
+			var repofiles []string
 			if len(resolveopts.in) == 0 {
 				var err error
-				repos, err = repo.LoadRepoFiles(resolveopts.repofiles)
+				repos, err = loadRepoFiles(resolveopts.repofiles)
 				if err != nil {
 					return err
 				}
+				if err := refreshIfForced(repos); err != nil {
+					return err
+				}
+				repofiles = resolveopts.repofiles
+			}
+			cacheDir, err := cacheDir(repos)
+			if err != nil {
+				return err
+			}
+			repo := reducer.NewRepoReducer(repos, resolveopts.in, resolveopts.lang, resolveopts.baseSystem, resolveopts.arch, cacheDir)
+			if resolveopts.weakDeps {
+				repo.SetWeakDeps(repos.IgnoreWeakDeps)
+			}
+			if resolveopts.allowForeignArch {
+				repo.SetAllowForeignArch()
 			}
-			repo := reducer.NewRepoReducer(repos, resolveopts.in, resolveopts.lang, resolveopts.baseSystem, resolveopts.arch, ".bazeldnf")
 			logrus.Info("Loading packages.")
-			if err := repo.Load(); err != nil {
+			if err := progress.Phase("load", repo.Load); err != nil {
 				return err
 			}
 			logrus.Info("Initial reduction of involved packages.")
-			matched, involved, err := repo.Resolve(required)
-			if err != nil {
+			var matched []string
+			var involved []*api.Package
+			err = progress.Phase("reduce", func() (err error) {
+				matched, involved, err = repo.Resolve(required)
 				return err
-			}
-			solver := sat.NewResolver(resolveopts.nobest)
-			logrus.Info("Loading involved packages into the resolver.")
-			err = solver.LoadInvolvedPackages(involved, resolveopts.forceIgnoreRegex)
+			})
 			if err != nil {
 				return err
 			}
-			logrus.Info("Adding required packages to the resolver.")
-			err = solver.ConstructRequirements(matched)
-			if err != nil {
-				return err
+			if resolveopts.interactive {
+				if err := resolveInteractively(involved, matched, repos, repofiles); err != nil {
+					return err
+				}
 			}
-			logrus.Info("Solving.")
-			install, _, forceIgnored, err := solver.Resolve()
+			var install, forceIgnored []*api.Package
+			err = progress.Phase("solve", func() error {
+				for {
+					solver := sat.NewResolver(resolveopts.nobest)
+					solver.SetPreferences(repos.Preferences)
+					solver.SetPortfolio(resolveopts.portfolio)
+					if resolveopts.weakDeps {
+						solver.SetWeakDeps(repos.IgnoreWeakDeps)
+					}
+					install, forceIgnored, err = solve(solver, involved, matched, resolveopts.forceIgnoreRegex)
+					if err == nil || !resolveopts.interactive {
+						break
+					}
+					retry, interactiveErr := resolveFailureInteractively(err, solver, involved, matched, repos, repofiles)
+					if interactiveErr != nil {
+						return interactiveErr
+					}
+					if !retry {
+						break
+					}
+				}
+				return err
+			})
 			if err != nil {
 				return err
 			}
-			if err := template.Render(os.Stdout, install, forceIgnored); err != nil {
+			if resolveopts.requirementsFile == "-" {
+				// the requirements replace the table to keep stdout machine-readable
+				if err := writeRequirements(resolveopts.requirementsFile, install); err != nil {
+					return err
+				}
+			} else {
+				if err := template.Render(os.Stdout, install, forceIgnored); err != nil {
+					return err
+				}
+				if err := template.RenderSkippedWeakDeps(os.Stdout, sat.FindSkippedWeakDeps(install)); err != nil {
+					return err
+				}
+				if err := writeRequirements(resolveopts.requirementsFile, install); err != nil {
+					return err
+				}
+			}
+			if err := template.CheckBudget(install, maxDownloadSize, maxInstalledSize); err != nil {
 				return err
 			}
 			return nil
@@ -75,10 +147,19 @@ func NewResolveCmd() *cobra.Command {
 
 	resolveCmd.Flags().StringArrayVarP(&resolveopts.in, "input", "i", nil, "primary.xml of the repository")
 	resolveCmd.Flags().StringVar(&resolveopts.baseSystem, "basesystem", "fedora-release-container", "base system to use (e.g. fedora-release-server, centos-stream-release, ...)")
-	resolveCmd.Flags().StringVarP(&resolveopts.arch, "arch", "a", "x86_64", "target architecture")
+	resolveCmd.Flags().VarP(newArchValue("x86_64", &resolveopts.arch), "arch", "a", "target architecture, GOARCH names like amd64 or arm64 are accepted too")
 	resolveCmd.Flags().BoolVarP(&resolveopts.nobest, "nobest", "n", false, "allow picking versions which are not the newest")
-	resolveCmd.Flags().StringArrayVarP(&resolveopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file. Can be specified multiple times. Will be used by default if no explicit inputs are provided.")
+	resolveCmd.Flags().StringArrayVarP(&resolveopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file or directory like repos.d/. Can be specified multiple times, later files override repositories with the same name. Will be used by default if no explicit inputs are provided.")
 	resolveCmd.Flags().StringArrayVar(&resolveopts.forceIgnoreRegex, "force-ignore-with-dependencies", []string{}, "Packages matching these regex patterns will not be installed. Allows force-removing unwanted dependencies. Be careful, this can lead to hidden missing dependencies.")
+	resolveCmd.Flags().BoolVar(&resolveopts.interactive, "interactive", false, "interactively decide which package should provide capabilities with multiple candidates, also after resolving failed, and persist the decisions in the last repofile, whose preferences win")
+	resolveCmd.Flags().StringVar(&resolveopts.maxDownloadSize, "max-download-size", "", "fail if the total download size of the solution exceeds this size (e.g. 500M, 1.5G)")
+	resolveCmd.Flags().IntVar(&resolveopts.portfolio, "solver-portfolio", 1, "solve with this many differently shuffled solver configurations in parallel and take the first solution, to bound the solving time on hard instances. Shuffling variables and clauses is only a weak stand-in for different decision heuristics, which the solver does not offer")
+	resolveCmd.Flags().BoolVar(&resolveopts.weakDeps, "weak-deps", false, "also install recommended packages, except for the ones ignored in the repository file")
+	resolveCmd.Flags().BoolVar(&resolveopts.allowForeignArch, "allow-foreign-arch", false, "also consider the i686 or armv7hl compatibility packages of x86_64 or aarch64 repositories, which are dropped by default, for multilib installations")
+	resolveCmd.Flags().StringVar(&resolveopts.requirementsFile, "requirements-file", "", "also write the resolved packages as sorted name-epoch:version-release.arch lines to this file, - prints them instead of the table")
+	resolveCmd.Flags().StringVar(&resolveopts.manifest, "manifest", "", "YAML or JSON file with the packages to resolve, in addition to the ones given as arguments")
+	resolveCmd.Flags().StringVar(&resolveopts.target, "target", "", "only resolve the packages of this target of the manifest, all targets are resolved together by default")
+	resolveCmd.Flags().StringVar(&resolveopts.maxInstalledSize, "max-installed-size", "", "fail if the total installed size of the solution exceeds this size (e.g. 500M, 1.5G)")
 	// deprecated options
 	resolveCmd.Flags().StringVarP(&resolveopts.baseSystem, "fedora-base-system", "f", "fedora-release-container", "base system to use (e.g. fedora-release-server, centos-stream-release, ...)")
 	resolveCmd.Flags().MarkDeprecated("fedora-base-system", "use --basesystem instead")
@@ -86,3 +167,39 @@ func NewResolveCmd() *cobra.Command {
 	resolveCmd.Flags().MarkShorthandDeprecated("nobest", "use --nobest instead")
 	return resolveCmd
 }
+
+// solve loads the involved packages into the solver and resolves the matched ones
+func solve(solver *sat.Resolver, involved []*api.Package, matched []string, forceIgnoreRegex []string) (install []*api.Package, forceIgnored []*api.Package, err error) {
+	logrus.Info("Loading involved packages into the resolver.")
+	if err := solver.LoadInvolvedPackages(involved, forceIgnoreRegex); err != nil {
+		return nil, nil, err
+	}
+	logrus.Info("Adding required packages to the resolver.")
+	if err := solver.ConstructRequirements(matched); err != nil {
+		return nil, nil, err
+	}
+	logrus.Info("Solving.")
+	install, _, forceIgnored, err = solver.Resolve()
+	return install, forceIgnored, err
+}
+
+// writeRequirements writes the installed packages in the requirements format to the file, or to stdout if the
+// path is -
+func writeRequirements(path string, install []*api.Package) error {
+	if path == "" {
+		return nil
+	}
+	if path == "-" {
+		return template.RenderRequirements(os.Stdout, install)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := template.RenderRequirements(f, install); err != nil {
+		f.Close()
+		return err
+	}
+	logrus.Infof("Writing %d resolved packages to %s.", len(install), path)
+	return f.Close()
+}
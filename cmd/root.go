@@ -2,11 +2,38 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/rmohr/bazeldnf/cmd/template"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/progress"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/rmohr/bazeldnf/pkg/rpmarch"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
+type rootOpts struct {
+	forceRefresh bool
+	cacheDir     string
+	record       string
+	replay       string
+	rewriteRules string
+	distroRepos  []string
+	awsRegion    string
+	snapshot     string
+	kojiBuilds   []string
+	progress     string
+	httpRetries  int
+	connections  int
+	chunkSize    string
+}
+
+var rootopts = rootOpts{}
+
 var rootCmd = &cobra.Command{
 	Use:   "bazeldnf",
 	Short: "bazeldnf is a tool which can query RPM repos and determine package dependencies",
@@ -16,6 +43,32 @@ var rootCmd = &cobra.Command{
 }
 
 func Execute() {
+	rootCmd.PersistentFlags().BoolVar(&rootopts.forceRefresh, "force-refresh", false, "ignore all cached repository metadata and fetch it again before doing anything else")
+	rootCmd.PersistentFlags().StringVar(&rootopts.cacheDir, "cache-dir", "", "directory for cached repository metadata (defaults to $"+repo.CacheDirEnv+", the cacheDir of the repository files or $XDG_CACHE_HOME/bazeldnf)")
+	rootCmd.PersistentFlags().StringArrayVar(&rootopts.distroRepos, "distro-repo", []string{}, "add the repositories of a built-in distribution like fedora-41 or centos-stream-9/x86_64, missing repository files are ignored then. Can be specified multiple times")
+	rootCmd.PersistentFlags().StringVar(&rootopts.awsRegion, "aws-region", "", "AWS region whose mirrors are used for Amazon Linux 2 distribution repositories (defaults to the awsRegion of the repository files)")
+	rootCmd.PersistentFlags().StringVar(&rootopts.record, "record", "", "record all HTTP responses into this fixture directory")
+	rootCmd.PersistentFlags().StringVar(&rootopts.rewriteRules, "rewrite-rules", "", "file with URL rewrite rules which are applied to all mirror and package URLs (defaults to $"+repo.RewriteRulesEnv+")")
+	rootCmd.PersistentFlags().StringVar(&rootopts.snapshot, "snapshot", "", "resolve against the snapshots of this date (e.g. 2024-11-01) using the snapshot URLs of the repositories")
+	rootCmd.PersistentFlags().StringArrayVar(&rootopts.kojiBuilds, "koji-build", []string{}, "add the RPMs of a Fedora Koji build like koji:bash-5.2.26-1.fc40 as package source. Can be specified multiple times")
+	rootCmd.PersistentFlags().StringVar(&rootopts.progress, "progress-events", "", "write newline-delimited JSON progress events to this file or file descriptor number, e.g. 3")
+	rootCmd.PersistentFlags().IntVar(&rootopts.httpRetries, "http-retries", 3, "how often HTTP requests failing with network or server errors are attempted in total")
+	rootCmd.PersistentFlags().IntVar(&rootopts.connections, "download-connections", 1, "download files larger than --chunk-size in byte ranges over this many parallel connections, spread over all mirrors which serve them")
+	rootCmd.PersistentFlags().StringVar(&rootopts.chunkSize, "chunk-size", "16M", "size of the byte ranges downloaded in parallel with --download-connections (e.g. 16M)")
+	rootCmd.PersistentFlags().StringVar(&rootopts.replay, "replay", "", "serve all HTTP requests from this fixture directory instead of the network")
+	var progressOutput io.Closer
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if rootopts.progress == "" {
+			return nil
+		}
+		output, err := progress.Open(rootopts.progress)
+		if err != nil {
+			return err
+		}
+		progressOutput = output
+		progress.SetOutput(output)
+		return nil
+	}
 	rootCmd.AddCommand(NewXATTRCmd())
 	rootCmd.AddCommand(NewSandboxCmd())
 	rootCmd.AddCommand(NewFetchCmd())
@@ -28,8 +81,160 @@ func Execute() {
 	rootCmd.AddCommand(NewTar2FilesCmd())
 	rootCmd.AddCommand(NewLddCmd())
 	rootCmd.AddCommand(NewVerifyCmd())
-	if err := rootCmd.Execute(); err != nil {
+	rootCmd.AddCommand(NewSysrootCmd())
+	rootCmd.AddCommand(NewServeCmd())
+	rootCmd.AddCommand(NewQueryCmd())
+	rootCmd.AddCommand(NewDownloaderConfigCmd())
+	rootCmd.AddCommand(NewDownloadCmd())
+	rootCmd.AddCommand(NewProvidesCmd())
+	rootCmd.AddCommand(NewCheckUpdateCmd())
+	rootCmd.AddCommand(NewCleanCmd())
+	rootCmd.AddCommand(NewDoctorCmd())
+	err := rootCmd.Execute()
+	if progressOutput != nil {
+		progressOutput.Close()
+	}
+	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
+
+// refreshIfForced fetches fresh metadata for all given repositories if --force-refresh is set
+func refreshIfForced(repos *bazeldnf.Repositories) error {
+	if !rootopts.forceRefresh {
+		return nil
+	}
+	logrus.Info("Refreshing repository metadata.")
+	cacheDir, err := cacheDir(repos)
+	if err != nil {
+		return err
+	}
+	fetcher, err := newRepoFetcher(repos.Repositories, cacheDir)
+	if err != nil {
+		return err
+	}
+	return fetcher.Fetch()
+}
+
+// loadRepoFiles loads the given repository files and adds the repositories selected with --distro-repo and
+// --koji-build. If distribution repositories are selected, repository files which don't exist are skipped.
+func loadRepoFiles(files []string) (*bazeldnf.Repositories, error) {
+	if len(rootopts.distroRepos) > 0 {
+		existing := []string{}
+		for _, file := range files {
+			if _, err := os.Stat(file); os.IsNotExist(err) {
+				logrus.Debugf("Skipping missing repository file %s.", file)
+				continue
+			}
+			existing = append(existing, file)
+		}
+		files = existing
+	}
+	repos, err := repo.LoadRepoFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.AddDistroRepos(repos, rootopts.distroRepos, rootopts.awsRegion); err != nil {
+		return nil, err
+	}
+	if err := repo.AddKojiBuilds(repos, rootopts.kojiBuilds); err != nil {
+		return nil, err
+	}
+	if rootopts.snapshot != "" {
+		if err := repo.ApplySnapshot(repos, rootopts.snapshot); err != nil {
+			return nil, err
+		}
+	}
+	return repos, nil
+}
+
+// cacheDir returns the directory which all commands use for cached repository metadata. Metadata of snapshots
+// is kept apart from the metadata of the live repositories.
+func cacheDir(repos *bazeldnf.Repositories) (string, error) {
+	dir, err := repo.ResolveCacheDir(rootopts.cacheDir, repos)
+	if err != nil || rootopts.snapshot == "" {
+		return dir, err
+	}
+	return filepath.Join(dir, "snapshots", rootopts.snapshot), nil
+}
+
+// newGetter returns the Getter which all commands use for network access, taking --record, --replay,
+// --rewrite-rules and --download-connections into account
+func newGetter() (repo.Getter, error) {
+	var getter repo.Getter
+	switch {
+	case rootopts.record != "" && rootopts.replay != "":
+		return nil, fmt.Errorf("--record and --replay can't be used together")
+	case rootopts.record != "":
+		getter = &repo.RecordingGetter{Getter: repo.NewGetter(httpMiddlewares()...), Dir: rootopts.record}
+	case rootopts.replay != "":
+		getter = &repo.ReplayGetter{Dir: rootopts.replay}
+	default:
+		getter = repo.NewGetter(httpMiddlewares()...)
+	}
+	rewriter, err := urlRewriter()
+	if err != nil {
+		return nil, err
+	}
+	if rewriter != nil {
+		getter = &repo.RewritingGetter{Getter: getter, Rewriter: rewriter}
+	}
+	if rootopts.connections > 1 {
+		chunkSize, err := template.ParseQuantity(rootopts.chunkSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --chunk-size: %v", err)
+		}
+		getter = &repo.ChunkingGetter{Getter: getter, ChunkSize: int64(chunkSize), Connections: rootopts.connections}
+	}
+	return getter, nil
+}
+
+// httpMiddlewares returns the middlewares all HTTP requests pass: retries of failed requests and the
+// verification of the digests servers announce
+func httpMiddlewares() []repo.Middleware {
+	return []repo.Middleware{
+		repo.RetryMiddleware(rootopts.httpRetries, time.Second),
+		repo.ChecksumMiddleware(),
+	}
+}
+
+// urlRewriter returns the rewriter for the rules given with --rewrite-rules or $BAZELDNF_REWRITE_RULES, or nil
+// if there are none
+func urlRewriter() (*repo.URLRewriter, error) {
+	return repo.LoadURLRewriter(rootopts.rewriteRules)
+}
+
+func newRepoFetcher(repos []bazeldnf.Repository, cacheDir string) (repo.RepoFetcher, error) {
+	getter, err := newGetter()
+	if err != nil {
+		return nil, err
+	}
+	return &repo.RepoFetcherImpl{
+		Repos:       repos,
+		Getter:      getter,
+		CacheHelper: &repo.CacheHelper{CacheDir: cacheDir},
+	}, nil
+}
+
+// archValue is a flag value which accepts architecture names like amd64 or arm64 and stores the matching RPM
+// architecture
+type archValue string
+
+func newArchValue(value string, p *string) *archValue {
+	*p = value
+	return (*archValue)(p)
+}
+
+func (a *archValue) Set(value string) error {
+	*a = archValue(rpmarch.Normalize(value))
+	return nil
+}
+
+func (a *archValue) String() string {
+	return string(*a)
+}
+
+func (a *archValue) Type() string {
+	return "string"
+}
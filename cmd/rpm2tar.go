@@ -4,10 +4,12 @@ import (
 	"archive/tar"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 
 	"github.com/rmohr/bazeldnf/pkg/order"
+	"github.com/rmohr/bazeldnf/pkg/provenance"
 	"github.com/rmohr/bazeldnf/pkg/rpm"
 	"github.com/spf13/cobra"
 )
@@ -19,6 +21,7 @@ type rpm2tarOpts struct {
 	symlinks       map[string]string
 	capabilities   map[string]string
 	selinuxLabels  map[string]string
+	provenance     string
 }
 
 var rpm2taropts = rpm2tarOpts{}
@@ -28,6 +31,9 @@ func NewRpm2TarCmd() *cobra.Command {
 		Use:   "rpm2tar",
 		Short: "convert a rpm to a tar archive",
 		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			if rpm2taropts.provenance != "" && rpm2taropts.output == "" {
+				return fmt.Errorf("--provenance requires --output")
+			}
 			sortSymlinkKeys()
 			rpmStream := os.Stdin
 			tarStream := os.Stdout
@@ -36,6 +42,7 @@ func NewRpm2TarCmd() *cobra.Command {
 				if err != nil {
 					return fmt.Errorf("could not create tar: %v", err)
 				}
+				defer tarStream.Close()
 			}
 			cap := map[string][]string{}
 			for file, caps := range rpm2taropts.capabilities {
@@ -96,6 +103,30 @@ func NewRpm2TarCmd() *cobra.Command {
 					return fmt.Errorf("could not convert rpm : %v", err)
 				}
 			}
+			if rpm2taropts.provenance != "" {
+				if err := tarWriter.Close(); err != nil {
+					return fmt.Errorf("could not finish tar: %v", err)
+				}
+				// rpm2tar runs in Bazel actions, so neither timestamps nor the paths of the arguments may end up in
+				// the statement, the input RPMs are recorded as dependencies
+				statement := provenance.NewStatement("rpm2tar", map[string]interface{}{
+					"output":        filepath.Base(rpm2taropts.output),
+					"symlinks":      rpm2taropts.symlinks,
+					"capabilities":  rpm2taropts.capabilities,
+					"selinuxLabels": rpm2taropts.selinuxLabels,
+				})
+				for _, i := range rpm2taropts.input {
+					digest, err := provenance.FileDigest(i)
+					if err != nil {
+						return err
+					}
+					statement.AddDependency(filepath.Base(i), "", digest)
+				}
+				if err := statement.AddSubject(rpm2taropts.output); err != nil {
+					return err
+				}
+				return statement.Write(rpm2taropts.provenance)
+			}
 			return nil
 		},
 	}
@@ -105,6 +136,7 @@ func NewRpm2TarCmd() *cobra.Command {
 	rpm2tarCmd.Flags().StringToStringVarP(&rpm2taropts.symlinks, "symlinks", "s", map[string]string{}, "symlinks to add. Relative or absolute.")
 	rpm2tarCmd.Flags().StringToStringVarP(&rpm2taropts.capabilities, "capabilities", "c", map[string]string{}, "capabilities of files (--capabilities=/bin/ls=cap_net_bind_service)")
 	rpm2tarCmd.Flags().StringToStringVar(&rpm2taropts.selinuxLabels, "selinux-labels", map[string]string{}, "selinux labels of files (--selinux-labels=/bin/ls=unconfined_u:object_r:default_t:s0)")
+	rpm2tarCmd.Flags().StringVar(&rpm2taropts.provenance, "provenance", "", "write a SLSA provenance statement for the resulting tar file to this file")
 	// deprecated options
 	rpm2tarCmd.Flags().StringToStringVar(&rpm2taropts.capabilities, "capabilties", map[string]string{}, "capabilities of files (-c=/bin/ls=cap_net_bind_service)")
 	rpm2tarCmd.Flags().MarkDeprecated("capabilties", "use --capabilities instead")
@@ -5,7 +5,12 @@ import (
 
 	"github.com/bazelbuild/buildtools/build"
 	"github.com/rmohr/bazeldnf/cmd/template"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
 	"github.com/rmohr/bazeldnf/pkg/bazel"
+	"github.com/rmohr/bazeldnf/pkg/lockfile"
+	"github.com/rmohr/bazeldnf/pkg/progress"
+	"github.com/rmohr/bazeldnf/pkg/provenance"
 	"github.com/rmohr/bazeldnf/pkg/reducer"
 	"github.com/rmohr/bazeldnf/pkg/repo"
 	"github.com/rmohr/bazeldnf/pkg/sat"
@@ -25,6 +30,23 @@ type rpmtreeOpts struct {
 	name             string
 	public           bool
 	forceIgnoreRegex []string
+	interactive      bool
+	maxDownloadSize  string
+	maxInstalledSize string
+	weakDeps         bool
+	allowForeignArch bool
+	requirementsFile string
+	portfolio        int
+	canonicalID      bool
+	noColor          bool
+	ociImage         string
+	provenance       string
+	lockfile         string
+	signingKey       string
+	minimalChurn     bool
+	update           []string
+	manifest         string
+	rehashSHA256     bool
 }
 
 var rpmtreeopts = rpmtreeOpts{}
@@ -34,40 +56,128 @@ func NewRpmTreeCmd() *cobra.Command {
 	rpmtreeCmd := &cobra.Command{
 		Use:   "rpmtree",
 		Short: "Writes a rpmtree rule and its rpmdependencies to bazel files",
-		Args:  cobra.MinimumNArgs(1),
-		RunE: func(cmd *cobra.Command, required []string) error {
+		RunE: func(cmd *cobra.Command, args []string) error {
+			required, annotations, err := requestedPackages(rpmtreeopts.manifest, rpmtreeopts.name, args)
+			if err != nil {
+				return err
+			}
+			statement := provenance.NewStatement("rpmtree", map[string]interface{}{"arguments": os.Args[1:]})
+			statement.Start()
+			maxDownloadSize, err := template.ParseQuantity(rpmtreeopts.maxDownloadSize)
+			if err != nil {
+				return err
+			}
+			maxInstalledSize, err := template.ParseQuantity(rpmtreeopts.maxInstalledSize)
+			if err != nil {
+				return err
+			}
 			writeToMacro := rpmtreeopts.toMacro != ""
+			if rpmtreeopts.lockfile != "" {
+				if rootopts.snapshot, err = lockedSnapshot(rpmtreeopts.lockfile, rootopts.snapshot); err != nil {
+					return err
+				}
+			}
 
-			repos, err := repo.LoadRepoFiles(rpmtreeopts.repofiles)
+			repos, err := loadRepoFiles(rpmtreeopts.repofiles)
 			if err != nil {
 				return err
 			}
-			repoReducer := reducer.NewRepoReducer(repos, nil, rpmtreeopts.lang, rpmtreeopts.baseSystem, rpmtreeopts.arch, ".bazeldnf")
+			if err := refreshIfForced(repos); err != nil {
+				return err
+			}
+			cacheDir, err := cacheDir(repos)
+			if err != nil {
+				return err
+			}
+			repoReducer := reducer.NewRepoReducer(repos, nil, rpmtreeopts.lang, rpmtreeopts.baseSystem, rpmtreeopts.arch, cacheDir)
+			if rpmtreeopts.weakDeps {
+				repoReducer.SetWeakDeps(repos.IgnoreWeakDeps)
+			}
+			if rpmtreeopts.allowForeignArch {
+				repoReducer.SetAllowForeignArch()
+			}
 			logrus.Info("Loading packages.")
-			if err := repoReducer.Load(); err != nil {
+			if err := progress.Phase("load", repoReducer.Load); err != nil {
 				return err
 			}
-			logrus.Info("Initial reduction of involved packages.")
-			matched, involved, err := repoReducer.Resolve(required)
+			rewriter, err := urlRewriter()
 			if err != nil {
 				return err
 			}
-			solver := sat.NewResolver(rpmtreeopts.nobest)
-			logrus.Info("Loading involved packages into the rpmtreer.")
-			err = solver.LoadInvolvedPackages(involved, rpmtreeopts.forceIgnoreRegex)
+			logrus.Info("Initial reduction of involved packages.")
+			var matched []string
+			var involved []*api.Package
+			err = progress.Phase("reduce", func() (err error) {
+				matched, involved, err = repoReducer.Resolve(required)
+				return err
+			})
 			if err != nil {
 				return err
 			}
-			logrus.Info("Adding required packages to the rpmtreer.")
-			err = solver.ConstructRequirements(matched)
+			if rpmtreeopts.interactive {
+				if err := resolveInteractively(involved, matched, repos, rpmtreeopts.repofiles); err != nil {
+					return err
+				}
+			}
+			buildfile, err := bazel.LoadBuild(rpmtreeopts.buildfile)
 			if err != nil {
 				return err
 			}
-			logrus.Info("Solving.")
-			install, _, forceIgnored, err := solver.Resolve()
+			oldPackages := map[string]string{}
+			for _, label := range bazel.GetTreeRPMs(buildfile, rpmtreeopts.name) {
+				name, version, err := bazel.ParseRPMLabel(label, rpmtreeopts.arch)
+				if err != nil {
+					logrus.Warnf("Ignoring unparsable rpm reference: %v", err)
+					continue
+				}
+				oldPackages[name] = version
+			}
+			locked := map[string]string{}
+			if rpmtreeopts.minimalChurn {
+				locked, err = lockedVersions(rpmtreeopts.lockfile, rpmtreeopts.name, oldPackages, rpmtreeopts.update)
+				if err != nil {
+					return err
+				}
+			}
+			var install, forceIgnored []*api.Package
+			err = progress.Phase("solve", func() error {
+				for {
+					solver := sat.NewResolver(rpmtreeopts.nobest)
+					solver.SetLockedVersions(locked)
+					solver.SetPreferences(repos.Preferences)
+					solver.SetPortfolio(rpmtreeopts.portfolio)
+					if rpmtreeopts.weakDeps {
+						solver.SetWeakDeps(repos.IgnoreWeakDeps)
+					}
+					install, forceIgnored, err = solve(solver, involved, matched, rpmtreeopts.forceIgnoreRegex)
+					if err == nil || !rpmtreeopts.interactive {
+						break
+					}
+					retry, interactiveErr := resolveFailureInteractively(err, solver, involved, matched, repos, rpmtreeopts.repofiles)
+					if interactiveErr != nil {
+						return interactiveErr
+					}
+					if !retry {
+						break
+					}
+				}
+				return err
+			})
 			if err != nil {
 				return err
 			}
+			if err := template.CheckBudget(install, maxDownloadSize, maxInstalledSize); err != nil {
+				return err
+			}
+			if rpmtreeopts.rehashSHA256 {
+				getter, err := newGetter()
+				if err != nil {
+					return err
+				}
+				if err := repo.RehashSHA256(getter, &repo.CacheHelper{CacheDir: cacheDir}, install); err != nil {
+					return err
+				}
+			}
 			workspace, err := bazel.LoadWorkspace(rpmtreeopts.workspace)
 			if err != nil {
 				return err
@@ -84,26 +194,52 @@ func NewRpmTreeCmd() *cobra.Command {
 					return err
 				}
 			}
-			build, err := bazel.LoadBuild(rpmtreeopts.buildfile)
-			if err != nil {
-				return err
-			}
 			if writeToMacro {
+				previousURLs := bazel.URLs(bazel.GetBzlfileRPMs(bzlfile, defName))
 				err = bazel.AddBzlfileRPMs(bzlfile, defName, install, rpmtreeopts.arch)
 				if err != nil {
 					return err
 				}
+				if rewriter != nil {
+					bazel.RewriteURLs(bazel.GetBzlfileRPMs(bzlfile, defName), previousURLs, rewriter.Rewrite)
+				}
+				if rpmtreeopts.canonicalID {
+					bazel.SetCanonicalIDs(bazel.GetBzlfileRPMs(bzlfile, defName))
+				}
+				if annotations != nil {
+					bazel.AnnotateRPMs(bazel.GetBzlfileRPMs(bzlfile, defName), install, rpmtreeopts.arch, annotations)
+				}
 			} else {
+				previousURLs := bazel.URLs(bazel.GetWorkspaceRPMs(workspace))
 				err = bazel.AddWorkspaceRPMs(workspace, install, rpmtreeopts.arch)
 				if err != nil {
 					return err
 				}
+				if rewriter != nil {
+					bazel.RewriteURLs(bazel.GetWorkspaceRPMs(workspace), previousURLs, rewriter.Rewrite)
+				}
+				if rpmtreeopts.canonicalID {
+					bazel.SetCanonicalIDs(bazel.GetWorkspaceRPMs(workspace))
+				}
+				if annotations != nil {
+					bazel.AnnotateRPMs(bazel.GetWorkspaceRPMs(workspace), install, rpmtreeopts.arch, annotations)
+				}
+			}
+			newPackages := map[string]string{}
+			for _, pkg := range install {
+				newPackages[pkg.Name] = pkg.Version.String()
+			}
+			bazel.AddTree(rpmtreeopts.name, buildfile, install, rpmtreeopts.arch, rpmtreeopts.public)
+			if annotations != nil {
+				bazel.AnnotateTree(buildfile, rpmtreeopts.name, install, rpmtreeopts.arch, annotations)
+			}
+			if rpmtreeopts.ociImage != "" {
+				bazel.AddOCIImage(buildfile, rpmtreeopts.ociImage, rpmtreeopts.name, rpmtreeopts.arch, rpmtreeopts.public)
 			}
-			bazel.AddTree(rpmtreeopts.name, build, install, rpmtreeopts.arch, rpmtreeopts.public)
 			if writeToMacro {
-				bazel.PruneBzlfileRPMs(build, bzlfile, defName)
+				bazel.PruneBzlfileRPMs(buildfile, bzlfile, defName)
 			} else {
-				bazel.PruneWorkspaceRPMs(build, workspace)
+				bazel.PruneWorkspaceRPMs(buildfile, workspace)
 			}
 			logrus.Info("Writing bazel files.")
 			err = bazel.WriteWorkspace(false, workspace, rpmtreeopts.workspace)
@@ -115,30 +251,83 @@ func NewRpmTreeCmd() *cobra.Command {
 				if err != nil {
 					return err
 				}
+				progress.Emit(progress.Event{Type: progress.RulesWritten, File: bzl, Count: len(bazel.GetBzlfileRPMs(bzlfile, defName))})
+			} else {
+				progress.Emit(progress.Event{Type: progress.RulesWritten, File: rpmtreeopts.workspace, Count: len(bazel.GetWorkspaceRPMs(workspace))})
 			}
-			err = bazel.WriteBuild(false, build, rpmtreeopts.buildfile)
+			err = bazel.WriteBuild(false, buildfile, rpmtreeopts.buildfile)
 			if err != nil {
 				return err
 			}
+			progress.Emit(progress.Event{Type: progress.RulesWritten, File: rpmtreeopts.buildfile, Count: len(buildfile.Rules(""))})
+			if rpmtreeopts.lockfile != "" {
+				if err := updateLockfile(rpmtreeopts.lockfile, rpmtreeopts.name, repos, cacheDir, rpmtreeopts.arch, install, rewriter); err != nil {
+					return err
+				}
+				if rpmtreeopts.signingKey != "" {
+					if err := lockfile.Sign(rpmtreeopts.lockfile, rpmtreeopts.signingKey); err != nil {
+						return err
+					}
+				}
+			}
+			if rpmtreeopts.provenance != "" {
+				written := []string{rpmtreeopts.buildfile, rpmtreeopts.workspace}
+				if writeToMacro {
+					written = []string{rpmtreeopts.buildfile, bzl}
+				}
+				if rpmtreeopts.lockfile != "" {
+					written = append(written, rpmtreeopts.lockfile)
+				}
+				if err := writeRpmtreeProvenance(statement, repos, cacheDir, install, written); err != nil {
+					return err
+				}
+			}
 			if err := template.Render(os.Stdout, install, forceIgnored); err != nil {
 				return err
 			}
+			if err := template.RenderSkippedWeakDeps(os.Stdout, sat.FindSkippedWeakDeps(install)); err != nil {
+				return err
+			}
+			if err := writeRequirements(rpmtreeopts.requirementsFile, install); err != nil {
+				return err
+			}
+			color := !rpmtreeopts.noColor && isTerminal(os.Stdout)
+			if err := template.RenderDiff(os.Stdout, template.Diff(oldPackages, newPackages), color, terminalWidth()); err != nil {
+				return err
+			}
 
 			return nil
 		},
 	}
 
 	rpmtreeCmd.Flags().StringVar(&rpmtreeopts.baseSystem, "basesystem", "fedora-release-container", "base system to use (e.g. fedora-release-server, centos-stream-release, ...)")
-	rpmtreeCmd.Flags().StringVarP(&rpmtreeopts.arch, "arch", "a", "x86_64", "target architecture")
+	rpmtreeCmd.Flags().VarP(newArchValue("x86_64", &rpmtreeopts.arch), "arch", "a", "target architecture, GOARCH names like amd64 or arm64 are accepted too")
 	rpmtreeCmd.Flags().BoolVarP(&rpmtreeopts.nobest, "nobest", "n", false, "allow picking versions which are not the newest")
 	rpmtreeCmd.Flags().BoolVarP(&rpmtreeopts.public, "public", "p", true, "if the rpmtree rule should be public")
-	rpmtreeCmd.Flags().StringArrayVarP(&rpmtreeopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file. Can be specified multiple times. Will be used by default if no explicit inputs are provided.")
+	rpmtreeCmd.Flags().StringArrayVarP(&rpmtreeopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file or directory like repos.d/. Can be specified multiple times, later files override repositories with the same name. Will be used by default if no explicit inputs are provided.")
 	rpmtreeCmd.Flags().StringVarP(&rpmtreeopts.workspace, "workspace", "w", "WORKSPACE", "Bazel workspace file")
 	rpmtreeCmd.Flags().StringVarP(&rpmtreeopts.toMacro, "to-macro", "", "", "Tells bazeldnf to write the RPMs to a macro in the given bzl file instead of the WORKSPACE file. The expected format is: macroFile%defName")
 	rpmtreeCmd.Flags().StringVarP(&rpmtreeopts.buildfile, "buildfile", "b", "rpm/BUILD.bazel", "Build file for RPMs")
 	rpmtreeCmd.Flags().StringVar(&rpmtreeopts.name, "name", "", "rpmtree rule name")
+	rpmtreeCmd.Flags().StringVar(&rpmtreeopts.manifest, "manifest", "", "YAML or JSON file with the packages of the rpmtree, the target with the rpmtree name is used in addition to the packages given as arguments")
 	rpmtreeCmd.Flags().StringArrayVar(&rpmtreeopts.forceIgnoreRegex, "force-ignore-with-dependencies", []string{}, "Packages matching these regex patterns will not be installed. Allows force-removing unwanted dependencies. Be careful, this can lead to hidden missing dependencies.")
+	rpmtreeCmd.Flags().BoolVar(&rpmtreeopts.noColor, "no-color", false, "don't color the summary of package changes")
+	rpmtreeCmd.Flags().StringVar(&rpmtreeopts.ociImage, "oci-image", "", "add the rpmtree as layer to a rpmtree_oci_image rule with this name (see @bazeldnf//bazeldnf:oci.bzl)")
+	rpmtreeCmd.Flags().StringVar(&rpmtreeopts.lockfile, "lockfile", "", "record the packages of the rpmtree and the state of the repositories in this lockfile")
+	rpmtreeCmd.Flags().StringVar(&rpmtreeopts.signingKey, "lockfile-signing-key", "", "armored unencrypted private gpg key used to write a detached signature next to the lockfile")
+	rpmtreeCmd.Flags().BoolVar(&rpmtreeopts.minimalChurn, "minimal-churn", false, "prefer keeping the versions which are currently locked for the rpmtree and only move packages which have to")
+	rpmtreeCmd.Flags().StringArrayVar(&rpmtreeopts.update, "update", []string{}, "with --minimal-churn, update this package to the newest version anyway. Can be specified multiple times")
+	rpmtreeCmd.Flags().BoolVar(&rpmtreeopts.canonicalID, "canonical-id", false, "set the canonical_id of the rpm rules to the RPM file name, so that the repository cache and remote downloaders recognize RPMs independent of the mirror")
+	rpmtreeCmd.Flags().BoolVar(&rpmtreeopts.rehashSHA256, "rehash-sha256", false, "download packages whose repository declares a checksum other than sha256, verify them and record their sha256 sum instead")
+	rpmtreeCmd.Flags().StringVar(&rpmtreeopts.provenance, "provenance", "", "write a SLSA provenance statement for the written bazel files to this file")
 	rpmtreeCmd.MarkFlagRequired("name")
+	rpmtreeCmd.Flags().BoolVar(&rpmtreeopts.interactive, "interactive", false, "interactively decide which package should provide capabilities with multiple candidates, also after resolving failed, and persist the decisions in the last repofile, whose preferences win")
+	rpmtreeCmd.Flags().StringVar(&rpmtreeopts.maxDownloadSize, "max-download-size", "", "fail if the total download size of the solution exceeds this size (e.g. 500M, 1.5G)")
+	rpmtreeCmd.Flags().IntVar(&rpmtreeopts.portfolio, "solver-portfolio", 1, "solve with this many differently shuffled solver configurations in parallel and take the first solution, to bound the solving time on hard instances. Shuffling variables and clauses is only a weak stand-in for different decision heuristics, which the solver does not offer")
+	rpmtreeCmd.Flags().BoolVar(&rpmtreeopts.weakDeps, "weak-deps", false, "also install recommended packages, except for the ones ignored in the repository file")
+	rpmtreeCmd.Flags().BoolVar(&rpmtreeopts.allowForeignArch, "allow-foreign-arch", false, "also consider the i686 or armv7hl compatibility packages of x86_64 or aarch64 repositories, which are dropped by default, for multilib installations")
+	rpmtreeCmd.Flags().StringVar(&rpmtreeopts.requirementsFile, "requirements-file", "", "also write the resolved packages as sorted name-epoch:version-release.arch lines to this file")
+	rpmtreeCmd.Flags().StringVar(&rpmtreeopts.maxInstalledSize, "max-installed-size", "", "fail if the total installed size of the solution exceeds this size (e.g. 500M, 1.5G)")
 	// deprecated options
 	rpmtreeCmd.Flags().StringVarP(&rpmtreeopts.baseSystem, "fedora-base-system", "f", "fedora-release-container", "base system to use (e.g. fedora-release-server, centos-stream-release, ...)")
 	rpmtreeCmd.Flags().MarkDeprecated("fedora-base-system", "use --basesystem instead")
@@ -146,3 +335,45 @@ func NewRpmTreeCmd() *cobra.Command {
 	rpmtreeCmd.Flags().MarkShorthandDeprecated("nobest", "use --nobest instead")
 	return rpmtreeCmd
 }
+
+// writeRpmtreeProvenance records the repository metadata and all packages which were used to write the given
+// bazel files
+func writeRpmtreeProvenance(statement *provenance.Statement, repos *bazeldnf.Repositories, cacheDir string, install []*api.Package, written []string) error {
+	cacheHelper := &repo.CacheHelper{CacheDir: cacheDir}
+	for i, r := range repos.Repositories {
+		if !r.ServesArch(rpmtreeopts.arch) {
+			continue
+		}
+		sum, err := cacheHelper.RepomdSHA256(&repos.Repositories[i])
+		if err != nil {
+			return err
+		}
+		uri := r.Metalink
+		if r.Koji != nil {
+			uri = r.Koji.Hub
+			if uri == "" {
+				uri = repo.DefaultKojiHub
+			}
+		}
+		if uri == "" {
+			uri = r.Mirrorlist
+		}
+		if uri == "" && len(r.Baseurl) > 0 {
+			uri = r.Baseurl[0]
+		}
+		statement.AddDependency(r.Name+"/repomd.xml", uri, map[string]string{"sha256": sum})
+	}
+	for _, pkg := range install {
+		uri := ""
+		if urls, err := pkg.URLs(); err == nil && len(urls) > 0 {
+			uri = urls[0]
+		}
+		statement.AddDependency(pkg.String()+"."+pkg.Arch, uri, map[string]string{pkg.Checksum.Algorithm(): pkg.Checksum.Text})
+	}
+	for _, file := range written {
+		if err := statement.AddSubject(file); err != nil {
+			return err
+		}
+	}
+	return statement.Write(rpmtreeopts.provenance)
+}
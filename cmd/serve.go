@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type ServeOpts struct {
+	repofiles []string
+	listen    string
+	rpmDir    string
+}
+
+var serveopts = &ServeOpts{}
+
+func NewServeCmd() *cobra.Command {
+
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve cached repo metadata and RPMs over HTTP",
+		Long: `Serve the cached repo metadata and RPMs from a local directory over HTTP, with the same paths as the original repositories.
+A matching repo.yaml file which points all repositories to the server is served at /repo.yaml.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repos, err := loadRepoFiles(serveopts.repofiles)
+			if err != nil {
+				return err
+			}
+			cacheDir, err := cacheDir(repos)
+			if err != nil {
+				return err
+			}
+			server := &repo.CacheServer{
+				CacheDir: cacheDir,
+				RPMDir:   serveopts.rpmDir,
+				Repos:    repos.Repositories,
+			}
+			logrus.Infof("Serving %s on http://%s, repository file at http://%s/repo.yaml", cacheDir, serveopts.listen, serveopts.listen)
+			return http.ListenAndServe(serveopts.listen, server)
+		},
+	}
+
+	serveCmd.Flags().StringArrayVarP(&serveopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file or directory like repos.d/. Can be specified multiple times, later files override repositories with the same name")
+	serveCmd.Flags().StringVarP(&serveopts.listen, "listen", "l", "localhost:8080", "address to listen on")
+	serveCmd.Flags().StringVar(&serveopts.rpmDir, "rpm-dir", "", "directory containing RPM files which are served for every RPM path with a matching file name")
+	return serveCmd
+}
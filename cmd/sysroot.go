@@ -0,0 +1,255 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/buildtools/build"
+	"github.com/rmohr/bazeldnf/pkg/bazel"
+	"github.com/rmohr/bazeldnf/pkg/pkgconfig"
+	"github.com/rmohr/bazeldnf/pkg/rpm"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type sysrootOpts struct {
+	buildfile   string
+	name        string
+	rpmtree     string
+	tar         string
+	includeDirs []string
+	libDirs     []string
+	public      bool
+	filegroup   bool
+	pkgconfig   bool
+}
+
+var pkgconfigDirs = []string{"/usr/lib64/pkgconfig", "/usr/lib/pkgconfig", "/usr/share/pkgconfig"}
+
+var sysrootopts = sysrootOpts{}
+
+func NewSysrootCmd() *cobra.Command {
+
+	sysrootCmd := &cobra.Command{
+		Use:   "sysroot",
+		Short: "Generate cc_library and cc_import targets for headers and libraries of a rpmtree",
+		Long: `Extracts all headers and static and shared libraries from a rpmtree, typically consisting of -devel packages,
+and generates tar2files, cc_import and cc_library targets for them. Optionally a filegroup which can serve as
+cc_toolchain sysroot is generated too.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tmpRoot, err := ioutil.TempDir("", "bazeldnf-sysroot")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(tmpRoot)
+
+			err = rpm.Untar(tmpRoot, sysrootopts.tar)
+			if err != nil {
+				return err
+			}
+
+			files := []string{}
+			for _, dir := range sysrootopts.includeDirs {
+				headers, err := listFiles(tmpRoot, dir, true)
+				if err != nil {
+					return err
+				}
+				files = append(files, headers...)
+			}
+			for _, dir := range sysrootopts.libDirs {
+				libs, err := listFiles(tmpRoot, dir, false)
+				if err != nil {
+					return err
+				}
+				files = append(files, libs...)
+			}
+			files = filterFiles(files)
+
+			build, err := bazel.LoadBuild(sysrootopts.buildfile)
+			if err != nil {
+				return err
+			}
+			bazel.AddTar2Files(sysrootopts.name, sysrootopts.rpmtree, build, files, sysrootopts.public)
+
+			imports := []*bazel.CCImport{}
+			importLabels := map[string]string{}
+			deps := []string{}
+			for libName, lib := range bazel.GroupLibraries(files) {
+				lib.Name = sysrootopts.name + "_" + lib.Name
+				importLabels[libName] = ":" + lib.Name
+				if lib.SharedLibrary != "" {
+					lib.SharedLibrary = ":" + sysrootopts.name + lib.SharedLibrary
+				}
+				if lib.StaticLibrary != "" {
+					lib.StaticLibrary = ":" + sysrootopts.name + lib.StaticLibrary
+				}
+				imports = append(imports, lib)
+				deps = append(deps, ":"+lib.Name)
+			}
+			sort.Strings(deps)
+			bazel.AddCCImports(build, imports, sysrootopts.public)
+
+			dirs := map[string]struct{}{}
+			hdrs := []string{}
+			for _, file := range files {
+				dir := filepath.Dir(file)
+				if _, exists := dirs[dir]; exists {
+					continue
+				}
+				dirs[dir] = struct{}{}
+				for _, includeDir := range sysrootopts.includeDirs {
+					if strings.HasPrefix(file, includeDir+"/") {
+						hdrs = append(hdrs, ":"+sysrootopts.name+dir)
+						break
+					}
+				}
+			}
+			sort.Strings(hdrs)
+			includes := []string{}
+			for _, includeDir := range sysrootopts.includeDirs {
+				includes = append(includes, sysrootopts.name+includeDir)
+			}
+			bazel.AddCCLibrary(build, bazel.CCLibrary{
+				Name:     sysrootopts.name + "_cc",
+				Hdrs:     hdrs,
+				Includes: includes,
+				Deps:     deps,
+			}, sysrootopts.public)
+
+			if sysrootopts.pkgconfig {
+				dirList := []string{}
+				for dir := range dirs {
+					dirList = append(dirList, dir)
+				}
+				sort.Strings(dirList)
+				if err := addPkgConfigLibraries(build, tmpRoot, dirList, importLabels); err != nil {
+					return err
+				}
+			}
+
+			if sysrootopts.filegroup {
+				srcs := []string{}
+				for dir := range dirs {
+					srcs = append(srcs, ":"+sysrootopts.name+dir)
+				}
+				sort.Strings(srcs)
+				bazel.AddFilegroup(build, sysrootopts.name+"_sysroot", srcs, sysrootopts.public)
+			}
+
+			err = bazel.WriteBuild(false, build, sysrootopts.buildfile)
+			if err != nil {
+				return err
+			}
+			logrus.Info("Done.")
+			return nil
+		},
+	}
+
+	sysrootCmd.Flags().StringVarP(&sysrootopts.tar, "input", "i", "", "Tar file with all dependencies")
+	sysrootCmd.Flags().StringVarP(&sysrootopts.buildfile, "buildfile", "b", "rpm/BUILD.bazel", "Build file for RPMs")
+	sysrootCmd.Flags().BoolVarP(&sysrootopts.public, "public", "p", true, "if the generated rules should be public")
+	sysrootCmd.Flags().StringVar(&sysrootopts.name, "name", "", "base name of the generated rules")
+	sysrootCmd.Flags().StringVar(&sysrootopts.rpmtree, "rpmtree", "", "rpmtree rule name")
+	sysrootCmd.Flags().StringArrayVar(&sysrootopts.includeDirs, "include-dir", []string{"/usr/include"}, "directory containing headers (can be specified multiple times)")
+	sysrootCmd.Flags().StringArrayVar(&sysrootopts.libDirs, "lib-dir", []string{"/usr/lib64", "/usr/lib"}, "directory containing libraries (can be specified multiple times)")
+	sysrootCmd.Flags().BoolVar(&sysrootopts.filegroup, "sysroot-filegroup", false, "also generate a <name>_sysroot filegroup which can be used as cc_toolchain sysroot")
+	sysrootCmd.Flags().BoolVar(&sysrootopts.pkgconfig, "pkgconfig", false, "also generate a <name>_pc_<module> cc_library for every pkg-config file in the rpmtree")
+	sysrootCmd.MarkFlagRequired("name")
+	sysrootCmd.MarkFlagRequired("input")
+	return sysrootCmd
+}
+
+// addPkgConfigLibraries generates a cc_library for every pkg-config file in the tree. Include directories,
+// defines and link flags are taken from the .pc files, libraries which have a cc_import are referenced as deps
+// and pkg-config requirements are translated to dependencies on the corresponding cc_library.
+func addPkgConfigLibraries(build *build.File, root string, dirs []string, importLabels map[string]string) error {
+	modules := map[string]*pkgconfig.Package{}
+	for _, dir := range pkgconfigDirs {
+		pcFiles, err := listFiles(root, dir, false)
+		if err != nil {
+			return err
+		}
+		for _, pcFile := range pcFiles {
+			if !strings.HasSuffix(pcFile, ".pc") {
+				continue
+			}
+			module, err := pkgconfig.ParseFile(filepath.Join(root, pcFile))
+			if err != nil {
+				return err
+			}
+			if _, exists := modules[module.Name]; !exists {
+				modules[module.Name] = module
+			}
+		}
+	}
+
+	names := []string{}
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		module := modules[name]
+		lib := bazel.CCLibrary{
+			Name:    pkgconfigTarget(module.Name),
+			Defines: module.Defines(),
+		}
+		for _, include := range module.IncludeDirs() {
+			lib.Includes = append(lib.Includes, sysrootopts.name+include)
+			for _, dir := range dirs {
+				if dir == include || strings.HasPrefix(dir, include+"/") {
+					lib.Hdrs = append(lib.Hdrs, ":"+sysrootopts.name+dir)
+				}
+			}
+		}
+		for _, linkLib := range module.LinkLibraries() {
+			if label, exists := importLabels["lib"+linkLib]; exists {
+				lib.Deps = append(lib.Deps, label)
+			} else {
+				lib.Linkopts = append(lib.Linkopts, "-l"+linkLib)
+			}
+		}
+		lib.Linkopts = append(lib.Linkopts, module.Linkopts()...)
+		for _, req := range module.Requires {
+			if _, exists := modules[req]; exists {
+				lib.Deps = append(lib.Deps, ":"+pkgconfigTarget(req))
+			} else {
+				logrus.Warnf("pkg-config module %s requires %s which is not part of the rpmtree", module.Name, req)
+			}
+		}
+		sort.Strings(lib.Hdrs)
+		sort.Strings(lib.Deps)
+		bazel.AddCCLibrary(build, lib, sysrootopts.public)
+	}
+	return nil
+}
+
+func pkgconfigTarget(module string) string {
+	return sysrootopts.name + "_pc_" + module
+}
+
+// listFiles returns the paths of all regular files and symlinks in dir, relative to root. If recursive is false
+// only direct children are returned.
+func listFiles(root string, dir string, recursive bool) (files []string, err error) {
+	base := filepath.Join(root, dir)
+	if _, err := os.Stat(base); os.IsNotExist(err) {
+		return nil, nil
+	}
+	err = filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if !recursive && path != base {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		files = append(files, strings.TrimPrefix(path, root))
+		return nil
+	})
+	return files, err
+}
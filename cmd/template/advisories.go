@@ -0,0 +1,28 @@
+package template
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rmohr/bazeldnf/pkg/advisory"
+)
+
+// RenderAdvisories writes a table of advisories together with the version which fixes them
+func RenderAdvisories(writer io.Writer, matches []advisory.Match) error {
+	tabWriter := tabwriter.NewWriter(writer, 0, 8, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tabWriter, "Advisory\tType\tSeverity\tFixed Version\tRepository\tCVEs"); err != nil {
+		return fmt.Errorf("failed to write header: %v", err)
+	}
+	for _, match := range matches {
+		cves := strings.Join(match.Advisory.CVEs(), ",")
+		if _, err := fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\t%s\t%s\n", match.Advisory.ID, match.Advisory.Type, match.Advisory.Severity, match.Fixed.String(), match.Repository, cves); err != nil {
+			return fmt.Errorf("failed to write entry: %v", err)
+		}
+	}
+	if err := tabWriter.Flush(); err != nil {
+		return fmt.Errorf("failed to flush table: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package template
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/bytesize"
+)
+
+const maxOffenders = 5
+
+// ParseQuantity parses human readable sizes like `500M`, `1.5G` or `300KB`. Units are decimal, matching the
+// sizes printed in the package table. An empty string parses to 0, which means no limit.
+func ParseQuantity(quantity string) (int, error) {
+	quantity = strings.TrimSpace(strings.ToUpper(quantity))
+	if quantity == "" {
+		return 0, nil
+	}
+	quantity = strings.TrimSuffix(quantity, "B")
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(quantity, "K"):
+		multiplier = 1000
+	case strings.HasSuffix(quantity, "M"):
+		multiplier = 1000 * 1000
+	case strings.HasSuffix(quantity, "G"):
+		multiplier = 1000 * 1000 * 1000
+	}
+	if multiplier != 1 {
+		quantity = quantity[:len(quantity)-1]
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(quantity), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", quantity)
+	}
+	return int(value * multiplier), nil
+}
+
+// CheckBudget returns an error listing the largest packages if the total download or installed size of the
+// given packages exceeds the limits. A limit of 0 disables the check.
+func CheckBudget(installed []*api.Package, maxDownloadSize int, maxInstalledSize int) error {
+	totalDownloadSize := 0
+	totalInstallSize := 0
+	for _, pkg := range installed {
+		totalDownloadSize += pkg.Size.Package
+		totalInstallSize += pkg.Size.Installed
+	}
+	if maxDownloadSize > 0 && totalDownloadSize > maxDownloadSize {
+		return fmt.Errorf("total download size %s exceeds the limit of %s, largest packages: %s",
+			bytesize.Format(int64(totalDownloadSize)), bytesize.Format(int64(maxDownloadSize)),
+			offenders(installed, func(pkg *api.Package) int { return pkg.Size.Package }))
+	}
+	if maxInstalledSize > 0 && totalInstallSize > maxInstalledSize {
+		return fmt.Errorf("total install size %s exceeds the limit of %s, largest packages: %s",
+			bytesize.Format(int64(totalInstallSize)), bytesize.Format(int64(maxInstalledSize)),
+			offenders(installed, func(pkg *api.Package) int { return pkg.Size.Installed }))
+	}
+	return nil
+}
+
+func offenders(pkgs []*api.Package, size func(pkg *api.Package) int) string {
+	sorted := append([]*api.Package{}, pkgs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return size(sorted[i]) > size(sorted[j])
+	})
+	if len(sorted) > maxOffenders {
+		sorted = sorted[:maxOffenders]
+	}
+	var desc []string
+	for _, pkg := range sorted {
+		desc = append(desc, fmt.Sprintf("%s (%s)", pkg.Name, bytesize.Format(int64(size(pkg)))))
+	}
+	return strings.Join(desc, ", ")
+}
@@ -0,0 +1,65 @@
+package template
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+)
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		quantity string
+		want     int
+		wantErr  bool
+	}{
+		{quantity: "", want: 0},
+		{quantity: "300", want: 300},
+		{quantity: "300B", want: 300},
+		{quantity: "300K", want: 300 * 1000},
+		{quantity: "300kb", want: 300 * 1000},
+		{quantity: "500M", want: 500 * 1000 * 1000},
+		{quantity: "500 MB", want: 500 * 1000 * 1000},
+		{quantity: "1.5G", want: 1500 * 1000 * 1000},
+		{quantity: "1.5GB", want: 1500 * 1000 * 1000},
+		{quantity: "-1M", wantErr: true},
+		{quantity: "lots", wantErr: true},
+		{quantity: "1T", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.quantity, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			got, err := ParseQuantity(tt.quantity)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestCheckBudget(t *testing.T) {
+	g := NewGomegaWithT(t)
+	newPackage := func(name string, download int, installed int) *api.Package {
+		pkg := &api.Package{Name: name}
+		pkg.Size.Package = download
+		pkg.Size.Installed = installed
+		return pkg
+	}
+	installed := []*api.Package{
+		newPackage("bash", 2*1000*1000, 8*1000*1000),
+		newPackage("glibc", 3*1000*1000, 10*1000*1000),
+		newPackage("zlib", 100*1000, 200*1000),
+	}
+
+	g.Expect(CheckBudget(installed, 0, 0)).To(Succeed())
+	g.Expect(CheckBudget(installed, 6*1000*1000, 20*1000*1000)).To(Succeed())
+
+	err := CheckBudget(installed, 5*1000*1000, 0)
+	g.Expect(err).To(MatchError("total download size 5.10 M exceeds the limit of 5.00 M, largest packages: glibc (3.00 M), bash (2.00 M), zlib (100.00 K)"))
+
+	err = CheckBudget(installed, 0, 15*1000*1000)
+	g.Expect(err).To(MatchError(ContainSubstring("total install size 18.20 M exceeds the limit of 15.00 M, largest packages: glibc (10.00 M)")))
+}
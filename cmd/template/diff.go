@@ -0,0 +1,127 @@
+package template
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/rpm"
+)
+
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+)
+
+// ChangeType describes how a package changed between two resolutions
+type ChangeType string
+
+const (
+	ChangeAdded      ChangeType = "+"
+	ChangeRemoved    ChangeType = "-"
+	ChangeUpgraded   ChangeType = "^"
+	ChangeDowngraded ChangeType = "v"
+)
+
+type Change struct {
+	Type       ChangeType
+	Name       string
+	OldVersion string
+	NewVersion string
+}
+
+// Diff compares two package sets, given as package name to version string maps, and returns the changes
+// sorted by package name.
+func Diff(old map[string]string, new map[string]string) (changes []Change) {
+	for name, newVersion := range new {
+		oldVersion, exists := old[name]
+		if !exists {
+			changes = append(changes, Change{Type: ChangeAdded, Name: name, NewVersion: newVersion})
+			continue
+		}
+		cmp := rpm.Compare(ParseVersion(newVersion), ParseVersion(oldVersion))
+		if cmp > 0 {
+			changes = append(changes, Change{Type: ChangeUpgraded, Name: name, OldVersion: oldVersion, NewVersion: newVersion})
+		} else if cmp < 0 {
+			changes = append(changes, Change{Type: ChangeDowngraded, Name: name, OldVersion: oldVersion, NewVersion: newVersion})
+		}
+	}
+	for name, oldVersion := range old {
+		if _, exists := new[name]; !exists {
+			changes = append(changes, Change{Type: ChangeRemoved, Name: name, OldVersion: oldVersion})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+// RenderDiff writes a diff-style summary of the changes. Lines are truncated to width if width is positive.
+func RenderDiff(writer io.Writer, changes []Change, color bool, width int) error {
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(writer, "No package changes.")
+		return err
+	}
+	nameWidth := 0
+	for _, change := range changes {
+		if len(change.Name) > nameWidth {
+			nameWidth = len(change.Name)
+		}
+	}
+	if width > 0 && nameWidth > width/2 {
+		nameWidth = width / 2
+	}
+	counts := map[ChangeType]int{}
+	for _, change := range changes {
+		counts[change.Type]++
+		var versions, colorCode string
+		switch change.Type {
+		case ChangeAdded:
+			versions, colorCode = change.NewVersion, colorGreen
+		case ChangeRemoved:
+			versions, colorCode = change.OldVersion, colorRed
+		default:
+			versions, colorCode = change.OldVersion+" → "+change.NewVersion, colorYellow
+		}
+		line := truncate(fmt.Sprintf("%s %-*s %s", change.Type, nameWidth, truncate(change.Name, nameWidth), versions), width)
+		if color {
+			line = colorCode + line + colorReset
+		}
+		if _, err := fmt.Fprintln(writer, line); err != nil {
+			return fmt.Errorf("failed to write change: %v", err)
+		}
+	}
+	_, err := fmt.Fprintf(writer, "%d added, %d removed, %d upgraded, %d downgraded\n", counts[ChangeAdded], counts[ChangeRemoved], counts[ChangeUpgraded], counts[ChangeDowngraded])
+	return err
+}
+
+// ParseVersion parses a version string of the form [epoch:]version[-release]
+func ParseVersion(version string) api.Version {
+	v := api.Version{}
+	if idx := strings.Index(version, ":"); idx >= 0 {
+		v.Epoch = version[:idx]
+		version = version[idx+1:]
+	}
+	if idx := strings.LastIndex(version, "-"); idx >= 0 {
+		v.Rel = version[idx+1:]
+		version = version[:idx]
+	}
+	v.Ver = version
+	return v
+}
+
+func truncate(text string, width int) string {
+	runes := []rune(text)
+	if width <= 0 || len(runes) <= width {
+		return text
+	}
+	if width == 1 {
+		return "…"
+	}
+	return string(runes[:width-1]) + "…"
+}
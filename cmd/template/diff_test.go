@@ -0,0 +1,115 @@
+package template
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		old  map[string]string
+		new  map[string]string
+		want []Change
+	}{
+		{
+			name: "no changes",
+			old:  map[string]string{"bash": "5.2-1.fc40"},
+			new:  map[string]string{"bash": "5.2-1.fc40"},
+			want: nil,
+		},
+		{
+			name: "added packages",
+			old:  map[string]string{"bash": "5.2-1.fc40"},
+			new:  map[string]string{"bash": "5.2-1.fc40", "glibc": "2.39-1.fc40"},
+			want: []Change{{Type: ChangeAdded, Name: "glibc", NewVersion: "2.39-1.fc40"}},
+		},
+		{
+			name: "removed packages",
+			old:  map[string]string{"bash": "5.2-1.fc40", "glibc": "2.39-1.fc40"},
+			new:  map[string]string{"bash": "5.2-1.fc40"},
+			want: []Change{{Type: ChangeRemoved, Name: "glibc", OldVersion: "2.39-1.fc40"}},
+		},
+		{
+			name: "upgraded and downgraded packages",
+			old:  map[string]string{"bash": "5.2-1.fc40", "glibc": "2.39-2.fc40"},
+			new:  map[string]string{"bash": "5.2-2.fc40", "glibc": "2.39-1.fc40"},
+			want: []Change{
+				{Type: ChangeUpgraded, Name: "bash", OldVersion: "5.2-1.fc40", NewVersion: "5.2-2.fc40"},
+				{Type: ChangeDowngraded, Name: "glibc", OldVersion: "2.39-2.fc40", NewVersion: "2.39-1.fc40"},
+			},
+		},
+		{
+			name: "epochs outweigh versions",
+			old:  map[string]string{"openssl": "1:3.2-1.fc40"},
+			new:  map[string]string{"openssl": "3.3-1.fc40"},
+			want: []Change{{Type: ChangeDowngraded, Name: "openssl", OldVersion: "1:3.2-1.fc40", NewVersion: "3.3-1.fc40"}},
+		},
+		{
+			name: "changes are sorted by name",
+			old:  map[string]string{"zlib": "1.3-1.fc40"},
+			new:  map[string]string{"acl": "2.3-1.fc40"},
+			want: []Change{
+				{Type: ChangeAdded, Name: "acl", NewVersion: "2.3-1.fc40"},
+				{Type: ChangeRemoved, Name: "zlib", OldVersion: "1.3-1.fc40"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			g.Expect(Diff(tt.old, tt.new)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestRenderDiff(t *testing.T) {
+	changes := []Change{
+		{Type: ChangeAdded, Name: "acl", NewVersion: "2.3-1.fc40"},
+		{Type: ChangeUpgraded, Name: "bash", OldVersion: "5.2-1.fc40", NewVersion: "5.2-2.fc40"},
+		{Type: ChangeRemoved, Name: "zlib", OldVersion: "1.3-1.fc40"},
+	}
+	tests := []struct {
+		name    string
+		changes []Change
+		color   bool
+		width   int
+		want    string
+	}{
+		{
+			name:  "no changes",
+			want:  "No package changes.\n",
+			color: true,
+		},
+		{
+			name:    "plain",
+			changes: changes,
+			want: "+ acl  2.3-1.fc40\n" +
+				"^ bash 5.2-1.fc40 → 5.2-2.fc40\n" +
+				"- zlib 1.3-1.fc40\n" +
+				"1 added, 1 removed, 1 upgraded, 0 downgraded\n",
+		},
+		{
+			name:    "colored",
+			changes: changes[:1],
+			color:   true,
+			want:    colorGreen + "+ acl 2.3-1.fc40" + colorReset + "\n1 added, 0 removed, 0 upgraded, 0 downgraded\n",
+		},
+		{
+			name:    "truncated to the width",
+			changes: changes[1:2],
+			width:   12,
+			want:    "^ bash 5.2-…\n0 added, 0 removed, 1 upgraded, 0 downgraded\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			buf := &bytes.Buffer{}
+			g.Expect(RenderDiff(buf, tt.changes, tt.color, tt.width)).To(Succeed())
+			g.Expect(buf.String()).To(Equal(tt.want))
+		})
+	}
+}
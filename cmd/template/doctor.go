@@ -0,0 +1,33 @@
+package template
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/rmohr/bazeldnf/pkg/doctor"
+)
+
+// RenderFindings writes a table of the diagnostic findings followed by the number of errors and warnings. Unless
+// verbose is set, findings which are OK are left out.
+func RenderFindings(writer io.Writer, findings []doctor.Finding, verbose bool) error {
+	tabWriter := tabwriter.NewWriter(writer, 0, 8, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tabWriter, "Check\tStatus\tSubject\tFinding"); err != nil {
+		return fmt.Errorf("failed to write header: %v", err)
+	}
+	for _, finding := range findings {
+		if finding.Severity == doctor.OK && !verbose {
+			continue
+		}
+		if _, err := fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\n", finding.Check, finding.Severity, finding.Subject, finding.Message); err != nil {
+			return fmt.Errorf("failed to write entry: %v", err)
+		}
+	}
+	if err := tabWriter.Flush(); err != nil {
+		return fmt.Errorf("failed to flush table: %v", err)
+	}
+	if _, err := fmt.Fprintf(writer, "%d checks passed, %d warnings, %d errors\n", doctor.Count(findings, doctor.OK), doctor.Count(findings, doctor.Warning), doctor.Count(findings, doctor.Error)); err != nil {
+		return fmt.Errorf("failed to write summary: %v", err)
+	}
+	return nil
+}
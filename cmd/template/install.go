@@ -3,66 +3,69 @@ package template
 import (
 	"fmt"
 	"io"
+	"sort"
 	"text/tabwriter"
 
 	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/bytesize"
 )
 
 func Render(writer io.Writer, installed []*api.Package, forceIgnored []*api.Package) error {
 	totalDownloadSize := 0
 	totalInstallSize := 0
 
-	tabWriter := tabwriter.NewWriter(writer, 0, 8, 1, '\t', 0)
-	if _, err := fmt.Fprintln(tabWriter, "Package\tVersion\tSize\tDownload Size"); err != nil {
+	tabWriter := tabwriter.NewWriter(writer, 0, 8, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tabWriter, "Package\tVersion\tRepository\tDownload Size\tInstalled Size"); err != nil {
 		return fmt.Errorf("failed to write header: %v", err)
 	}
-	if _, err := fmt.Fprintln(tabWriter, "Installing:\t\t\t"); err != nil {
+	if _, err := fmt.Fprintln(tabWriter, "Installing:\t\t\t\t"); err != nil {
 		return fmt.Errorf("failed to write header: %v", err)
 	}
-	for _, pkg := range installed {
-		totalInstallSize += pkg.Size.Archive
+	for _, pkg := range sortedByName(installed) {
+		totalInstallSize += pkg.Size.Installed
 		totalDownloadSize += pkg.Size.Package
-		if _, err := fmt.Fprintf(tabWriter, " %v\t%v\t%s\t%s\n", pkg.Name, pkg.Version.String(), toReadableQuantity(pkg.Size.Archive), toReadableQuantity(pkg.Size.Package)); err != nil {
-			return fmt.Errorf("failed to write entry: %v", err)
+		if err := writeEntry(tabWriter, pkg); err != nil {
+			return err
 		}
 	}
-	if _, err := fmt.Fprintln(tabWriter, "Ignoring:\t\t\t"); err != nil {
+	if _, err := fmt.Fprintln(tabWriter, "Ignoring:\t\t\t\t"); err != nil {
 		return fmt.Errorf("failed to write header: %v", err)
 	}
-	for _, pkg := range forceIgnored {
-		if _, err := fmt.Fprintf(tabWriter, " %v\t%v\t%s\t%s\n", pkg.Name, pkg.Version.String(), toReadableQuantity(pkg.Size.Archive), toReadableQuantity(pkg.Size.Package)); err != nil {
-			return fmt.Errorf("failed to write entry: %v", err)
+	for _, pkg := range sortedByName(forceIgnored) {
+		if err := writeEntry(tabWriter, pkg); err != nil {
+			return err
 		}
 	}
-	if _, err := fmt.Fprintln(tabWriter, "\t\t\t\nTransaction Summary:\t\t\t"); err != nil {
-		return fmt.Errorf("failed to write header: %v", err)
+	if err := tabWriter.Flush(); err != nil {
+		return fmt.Errorf("failed to flush table: %v", err)
 	}
-	if _, err := fmt.Fprintf(tabWriter, "Installing %d Packages \t\t\t\n", len(installed)); err != nil {
-		return fmt.Errorf("failed to write header: %v", err)
+	if _, err := fmt.Fprintf(writer, "\nTransaction Summary:\nInstalling %d Packages\n", len(installed)); err != nil {
+		return fmt.Errorf("failed to write summary: %v", err)
 	}
-	if _, err := fmt.Fprintf(tabWriter, "Total download size: %s\t\t\t\n", toReadableQuantity(totalDownloadSize)); err != nil {
-		return fmt.Errorf("failed to write header: %v", err)
+	if _, err := fmt.Fprintf(writer, "Total download size: %s\n", bytesize.Format(int64(totalDownloadSize))); err != nil {
+		return fmt.Errorf("failed to write summary: %v", err)
 	}
-	if _, err := fmt.Fprintf(tabWriter, "Total install size: %s\t\t\t\n", toReadableQuantity(totalInstallSize)); err != nil {
-		return fmt.Errorf("failed to write header: %v", err)
-	}
-	if err := tabWriter.Flush(); err != nil {
-		return fmt.Errorf("failed to flush table: %v", err)
+	if _, err := fmt.Fprintf(writer, "Total install size: %s\n", bytesize.Format(int64(totalInstallSize))); err != nil {
+		return fmt.Errorf("failed to write summary: %v", err)
 	}
 	return nil
 }
 
-func toReadableQuantity(bytes int) string {
-	if bytes > 1000*1000*1000 {
-		q := float64(bytes) / 1000 / 1000 / 1000
-		return fmt.Sprintf("%.2f G", q)
-	} else if bytes > 1000*1000 {
-		q := float64(bytes) / 1000 / 1000
-		return fmt.Sprintf("%.2f M", q)
-	} else if bytes > 1000 {
-		q := float64(bytes) / 1000
-		return fmt.Sprintf("%.2f K", q)
-	} else {
-		return fmt.Sprintf("%d", bytes)
+func writeEntry(writer io.Writer, pkg *api.Package) error {
+	repository := ""
+	if pkg.Repository != nil {
+		repository = pkg.Repository.Name
+	}
+	if _, err := fmt.Fprintf(writer, " %v\t%v\t%s\t%s\t%s\n", pkg.Name, pkg.Version.String(), repository, bytesize.Format(int64(pkg.Size.Package)), bytesize.Format(int64(pkg.Size.Installed))); err != nil {
+		return fmt.Errorf("failed to write entry: %v", err)
 	}
+	return nil
+}
+
+func sortedByName(pkgs []*api.Package) []*api.Package {
+	sorted := append([]*api.Package{}, pkgs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
 }
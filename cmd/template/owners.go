@@ -0,0 +1,35 @@
+package template
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// FileOwner lists the packages which contain a path, formatted as repository/name-version.arch
+type FileOwner struct {
+	Path     string
+	Packages []string
+}
+
+// RenderFileOwners writes a table mapping paths to the packages containing them
+func RenderFileOwners(writer io.Writer, owners []FileOwner) error {
+	tabWriter := tabwriter.NewWriter(writer, 0, 8, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tabWriter, "Path\tPackages"); err != nil {
+		return fmt.Errorf("failed to write header: %v", err)
+	}
+	for _, owner := range owners {
+		packages := "-"
+		if len(owner.Packages) > 0 {
+			packages = strings.Join(owner.Packages, ", ")
+		}
+		if _, err := fmt.Fprintf(tabWriter, "%s\t%s\n", owner.Path, packages); err != nil {
+			return fmt.Errorf("failed to write entry: %v", err)
+		}
+	}
+	if err := tabWriter.Flush(); err != nil {
+		return fmt.Errorf("failed to flush table: %v", err)
+	}
+	return nil
+}
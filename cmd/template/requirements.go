@@ -0,0 +1,30 @@
+package template
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+)
+
+// RenderRequirements writes the installed packages as sorted name-epoch:version-release.arch lines, which diff
+// well and can be fed into package tooling which does not understand the lockfile
+func RenderRequirements(writer io.Writer, installed []*api.Package) error {
+	lines := []string{}
+	seen := map[string]bool{}
+	for _, pkg := range installed {
+		line := fmt.Sprintf("%s-%s.%s", pkg.Name, pkg.Version.String(), pkg.Arch)
+		if !seen[line] {
+			seen[line] = true
+			lines = append(lines, line)
+		}
+	}
+	sort.Strings(lines)
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(writer, line); err != nil {
+			return fmt.Errorf("failed to write requirement: %v", err)
+		}
+	}
+	return nil
+}
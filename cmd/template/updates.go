@@ -0,0 +1,101 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/updates"
+)
+
+// UpdateReport is the machine readable form of an available upgrade
+type UpdateReport struct {
+	Name       string `json:"name"`
+	Arch       string `json:"arch"`
+	Locked     string `json:"locked"`
+	Available  string `json:"available"`
+	Repository string `json:"repository,omitempty"`
+	// ObsoletedBy is the name of the package which replaces the locked package
+	ObsoletedBy string           `json:"obsoletedBy,omitempty"`
+	Advisories  []AdvisoryReport `json:"advisories,omitempty"`
+}
+
+// AdvisoryReport is the machine readable form of an advisory which is fixed by an upgrade
+type AdvisoryReport struct {
+	ID       string   `json:"id"`
+	Type     string   `json:"type,omitempty"`
+	Severity string   `json:"severity,omitempty"`
+	CVEs     []string `json:"cves,omitempty"`
+}
+
+// RenderUpdates writes a table of the available upgrades and the advisories they fix
+func RenderUpdates(writer io.Writer, available []updates.Update) error {
+	tabWriter := tabwriter.NewWriter(writer, 0, 8, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tabWriter, "Package\tLocked\tAvailable\tRepository\tAdvisories"); err != nil {
+		return fmt.Errorf("failed to write header: %v", err)
+	}
+	for _, report := range UpdateReports(available) {
+		target := report.Available
+		if report.ObsoletedBy != "" {
+			target = report.ObsoletedBy + "-" + target + " (obsoletes)"
+		}
+		ids := []string{}
+		for _, advisory := range report.Advisories {
+			ids = append(ids, advisory.ID)
+		}
+		advisories := "-"
+		if len(ids) > 0 {
+			advisories = strings.Join(ids, ",")
+		}
+		if _, err := fmt.Fprintf(tabWriter, "%s.%s\t%s\t%s\t%s\t%s\n", report.Name, report.Arch, report.Locked, target, report.Repository, advisories); err != nil {
+			return fmt.Errorf("failed to write entry: %v", err)
+		}
+	}
+	if err := tabWriter.Flush(); err != nil {
+		return fmt.Errorf("failed to flush table: %v", err)
+	}
+	return nil
+}
+
+// RenderUpdatesJSON writes the available upgrades as JSON list
+func RenderUpdatesJSON(writer io.Writer, available []updates.Update) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(UpdateReports(available)); err != nil {
+		return fmt.Errorf("failed to write updates: %v", err)
+	}
+	return nil
+}
+
+// UpdateReports converts the available upgrades into their machine readable form
+func UpdateReports(available []updates.Update) []UpdateReport {
+	reports := []UpdateReport{}
+	for _, update := range available {
+		locked := api.Version{Epoch: update.Locked.Epoch, Ver: update.Locked.Version, Rel: update.Locked.Release}
+		report := UpdateReport{
+			Name:      update.Locked.Name,
+			Arch:      update.Locked.Arch,
+			Locked:    locked.String(),
+			Available: update.Package.Version.String(),
+		}
+		if update.Package.Repository != nil {
+			report.Repository = update.Package.Repository.Name
+		}
+		if update.Obsoleted {
+			report.ObsoletedBy = update.Package.Name
+		}
+		for _, match := range update.Advisories {
+			report.Advisories = append(report.Advisories, AdvisoryReport{
+				ID:       match.Advisory.ID,
+				Type:     match.Advisory.Type,
+				Severity: match.Advisory.Severity,
+				CVEs:     match.Advisory.CVEs(),
+			})
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
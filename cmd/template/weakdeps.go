@@ -0,0 +1,34 @@
+package template
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/sat"
+)
+
+// RenderSkippedWeakDeps lists the recommendations which are not installed below the transaction summary,
+// together with the packages recommending them. Nothing is written if all recommendations are installed.
+func RenderSkippedWeakDeps(writer io.Writer, skipped []sat.SkippedWeakDep) error {
+	if len(skipped) == 0 {
+		return nil
+	}
+	capabilities := []string{}
+	recommenders := map[string][]string{}
+	for _, dep := range skipped {
+		if _, exists := recommenders[dep.Capability]; !exists {
+			capabilities = append(capabilities, dep.Capability)
+		}
+		recommenders[dep.Capability] = append(recommenders[dep.Capability], dep.Package)
+	}
+	if _, err := fmt.Fprintln(writer, "\nRecommended but not installed:"); err != nil {
+		return fmt.Errorf("failed to write header: %v", err)
+	}
+	for _, capability := range capabilities {
+		if _, err := fmt.Fprintf(writer, " %s (recommended by %s)\n", capability, strings.Join(recommenders[capability], ", ")); err != nil {
+			return fmt.Errorf("failed to write entry: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os"
+	"strconv"
+)
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// terminalWidth returns the width of the terminal attached to stdout, based on $COLUMNS, or 0 if output
+// should not be truncated.
+func terminalWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	if isTerminal(os.Stdout) {
+		return 80
+	}
+	return 0
+}
@@ -1,14 +1,16 @@
 package main
 
 import (
-	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"hash"
 	"io"
-	"net/http"
+	"os"
+	"strings"
 
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
 	"github.com/rmohr/bazeldnf/pkg/bazel"
+	"github.com/rmohr/bazeldnf/pkg/lockfile"
 	"github.com/rmohr/bazeldnf/pkg/repo"
 	"github.com/sassoftware/go-rpmutils"
 	log "github.com/sirupsen/logrus"
@@ -20,6 +22,8 @@ type VerifyOpts struct {
 	repofiles []string
 	workspace string
 	fromMacro string
+	lockfile  string
+	keyring   string
 }
 
 var verifyopts = VerifyOpts{}
@@ -31,25 +35,31 @@ func NewVerifyCmd() *cobra.Command {
 		Short: "verify RPMs against gpg keys defined in repo.yaml",
 		Long:  `verify RPMs against gpg keys defined in repo.yaml`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			repos, err := repo.LoadRepoFiles(verifyopts.repofiles)
+			repos, err := loadRepoFiles(verifyopts.repofiles)
 			if err != nil {
 				return err
 			}
-			keyring := openpgp.EntityList{}
-			for _, repo := range repos.Repositories {
-				if !repo.Disabled && repo.GPGKey != "" {
-					resp, err := http.Get(repo.GPGKey)
-					if err != nil {
-						return fmt.Errorf("could not fetch gpgkey %s: %w", repo.GPGKey, err)
-					}
-					defer resp.Body.Close()
-					keys, err := openpgp.ReadArmoredKeyRing(resp.Body)
-					if err != nil {
-						return fmt.Errorf("could not load gpgkey %s: %w", repo.GPGKey, err)
-					}
-					for _, k := range keys {
-						keyring = append(keyring, k)
-					}
+			getter, err := newGetter()
+			if err != nil {
+				return err
+			}
+			cacheDir, err := cacheDir(repos)
+			if err != nil {
+				return err
+			}
+			policy, err := newSignaturePolicy(getter, repos, &repo.CacheHelper{CacheDir: cacheDir})
+			if err != nil {
+				return err
+			}
+
+			if verifyopts.lockfile != "" && verifyopts.keyring != "" {
+				if err := lockfile.VerifySignature(verifyopts.lockfile, verifyopts.keyring); err != nil {
+					return err
+				}
+			}
+			if verifyopts.lockfile != "" {
+				if err := reportRepositoryChanges(verifyopts.lockfile, repos); err != nil {
+					return err
 				}
 			}
 
@@ -59,7 +69,7 @@ func NewVerifyCmd() *cobra.Command {
 					return fmt.Errorf("failed to open workspace %s: %w", verifyopts.workspace, err)
 				}
 				for _, rpm := range bazel.GetWorkspaceRPMs(workspace) {
-					err := verify(rpm, keyring)
+					err := verify(getter, rpm, policy)
 					if err != nil {
 						return fmt.Errorf("Could not verify %s: %w", rpm.Name(), err)
 					}
@@ -74,7 +84,7 @@ func NewVerifyCmd() *cobra.Command {
 					return err
 				}
 				for _, rpm := range bazel.GetBzlfileRPMs(bzlfile, defname) {
-					err := verify(rpm, keyring)
+					err := verify(getter, rpm, policy)
 					if err != nil {
 						return fmt.Errorf("Could not verify %s: %w", rpm.Name(), err)
 					}
@@ -84,22 +94,31 @@ func NewVerifyCmd() *cobra.Command {
 		},
 	}
 
-	verifyCmd.Flags().StringArrayVarP(&verifyopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file (can be specified multiple times)")
+	verifyCmd.Flags().StringArrayVarP(&verifyopts.repofiles, "repofile", "r", []string{"repo.yaml"}, "repository information file or directory like repos.d/ (can be specified multiple times, later files override repositories with the same name)")
 	verifyCmd.Flags().StringVarP(&verifyopts.workspace, "workspace", "w", "WORKSPACE", "Bazel workspace file")
 	verifyCmd.Flags().StringVarP(&verifyopts.fromMacro, "from-macro", "", "", "Tells bazeldnf to read the RPMs from a macro in the given bzl file instead of the WORKSPACE file. The expected format is: macroFile%defName")
+	verifyCmd.Flags().StringVar(&verifyopts.lockfile, "lockfile", "", "report repositories which moved on since they were recorded in this lockfile")
+	verifyCmd.Flags().StringVar(&verifyopts.keyring, "lockfile-keyring", "", "armored gpg public keys which are used to verify the detached signature of the lockfile")
 	return verifyCmd
 }
 
-func verify(rpm *bazel.RPMRule, keyring openpgp.EntityList) (err error) {
-	// Force a test. If `nil` the verification library just does no GPG check
-	if keyring == nil {
-		keyring = openpgp.EntityList{}
+func verify(getter repo.Getter, rpm *bazel.RPMRule, policy *signaturePolicy) (err error) {
+	source, keyring := policy.forRPM(rpm)
+	if source != nil && policy.getters[source.Name] != nil {
+		getter = policy.getters[source.Name]
 	}
 
 	log.Infof("Verifying %s", rpm.Name())
+	checksumType, checksum, err := rpm.Checksum()
+	if err != nil {
+		return err
+	}
 	for _, url := range rpm.URLs() {
-		sha := sha256.New()
-		resp, err := http.Get(url)
+		sha, err := repo.NewChecksumHash(checksumType)
+		if err != nil {
+			return err
+		}
+		resp, err := getter.Get(url)
 		if err != nil {
 			log.Warningf("Failed to download %s: %v", rpm.Name(), err)
 			continue
@@ -110,10 +129,10 @@ func verify(rpm *bazel.RPMRule, keyring openpgp.EntityList) (err error) {
 		}
 		defer resp.Body.Close()
 		body := io.TeeReader(resp.Body, sha)
-		_, _, verifyErr := rpmutils.Verify(body, keyring)
+		_, sigs, verifyErr := rpmutils.Verify(body, keyring)
 		var shaErr error
-		if rpm.SHA256() != toHex(sha) {
-			shaErr = fmt.Errorf("expected sha256 sum %s, but got %s", rpm.SHA256(), toHex(sha))
+		if checksum != toHex(sha) {
+			shaErr = fmt.Errorf("expected %s sum %s, but got %s", checksumType, checksum, toHex(sha))
 		}
 
 		if verifyErr != nil && shaErr != nil {
@@ -124,11 +143,125 @@ func verify(rpm *bazel.RPMRule, keyring openpgp.EntityList) (err error) {
 		} else if shaErr != nil {
 			return fmt.Errorf("the artifact is a RPM but not the right one: %v", shaErr)
 		}
+		if len(sigs) == 0 && source != nil && repo.RequiresSignedRPMs(source) {
+			return fmt.Errorf("the RPM is not signed, but gpgcheck is enabled for %s", source.Name)
+		}
 		return nil
 	}
 	return fmt.Errorf("Could not verify %s", rpm.Name())
 }
 
+// signaturePolicy decides with which keys the signatures of a RPM are checked and through which proxy it is
+// downloaded, depending on the repository it is downloaded from
+type signaturePolicy struct {
+	repos    []bazeldnf.Repository
+	keyrings map[string]openpgp.EntityList
+	// all contains the keys of all repositories, it is used for RPMs of unknown origin. It stays nil if no
+	// repository has keys, which disables the signature check.
+	all openpgp.EntityList
+	// getters contains the getters of repositories which override the proxy
+	getters map[string]repo.Getter
+}
+
+func newSignaturePolicy(getter repo.Getter, repos *bazeldnf.Repositories, cacheHelper *repo.CacheHelper) (*signaturePolicy, error) {
+	policy := &signaturePolicy{keyrings: map[string]openpgp.EntityList{}, getters: map[string]repo.Getter{}}
+	for _, r := range repos.Repositories {
+		if r.Disabled {
+			continue
+		}
+		if proxyGetter, ok := getter.(repo.ProxyGetter); ok && r.Proxy != "" {
+			proxied, err := proxyGetter.WithProxy(r.Proxy)
+			if err != nil {
+				return nil, fmt.Errorf("failed to configure proxy for %s: %v", r.Name, err)
+			}
+			policy.getters[r.Name] = proxied
+		}
+		if r.GPGKey == "" && repo.RequiresSignedRPMs(&r) {
+			return nil, fmt.Errorf("gpgcheck is enabled for %s, but no gpgkey is configured", r.Name)
+		}
+		if r.GPGKey != "" {
+			keys, err := repo.LoadGPGKeys(getter, &r)
+			if err != nil {
+				return nil, err
+			}
+			policy.keyrings[r.Name] = keys
+			policy.all = append(policy.all, keys...)
+		}
+		if err := cacheHelper.ResolveMirrors(&r); err != nil {
+			log.Debugf("Failed to resolve the mirrors of %s, its RPMs are verified with the keys of all repositories: %v", r.Name, err)
+		}
+		policy.repos = append(policy.repos, r)
+	}
+	return policy, nil
+}
+
+// forRPM returns the repository the RPM is downloaded from, or nil if it is unknown, and the keys its signatures
+// have to be made with. A nil keyring disables the signature check.
+func (p *signaturePolicy) forRPM(rpm *bazel.RPMRule) (*bazeldnf.Repository, openpgp.EntityList) {
+	for i := range p.repos {
+		source := &p.repos[i]
+		if servedBy(source, rpm.URLs()) {
+			return source, p.keyring(source)
+		}
+	}
+	return nil, p.all
+}
+
+// keyring returns the keys the signatures of RPMs of the repository have to be made with. A nil keyring disables
+// the signature check, which is the case for repositories without keys unless gpgcheck is explicitly enabled.
+func (p *signaturePolicy) keyring(source *bazeldnf.Repository) openpgp.EntityList {
+	if !repo.ChecksRPMSignatures(source) {
+		return nil
+	}
+	if keyring, exists := p.keyrings[source.Name]; exists {
+		return keyring
+	}
+	if repo.RequiresSignedRPMs(source) {
+		return p.all
+	}
+	return nil
+}
+
+// verifyLocked checks the signatures of a downloaded RPM against the policy of the repository it was locked from.
+// RPMs of repositories which are not configured are checked with the keys of all repositories.
+func (p *signaturePolicy) verifyLocked(pkg bazeldnf.LockedPackage, file string) error {
+	var source *bazeldnf.Repository
+	keyring := p.all
+	for i := range p.repos {
+		if p.repos[i].Name == pkg.Repository {
+			source = &p.repos[i]
+			keyring = p.keyring(source)
+			break
+		}
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, sigs, err := rpmutils.Verify(f, keyring)
+	if err != nil {
+		return fmt.Errorf("failed to verify the signatures of %s: %v", pkg.ID(), err)
+	}
+	if len(sigs) == 0 && source != nil && repo.RequiresSignedRPMs(source) {
+		return fmt.Errorf("the RPM %s is not signed, but gpgcheck is enabled for %s", pkg.ID(), source.Name)
+	}
+	return nil
+}
+
+// servedBy returns true if one of the URLs points to a mirror of the repository
+func servedBy(r *bazeldnf.Repository, urls []string) bool {
+	for _, mirror := range r.Mirrors {
+		prefix := strings.TrimSuffix(mirror, "/") + "/"
+		for _, u := range urls {
+			if strings.HasPrefix(u, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func toHex(hasher hash.Hash) string {
 	return hex.EncodeToString(hasher.Sum(nil))
 }
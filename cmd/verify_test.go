@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/sassoftware/go-rpmutils"
+	"golang.org/x/crypto/openpgp"
+)
+
+// signRPM signs the RPM with a new key and returns the path of the signed copy and the key
+func signRPM(t *testing.T, g *WithT, rpm string) (string, *openpgp.Entity) {
+	key, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	f, err := os.Open(rpm)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer f.Close()
+	header, err := rpmutils.SignRpmStream(f, key.PrivateKey, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	signatures, err := header.DumpSignatureHeader(false)
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = f.Seek(int64(header.OriginalSignatureHeaderSize()), io.SeekStart)
+	g.Expect(err).ToNot(HaveOccurred())
+	rest, err := io.ReadAll(f)
+	g.Expect(err).ToNot(HaveOccurred())
+	signed := filepath.Join(t.TempDir(), filepath.Base(rpm))
+	g.Expect(os.WriteFile(signed, append(signatures, rest...), 0666)).To(Succeed())
+	return signed, key
+}
+
+func TestVerifyLocked(t *testing.T) {
+	g := NewGomegaWithT(t)
+	file, key := signRPM(t, g, "testdata/simple-1.0.1-1.i386.rpm")
+	pkg := bazeldnf.LockedPackage{Name: "simple", Version: "1.0.1", Release: "1", Arch: "i386", Repository: "fedora"}
+
+	// repositories without keys and with gpgcheck unset, like the ones bazeldnf init writes, don't check signatures
+	policy, err := newSignaturePolicy(repo.NewGetter(), &bazeldnf.Repositories{Repositories: []bazeldnf.Repository{{Name: "fedora", Arch: "i386"}}}, &repo.CacheHelper{CacheDir: t.TempDir()})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(policy.verifyLocked(pkg, file)).To(Succeed())
+	pkg.Repository = "unknown"
+	g.Expect(policy.verifyLocked(pkg, file)).To(Succeed())
+	pkg.Repository = "fedora"
+
+	policy.keyrings["fedora"] = openpgp.EntityList{key}
+	policy.all = openpgp.EntityList{key}
+	g.Expect(policy.verifyLocked(pkg, file)).To(Succeed())
+
+	other, err := openpgp.NewEntity("other", "", "other@example.com", nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	policy.keyrings["fedora"] = openpgp.EntityList{other}
+	g.Expect(policy.verifyLocked(pkg, file)).To(MatchError(ContainSubstring("failed to verify the signatures of simple-0:1.0.1-1.i386")))
+
+	enforce := true
+	policy = &signaturePolicy{repos: []bazeldnf.Repository{{Name: "fedora", GPGCheck: &enforce}}, keyrings: map[string]openpgp.EntityList{}}
+	g.Expect(policy.verifyLocked(pkg, "testdata/simple-1.0.1-1.i386.rpm")).To(MatchError("the RPM simple-0:1.0.1-1.i386 is not signed, but gpgcheck is enabled for fedora"))
+}
@@ -48,6 +48,7 @@ _bazeldnf = rule(
                 "",
                 "ldd",
                 "sandbox",
+                "sysroot",
             ],
             default = "",
         ),
@@ -32,6 +32,7 @@ def _rpm_impl(ctx):
             output = "rpm/" + downloaded_file_path,
             sha256 = ctx.attr.sha256,
             integrity = ctx.attr.integrity,
+            canonical_id = ctx.attr.canonical_id,
         )
     else:
         fail("urls must be specified")
@@ -43,6 +44,7 @@ _rpm_attrs = {
     "urls": attr.string_list(),
     "sha256": attr.string(),
     "integrity": attr.string(),
+    "canonical_id": attr.string(),
 }
 
 rpm = repository_rule(
@@ -45,12 +45,17 @@ def _rpm2tar_impl(ctx):
             selinux_labels.append(k + "=" + v)
         args.add_joined("--selinux-labels", selinux_labels, join_with = ",")
 
+    outputs = [out]
+    if ctx.outputs.provenance:
+        args.add_all(["--provenance", ctx.outputs.provenance])
+        outputs.append(ctx.outputs.provenance)
+
     for rpm in ctx.files.rpms:
         args.add_all(["--input", rpm.path])
 
     ctx.actions.run(
         inputs = ctx.files.rpms,
-        outputs = [out],
+        outputs = outputs,
         arguments = [args],
         mnemonic = "Rpm2Tar",
         progress_message = "Converting %s to tar" % ctx.label.name,
@@ -85,6 +90,7 @@ _rpm2tar_attrs = {
     "capabilities": attr.string_list_dict(),
     "selinux_labels": attr.string_list_dict(),
     "out": attr.output(mandatory = True),
+    "provenance": attr.output(),
 }
 
 _tar2files_attrs = {
@@ -105,12 +111,19 @@ _tar2files = rule(
     toolchains = [BAZELDNF_TOOLCHAIN],
 )
 
-def rpmtree(name, **kwargs):
-    """Creates a tar file from a list of rpm files."""
+def rpmtree(name, provenance = False, **kwargs):
+    """Creates a tar file from a list of rpm files.
+
+    Args:
+        name: The name of the target, the tar file is called name.tar.
+        provenance: If True, also write a SLSA provenance statement for the tar file to name.provenance.json.
+        **kwargs: Additional keyword arguments to be passed to the _rpm2tar function.
+    """
     tarname = name + ".tar"
     _rpm2tar(
         name = name,
         out = tarname,
+        provenance = name + ".provenance.json" if provenance else None,
         **kwargs
     )
 
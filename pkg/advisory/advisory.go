@@ -0,0 +1,71 @@
+package advisory
+
+import (
+	"sort"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/rpm"
+)
+
+// Match is an advisory which contains a fix for a package
+type Match struct {
+	Advisory   *api.Advisory
+	Repository string
+	// Fixed is the minimum version of the package which contains the fix
+	Fixed api.Version
+}
+
+// Find returns all advisories of the given repositories which contain fixes for the named package, sorted by
+// the fixed version. If version is not nil, only advisories which are not fixed in this version are returned.
+func Find(updateinfos map[string]*api.Updateinfo, name string, arch string, version *api.Version) []Match {
+	if version != nil {
+		version = withEpoch(*version)
+	}
+	matches := []Match{}
+	for repo, updateinfo := range updateinfos {
+		for i := range updateinfo.Advisories {
+			advisory := &updateinfo.Advisories[i]
+			fixed, found := minimumFixedVersion(advisory, name, arch)
+			if !found {
+				continue
+			}
+			if version != nil && rpm.Compare(*version, fixed) >= 0 {
+				continue
+			}
+			matches = append(matches, Match{Advisory: advisory, Repository: repo, Fixed: fixed})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if c := rpm.Compare(matches[i].Fixed, matches[j].Fixed); c != 0 {
+			return c < 0
+		}
+		return matches[i].Advisory.ID < matches[j].Advisory.ID
+	})
+	return matches
+}
+
+func minimumFixedVersion(advisory *api.Advisory, name string, arch string) (fixed api.Version, found bool) {
+	for _, pkg := range advisory.Packages {
+		if pkg.Name != name {
+			continue
+		}
+		if arch != "" && pkg.Arch != arch && pkg.Arch != "noarch" {
+			continue
+		}
+		evr := withEpoch(pkg.EVR())
+		if !found || rpm.Compare(*evr, fixed) < 0 {
+			fixed = *evr
+			found = true
+		}
+	}
+	return fixed, found
+}
+
+// withEpoch returns a copy of the version with the implicit epoch 0 made explicit, so that versions compare
+// equally no matter if the epoch was omitted or not
+func withEpoch(version api.Version) *api.Version {
+	if version.Epoch == "" {
+		version.Epoch = "0"
+	}
+	return &version
+}
@@ -0,0 +1,79 @@
+package advisory
+
+import (
+	"encoding/xml"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+)
+
+const updateinfo = `<?xml version="1.0" encoding="UTF-8"?>
+<updates>
+  <update from="updates@fedoraproject.org" status="stable" type="security" version="2.0">
+    <id>FEDORA-2020-0001</id>
+    <title>bash-5.0.17-1.fc32</title>
+    <issued date="2020-05-01 00:00:00"/>
+    <severity>Important</severity>
+    <references>
+      <reference href="https://example.com/1" id="CVE-2020-0001" type="cve" title="CVE-2020-0001"/>
+      <reference href="https://example.com/2" id="123456" type="bugzilla" title="bug"/>
+    </references>
+    <pkglist>
+      <collection short="F32">
+        <package name="bash" version="5.0.17" release="1.fc32" epoch="0" arch="x86_64">
+          <filename>bash-5.0.17-1.fc32.x86_64.rpm</filename>
+        </package>
+        <package name="bash" version="5.0.17" release="1.fc32" epoch="0" arch="aarch64">
+          <filename>bash-5.0.17-1.fc32.aarch64.rpm</filename>
+        </package>
+      </collection>
+    </pkglist>
+  </update>
+  <update from="updates@fedoraproject.org" status="stable" type="bugfix" version="2.0">
+    <id>FEDORA-2020-0002</id>
+    <title>bash-5.0.18-1.fc32</title>
+    <severity>None</severity>
+    <pkglist>
+      <collection short="F32">
+        <package name="bash" version="5.0.18" release="1.fc32" epoch="0" arch="x86_64"/>
+      </collection>
+    </pkglist>
+  </update>
+  <update from="updates@fedoraproject.org" status="stable" type="security" version="2.0">
+    <id>FEDORA-2020-0003</id>
+    <pkglist>
+      <collection short="F32">
+        <package name="zsh" version="5.8" release="1.fc32" epoch="0" arch="x86_64"/>
+      </collection>
+    </pkglist>
+  </update>
+</updates>
+`
+
+func TestFind(t *testing.T) {
+	g := NewGomegaWithT(t)
+	info := &api.Updateinfo{}
+	g.Expect(xml.Unmarshal([]byte(updateinfo), info)).To(Succeed())
+	g.Expect(info.Advisories).To(HaveLen(3))
+	g.Expect(info.Advisories[0].CVEs()).To(Equal([]string{"CVE-2020-0001"}))
+	updateinfos := map[string]*api.Updateinfo{"updates": info}
+
+	ids := func(matches []Match) []string {
+		result := []string{}
+		for _, m := range matches {
+			result = append(result, m.Advisory.ID)
+		}
+		return result
+	}
+
+	g.Expect(ids(Find(updateinfos, "bash", "x86_64", nil))).To(Equal([]string{"FEDORA-2020-0001", "FEDORA-2020-0002"}))
+	g.Expect(ids(Find(updateinfos, "bash", "aarch64", nil))).To(Equal([]string{"FEDORA-2020-0001"}))
+	g.Expect(ids(Find(updateinfos, "bash", "x86_64", &api.Version{Ver: "5.0.17", Rel: "1.fc32"}))).To(Equal([]string{"FEDORA-2020-0002"}))
+	g.Expect(ids(Find(updateinfos, "bash", "x86_64", &api.Version{Ver: "5.0.16", Rel: "3.fc32"}))).To(Equal([]string{"FEDORA-2020-0001", "FEDORA-2020-0002"}))
+	g.Expect(Find(updateinfos, "bash", "x86_64", &api.Version{Ver: "5.1", Rel: "1.fc33"})).To(BeEmpty())
+
+	matches := Find(updateinfos, "bash", "x86_64", nil)
+	g.Expect(matches[0].Fixed).To(Equal(api.Version{Epoch: "0", Ver: "5.0.17", Rel: "1.fc32"}))
+	g.Expect(matches[0].Repository).To(Equal("updates"))
+}
@@ -3,13 +3,20 @@ package api
 import (
 	"encoding/xml"
 	"fmt"
+	"math"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
 )
 
 const (
-	PrimaryFileType   = "primary"
-	FilelistsFileType = "filelists"
+	PrimaryFileType    = "primary"
+	FilelistsFileType  = "filelists"
+	UpdateinfoFileType = "updateinfo"
 )
 
 type URL struct {
@@ -18,6 +25,13 @@ type URL struct {
 	Type       string `xml:"type,attr"`
 	Location   string `xml:"location,attr"`
 	Preference string `xml:"preference,attr"`
+	// Priority orders the URLs of metalink4 files, lower values are preferred
+	Priority string `xml:"priority,attr"`
+}
+
+type Hash struct {
+	Hash string `xml:",chardata"`
+	Type string `xml:"type,attr"`
 }
 
 type File struct {
@@ -28,10 +42,7 @@ type File struct {
 	Timestamp    string `xml:"timestamp"`
 	Size         string `xml:"size"`
 	Verification struct {
-		Hash []struct {
-			Hash string `xml:",chardata"`
-			Type string `xml:"type,attr"`
-		} `xml:"hash"`
+		Hash []Hash `xml:"hash"`
 	} `xml:"verification"`
 	Alternates struct {
 		Text      string        `xml:",chardata"`
@@ -45,10 +56,7 @@ type Alternative struct {
 	Size         string `xml:"size"`
 	Verification struct {
 		Text string `xml:",chardata"`
-		Hash []struct {
-			Hash string `xml:",chardata"`
-			Type string `xml:"type,attr"`
-		} `xml:"hash"`
+		Hash []Hash `xml:"hash"`
 	} `xml:"verification"`
 }
 
@@ -78,6 +86,61 @@ type Metalink struct {
 	} `xml:"files"`
 }
 
+// metalink4File is a file of a RFC 5854 metalink4 document, which lists urls and hashes directly below the file
+type metalink4File struct {
+	Name   string `xml:"name,attr"`
+	Size   string `xml:"size"`
+	Hashes []Hash `xml:"hash"`
+	URLs   []URL  `xml:"url"`
+}
+
+// UnmarshalXML accepts metalink3 documents as well as RFC 5854 metalink4 documents. Files of metalink4 documents
+// are converted to the metalink3 layout: their urls are ordered by priority and get the protocol of their
+// scheme, hash types like `sha-256` become `sha256`.
+func (m *Metalink) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	doc := struct {
+		XMLName xml.Name
+		Files   struct {
+			File []File `xml:"file"`
+		} `xml:"files"`
+		File []metalink4File `xml:"file"`
+	}{}
+	if err := d.DecodeElement(&doc, &start); err != nil {
+		return err
+	}
+	m.XMLName = doc.XMLName
+	m.Files.File = doc.Files.File
+	for _, v4 := range doc.File {
+		file := File{Name: v4.Name, Size: v4.Size}
+		for _, hash := range v4.Hashes {
+			hash.Type = strings.ReplaceAll(strings.ToLower(hash.Type), "-", "")
+			file.Verification.Hash = append(file.Verification.Hash, hash)
+		}
+		urls := append([]URL{}, v4.URLs...)
+		sort.SliceStable(urls, func(i, j int) bool {
+			return metalinkPriority(urls[i]) < metalinkPriority(urls[j])
+		})
+		for i := range urls {
+			urls[i].Text = strings.TrimSpace(urls[i].Text)
+			if u, err := url.Parse(urls[i].Text); err == nil && urls[i].Protocol == "" {
+				urls[i].Protocol = u.Scheme
+			}
+		}
+		file.Resources.URLs = urls
+		m.Files.File = append(m.Files.File, file)
+	}
+	return nil
+}
+
+// metalinkPriority returns the priority of a metalink4 url, urls without priority come last
+func metalinkPriority(u URL) int {
+	priority, err := strconv.Atoi(u.Priority)
+	if err != nil {
+		return math.MaxInt32
+	}
+	return priority
+}
+
 func (m *Metalink) Repomod() *File {
 	var repomod *File
 	for _, sec := range m.Files.File {
@@ -100,14 +163,11 @@ type Data struct {
 		Text string `xml:",chardata"`
 		Type string `xml:"type,attr"`
 	} `xml:"open-checksum"`
-	Location struct {
-		Text string `xml:",chardata"`
-		Href string `xml:"href,attr"`
-	} `xml:"location"`
-	Timestamp       string `xml:"timestamp"`
-	Size            string `xml:"size"`
-	OpenSize        string `xml:"open-size"`
-	DatabaseVersion string `xml:"database_version"`
+	Location        Location `xml:"location"`
+	Timestamp       string   `xml:"timestamp"`
+	Size            string   `xml:"size"`
+	OpenSize        string   `xml:"open-size"`
+	DatabaseVersion string   `xml:"database_version"`
 	HeaderChecksum  struct {
 		Text string `xml:",chardata"`
 		Type string `xml:"type,attr"`
@@ -211,11 +271,52 @@ type Checksum struct {
 	Pkgid string `xml:"pkgid,attr"`
 }
 
+// Algorithm returns the lowercase name of the checksum type. The legacy type "sha", which older createrepo
+// versions write, is reported as "sha1" and a missing type as "sha256".
+func (c Checksum) Algorithm() string {
+	switch algorithm := strings.ToLower(strings.TrimSpace(c.Type)); algorithm {
+	case "sha":
+		return "sha1"
+	case "":
+		return "sha256"
+	default:
+		return algorithm
+	}
+}
+
 type Location struct {
 	Text string `xml:",chardata"`
 	Href string `xml:"href,attr"`
 }
 
+// IsURL returns true if the href is a fully qualified URL. Some commercial repositories reference their files
+// like that to point to a CDN host different from the mirrors.
+func (l Location) IsURL() bool {
+	u, err := url.Parse(l.Href)
+	return err == nil && u.IsAbs() && u.Host != ""
+}
+
+// FileName returns the name of the referenced file, ignoring the query of fully qualified hrefs
+func (l Location) FileName() string {
+	if l.IsURL() {
+		u, _ := url.Parse(l.Href)
+		return path.Base(u.Path)
+	}
+	return path.Base(l.Href)
+}
+
+// URL returns the URL of the location on the given mirror, fully qualified hrefs are returned unchanged
+func (l Location) URL(mirror string) (string, error) {
+	if l.IsURL() {
+		return l.Href, nil
+	}
+	u, err := url.Parse(mirror)
+	if err != nil {
+		return "", err
+	}
+	return u.JoinPath(l.Href).String(), nil
+}
+
 type Package struct {
 	Type        string   `xml:"type,attr"`
 	Name        string   `xml:"name"`
@@ -266,6 +367,37 @@ func (p *Package) String() string {
 	return p.Name + "-" + p.Version.String()
 }
 
+// NameArch returns the name and the architecture of the package like glibc.i686, which tells multilib packages
+// apart from the native packages of the same name
+func (p *Package) NameArch() string {
+	return p.Name + "." + p.Arch
+}
+
+// ID returns the name, version and architecture of the package like glibc-0:2.39-1.fc40.i686
+func (p *Package) ID() string {
+	return p.String() + "." + p.Arch
+}
+
+// URLs returns the download URLs of the package on all mirrors of its repository. Packages with a fully qualified
+// location are only available from that single URL.
+func (p *Package) URLs() ([]string, error) {
+	if p.Location.IsURL() {
+		return []string{p.Location.Href}, nil
+	}
+	urls := []string{}
+	if p.Repository == nil {
+		return urls, nil
+	}
+	for _, mirror := range p.Repository.Mirrors {
+		u, err := p.Location.URL(mirror)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, u)
+	}
+	return urls, nil
+}
+
 type Repository struct {
 	XMLName      xml.Name  `xml:"metadata"`
 	Text         string    `xml:",chardata"`
@@ -0,0 +1,75 @@
+package bazeldnf
+
+import "fmt"
+
+// Lockfile records the packages of all rpmtrees together with the state of the repositories they were
+// resolved from.
+type Lockfile struct {
+	// Version of the lockfile format, older versions are migrated when the lockfile is read
+	Version int `json:"version"`
+	// Name of the proxy repository which the bzlmod extension creates for the lockfile, defaults to the file name
+	Name         string             `json:"name,omitempty"`
+	Repositories []LockedRepository `json:"repositories"`
+	// Trees maps rpmtree names to the IDs of their packages
+	Trees    map[string][]string `json:"trees,omitempty"`
+	Packages []LockedPackage     `json:"packages"`
+	// Snapshot is the date of the repository snapshots the packages were resolved from, if they were pinned
+	Snapshot string `json:"snapshot,omitempty"`
+	// Digest protects the content of the lockfile against manual edits and merge damage
+	Digest string `json:"digest,omitempty"`
+}
+
+// LockedRepository identifies the snapshot of a repository by the content of its repomd.xml file
+type LockedRepository struct {
+	Name     string `json:"name"`
+	Revision string `json:"revision,omitempty"`
+	// Timestamp is the newest timestamp of all metadata files referenced by repomd.xml
+	Timestamp int64        `json:"timestamp,omitempty"`
+	Data      []LockedData `json:"data,omitempty"`
+}
+
+// LockedData is a metadata file referenced by repomd.xml
+type LockedData struct {
+	Type string `json:"type"`
+	// Checksum is prefixed with its algorithm, e.g. `sha256:...`
+	Checksum string `json:"checksum"`
+}
+
+// SameSnapshot returns true if both repositories describe the same repomd.xml content
+func (r *LockedRepository) SameSnapshot(other *LockedRepository) bool {
+	if r.Revision != other.Revision || r.Timestamp != other.Timestamp || len(r.Data) != len(other.Data) {
+		return false
+	}
+	for i := range r.Data {
+		if r.Data[i] != other.Data[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type LockedPackage struct {
+	Name       string `json:"name"`
+	Epoch      string `json:"epoch,omitempty"`
+	Version    string `json:"version"`
+	Release    string `json:"release"`
+	Arch       string `json:"arch"`
+	Repository string `json:"repository"`
+	// Checksum is prefixed with its algorithm, e.g. `sha256:...`
+	Checksum string   `json:"checksum"`
+	URLs     []string `json:"urls"`
+	// Alias is the name of the bazel repository of the RPM, if it differs from the sanitized ID. It is kept when
+	// bzlmod lock files with custom names are migrated.
+	Alias string `json:"alias,omitempty"`
+	// Size is the download size of the RPM in bytes
+	Size int64 `json:"size,omitempty"`
+}
+
+// ID returns the name-epoch:version-release.arch string which identifies the package
+func (p *LockedPackage) ID() string {
+	epoch := p.Epoch
+	if epoch == "" {
+		epoch = "0"
+	}
+	return fmt.Sprintf("%s-%s:%s-%s.%s", p.Name, epoch, p.Version, p.Release, p.Arch)
+}
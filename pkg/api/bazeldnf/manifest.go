@@ -0,0 +1,61 @@
+package bazeldnf
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Manifest lists the packages which should be resolved, so that the intended dependency set can be reviewed
+// like any other source file.
+type Manifest struct {
+	// Packages are requested for every target of the manifest
+	Packages []ManifestPackage `json:"packages,omitempty"`
+	// Targets maps rpmtree names to the packages which are requested only for them
+	Targets map[string]ManifestTarget `json:"targets,omitempty"`
+}
+
+// ManifestTarget contains the packages of a single rpmtree
+type ManifestTarget struct {
+	Packages []ManifestPackage `json:"packages"`
+}
+
+// ManifestPackage is a requested package with optional annotations. In manifest files it can be written as a
+// plain string if it has no annotations.
+type ManifestPackage struct {
+	Name string `json:"name"`
+	PackageAnnotations
+}
+
+// PackageAnnotations explain why a package is requested, they are written as comments to the generated bazel
+// files
+type PackageAnnotations struct {
+	Owner  string   `json:"owner,omitempty"`
+	Reason string   `json:"reason,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+}
+
+// Empty returns true if no annotation is set
+func (a PackageAnnotations) Empty() bool {
+	return a.Owner == "" && a.Reason == "" && len(a.Tags) == 0
+}
+
+func (p *ManifestPackage) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*p = ManifestPackage{Name: name}
+		return nil
+	}
+	// manifests are decoded strictly, which a plain json.Unmarshal would undo for the package objects
+	type plain ManifestPackage
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode((*plain)(p))
+}
+
+func (p ManifestPackage) MarshalJSON() ([]byte, error) {
+	if p.PackageAnnotations.Empty() {
+		return json.Marshal(p.Name)
+	}
+	type plain ManifestPackage
+	return json.Marshal(plain(p))
+}
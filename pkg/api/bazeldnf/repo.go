@@ -1,15 +1,142 @@
 package bazeldnf
 
+import (
+	"encoding/json"
+	"path"
+)
+
 type Repositories struct {
+	// Version of the repository file format
+	Version      int          `json:"version,omitempty"`
 	Repositories []Repository `json:"repositories"`
+	// Preferences maps capabilities to the package which should provide them, e.g. `curl: curl-minimal`
+	Preferences map[string]string `json:"preferences,omitempty"`
+	// CacheDir is the directory where repository metadata is cached
+	CacheDir string `json:"cacheDir,omitempty"`
+	// DistroRepos references repositories of the built-in catalog, e.g. `fedora-41` or `centos-stream-9/x86_64`
+	DistroRepos []string `json:"distroRepos,omitempty"`
+	// AWSRegion selects the region-local mirrors of Amazon Linux 2 distroRepos, e.g. `eu-central-1`
+	AWSRegion string `json:"awsRegion,omitempty"`
+	// IgnoreWeakDeps lists weak dependencies which are not pulled in when weak dependencies are enabled
+	IgnoreWeakDeps []WeakDepsFilter `json:"ignoreWeakDeps,omitempty"`
 }
 
 type Repository struct {
-	Name     string   `json:"name"`
-	Disabled bool     `json:"disabled,omitempty"`
-	Metalink string   `json:"metalink,omitempty"`
-	Baseurl  string   `json:"baseurl,omitempty"`
-	Arch     string   `json:"arch"`
-	Mirrors  []string `json:"mirrors,omitempty"`
-	GPGKey   string   `json:"gpgkey,omitempty"`
+	Name     string `json:"name"`
+	Disabled bool   `json:"disabled,omitempty"`
+	Metalink string `json:"metalink,omitempty"`
+	// Mirrorlist is a URL returning a plain list of baseurls, one per line, like the mirror.list of Amazon Linux
+	Mirrorlist string `json:"mirrorlist,omitempty"`
+	Baseurl    URLs   `json:"baseurl,omitempty"`
+	// Arch is the target architecture the repository is used for. Repositories with `noarch` only carry noarch
+	// packages and are used for every target architecture.
+	Arch string `json:"arch"`
+	// Arches lists further target architectures the repository is used for, e.g. for repositories which keep
+	// the packages of all architectures in one tree
+	Arches  []string `json:"arches,omitempty"`
+	Mirrors []string `json:"mirrors,omitempty"`
+	GPGKey  string   `json:"gpgkey,omitempty"`
+	// GPGCheck enforces like in dnf that all RPMs of the repository are signed with one of the keys of GPGKey, false
+	// disables the signature check. If it is not set, signatures are checked if the RPMs have some.
+	GPGCheck *bool `json:"gpgcheck,omitempty"`
+	// RepoGPGCheck enforces like in dnf that repomd.xml is signed with one of the keys of GPGKey by verifying the
+	// detached signature repodata/repomd.xml.asc
+	RepoGPGCheck bool `json:"repo_gpgcheck,omitempty"`
+	// Proxy overrides the proxy from the environment for this repository, `none` connects directly
+	Proxy string `json:"proxy,omitempty"`
+	// MetalinkFilter restricts and orders the mirrors taken from the metalink file
+	MetalinkFilter *MetalinkFilter `json:"metalinkFilter,omitempty"`
+	// Snapshot is the baseurl of date-pinned snapshots of the repository which is used with --snapshot. $snapshot
+	// is replaced with the date like `2024-11-01`, $year, $month and $day with its parts.
+	Snapshot string `json:"snapshot,omitempty"`
+	// Koji turns the repository into a repository of the RPMs of specific Koji builds
+	Koji *KojiSource `json:"koji,omitempty"`
+}
+
+// KojiSource references builds of a Koji build system, e.g. builds of Fedora which are not released yet.
+type KojiSource struct {
+	// Hub is the URL of the Koji hub API, defaults to https://koji.fedoraproject.org/kojihub
+	Hub string `json:"hub,omitempty"`
+	// TopURL is the URL of the Koji package storage, defaults to https://kojipkgs.fedoraproject.org
+	TopURL string `json:"topurl,omitempty"`
+	// Builds lists the NVRs of the builds, e.g. `bash-5.2.26-1.fc40`. A `koji:` prefix is ignored.
+	Builds []string `json:"builds"`
+}
+
+// MetalinkFilter selects which mirrors listed in a metalink file are tried and in which order.
+type MetalinkFilter struct {
+	// Countries restricts mirrors to the given ISO country codes, e.g. `DE`
+	Countries []string `json:"countries,omitempty"`
+	// Protocols restricts mirrors to the given protocols, defaults to `https`
+	Protocols []string `json:"protocols,omitempty"`
+	// MaxMirrors limits the number of mirrors which are tried
+	MaxMirrors int `json:"maxMirrors,omitempty"`
+	// Prefer contains regular expressions, mirrors matching them are tried first
+	Prefer []string `json:"prefer,omitempty"`
+}
+
+// WeakDepsFilter ignores weak dependencies of packages, e.g. all recommendations of `systemd` matching `systemd-*`.
+type WeakDepsFilter struct {
+	// Package is a glob matching the names of the packages whose weak dependencies are ignored
+	Package string `json:"package"`
+	// Recommends contains globs matching the ignored capabilities, all weak dependencies are ignored if it is empty
+	Recommends []string `json:"recommends,omitempty"`
+}
+
+// ServesArch returns true if the repository is used when resolving for the target architecture
+func (r *Repository) ServesArch(arch string) bool {
+	if r.Arch == arch || r.Arch == "noarch" {
+		return true
+	}
+	for _, a := range r.Arches {
+		if a == arch {
+			return true
+		}
+	}
+	return false
+}
+
+// Ignores returns true if the filter ignores the weak dependency of the package on the capability. The globs are
+// validated when repository files are loaded.
+func (f WeakDepsFilter) Ignores(pkg string, capability string) bool {
+	if match, _ := path.Match(f.Package, pkg); !match {
+		return false
+	}
+	if len(f.Recommends) == 0 {
+		return true
+	}
+	for _, glob := range f.Recommends {
+		if match, _ := path.Match(glob, capability); match {
+			return true
+		}
+	}
+	return false
+}
+
+// URLs is a list of URLs which are tried in order. In configuration files it can be written as a single string
+// or as a list.
+type URLs []string
+
+func (u *URLs) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*u = URLs{single}
+		if single == "" {
+			*u = nil
+		}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*u = list
+	return nil
+}
+
+func (u URLs) MarshalJSON() ([]byte, error) {
+	if len(u) == 1 {
+		return json.Marshal(u[0])
+	}
+	return json.Marshal([]string(u))
 }
@@ -0,0 +1,17 @@
+package bazeldnf
+
+// RewriteConfig contains URL rewrite rules which are applied to all mirror and package URLs, e.g. to redirect
+// all downloads to an internal mirror
+type RewriteConfig struct {
+	Rewrites []RewriteRule `json:"rewrites"`
+}
+
+// RewriteRule replaces either a URL prefix or a regular expression match. Rules are tried in order and the first
+// matching one is applied.
+type RewriteRule struct {
+	// Prefix is replaced with the replacement if a URL starts with it
+	Prefix string `json:"prefix,omitempty"`
+	// Regex is replaced with the replacement, which can reference submatches like `$1`
+	Regex       string `json:"regex,omitempty"`
+	Replacement string `json:"replacement"`
+}
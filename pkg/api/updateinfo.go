@@ -0,0 +1,54 @@
+package api
+
+import "encoding/xml"
+
+// Updateinfo contains the advisories of a repository
+type Updateinfo struct {
+	XMLName    xml.Name   `xml:"updates"`
+	Advisories []Advisory `xml:"update"`
+}
+
+type Advisory struct {
+	Type     string `xml:"type,attr"`
+	Status   string `xml:"status,attr"`
+	ID       string `xml:"id"`
+	Title    string `xml:"title"`
+	Severity string `xml:"severity"`
+	Issued   struct {
+		Date string `xml:"date,attr"`
+	} `xml:"issued"`
+	References []Reference       `xml:"references>reference"`
+	Packages   []AdvisoryPackage `xml:"pkglist>collection>package"`
+}
+
+type Reference struct {
+	Href  string `xml:"href,attr"`
+	ID    string `xml:"id,attr"`
+	Type  string `xml:"type,attr"`
+	Title string `xml:"title,attr"`
+}
+
+// AdvisoryPackage is a package which fixes the issues of an advisory
+type AdvisoryPackage struct {
+	Name     string `xml:"name,attr"`
+	Epoch    string `xml:"epoch,attr"`
+	Version  string `xml:"version,attr"`
+	Release  string `xml:"release,attr"`
+	Arch     string `xml:"arch,attr"`
+	Filename string `xml:"filename"`
+}
+
+func (p *AdvisoryPackage) EVR() Version {
+	return Version{Epoch: p.Epoch, Ver: p.Version, Rel: p.Release}
+}
+
+// CVEs returns the ids of all CVEs referenced by the advisory
+func (a *Advisory) CVEs() []string {
+	cves := []string{}
+	for _, ref := range a.References {
+		if ref.Type == "cve" {
+			cves = append(cves, ref.ID)
+		}
+	}
+	return cves
+}
@@ -0,0 +1,95 @@
+package bazel
+
+import (
+	"strings"
+
+	"github.com/bazelbuild/buildtools/build"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+// annotationPrefixes identify the comments which were written for annotations
+var annotationPrefixes = []string{"# owner: ", "# reason: ", "# tags: "}
+
+// AnnotateRPMs writes the annotations of the packages as comments above their rpm rules. Annotation comments of
+// the packages which were written before are replaced, other comments are kept.
+func AnnotateRPMs(rpms []*RPMRule, pkgs []*api.Package, arch string, annotations map[string]bazeldnf.PackageAnnotations) {
+	byLabel := map[string]*bazeldnf.PackageAnnotations{}
+	for _, pkg := range pkgs {
+		var annotation *bazeldnf.PackageAnnotations
+		if a, exists := annotations[pkg.Name]; exists {
+			annotation = &a
+		}
+		byLabel[RPMLabel(pkg, arch)] = annotation
+	}
+	for _, rule := range rpms {
+		annotation, exists := byLabel["@"+rule.Name()+"//rpm"]
+		if !exists {
+			continue
+		}
+		comments := []build.Comment{}
+		for _, comment := range rule.Call.Comments.Before {
+			if !isAnnotation(comment.Token) {
+				comments = append(comments, comment)
+			}
+		}
+		if annotation != nil {
+			for _, line := range annotationLines(*annotation) {
+				comments = append(comments, build.Comment{Token: "# " + line})
+			}
+		}
+		rule.Call.Comments.Before = comments
+	}
+}
+
+// AnnotateTree adds the annotations of the packages as comments to their entries in the rpmtree rule
+func AnnotateTree(buildfile *build.File, name string, pkgs []*api.Package, arch string, annotations map[string]bazeldnf.PackageAnnotations) {
+	byLabel := map[string]bazeldnf.PackageAnnotations{}
+	for _, pkg := range pkgs {
+		if annotation, exists := annotations[pkg.Name]; exists {
+			byLabel[RPMLabel(pkg, arch)] = annotation
+		}
+	}
+	for _, rule := range buildfile.Rules("rpmtree") {
+		if rule.Name() != name {
+			continue
+		}
+		list, ok := rule.Attr("rpms").(*build.ListExpr)
+		if !ok {
+			return
+		}
+		for _, expr := range list.List {
+			label, ok := expr.(*build.StringExpr)
+			if !ok {
+				continue
+			}
+			if annotation, exists := byLabel[label.Value]; exists {
+				label.Comments.Suffix = []build.Comment{{Token: "# " + strings.Join(annotationLines(annotation), "; ")}}
+				list.ForceMultiLine = true
+			}
+		}
+	}
+}
+
+func annotationLines(annotation bazeldnf.PackageAnnotations) []string {
+	lines := []string{}
+	if annotation.Owner != "" {
+		lines = append(lines, "owner: "+annotation.Owner)
+	}
+	if annotation.Reason != "" {
+		lines = append(lines, "reason: "+annotation.Reason)
+	}
+	if len(annotation.Tags) > 0 {
+		lines = append(lines, "tags: "+strings.Join(annotation.Tags, ", "))
+	}
+	return lines
+}
+
+func isAnnotation(comment string) bool {
+	for _, prefix := range annotationPrefixes {
+		if strings.HasPrefix(comment, prefix) {
+			return true
+		}
+	}
+	return false
+}
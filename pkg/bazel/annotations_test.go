@@ -0,0 +1,64 @@
+package bazel
+
+import (
+	"testing"
+
+	"github.com/bazelbuild/buildtools/build"
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func TestAnnotations(t *testing.T) {
+	g := NewGomegaWithT(t)
+	workspace, err := build.ParseWorkspace("WORKSPACE", []byte(`# keep me
+# reason: outdated
+rpm(
+    name = "a-0__1.2.3.x86_64",
+    sha256 = "1234",
+    urls = ["https://example.com/something/a"],
+)
+`))
+	g.Expect(err).ToNot(HaveOccurred())
+	buildfile, err := build.ParseBuild("BUILD.bazel", []byte{})
+	g.Expect(err).ToNot(HaveOccurred())
+	pkgs := []*api.Package{
+		newPkg("a", "1.2.3", repo("a", []string{"https://example.com"})),
+		newPkg("b", "2.3.4", repo("a", []string{"https://example.com"})),
+	}
+	annotations := map[string]bazeldnf.PackageAnnotations{
+		"a": {Owner: "team-a", Reason: "needed by the entrypoint", Tags: []string{"shell", "debug"}},
+	}
+	g.Expect(AddWorkspaceRPMs(workspace, pkgs, "x86_64")).To(Succeed())
+	AnnotateRPMs(GetWorkspaceRPMs(workspace), pkgs, "x86_64", annotations)
+	AnnotateRPMs(GetWorkspaceRPMs(workspace), pkgs, "x86_64", annotations)
+	AddTree("tree", buildfile, pkgs, "x86_64", false)
+	AnnotateTree(buildfile, "tree", pkgs, "x86_64", annotations)
+
+	g.Expect(build.FormatString(workspace)).To(Equal(`# keep me
+# owner: team-a
+# reason: needed by the entrypoint
+# tags: shell, debug
+rpm(
+    name = "a-0__1.2.3.x86_64",
+    sha256 = "1234",
+    urls = ["https://example.com/something/a"],
+)
+
+rpm(
+    name = "b-0__2.3.4.x86_64",
+    urls = [
+        "https://example.com/something/b",
+    ],
+    sha256 = "1234",
+)
+`))
+	g.Expect(build.FormatString(buildfile)).To(Equal(`rpmtree(
+    name = "tree",
+    rpms = [
+        "@a-0__1.2.3.x86_64//rpm",  # owner: team-a; reason: needed by the entrypoint; tags: shell, debug
+        "@b-0__2.3.4.x86_64//rpm",
+    ],
+)
+`))
+}
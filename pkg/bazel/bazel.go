@@ -1,9 +1,10 @@
 package bazel
 
 import (
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
-	"net/url"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -126,13 +127,15 @@ func AddWorkspaceRPMs(workspace *build.File, pkgs []*api.Package, arch string) e
 		// Configure/re-configure the URLs when
 		// 1) no URLs are set, or
 		// 2) the checksum changed.
-		if len(urls) == 0 || (rule.SHA256() != pkg.Checksum.Text) {
-			err := rule.SetURLs(pkg.Repository.Mirrors, pkg.Location.Href)
+		if len(urls) == 0 || !rule.HasChecksum(pkg.Checksum) {
+			err := rule.SetURLs(pkg)
 			if err != nil {
 				return err
 			}
 		}
-		rule.SetSHA256(pkg.Checksum.Text)
+		if err := rule.SetChecksum(pkg.Checksum); err != nil {
+			return fmt.Errorf("failed to set checksum of %s: %v", pkgName, err)
+		}
 	}
 
 	rules := []*RPMRule{}
@@ -176,10 +179,12 @@ func AddBzlfileRPMs(bzlfile *build.File, defName string, pkgs []*api.Package, ar
 			rpms[pkgName] = rule
 		}
 		rule.SetName(pkgName)
-		rule.SetSHA256(pkg.Checksum.Text)
+		if err := rule.SetChecksum(pkg.Checksum); err != nil {
+			return fmt.Errorf("failed to set checksum of %s: %v", pkgName, err)
+		}
 		urls := rule.URLs()
 		if len(urls) == 0 {
-			err := rule.SetURLs(pkg.Repository.Mirrors, pkg.Location.Href)
+			err := rule.SetURLs(pkg)
 			if err != nil {
 				return err
 			}
@@ -268,8 +273,7 @@ func AddTree(name string, buildfile *build.File, pkgs []*api.Package, arch strin
 
 	rpms := []string{}
 	for _, pkg := range pkgs {
-		pkgName := sanitize(pkg.String() + "." + arch)
-		rpms = append(rpms, "@"+pkgName+"//rpm")
+		rpms = append(rpms, RPMLabel(pkg, arch))
 	}
 	sort.SliceStable(rpms, func(i, j int) bool {
 		return rpms[i] < rpms[j]
@@ -301,6 +305,36 @@ func AddTree(name string, buildfile *build.File, pkgs []*api.Package, arch strin
 	}
 }
 
+// GetTreeRPMs returns the rpm labels referenced by the rpmtree rule with the given name
+func GetTreeRPMs(buildfile *build.File, name string) []string {
+	for _, rule := range buildfile.Rules("rpmtree") {
+		if rule.Name() == name {
+			return (&rpmTree{rule}).RPMs()
+		}
+	}
+	return nil
+}
+
+// RPMLabel returns the label under which the rpm rule of the given package can be referenced
+func RPMLabel(pkg *api.Package, arch string) string {
+	return "@" + sanitize(pkg.String()+"."+arch) + "//rpm"
+}
+
+// ParseRPMLabel extracts the package name and version from a label like `@bash-0__5.0.17-1.fc32.x86_64//rpm`.
+func ParseRPMLabel(label string, arch string) (name string, version string, err error) {
+	repoName := strings.TrimSuffix(strings.TrimPrefix(label, "@"), "//rpm")
+	repoName = strings.TrimSuffix(repoName, "."+arch)
+	relSep := strings.LastIndex(repoName, "-")
+	if relSep < 0 {
+		return "", "", fmt.Errorf("invalid rpm label %s", label)
+	}
+	verSep := strings.LastIndex(repoName[:relSep], "-")
+	if verSep < 0 {
+		return "", "", fmt.Errorf("invalid rpm label %s", label)
+	}
+	return unsanitize(repoName[:verSep]), unsanitize(repoName[verSep+1:]), nil
+}
+
 func PruneWorkspaceRPMs(buildfile *build.File, workspace *build.File) {
 	referenced := map[string]struct{}{}
 	for _, pkg := range buildfile.Rules("rpmtree") {
@@ -407,15 +441,16 @@ func (r *RPMRule) URLs() []string {
 	return nil
 }
 
-func (r *RPMRule) SetURLs(mirrors []string, href string) error {
+// SetURLs sets the download URLs of the package, which are either located on the mirrors of its repository or,
+// for fully qualified locations, on the host the location points to
+func (r *RPMRule) SetURLs(pkg *api.Package) error {
+	urls, err := pkg.URLs()
+	if err != nil {
+		return err
+	}
 	urlsAttr := []build.Expr{}
-	for _, mirror := range mirrors {
-		u, err := url.Parse(mirror)
-		if err != nil {
-			return err
-		}
-		u = u.JoinPath(href)
-		urlsAttr = append(urlsAttr, &build.StringExpr{Value: u.String()})
+	for _, u := range urls {
+		urlsAttr = append(urlsAttr, &build.StringExpr{Value: u})
 	}
 	r.Rule.SetAttr("urls", &build.ListExpr{List: urlsAttr, ForceMultiLine: true})
 	return nil
@@ -433,6 +468,74 @@ func (r *RPMRule) SHA256() string {
 	return r.Rule.AttrString("sha256")
 }
 
+// Integrity returns the subresource integrity value of the rule, e.g. `sha512-<base64>`
+func (r *RPMRule) Integrity() string {
+	return r.Rule.AttrString("integrity")
+}
+
+// SetChecksum sets the sha256 attribute for sha256 checksums. Other checksum types which bazel can verify are
+// written as subresource integrity value to the integrity attribute instead. Only one of both attributes is kept.
+func (r *RPMRule) SetChecksum(checksum api.Checksum) error {
+	algorithm := checksum.Algorithm()
+	sum := strings.TrimSpace(checksum.Text)
+	if algorithm == "sha256" {
+		r.Rule.DelAttr("integrity")
+		r.SetSHA256(sum)
+		return nil
+	}
+	integrity, err := toIntegrity(algorithm, sum)
+	if err != nil {
+		return err
+	}
+	r.Rule.DelAttr("sha256")
+	r.Rule.SetAttr("integrity", &build.StringExpr{Value: integrity})
+	return nil
+}
+
+// HasChecksum returns true if the rule is already configured with the given checksum
+func (r *RPMRule) HasChecksum(checksum api.Checksum) bool {
+	algorithm, sum, err := r.Checksum()
+	if err != nil {
+		return false
+	}
+	return algorithm == checksum.Algorithm() && sum == strings.ToLower(strings.TrimSpace(checksum.Text))
+}
+
+// Checksum returns the algorithm and the hex encoded sum the rule is verified with, taken either from the sha256
+// or from the integrity attribute
+func (r *RPMRule) Checksum() (algorithm string, sum string, err error) {
+	if sha256 := r.SHA256(); sha256 != "" {
+		return "sha256", strings.ToLower(sha256), nil
+	}
+	integrity := r.Integrity()
+	if integrity == "" {
+		return "", "", fmt.Errorf("rpm %s has neither a sha256 nor an integrity attribute", r.Name())
+	}
+	algorithm, encoded, found := strings.Cut(integrity, "-")
+	if !found {
+		return "", "", fmt.Errorf("rpm %s has an invalid integrity %s", r.Name(), integrity)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", fmt.Errorf("rpm %s has an invalid integrity %s: %v", r.Name(), integrity, err)
+	}
+	return algorithm, hex.EncodeToString(decoded), nil
+}
+
+// toIntegrity converts a hex encoded checksum to a subresource integrity value
+func toIntegrity(algorithm string, sum string) (string, error) {
+	switch algorithm {
+	case "sha1", "sha256", "sha384", "sha512":
+	default:
+		return "", fmt.Errorf("checksum type %s is not supported by bazel, use --rehash-sha256 to record sha256 sums instead", algorithm)
+	}
+	decoded, err := hex.DecodeString(sum)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s checksum %s: %v", algorithm, sum, err)
+	}
+	return algorithm + "-" + base64.StdEncoding.EncodeToString(decoded), nil
+}
+
 type rpmTree struct {
 	*build.Rule
 }
@@ -493,3 +596,11 @@ func sanitize(name string) string {
 	name = strings.ReplaceAll(name, "^", "__caret__")
 	return name
 }
+
+func unsanitize(name string) string {
+	name = strings.ReplaceAll(name, "__plus__", "+")
+	name = strings.ReplaceAll(name, "__tilde__", "~")
+	name = strings.ReplaceAll(name, "__caret__", "^")
+	name = strings.ReplaceAll(name, "__", ":")
+	return name
+}
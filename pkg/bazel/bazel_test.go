@@ -6,6 +6,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/bazelbuild/buildtools/build"
 	. "github.com/onsi/gomega"
 	"github.com/rmohr/bazeldnf/pkg/api"
 	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
@@ -129,6 +130,20 @@ func TestBuildfileWithRPMs(t *testing.T) {
 	}
 }
 
+func TestParseRPMLabel(t *testing.T) {
+	g := NewGomegaWithT(t)
+	pkg := newPkg("libstdc++", "1.2.3~rc1", nil)
+	pkg.Version.Epoch = "2"
+	pkg.Version.Rel = "4.fc32"
+	name, version, err := ParseRPMLabel(RPMLabel(pkg, "myarch"), "myarch")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(name).To(Equal("libstdc++"))
+	g.Expect(version).To(Equal("2:1.2.3~rc1-4.fc32"))
+
+	_, _, err = ParseRPMLabel("@invalid//rpm", "myarch")
+	g.Expect(err).To(HaveOccurred())
+}
+
 func newPkg(name string, version string, repository *bazeldnf.Repository) *api.Package {
 	pkg := &api.Package{}
 	pkg.Name = name
@@ -145,3 +160,44 @@ func repo(name string, urls []string) *bazeldnf.Repository {
 		Mirrors: urls,
 	}
 }
+
+func TestRPMRuleChecksum(t *testing.T) {
+	g := NewGomegaWithT(t)
+	rule := &RPMRule{&build.Rule{Call: &build.CallExpr{X: &build.Ident{Name: "rpm"}}}}
+	rule.SetName("bash")
+
+	sha1 := api.Checksum{Type: "sha", Text: "2aae6c35c94fcfb415dbe95f408b9ce91ee846ed"}
+	g.Expect(rule.SetChecksum(sha1)).To(Succeed())
+	g.Expect(rule.SHA256()).To(BeEmpty())
+	g.Expect(rule.Integrity()).To(Equal("sha1-Kq5sNclPz7QV2+lfQIuc6R7oRu0="))
+	g.Expect(rule.HasChecksum(sha1)).To(BeTrue())
+	algorithm, sum, err := rule.Checksum()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(algorithm).To(Equal("sha1"))
+	g.Expect(sum).To(Equal(sha1.Text))
+
+	sha256 := api.Checksum{Type: "sha256", Text: "1234"}
+	g.Expect(rule.HasChecksum(sha256)).To(BeFalse())
+	g.Expect(rule.SetChecksum(sha256)).To(Succeed())
+	g.Expect(rule.SHA256()).To(Equal("1234"))
+	g.Expect(rule.Integrity()).To(BeEmpty())
+	g.Expect(rule.HasChecksum(sha256)).To(BeTrue())
+
+	g.Expect(rule.SetChecksum(api.Checksum{Type: "sha224", Text: "1234"})).To(MatchError(ContainSubstring("--rehash-sha256")))
+}
+
+func TestRPMRuleURLs(t *testing.T) {
+	g := NewGomegaWithT(t)
+	rule := &RPMRule{&build.Rule{Call: &build.CallExpr{X: &build.Ident{Name: "rpm"}}}}
+	pkg := newPkg("bash", "5.0", repo("fedora", []string{"https://a.example.com/fedora/", "https://b.example.com/fedora"}))
+
+	g.Expect(rule.SetURLs(pkg)).To(Succeed())
+	g.Expect(rule.URLs()).To(Equal([]string{
+		"https://a.example.com/fedora/something/bash",
+		"https://b.example.com/fedora/something/bash",
+	}))
+
+	pkg.Location.Href = "https://cdn.example.com/signed/bash-5.0.rpm?token=abc"
+	g.Expect(rule.SetURLs(pkg)).To(Succeed())
+	g.Expect(rule.URLs()).To(Equal([]string{"https://cdn.example.com/signed/bash-5.0.rpm?token=abc"}))
+}
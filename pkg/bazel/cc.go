@@ -0,0 +1,137 @@
+package bazel
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/buildtools/build"
+	"github.com/bazelbuild/buildtools/edit"
+)
+
+// CCImport describes a prebuilt library which can be consumed by cc rules via cc_import
+type CCImport struct {
+	Name          string
+	SharedLibrary string
+	StaticLibrary string
+}
+
+// GroupLibraries groups shared and static library files by their library name. The shared library which is
+// preferred is the unversioned development symlink, and otherwise the one with the shortest name, which
+// usually is the soname.
+func GroupLibraries(files []string) map[string]*CCImport {
+	libs := map[string]*CCImport{}
+	for _, file := range files {
+		base := filepath.Base(file)
+		if !strings.HasPrefix(base, "lib") {
+			continue
+		}
+		var libName string
+		static := false
+		if strings.HasSuffix(base, ".a") {
+			libName = strings.TrimSuffix(base, ".a")
+			static = true
+		} else if idx := strings.Index(base, ".so"); idx > 0 && (len(base) == idx+3 || base[idx+3] == '.') {
+			libName = base[:idx]
+		} else {
+			continue
+		}
+		lib := libs[libName]
+		if lib == nil {
+			lib = &CCImport{Name: libName}
+			libs[libName] = lib
+		}
+		if static {
+			lib.StaticLibrary = file
+		} else if lib.SharedLibrary == "" || len(filepath.Base(file)) < len(filepath.Base(lib.SharedLibrary)) {
+			lib.SharedLibrary = file
+		}
+	}
+	return libs
+}
+
+// AddCCImports creates or updates cc_import rules for the given libraries. Library paths are expected to be
+// labels.
+func AddCCImports(buildfile *build.File, imports []*CCImport, public bool) {
+	sort.SliceStable(imports, func(i, j int) bool {
+		return imports[i].Name < imports[j].Name
+	})
+	for _, imp := range imports {
+		rule := findOrAddRule(buildfile, "cc_import", imp.Name)
+		rule.DelAttr("shared_library")
+		rule.DelAttr("static_library")
+		if imp.SharedLibrary != "" {
+			rule.SetAttr("shared_library", &build.StringExpr{Value: imp.SharedLibrary})
+		}
+		if imp.StaticLibrary != "" {
+			rule.SetAttr("static_library", &build.StringExpr{Value: imp.StaticLibrary})
+		}
+		setVisibility(rule, public)
+	}
+}
+
+// CCLibrary describes a header-only cc_library which exposes the headers and link flags of prebuilt libraries
+type CCLibrary struct {
+	Name     string
+	Hdrs     []string
+	Includes []string
+	Defines  []string
+	Linkopts []string
+	Deps     []string
+}
+
+// AddCCLibrary creates or updates a cc_library rule. Attributes without values are removed.
+func AddCCLibrary(buildfile *build.File, lib CCLibrary, public bool) {
+	rule := findOrAddRule(buildfile, "cc_library", lib.Name)
+	for _, attr := range []struct {
+		name   string
+		values []string
+	}{
+		{"hdrs", lib.Hdrs},
+		{"includes", lib.Includes},
+		{"defines", lib.Defines},
+		{"linkopts", lib.Linkopts},
+		{"deps", lib.Deps},
+	} {
+		if len(attr.values) == 0 {
+			rule.DelAttr(attr.name)
+		} else {
+			rule.SetAttr(attr.name, stringList(attr.values))
+		}
+	}
+	setVisibility(rule, public)
+}
+
+// AddFilegroup creates or updates a filegroup rule with the given srcs
+func AddFilegroup(buildfile *build.File, name string, srcs []string, public bool) {
+	rule := findOrAddRule(buildfile, "filegroup", name)
+	rule.SetAttr("srcs", stringList(srcs))
+	setVisibility(rule, public)
+}
+
+func findOrAddRule(buildfile *build.File, kind string, name string) *build.Rule {
+	for _, rule := range buildfile.Rules(kind) {
+		if rule.Name() == name {
+			return rule
+		}
+	}
+	call := &build.CallExpr{X: &build.Ident{Name: kind}}
+	buildfile.Stmt = edit.InsertAtEnd(buildfile.Stmt, call)
+	rule := buildfile.Rule(call)
+	rule.SetAttr("name", &build.StringExpr{Value: name})
+	return rule
+}
+
+func setVisibility(rule *build.Rule, public bool) {
+	if public {
+		rule.SetAttr("visibility", stringList([]string{"//visibility:public"}))
+	}
+}
+
+func stringList(values []string) *build.ListExpr {
+	list := &build.ListExpr{}
+	for _, value := range values {
+		list.List = append(list.List, &build.StringExpr{Value: value})
+	}
+	return list
+}
@@ -0,0 +1,61 @@
+package bazel
+
+import (
+	"testing"
+
+	"github.com/bazelbuild/buildtools/build"
+	. "github.com/onsi/gomega"
+)
+
+func TestGroupLibraries(t *testing.T) {
+	g := NewGomegaWithT(t)
+	libs := GroupLibraries([]string{
+		"/usr/lib64/libvirt.so.0.6000.0",
+		"/usr/lib64/libvirt.so.0",
+		"/usr/lib64/libvirt.so",
+		"/usr/lib64/libz.a",
+		"/usr/lib64/libz.so.1",
+		"/usr/lib64/libsomething.sorted",
+		"/usr/lib64/crt1.o",
+	})
+	g.Expect(libs).To(HaveLen(2))
+	g.Expect(*libs["libvirt"]).To(Equal(CCImport{Name: "libvirt", SharedLibrary: "/usr/lib64/libvirt.so"}))
+	g.Expect(*libs["libz"]).To(Equal(CCImport{Name: "libz", SharedLibrary: "/usr/lib64/libz.so.1", StaticLibrary: "/usr/lib64/libz.a"}))
+}
+
+func TestAddCCImports(t *testing.T) {
+	g := NewGomegaWithT(t)
+	file, err := build.ParseBuild("BUILD.bazel", []byte(`cc_import(name = "libs_libz", shared_library = "old")`))
+	g.Expect(err).ToNot(HaveOccurred())
+	AddCCImports(file, []*CCImport{
+		{Name: "libs_libz", StaticLibrary: ":libs/usr/lib64/libz.a"},
+		{Name: "libs_libvirt", SharedLibrary: ":libs/usr/lib64/libvirt.so"},
+	}, false)
+	AddCCLibrary(file, CCLibrary{
+		Name:     "libs_cc",
+		Hdrs:     []string{":libs/usr/include"},
+		Includes: []string{"libs/usr/include"},
+		Deps:     []string{":libs_libvirt", ":libs_libz"},
+	}, true)
+	g.Expect(build.FormatString(file)).To(Equal(`cc_import(
+    name = "libs_libz",
+    static_library = ":libs/usr/lib64/libz.a",
+)
+
+cc_import(
+    name = "libs_libvirt",
+    shared_library = ":libs/usr/lib64/libvirt.so",
+)
+
+cc_library(
+    name = "libs_cc",
+    hdrs = [":libs/usr/include"],
+    includes = ["libs/usr/include"],
+    deps = [
+        ":libs_libvirt",
+        ":libs_libz",
+    ],
+    visibility = ["//visibility:public"],
+)
+`))
+}
@@ -0,0 +1,134 @@
+package bazel
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/buildtools/build"
+)
+
+// SetCanonicalIDs sets the canonical_id of all rpm rules to the file name of the RPM. The file name is the same
+// on all mirrors, which allows Bazel's repository cache and remote downloader to identify the download
+// independent of the mirror it is fetched from.
+func SetCanonicalIDs(rpms []*RPMRule) {
+	for _, rule := range rpms {
+		urls := rule.URLs()
+		if len(urls) == 0 {
+			continue
+		}
+		u, err := url.Parse(urls[0])
+		if err != nil {
+			continue
+		}
+		rule.SetCanonicalID(path.Base(u.Path))
+	}
+}
+
+// URLs returns the URLs of all rpm rules by rule name
+func URLs(rpms []*RPMRule) map[string][]string {
+	urls := map[string][]string{}
+	for _, rule := range rpms {
+		urls[rule.Name()] = rule.URLs()
+	}
+	return urls
+}
+
+// RewriteURLs replaces the URLs of the rpm rules with their rewritten form. Only rules whose URLs differ from the
+// previous URLs by rule name are rewritten, which are the ones set since, so that rewritten URLs are not rewritten
+// again. With nil previous URLs, all rules are rewritten.
+func RewriteURLs(rpms []*RPMRule, previous map[string][]string, rewrite func(url string) string) {
+	for _, rule := range rpms {
+		urls := rule.URLs()
+		if len(urls) == 0 {
+			continue
+		}
+		if previousURLs, exists := previous[rule.Name()]; exists && equalURLs(previousURLs, urls) {
+			continue
+		}
+		list := &build.ListExpr{ForceMultiLine: true}
+		for _, u := range urls {
+			list.List = append(list.List, &build.StringExpr{Value: rewrite(u)})
+		}
+		rule.SetAttr("urls", list)
+	}
+}
+
+func equalURLs(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// rpmHosts returns the sorted hosts the rpm rules download from
+func rpmHosts(rpms []*RPMRule) ([]string, error) {
+	hosts := map[string]bool{}
+	for _, rule := range rpms {
+		for _, raw := range rule.URLs() {
+			u, err := url.Parse(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid url %s of rpm %s: %v", raw, rule.Name(), err)
+			}
+			hosts[u.Host] = true
+		}
+	}
+	sorted := []string{}
+	for host := range hosts {
+		sorted = append(sorted, host)
+	}
+	sort.Strings(sorted)
+	return sorted, nil
+}
+
+// DownloaderConfig returns a config for Bazel's `--experimental_downloader_config` which rewrites the URLs of all
+// hosts the rpm rules download from to `<rewriteTo>/<host>/<path>`. Downloads from other hosts are not affected.
+func DownloaderConfig(rpms []*RPMRule, rewriteTo string) (string, error) {
+	target, err := url.Parse(rewriteTo)
+	if err != nil || target.Host == "" {
+		return "", fmt.Errorf("invalid rewrite target %s", rewriteTo)
+	}
+	hosts, err := rpmHosts(rpms)
+	if err != nil {
+		return "", err
+	}
+	// rewrite rules match and produce URLs without the scheme
+	prefix := target.Host + strings.TrimSuffix(target.Path, "/")
+	config := &strings.Builder{}
+	fmt.Fprintln(config, "# Generated by bazeldnf")
+	for _, host := range hosts {
+		fmt.Fprintf(config, "rewrite %s/(.*) %s/%s/$1\n", strings.ReplaceAll(host, ".", `\.`), prefix, host)
+	}
+	return config.String(), nil
+}
+
+// DownloaderAllowlist returns a config for Bazel's `--experimental_downloader_config` which allows the hosts the
+// rpm rules download from. As soon as a config contains an allow directive, Bazel blocks all other hosts, so it is
+// only useful for builds which download nothing else.
+func DownloaderAllowlist(rpms []*RPMRule) (string, error) {
+	hosts, err := rpmHosts(rpms)
+	if err != nil {
+		return "", err
+	}
+	config := &strings.Builder{}
+	fmt.Fprintln(config, "# Generated by bazeldnf")
+	for _, host := range hosts {
+		fmt.Fprintf(config, "allow %s\n", host)
+	}
+	return config.String(), nil
+}
+
+func (r *RPMRule) SetCanonicalID(id string) {
+	r.Rule.SetAttr("canonical_id", &build.StringExpr{Value: id})
+}
+
+func (r *RPMRule) CanonicalID() string {
+	return r.Rule.AttrString("canonical_id")
+}
@@ -0,0 +1,67 @@
+package bazel
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/buildtools/build"
+	. "github.com/onsi/gomega"
+)
+
+func newRPMRule(name string, urls ...string) *RPMRule {
+	rule := &RPMRule{&build.Rule{Call: &build.CallExpr{X: &build.Ident{Name: "rpm"}}}}
+	rule.SetName(name)
+	list := &build.ListExpr{}
+	for _, u := range urls {
+		list.List = append(list.List, &build.StringExpr{Value: u})
+	}
+	rule.SetAttr("urls", list)
+	return rule
+}
+
+func TestSetCanonicalIDs(t *testing.T) {
+	g := NewGomegaWithT(t)
+	rule := newRPMRule("bash-0__5.0-1.fc32.x86_64", "https://a.example.com/fedora/Packages/b/bash-5.0-1.fc32.x86_64.rpm", "https://b.example.com/Packages/b/bash-5.0-1.fc32.x86_64.rpm")
+	SetCanonicalIDs([]*RPMRule{rule})
+	g.Expect(rule.CanonicalID()).To(Equal("bash-5.0-1.fc32.x86_64.rpm"))
+}
+
+func TestDownloaderConfig(t *testing.T) {
+	g := NewGomegaWithT(t)
+	rpms := []*RPMRule{
+		newRPMRule("a", "https://b.example.com/a.rpm", "https://a.example.com/a.rpm"),
+		newRPMRule("b", "https://a.example.com/b.rpm"),
+	}
+	config, err := DownloaderAllowlist(rpms)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(config).To(Equal("# Generated by bazeldnf\nallow a.example.com\nallow b.example.com\n"))
+
+	config, err = DownloaderConfig(rpms, "https://artifactory.example.com/rpms/")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(config).To(Equal(`# Generated by bazeldnf
+rewrite a\.example\.com/(.*) artifactory.example.com/rpms/a.example.com/$1
+rewrite b\.example\.com/(.*) artifactory.example.com/rpms/b.example.com/$1
+`))
+
+	_, err = DownloaderConfig(rpms, "artifactory")
+	g.Expect(err).To(HaveOccurred())
+	_, err = DownloaderConfig(rpms, "")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestRewriteURLs(t *testing.T) {
+	g := NewGomegaWithT(t)
+	rule := newRPMRule("a", "https://a.example.com/a.rpm", "https://b.example.com/a.rpm")
+	rewrite := func(url string) string {
+		return strings.Replace(url, "https://", "https://mirror.example.com/", 1)
+	}
+	RewriteURLs([]*RPMRule{rule}, nil, rewrite)
+	g.Expect(rule.URLs()).To(Equal([]string{"https://mirror.example.com/a.example.com/a.rpm", "https://mirror.example.com/b.example.com/a.rpm"}))
+
+	// rules which were not updated since are not rewritten again
+	previous := URLs([]*RPMRule{rule})
+	updated := newRPMRule("b", "https://a.example.com/b.rpm")
+	RewriteURLs([]*RPMRule{rule, updated}, previous, rewrite)
+	g.Expect(rule.URLs()).To(Equal([]string{"https://mirror.example.com/a.example.com/a.rpm", "https://mirror.example.com/b.example.com/a.rpm"}))
+	g.Expect(updated.URLs()).To(Equal([]string{"https://mirror.example.com/a.example.com/b.rpm"}))
+}
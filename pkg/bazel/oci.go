@@ -0,0 +1,26 @@
+package bazel
+
+import (
+	"github.com/bazelbuild/buildtools/build"
+	"github.com/rmohr/bazeldnf/pkg/rpmarch"
+)
+
+// AddOCIImage creates a rpmtree_oci_image rule with the given name or adds the rpmtree to the layers of an
+// existing one. Images without a base image get the platform of the given RPM architecture, since rules_oci
+// requires it for scratch images.
+func AddOCIImage(buildfile *build.File, name string, rpmtree string, arch string, public bool) {
+	rule := findOrAddRule(buildfile, "rpmtree_oci_image", name)
+	if rule.Attr("base") == nil && rule.Attr("architecture") == nil {
+		rule.SetAttr("os", &build.StringExpr{Value: "linux"})
+		rule.SetAttr("architecture", &build.StringExpr{Value: rpmarch.GOARCH(arch)})
+	}
+	label := ":" + rpmtree
+	rpmtrees := rule.AttrStrings("rpmtrees")
+	for _, existing := range rpmtrees {
+		if existing == label {
+			return
+		}
+	}
+	rule.SetAttr("rpmtrees", stringList(append(rpmtrees, label)))
+	setVisibility(rule, public)
+}
@@ -0,0 +1,33 @@
+package bazel
+
+import (
+	"testing"
+
+	"github.com/bazelbuild/buildtools/build"
+	. "github.com/onsi/gomega"
+)
+
+func TestAddOCIImage(t *testing.T) {
+	g := NewGomegaWithT(t)
+	file, err := build.ParseBuild("BUILD.bazel", []byte(`rpmtree_oci_image(name = "image", base = "@fedora", rpmtrees = [":base"])`))
+	g.Expect(err).ToNot(HaveOccurred())
+	AddOCIImage(file, "image", "app", "x86_64", false)
+	AddOCIImage(file, "image", "app", "x86_64", false)
+	AddOCIImage(file, "other", "app", "aarch64", false)
+	g.Expect(build.FormatString(file)).To(Equal(`rpmtree_oci_image(
+    name = "image",
+    base = "@fedora",
+    rpmtrees = [
+        ":base",
+        ":app",
+    ],
+)
+
+rpmtree_oci_image(
+    name = "other",
+    os = "linux",
+    architecture = "arm64",
+    rpmtrees = [":app"],
+)
+`))
+}
@@ -0,0 +1,19 @@
+/*
+Package bytesize formats sizes in bytes for humans.
+*/
+package bytesize
+
+import "fmt"
+
+// Format returns the size with decimal units like dnf prints them, e.g. `5.10 M`, `100.00 K` or `300 B`
+func Format(bytes int64) string {
+	switch {
+	case bytes > 1000*1000*1000:
+		return fmt.Sprintf("%.2f G", float64(bytes)/1000/1000/1000)
+	case bytes > 1000*1000:
+		return fmt.Sprintf("%.2f M", float64(bytes)/1000/1000)
+	case bytes > 1000:
+		return fmt.Sprintf("%.2f K", float64(bytes)/1000)
+	}
+	return fmt.Sprintf("%d B", bytes)
+}
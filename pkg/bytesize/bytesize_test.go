@@ -0,0 +1,20 @@
+package bytesize
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestFormat(t *testing.T) {
+	g := NewGomegaWithT(t)
+	for bytes, expected := range map[int64]string{
+		0:                  "0 B",
+		300:                "300 B",
+		100 * 1000:         "100.00 K",
+		5100 * 1000:        "5.10 M",
+		1500 * 1000 * 1000: "1.50 G",
+	} {
+		g.Expect(Format(bytes)).To(Equal(expected), "%d", bytes)
+	}
+}
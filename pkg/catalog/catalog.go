@@ -0,0 +1,119 @@
+package catalog
+
+import (
+	_ "embed"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/rpmarch"
+	"sigs.k8s.io/yaml"
+)
+
+//go:embed catalog.yaml
+var catalogYAML []byte
+
+// Catalog contains well-known repository definitions of distributions
+type Catalog struct {
+	Distros []Distro `json:"distros"`
+}
+
+// Distro contains the repositories of a distribution. `$releasever` and `$basearch` in their names and URLs are
+// replaced with the requested release and architecture.
+type Distro struct {
+	Name         string                `json:"name"`
+	Description  string                `json:"description"`
+	Arches       []string              `json:"arches"`
+	Repositories []bazeldnf.Repository `json:"repositories"`
+}
+
+// Load returns the embedded catalog
+func Load() (*Catalog, error) {
+	catalog := &Catalog{}
+	if err := yaml.Unmarshal(catalogYAML, catalog); err != nil {
+		return nil, fmt.Errorf("failed to parse the embedded repository catalog: %v", err)
+	}
+	return catalog, nil
+}
+
+// Names returns the names of all distributions in the catalog
+func (c *Catalog) Names() []string {
+	names := []string{}
+	for _, distro := range c.Distros {
+		names = append(names, distro.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Repositories returns the repositories for an id of the form `<distro>-<release>`, e.g. `fedora-41` or
+// `centos-stream-9`, optionally followed by `/<arch>` where names like `amd64` are accepted too. Without an
+// architecture the repositories of all architectures of the distribution are returned. The AWS region selects the
+// region-local mirrors of Amazon Linux 2 and is required for it.
+func (c *Catalog) Repositories(id string, awsRegion string) ([]bazeldnf.Repository, error) {
+	arch := ""
+	if i := strings.LastIndex(id, "/"); i >= 0 {
+		id, arch = id[:i], rpmarch.Normalize(id[i+1:])
+	}
+	sep := strings.LastIndex(id, "-")
+	if sep <= 0 || sep == len(id)-1 {
+		return nil, fmt.Errorf("invalid distribution repository %s, expected <distro>-<release>[/<arch>]", id)
+	}
+	name, release := id[:sep], id[sep+1:]
+	for _, distro := range c.Distros {
+		if distro.Name != name {
+			continue
+		}
+		arches := distro.Arches
+		if arch != "" {
+			if !contains(distro.Arches, arch) {
+				return nil, fmt.Errorf("distribution %s does not support architecture %s, supported are %s", name, arch, strings.Join(distro.Arches, ", "))
+			}
+			arches = []string{arch}
+		}
+		repos := []bazeldnf.Repository{}
+		for _, a := range arches {
+			for _, r := range distro.Repositories {
+				expanded, err := expand(r, release, a, awsRegion)
+				if err != nil {
+					return nil, fmt.Errorf("distribution %s: %v", name, err)
+				}
+				repos = append(repos, expanded)
+			}
+		}
+		return repos, nil
+	}
+	return nil, fmt.Errorf("unknown distribution %s, known are %s", name, strings.Join(c.Names(), ", "))
+}
+
+// expand replaces the release, architecture and region variables in a copy of the repository
+func expand(repo bazeldnf.Repository, release string, arch string, awsRegion string) (bazeldnf.Repository, error) {
+	if awsRegion == "" && strings.Contains(repo.Mirrorlist+repo.Metalink+strings.Join(repo.Baseurl, " "), "$awsregion") {
+		return repo, fmt.Errorf("an AWS region is required, set awsRegion in the repository file or pass --aws-region")
+	}
+	replacer := strings.NewReplacer("$releasever", release, "$basearch", arch, "$awsregion", awsRegion)
+	repo.Name = replacer.Replace(repo.Name)
+	repo.Arch = arch
+	repo.Metalink = replacer.Replace(repo.Metalink)
+	repo.Mirrorlist = replacer.Replace(repo.Mirrorlist)
+	repo.GPGKey = replacer.Replace(repo.GPGKey)
+	repo.Snapshot = replacer.Replace(repo.Snapshot)
+	baseurls := bazeldnf.URLs{}
+	for _, baseurl := range repo.Baseurl {
+		baseurls = append(baseurls, replacer.Replace(baseurl))
+	}
+	if len(baseurls) > 0 {
+		repo.Baseurl = baseurls
+	}
+	return repo, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package catalog
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRepositories(t *testing.T) {
+	g := NewGomegaWithT(t)
+	catalog, err := Load()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(catalog.Names()).To(ContainElements("fedora", "centos-stream", "epel", "ubi", "rocky", "alma", "amazonlinux", "al2023"))
+
+	repos, err := catalog.Repositories("fedora-41/x86_64", "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repos).To(HaveLen(2))
+	g.Expect(repos[0].Name).To(Equal("fedora-41-x86_64-primary-repo"))
+	g.Expect(repos[0].Arch).To(Equal("x86_64"))
+	g.Expect(repos[0].Metalink).To(Equal("https://mirrors.fedoraproject.org/metalink?repo=fedora-41&arch=x86_64"))
+	g.Expect(repos[0].GPGKey).To(Equal("https://src.fedoraproject.org/rpms/fedora-repos/raw/rawhide/f/RPM-GPG-KEY-fedora-41-primary"))
+
+	for _, distro := range catalog.Distros {
+		if distro.Name == "amazonlinux" || distro.Name == "al2023" {
+			continue
+		}
+		for _, r := range distro.Repositories {
+			g.Expect(r.GPGKey).ToNot(BeEmpty(), "repository %s has no gpgkey", r.Name)
+		}
+	}
+
+	repos, err = catalog.Repositories("centos-stream-9", "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repos).To(HaveLen(8))
+	g.Expect(repos[0].Snapshot).To(Equal("https://composes.stream.centos.org/production/CentOS-Stream-9-$year$month$day.0/compose/BaseOS/x86_64/os/"))
+
+	repos, err = catalog.Repositories("alma-9/arm64", "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect([]string(repos[0].Baseurl)).To(Equal([]string{"https://repo.almalinux.org/almalinux/9/BaseOS/aarch64/os/"}))
+
+	repos, err = catalog.Repositories("al2023-2023.6.20241010/aarch64", "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repos).To(HaveLen(1))
+	g.Expect(repos[0].Name).To(Equal("al2023-2023.6.20241010-aarch64"))
+	g.Expect(repos[0].Mirrorlist).To(Equal("https://cdn.amazonlinux.com/al2023/core/mirrors/2023.6.20241010/aarch64/mirror.list"))
+
+	_, err = catalog.Repositories("amazonlinux-2/x86_64", "")
+	g.Expect(err).To(MatchError(ContainSubstring("an AWS region is required")))
+	repos, err = catalog.Repositories("amazonlinux-2/x86_64", "eu-central-1")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repos[0].Mirrorlist).To(Equal("https://amazonlinux.eu-central-1.amazonaws.com/2/core/latest/x86_64/mirror.list"))
+
+	_, err = catalog.Repositories("debian-12", "")
+	g.Expect(err).To(MatchError(ContainSubstring("unknown distribution debian")))
+	_, err = catalog.Repositories("fedora-41/sparc", "")
+	g.Expect(err).To(HaveOccurred())
+	_, err = catalog.Repositories("fedora", "")
+	g.Expect(err).To(HaveOccurred())
+}
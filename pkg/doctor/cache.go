@@ -0,0 +1,98 @@
+package doctor
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/bytesize"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+)
+
+// StaleMetadata is the age above which cached repository metadata is reported as stale
+var StaleMetadata = 30 * 24 * time.Hour
+
+// MinFreeSpace is the free disk space below which the filesystem of the cache is reported as almost full
+var MinFreeSpace uint64 = 1000 * 1000 * 1000
+
+// CheckCache reports the size of the cache directory, the free disk space next to it and whether the cached
+// metadata of the enabled repositories is complete and recent
+func CheckCache(cacheDir string, repos *bazeldnf.Repositories) (findings []Finding) {
+	if _, err := os.Stat(cacheDir); os.IsNotExist(err) {
+		return []Finding{warning("cache", cacheDir, "the cache directory does not exist yet, run bazeldnf fetch")}
+	} else if err != nil {
+		return []Finding{failure("cache", cacheDir, "%v", err)}
+	}
+	files := 0
+	var size int64
+	err := filepath.WalkDir(cacheDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		files++
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		findings = append(findings, failure("cache", cacheDir, "failed to read the cache directory: %v", err))
+	} else {
+		findings = append(findings, ok("cache", cacheDir, "%d files with %s, remove old ones with bazeldnf clean", files, bytesize.Format(size)))
+	}
+	if err := repo.CheckDiskSpace(cacheDir, MinFreeSpace); err != nil {
+		findings = append(findings, warning("cache", cacheDir, "%v", err))
+	}
+
+	cacheHelper := &repo.CacheHelper{CacheDir: cacheDir}
+	for _, r := range repos.Repositories {
+		if r.Disabled || r.Name == "" {
+			continue
+		}
+		findings = append(findings, checkRepoCache(cacheHelper, &r))
+	}
+	return findings
+}
+
+// checkRepoCache verifies that repomd.xml and the primary metadata it references are cached completely
+func checkRepoCache(cacheHelper *repo.CacheHelper, r *bazeldnf.Repository) Finding {
+	if _, err := os.Stat(filepath.Join(cacheHelper.CacheDir, r.Name, "repomd.xml")); os.IsNotExist(err) {
+		return warning("cache", r.Name, "no metadata cached, run bazeldnf fetch")
+	}
+	repomd := &api.Repomd{}
+	if err := cacheHelper.UnmarshalFromRepoDir(r, "repomd.xml", repomd); err != nil {
+		return failure("cache", r.Name, "the cached repomd.xml is damaged, run bazeldnf fetch --force-refresh: %v", err)
+	}
+	primary := repomd.File(api.PrimaryFileType)
+	if primary == nil {
+		return failure("cache", r.Name, "the cached repomd.xml references no primary metadata, run bazeldnf fetch --force-refresh")
+	}
+	file := filepath.Join(cacheHelper.CacheDir, r.Name, primary.Location.FileName())
+	info, err := os.Stat(file)
+	if err != nil {
+		return failure("cache", r.Name, "the primary metadata %s is missing, run bazeldnf fetch --force-refresh", primary.Location.FileName())
+	}
+	if expected, err := strconv.ParseInt(strings.TrimSpace(primary.Size), 10, 64); err == nil && expected != info.Size() {
+		return failure("cache", r.Name, "the primary metadata %s has %d bytes instead of %d, run bazeldnf fetch --force-refresh", primary.Location.FileName(), info.Size(), expected)
+	}
+	var newest int64
+	for _, data := range repomd.Data {
+		if timestamp, err := strconv.ParseInt(strings.TrimSpace(data.Timestamp), 10, 64); err == nil && timestamp > newest {
+			newest = timestamp
+		}
+	}
+	if newest > 0 {
+		age := time.Since(time.Unix(newest, 0))
+		if age > StaleMetadata {
+			return warning("cache", r.Name, "the cached metadata is %d days old, refresh it with bazeldnf fetch --force-refresh", int(age.Hours()/24))
+		}
+	}
+	return ok("cache", r.Name, "metadata of revision %s is cached", strings.TrimSpace(repomd.Revision))
+}
@@ -0,0 +1,123 @@
+package doctor
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/rpmarch"
+)
+
+type Severity string
+
+const (
+	OK      Severity = "ok"
+	Warning Severity = "warning"
+	Error   Severity = "error"
+)
+
+// Finding is the result of one diagnostic check. Findings which are not OK say what to do about them.
+type Finding struct {
+	// Check is the area of the check, e.g. `config` or `mirrors`
+	Check    string
+	Severity Severity
+	// Subject is what was checked, e.g. the name of a repository, a URL or a file
+	Subject string
+	Message string
+}
+
+func ok(check string, subject string, format string, args ...interface{}) Finding {
+	return Finding{Check: check, Severity: OK, Subject: subject, Message: fmt.Sprintf(format, args...)}
+}
+
+func warning(check string, subject string, format string, args ...interface{}) Finding {
+	return Finding{Check: check, Severity: Warning, Subject: subject, Message: fmt.Sprintf(format, args...)}
+}
+
+func failure(check string, subject string, format string, args ...interface{}) Finding {
+	return Finding{Check: check, Severity: Error, Subject: subject, Message: fmt.Sprintf(format, args...)}
+}
+
+// Count returns the number of findings with the given severity
+func Count(findings []Finding, severity Severity) int {
+	count := 0
+	for _, finding := range findings {
+		if finding.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
+
+// CheckConfig validates the merged repository configuration: every enabled repository needs an architecture,
+// further architectures which are known and distinct, a source to fetch metadata from, valid URLs and a gpgkey if
+// signatures are enforced
+func CheckConfig(repos *bazeldnf.Repositories) (findings []Finding) {
+	enabled := 0
+	for _, r := range repos.Repositories {
+		if r.Disabled {
+			continue
+		}
+		enabled++
+		if r.Name == "" {
+			findings = append(findings, failure("config", "repository", "a repository has no name, add one since the cache directory is named after it"))
+			continue
+		}
+		problems := len(findings)
+		if r.Arch == "" {
+			findings = append(findings, failure("config", r.Name, "no arch configured, set it to the target architecture like x86_64, or to noarch"))
+		}
+		arches := map[string]struct{}{rpmarch.Normalize(r.Arch): {}}
+		for _, arch := range r.Arches {
+			switch {
+			case arch == "":
+				findings = append(findings, failure("config", r.Name, "arches contains an empty architecture, remove it"))
+			case !rpmarch.IsKnown(arch):
+				findings = append(findings, failure("config", r.Name, "unknown architecture %s in arches, use RPM architectures like x86_64 or aarch64", arch))
+			default:
+				if _, exists := arches[rpmarch.Normalize(arch)]; exists {
+					findings = append(findings, failure("config", r.Name, "the architecture %s is listed twice in arch and arches, remove the duplicate", arch))
+				}
+				arches[rpmarch.Normalize(arch)] = struct{}{}
+			}
+		}
+		if r.Metalink == "" && r.Mirrorlist == "" && len(r.Baseurl) == 0 && len(r.Mirrors) == 0 && r.Koji == nil {
+			findings = append(findings, failure("config", r.Name, "neither metalink, mirrorlist, baseurl nor mirrors configured"))
+		}
+		for _, u := range append(append([]string{r.Metalink, r.Mirrorlist, r.Snapshot}, r.Baseurl...), r.Mirrors...) {
+			if u == "" {
+				continue
+			}
+			if err := checkURL(u); err != nil {
+				findings = append(findings, failure("config", r.Name, "invalid URL %s: %v", u, err))
+			}
+		}
+		if (r.RepoGPGCheck || r.GPGCheck != nil && *r.GPGCheck) && strings.TrimSpace(r.GPGKey) == "" {
+			findings = append(findings, failure("config", r.Name, "gpgcheck or repo_gpgcheck is enabled, but no gpgkey is configured"))
+		}
+		if len(findings) == problems {
+			findings = append(findings, ok("config", r.Name, "valid"))
+		}
+	}
+	if enabled == 0 {
+		findings = append(findings, failure("config", "repositories", "no enabled repositories, pass a repository file with --repofile or use --distro-repo"))
+	}
+	return findings
+}
+
+func checkURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https", "file":
+	default:
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Scheme != "file" && u.Host == "" {
+		return fmt.Errorf("no host")
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package doctor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bazelbuild/buildtools/build"
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/bazel"
+	"github.com/rmohr/bazeldnf/pkg/lockfile"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+)
+
+func severities(findings []Finding) map[string]Severity {
+	result := map[string]Severity{}
+	for _, finding := range findings {
+		if result[finding.Subject] != Error {
+			result[finding.Subject] = finding.Severity
+		}
+	}
+	return result
+}
+
+func TestCheckConfig(t *testing.T) {
+	g := NewGomegaWithT(t)
+	enforce := true
+	findings := CheckConfig(&bazeldnf.Repositories{Repositories: []bazeldnf.Repository{
+		{Name: "fedora", Arch: "x86_64", Metalink: "https://mirrors.fedoraproject.org/metalink?repo=fedora-41&arch=x86_64"},
+		{Name: "archless", Baseurl: bazeldnf.URLs{"https://example.com/repo/"}},
+		{Name: "unsigned", Arch: "x86_64", Baseurl: bazeldnf.URLs{"https://example.com/repo/"}, GPGCheck: &enforce},
+		{Name: "broken", Arch: "x86_64", Baseurl: bazeldnf.URLs{"example.com/repo/"}},
+		{Name: "disabled", Disabled: true},
+		{Name: "multiarch", Arch: "x86_64", Arches: []string{"aarch64", "ppc64le"}, Baseurl: bazeldnf.URLs{"https://example.com/repo/"}},
+		{Name: "empty-arches", Arch: "x86_64", Arches: []string{""}, Baseurl: bazeldnf.URLs{"https://example.com/repo/"}},
+		{Name: "unknown-arches", Arch: "x86_64", Arches: []string{"x86"}, Baseurl: bazeldnf.URLs{"https://example.com/repo/"}},
+		{Name: "duplicate-arches", Arch: "x86_64", Arches: []string{"aarch64", "amd64"}, Baseurl: bazeldnf.URLs{"https://example.com/repo/"}},
+	}})
+	g.Expect(severities(findings)).To(Equal(map[string]Severity{
+		"fedora":           OK,
+		"archless":         Error,
+		"unsigned":         Error,
+		"broken":           Error,
+		"multiarch":        OK,
+		"empty-arches":     Error,
+		"unknown-arches":   Error,
+		"duplicate-arches": Error,
+	}))
+
+	findings = CheckConfig(&bazeldnf.Repositories{Repositories: []bazeldnf.Repository{{Name: "disabled", Disabled: true}}})
+	g.Expect(severities(findings)).To(Equal(map[string]Severity{"repositories": Error}))
+}
+
+func TestCheckMirrors(t *testing.T) {
+	g := NewGomegaWithT(t)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/good/repodata/repomd.xml":
+			rw.Write([]byte("<repomd/>"))
+		case "/slow/repodata/repomd.xml":
+			time.Sleep(50 * time.Millisecond)
+			rw.Write([]byte("<repomd/>"))
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	defer func(slow time.Duration) { SlowMirror = slow }(SlowMirror)
+	SlowMirror = 40 * time.Millisecond
+
+	repos := &bazeldnf.Repositories{Repositories: []bazeldnf.Repository{
+		{Name: "good", Arch: "x86_64", Baseurl: bazeldnf.URLs{server.URL + "/good/"}},
+		{Name: "slow", Arch: "x86_64", Mirrors: []string{server.URL + "/slow"}},
+		{Name: "missing", Arch: "x86_64", Baseurl: bazeldnf.URLs{server.URL + "/good/", server.URL + "/missing/"}},
+	}}
+	findings := CheckMirrors(repo.NewGetter(), repos, &repo.CacheHelper{CacheDir: t.TempDir()})
+	g.Expect(findings).To(HaveLen(4))
+	g.Expect(severities(findings)).To(Equal(map[string]Severity{
+		"good":    OK,
+		"slow":    Warning,
+		"missing": Error,
+	}))
+}
+
+func TestCheckCache(t *testing.T) {
+	g := NewGomegaWithT(t)
+	cacheDir := t.TempDir()
+	now := time.Now().Unix()
+	writeRepo := func(name string, timestamp int64, primary string) {
+		g.Expect(os.MkdirAll(filepath.Join(cacheDir, name), 0770)).To(Succeed())
+		repomd := `<repomd><revision>1</revision><data type="primary"><location href="repodata/primary.xml"/><timestamp>` +
+			strconv.FormatInt(timestamp, 10) + `</timestamp><size>8</size></data></repomd>`
+		g.Expect(os.WriteFile(filepath.Join(cacheDir, name, "repomd.xml"), []byte(repomd), 0660)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(cacheDir, name, "primary.xml"), []byte(primary), 0660)).To(Succeed())
+	}
+	writeRepo("fresh", now, "complete")
+	writeRepo("stale", now-60*24*60*60, "complete")
+	writeRepo("truncated", now, "trunc")
+
+	findings := CheckCache(cacheDir, &bazeldnf.Repositories{Repositories: []bazeldnf.Repository{
+		{Name: "fresh"}, {Name: "stale"}, {Name: "truncated"}, {Name: "uncached"},
+	}})
+	g.Expect(findings[0].Subject).To(Equal(cacheDir))
+	g.Expect(findings[0].Message).To(ContainSubstring("6 files"))
+	result := severities(findings)
+	delete(result, cacheDir)
+	g.Expect(result).To(Equal(map[string]Severity{
+		"fresh":     OK,
+		"stale":     Warning,
+		"truncated": Error,
+		"uncached":  Warning,
+	}))
+
+	findings = CheckCache(filepath.Join(cacheDir, "nonexistent"), &bazeldnf.Repositories{})
+	g.Expect(findings).To(HaveLen(1))
+	g.Expect(findings[0].Severity).To(Equal(Warning))
+}
+
+func TestCheckLockfileAndWorkspace(t *testing.T) {
+	g := NewGomegaWithT(t)
+	path := filepath.Join(t.TempDir(), "bazeldnf-lock.json")
+	g.Expect(lockfile.Write(path, &bazeldnf.Lockfile{
+		Repositories: []bazeldnf.LockedRepository{{Name: "fedora"}, {Name: "removed"}},
+		Trees:        map[string][]string{"tree": {"bash-0:5.2-1.fc41.x86_64"}},
+		Packages: []bazeldnf.LockedPackage{{
+			Name: "bash", Version: "5.2", Release: "1.fc41", Arch: "x86_64", Repository: "fedora",
+			Checksum: "sha256:aaaa", URLs: []string{"https://example.com/bash-5.2-1.fc41.x86_64.rpm"},
+		}},
+	})).To(Succeed())
+
+	repos := &bazeldnf.Repositories{Repositories: []bazeldnf.Repository{{Name: "fedora", Arch: "x86_64"}}}
+	lock, findings := CheckLockfile(path, repos, &repo.CacheHelper{CacheDir: t.TempDir()})
+	g.Expect(lock).ToNot(BeNil())
+	g.Expect(findings).To(HaveLen(1))
+	g.Expect(findings[0].Severity).To(Equal(Warning))
+	g.Expect(findings[0].Message).To(ContainSubstring("removed"))
+
+	workspace, err := build.ParseWorkspace("WORKSPACE", []byte(`
+rpm(name = "bash", sha256 = "aaaa", urls = ["https://example.com/bash-5.2-1.fc41.x86_64.rpm"])
+rpm(name = "bash-old", sha256 = "bbbb", urls = ["https://example.com/bash-5.2-1.fc41.x86_64.rpm"])
+rpm(name = "glibc", sha256 = "cccc", urls = ["https://example.com/glibc-2.40-1.fc41.x86_64.rpm"])
+`))
+	g.Expect(err).ToNot(HaveOccurred())
+	findings = CheckWorkspace("WORKSPACE", lock, bazel.GetWorkspaceRPMs(workspace))
+	g.Expect(findings).To(HaveLen(2))
+	g.Expect(findings[0].Severity).To(Equal(Error))
+	g.Expect(findings[0].Message).To(ContainSubstring("bash-old"))
+	g.Expect(findings[1].Severity).To(Equal(Warning))
+	g.Expect(findings[1].Message).To(ContainSubstring("glibc"))
+
+	// bzlmod lock files are migrated when they are loaded
+	g.Expect(os.WriteFile(path, []byte(`{
+  "name": "bazeldnf_rpms",
+  "rpms": [
+    {"name": "bash-0__5.2-1.fc41.x86_64", "urls": ["https://example.com/bash-5.2-1.fc41.x86_64.rpm"], "sha256": "aaaa"}
+  ]
+}`), 0666)).To(Succeed())
+	lock, findings = CheckLockfile(path, repos, &repo.CacheHelper{CacheDir: t.TempDir()})
+	g.Expect(lock).ToNot(BeNil())
+	g.Expect(lock.Trees).To(HaveKey("bazeldnf_rpms"))
+	g.Expect(findings).To(HaveLen(1))
+	g.Expect(findings[0].Severity).To(Equal(OK))
+	g.Expect(findings[0].Message).To(Equal("1 packages in 1 rpmtrees are locked"))
+	findings = CheckWorkspace("WORKSPACE", lock, bazel.GetWorkspaceRPMs(workspace))
+	g.Expect(severities(findings)).To(Equal(map[string]Severity{"WORKSPACE": Error}))
+
+	g.Expect(os.WriteFile(path, []byte(`{"version": 2, "digest": "sha256:0000", "packages": []}`), 0666)).To(Succeed())
+	lock, findings = CheckLockfile(path, repos, &repo.CacheHelper{CacheDir: t.TempDir()})
+	g.Expect(lock).To(BeNil())
+	g.Expect(findings).To(HaveLen(1))
+	g.Expect(findings[0].Severity).To(Equal(Error))
+}
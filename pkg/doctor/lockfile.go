@@ -0,0 +1,104 @@
+package doctor
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/bazel"
+	"github.com/rmohr/bazeldnf/pkg/lockfile"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+)
+
+// CheckLockfile loads the lockfile and reports damage, packages which can't be downloaded and locked repositories
+// which are no longer configured or moved on since the lockfile was written. The lockfile is nil if it can't be
+// loaded.
+func CheckLockfile(path string, repos *bazeldnf.Repositories, cacheHelper *repo.CacheHelper) (*bazeldnf.Lockfile, []Finding) {
+	lock, err := lockfile.Load(path)
+	if err != nil {
+		return nil, []Finding{failure("lockfile", path, "%v", err)}
+	}
+	findings := []Finding{}
+	configured := map[string]*bazeldnf.Repository{}
+	for i, r := range repos.Repositories {
+		if !r.Disabled {
+			configured[r.Name] = &repos.Repositories[i]
+		}
+	}
+	current := []*bazeldnf.LockedRepository{}
+	for _, locked := range lock.Repositories {
+		r, exists := configured[locked.Name]
+		if !exists {
+			findings = append(findings, warning("lockfile", path, "the locked repository %s is not configured, re-resolve the rpmtrees which use it", locked.Name))
+			continue
+		}
+		// repositories without cached metadata are reported by the cache check
+		if snapshot, err := cacheHelper.Snapshot(r); err == nil {
+			current = append(current, snapshot)
+		}
+	}
+	for _, change := range lockfile.ChangedRepositories(lock, current) {
+		findings = append(findings, warning("lockfile", path, "%s, re-resolve the rpmtrees to pick up the updates", change))
+	}
+	ids := map[string]struct{}{}
+	for _, pkg := range lock.Packages {
+		ids[pkg.ID()] = struct{}{}
+		if len(pkg.URLs) == 0 {
+			findings = append(findings, failure("lockfile", path, "the locked package %s has no download URLs, re-resolve the rpmtrees which contain it", pkg.ID()))
+		}
+	}
+	trees := []string{}
+	for tree := range lock.Trees {
+		trees = append(trees, tree)
+	}
+	sort.Strings(trees)
+	for _, tree := range trees {
+		for _, id := range lock.Trees[tree] {
+			if _, exists := ids[id]; !exists {
+				findings = append(findings, failure("lockfile", path, "the rpmtree %s references %s which is not locked, re-resolve it", tree, id))
+			}
+		}
+	}
+	if len(findings) == 0 {
+		findings = append(findings, ok("lockfile", path, "%d packages in %d rpmtrees are locked", len(lock.Packages), len(lock.Trees)))
+	}
+	return lock, findings
+}
+
+// CheckWorkspace compares the rpm rules of a WORKSPACE or macro file with the lockfile. Rules whose checksum
+// differs from the locked package with the same URL are errors, rules which are not locked at all only warnings.
+func CheckWorkspace(subject string, lock *bazeldnf.Lockfile, rpms []*bazel.RPMRule) (findings []Finding) {
+	checksums := map[string]struct{}{}
+	urls := map[string]*bazeldnf.LockedPackage{}
+	for i, pkg := range lock.Packages {
+		checksums[pkg.Checksum] = struct{}{}
+		for _, u := range pkg.URLs {
+			urls[u] = &lock.Packages[i]
+		}
+	}
+	for _, rpm := range rpms {
+		algorithm, sum, err := rpm.Checksum()
+		if err != nil {
+			findings = append(findings, failure("workspace", subject, "%v", err))
+			continue
+		}
+		if _, exists := checksums[fmt.Sprintf("%s:%s", algorithm, sum)]; exists {
+			continue
+		}
+		var locked *bazeldnf.LockedPackage
+		for _, u := range rpm.URLs() {
+			if locked = urls[u]; locked != nil {
+				break
+			}
+		}
+		if locked != nil {
+			findings = append(findings, failure("workspace", subject, "rpm %s does not match the checksum %s of the locked package %s, run bazeldnf rpmtree again", rpm.Name(), locked.Checksum, locked.ID()))
+		} else {
+			findings = append(findings, warning("workspace", subject, "rpm %s is not in the lockfile", rpm.Name()))
+		}
+	}
+	if len(findings) == 0 {
+		findings = append(findings, ok("workspace", subject, "all %d rpms match the lockfile", len(rpms)))
+	}
+	return findings
+}
@@ -0,0 +1,135 @@
+package doctor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/repo"
+	"golang.org/x/crypto/openpgp"
+)
+
+// SlowMirror is the response time above which a mirror is reported as slow
+var SlowMirror = 2 * time.Second
+
+// CheckMirrors probes the metalink and mirror list URLs and the repomd.xml files of all mirrors of the enabled
+// repositories and reports the ones which fail or respond slowly. Mirrors of metalinks and mirror lists are
+// taken from the cache, so they are only probed after the repositories were fetched once.
+func CheckMirrors(getter repo.Getter, repos *bazeldnf.Repositories, cacheHelper *repo.CacheHelper) (findings []Finding) {
+	for _, r := range repos.Repositories {
+		if r.Disabled || r.Name == "" || r.Koji != nil {
+			continue
+		}
+		repoGetter, err := withProxy(getter, &r)
+		if err != nil {
+			findings = append(findings, failure("mirrors", r.Name, "%v", err))
+			continue
+		}
+		for _, u := range []string{r.Metalink, r.Mirrorlist} {
+			if u != "" {
+				findings = append(findings, probe(repoGetter, r.Name, u))
+			}
+		}
+		resolved := r
+		if err := cacheHelper.ResolveMirrors(&resolved); err != nil {
+			findings = append(findings, warning("mirrors", r.Name, "failed to load the cached mirrors, run bazeldnf fetch: %v", err))
+			continue
+		}
+		for _, mirror := range mirrors(&resolved) {
+			findings = append(findings, probe(repoGetter, r.Name, strings.TrimSuffix(mirror, "/")+"/repodata/repomd.xml"))
+		}
+	}
+	return findings
+}
+
+// mirrors returns the baseurls and mirrors of the repository without duplicates
+func mirrors(r *bazeldnf.Repository) (urls []string) {
+	seen := map[string]struct{}{}
+	for _, u := range append(append([]string{}, r.Baseurl...), r.Mirrors...) {
+		if _, exists := seen[u]; exists {
+			continue
+		}
+		seen[u] = struct{}{}
+		urls = append(urls, u)
+	}
+	return urls
+}
+
+// probe downloads the URL and reports how long the request took
+func probe(getter repo.Getter, name string, url string) Finding {
+	start := time.Now()
+	resp, err := getter.Get(url)
+	latency := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		return failure("mirrors", name, "%s is unreachable: %v", url, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return failure("mirrors", name, "%s returned status %v, remove the mirror or fix its URL", url, resp.StatusCode)
+	}
+	if latency > SlowMirror {
+		return warning("mirrors", name, "%s responded slowly in %v, consider a closer mirror", url, latency)
+	}
+	return ok("mirrors", name, "%s responded in %v", url, latency)
+}
+
+// CheckGPGKeys downloads the gpg keys of the enabled repositories and reports keys which can't be loaded or have
+// expired
+func CheckGPGKeys(getter repo.Getter, repos *bazeldnf.Repositories) (findings []Finding) {
+	for _, r := range repos.Repositories {
+		if r.Disabled || r.Name == "" {
+			continue
+		}
+		if strings.TrimSpace(r.GPGKey) == "" {
+			if repo.ChecksRPMSignatures(&r) {
+				findings = append(findings, warning("gpg", r.Name, "no gpgkey configured, RPM signatures are not verified"))
+			}
+			continue
+		}
+		keys, err := repo.LoadGPGKeys(getter, &r)
+		if err != nil {
+			findings = append(findings, failure("gpg", r.Name, "%v", err))
+			continue
+		}
+		if len(keys) == 0 {
+			findings = append(findings, failure("gpg", r.Name, "the gpgkey %s contains no keys", r.GPGKey))
+			continue
+		}
+		problems := len(findings)
+		for _, key := range keys {
+			if expired(key, time.Now()) {
+				findings = append(findings, warning("gpg", r.Name, "key %s has expired, update the gpgkey", key.PrimaryKey.KeyIdString()))
+			}
+		}
+		if len(findings) == problems {
+			findings = append(findings, ok("gpg", r.Name, "%d valid keys", len(keys)))
+		}
+	}
+	return findings
+}
+
+// expired returns true if all self-signatures of the identities of the key have expired
+func expired(key *openpgp.Entity, now time.Time) bool {
+	if len(key.Identities) == 0 {
+		return false
+	}
+	for _, identity := range key.Identities {
+		if identity.SelfSignature == nil || !identity.SelfSignature.KeyExpired(now) {
+			return false
+		}
+	}
+	return true
+}
+
+func withProxy(getter repo.Getter, r *bazeldnf.Repository) (repo.Getter, error) {
+	proxyGetter, ok := getter.(repo.ProxyGetter)
+	if !ok || r.Proxy == "" {
+		return getter, nil
+	}
+	proxied, err := proxyGetter.WithProxy(r.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure proxy for %s: %v", r.Name, err)
+	}
+	return proxied, nil
+}
@@ -0,0 +1,127 @@
+package lockfile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"golang.org/x/crypto/openpgp"
+)
+
+// canonicalize returns a copy of the lockfile with all entries sorted, so that equal content always serializes the
+// same way. The lockfile itself is not modified.
+func canonicalize(lock *bazeldnf.Lockfile) *bazeldnf.Lockfile {
+	canonical := *lock
+	canonical.Repositories = append([]bazeldnf.LockedRepository(nil), lock.Repositories...)
+	sort.Slice(canonical.Repositories, func(i, j int) bool {
+		return canonical.Repositories[i].Name < canonical.Repositories[j].Name
+	})
+	canonical.Packages = append([]bazeldnf.LockedPackage(nil), lock.Packages...)
+	sort.Slice(canonical.Packages, func(i, j int) bool {
+		return canonical.Packages[i].ID() < canonical.Packages[j].ID()
+	})
+	if lock.Trees != nil {
+		canonical.Trees = map[string][]string{}
+		for name, ids := range lock.Trees {
+			canonical.Trees[name] = append([]string{}, ids...)
+			sort.Strings(canonical.Trees[name])
+		}
+	}
+	return &canonical
+}
+
+// Digest computes the sha256 digest over the canonical compact JSON form of the lockfile without its digest field
+func Digest(lock *bazeldnf.Lockfile) (string, error) {
+	canonical := canonicalize(lock)
+	canonical.Digest = ""
+	data, err := json.Marshal(canonical)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// SignaturePath returns where the detached signature of the lockfile is stored
+func SignaturePath(path string) string {
+	return path + ".asc"
+}
+
+// Sign writes an armored detached signature of the lockfile, created with the first private key of the given
+// armored keyring file. Encrypted private keys are not supported.
+func Sign(path string, keyFile string) error {
+	signer, err := loadSigner(keyFile)
+	if err != nil {
+		return err
+	}
+	lockfile, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer lockfile.Close()
+	signature, err := os.Create(SignaturePath(path))
+	if err != nil {
+		return err
+	}
+	defer signature.Close()
+	if err := openpgp.ArmoredDetachSign(signature, signer, lockfile, nil); err != nil {
+		return fmt.Errorf("failed to sign lockfile %s: %v", path, err)
+	}
+	return signature.Close()
+}
+
+// VerifySignature checks the detached signature of the lockfile against the given armored public keyring file
+func VerifySignature(path string, keyringFile string) error {
+	keyring, err := loadKeyring(keyringFile)
+	if err != nil {
+		return err
+	}
+	lockfile, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer lockfile.Close()
+	signature, err := os.Open(SignaturePath(path))
+	if err != nil {
+		return fmt.Errorf("failed to open signature of lockfile %s: %v", path, err)
+	}
+	defer signature.Close()
+	if _, err := openpgp.CheckArmoredDetachedSignature(keyring, lockfile, signature); err != nil {
+		return fmt.Errorf("invalid signature of lockfile %s: %v", path, err)
+	}
+	return nil
+}
+
+func loadKeyring(keyringFile string) (openpgp.EntityList, error) {
+	f, err := os.Open(keyringFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	keyring, err := openpgp.ReadArmoredKeyRing(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not load keyring %s: %v", keyringFile, err)
+	}
+	return keyring, nil
+}
+
+func loadSigner(keyFile string) (*openpgp.Entity, error) {
+	keyring, err := loadKeyring(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, entity := range keyring {
+		if entity.PrivateKey == nil {
+			continue
+		}
+		if entity.PrivateKey.Encrypted {
+			return nil, fmt.Errorf("private key in %s is encrypted, which is not supported", keyFile)
+		}
+		return entity, nil
+	}
+	return nil, fmt.Errorf("no private key found in %s", keyFile)
+}
@@ -0,0 +1,100 @@
+package lockfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func TestDigestDetectsModifications(t *testing.T) {
+	g := NewGomegaWithT(t)
+	path := filepath.Join(t.TempDir(), "bazeldnf-lock.json")
+	lock := &bazeldnf.Lockfile{}
+	g.Expect(SetTree(lock, "a", []*api.Package{newPackage("bash", "5.0")})).To(Succeed())
+	g.Expect(Write(path, lock)).To(Succeed())
+	g.Expect(lock.Digest).To(HavePrefix("sha256:"))
+
+	loaded, err := Load(path)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(loaded.Digest).To(Equal(lock.Digest))
+
+	data, err := os.ReadFile(path)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(os.WriteFile(path, []byte(strings.Replace(string(data), "5.0", "5.1", 1)), 0666)).To(Succeed())
+	_, err = Load(path)
+	g.Expect(err).To(MatchError(ContainSubstring("was modified or damaged")))
+}
+
+func TestDigestIsCanonical(t *testing.T) {
+	g := NewGomegaWithT(t)
+	first := &bazeldnf.Lockfile{}
+	g.Expect(SetTree(first, "a", []*api.Package{newPackage("bash", "5.0"), newPackage("glibc", "2.31")})).To(Succeed())
+	second := &bazeldnf.Lockfile{}
+	g.Expect(SetTree(second, "a", []*api.Package{newPackage("glibc", "2.31"), newPackage("bash", "5.0")})).To(Succeed())
+	second.Digest = "sha256:outdated"
+
+	firstDigest, err := Digest(first)
+	g.Expect(err).ToNot(HaveOccurred())
+	secondDigest, err := Digest(second)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(firstDigest).To(Equal(secondDigest))
+	g.Expect(second.Trees["a"]).To(Equal([]string{"glibc-0:2.31-1.fc32.x86_64", "bash-0:5.0-1.fc32.x86_64"}), "the digest must not reorder the lockfile")
+}
+
+func TestSignAndVerify(t *testing.T) {
+	g := NewGomegaWithT(t)
+	dir := t.TempDir()
+	entity, err := openpgp.NewEntity("bazeldnf", "test", "bazeldnf@example.com", nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	privateKey := filepath.Join(dir, "private.asc")
+	publicKey := filepath.Join(dir, "public.asc")
+	writeArmored(t, privateKey, openpgp.PrivateKeyType, func(w *os.File) error {
+		wc, err := armor.Encode(w, openpgp.PrivateKeyType, nil)
+		if err != nil {
+			return err
+		}
+		if err := entity.SerializePrivate(wc, nil); err != nil {
+			return err
+		}
+		return wc.Close()
+	})
+	writeArmored(t, publicKey, openpgp.PublicKeyType, func(w *os.File) error {
+		wc, err := armor.Encode(w, openpgp.PublicKeyType, nil)
+		if err != nil {
+			return err
+		}
+		if err := entity.Serialize(wc); err != nil {
+			return err
+		}
+		return wc.Close()
+	})
+
+	path := filepath.Join(dir, "bazeldnf-lock.json")
+	g.Expect(Write(path, &bazeldnf.Lockfile{})).To(Succeed())
+	g.Expect(Sign(path, privateKey)).To(Succeed())
+	g.Expect(VerifySignature(path, publicKey)).To(Succeed())
+
+	g.Expect(os.WriteFile(path, []byte("{}"), 0666)).To(Succeed())
+	g.Expect(VerifySignature(path, publicKey)).To(MatchError(ContainSubstring("invalid signature")))
+
+	g.Expect(Write(path, &bazeldnf.Lockfile{})).To(Succeed())
+	g.Expect(SignaturePath(path)).ToNot(BeAnExistingFile())
+}
+
+func writeArmored(t *testing.T, path string, blockType string, write func(w *os.File) error) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create %s: %v", path, err)
+	}
+	defer f.Close()
+	if err := write(f); err != nil {
+		t.Fatalf("failed to write %s block to %s: %v", blockType, path, err)
+	}
+}
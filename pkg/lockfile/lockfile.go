@@ -0,0 +1,214 @@
+package lockfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+// Load reads the lockfile at the given path
+func Load(path string) (*bazeldnf.Lockfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data, version, err := migrate(data, strings.TrimSuffix(filepath.Base(path), ".json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile %s: %v", path, err)
+	}
+	lock := &bazeldnf.Lockfile{}
+	if err := json.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("failed to parse lockfile %s: %v", path, err)
+	}
+	if version >= digestVersion || lock.Digest != "" {
+		digest, err := Digest(lock)
+		if err != nil {
+			return nil, err
+		}
+		if lock.Digest != digest {
+			return nil, fmt.Errorf("lockfile %s was modified or damaged, expected digest %s but got %s, regenerate it with bazeldnf", path, lock.Digest, digest)
+		}
+	}
+	return lock, nil
+}
+
+// LoadOrCreate reads the lockfile at the given path or returns an empty one if it does not exist yet
+func LoadOrCreate(path string) (*bazeldnf.Lockfile, error) {
+	lock, err := Load(path)
+	if os.IsNotExist(err) {
+		return &bazeldnf.Lockfile{Version: CurrentVersion}, nil
+	}
+	return lock, err
+}
+
+// Write writes the lockfile to the given path with all entries sorted, to keep diffs small. A detached signature
+// next to the lockfile is removed, since it can't match the new content.
+func Write(path string, lock *bazeldnf.Lockfile) error {
+	lock.Version = CurrentVersion
+	digest, err := Digest(lock)
+	if err != nil {
+		return err
+	}
+	lock.Digest = digest
+	data, err := json.MarshalIndent(canonicalize(lock), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0666); err != nil {
+		return err
+	}
+	if err := os.Remove(SignaturePath(path)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove the outdated signature of lockfile %s: %v", path, err)
+	}
+	return nil
+}
+
+// SetTree replaces the packages of the given rpmtree and drops all packages which are not referenced by any
+// rpmtree anymore.
+func SetTree(lock *bazeldnf.Lockfile, name string, pkgs []*api.Package) error {
+	locked := map[string]bazeldnf.LockedPackage{}
+	for _, pkg := range lock.Packages {
+		locked[pkg.ID()] = pkg
+	}
+	ids := []string{}
+	for _, pkg := range pkgs {
+		lockedPkg, err := NewLockedPackage(pkg)
+		if err != nil {
+			return err
+		}
+		if existing, exists := locked[lockedPkg.ID()]; exists {
+			lockedPkg.Alias = existing.Alias
+		}
+		locked[lockedPkg.ID()] = lockedPkg
+		ids = append(ids, lockedPkg.ID())
+	}
+	if lock.Trees == nil {
+		lock.Trees = map[string][]string{}
+	}
+	lock.Trees[name] = ids
+
+	referenced := map[string]bool{}
+	for _, ids := range lock.Trees {
+		for _, id := range ids {
+			referenced[id] = true
+		}
+	}
+	lock.Packages = nil
+	for id, pkg := range locked {
+		if referenced[id] {
+			lock.Packages = append(lock.Packages, pkg)
+		}
+	}
+	return nil
+}
+
+// RewriteTreeURLs rewrites the URLs of the packages of the given rpmtree. It is meant to be called right after
+// SetTree, which records the original URLs of all packages of the rpmtree.
+func RewriteTreeURLs(lock *bazeldnf.Lockfile, name string, rewrite func(url string) string) {
+	ids := map[string]bool{}
+	for _, id := range lock.Trees[name] {
+		ids[id] = true
+	}
+	for i := range lock.Packages {
+		if !ids[lock.Packages[i].ID()] {
+			continue
+		}
+		for j, u := range lock.Packages[i].URLs {
+			lock.Packages[i].URLs[j] = rewrite(u)
+		}
+	}
+}
+
+// TreePackages returns the locked packages of the given rpmtree and whether the rpmtree is known
+func TreePackages(lock *bazeldnf.Lockfile, name string) ([]bazeldnf.LockedPackage, bool) {
+	ids, exists := lock.Trees[name]
+	if !exists {
+		return nil, false
+	}
+	locked := map[string]bazeldnf.LockedPackage{}
+	for _, pkg := range lock.Packages {
+		locked[pkg.ID()] = pkg
+	}
+	pkgs := []bazeldnf.LockedPackage{}
+	for _, id := range ids {
+		if pkg, exists := locked[id]; exists {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs, true
+}
+
+// NewLockedPackage records where a resolved package can be downloaded from
+func NewLockedPackage(pkg *api.Package) (bazeldnf.LockedPackage, error) {
+	lockedPkg := bazeldnf.LockedPackage{
+		Name:     pkg.Name,
+		Epoch:    pkg.Version.Epoch,
+		Version:  pkg.Version.Ver,
+		Release:  pkg.Version.Rel,
+		Arch:     pkg.Arch,
+		Checksum: pkg.Checksum.Algorithm() + ":" + pkg.Checksum.Text,
+		Size:     int64(pkg.Size.Package),
+	}
+	if pkg.Repository != nil {
+		lockedPkg.Repository = pkg.Repository.Name
+	}
+	urls, err := pkg.URLs()
+	if err != nil {
+		return lockedPkg, err
+	}
+	if len(urls) > 0 {
+		lockedPkg.URLs = urls
+	}
+	return lockedPkg, nil
+}
+
+// RepositoryChange describes a repository whose metadata differs from the locked snapshot
+type RepositoryChange struct {
+	Locked  *bazeldnf.LockedRepository
+	Current *bazeldnf.LockedRepository
+}
+
+func (c RepositoryChange) String() string {
+	return fmt.Sprintf("Repository %s moved on from revision %s (timestamp %d) to revision %s (timestamp %d)",
+		c.Current.Name, c.Locked.Revision, c.Locked.Timestamp, c.Current.Revision, c.Current.Timestamp)
+}
+
+// SetRepositories records the given repository snapshots and returns the changes compared to the previously
+// locked snapshots.
+func SetRepositories(lock *bazeldnf.Lockfile, current []*bazeldnf.LockedRepository) []RepositoryChange {
+	changes := ChangedRepositories(lock, current)
+	for _, snapshot := range current {
+		replaced := false
+		for i := range lock.Repositories {
+			if lock.Repositories[i].Name == snapshot.Name {
+				lock.Repositories[i] = *snapshot
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			lock.Repositories = append(lock.Repositories, *snapshot)
+		}
+	}
+	return changes
+}
+
+// ChangedRepositories compares the locked repository snapshots with the current ones. Repositories which are
+// not part of the lockfile are ignored.
+func ChangedRepositories(lock *bazeldnf.Lockfile, current []*bazeldnf.LockedRepository) []RepositoryChange {
+	changes := []RepositoryChange{}
+	for _, snapshot := range current {
+		for i := range lock.Repositories {
+			locked := lock.Repositories[i]
+			if locked.Name == snapshot.Name && !locked.SameSnapshot(snapshot) {
+				changes = append(changes, RepositoryChange{Locked: &locked, Current: snapshot})
+			}
+		}
+	}
+	return changes
+}
@@ -0,0 +1,132 @@
+package lockfile
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func newPackage(name string, version string) *api.Package {
+	return &api.Package{
+		Name:       name,
+		Arch:       "x86_64",
+		Version:    api.Version{Ver: version, Rel: "1.fc32"},
+		Checksum:   api.Checksum{Type: "sha256", Text: name + "-" + version + "-sum"},
+		Location:   api.Location{Href: "Packages/" + name + "-" + version + "-1.fc32.x86_64.rpm"},
+		Repository: &bazeldnf.Repository{Name: "fedora", Mirrors: []string{"https://a.example.com/fedora/"}},
+	}
+}
+
+func TestSetTree(t *testing.T) {
+	g := NewGomegaWithT(t)
+	lock := &bazeldnf.Lockfile{}
+	g.Expect(SetTree(lock, "a", []*api.Package{newPackage("bash", "5.0"), newPackage("glibc", "2.31")})).To(Succeed())
+	g.Expect(SetTree(lock, "b", []*api.Package{newPackage("glibc", "2.31")})).To(Succeed())
+	g.Expect(lock.Packages).To(HaveLen(2))
+
+	g.Expect(SetTree(lock, "a", []*api.Package{newPackage("bash", "5.1"), newPackage("glibc", "2.31")})).To(Succeed())
+	ids := []string{}
+	for _, pkg := range lock.Packages {
+		ids = append(ids, pkg.ID())
+	}
+	g.Expect(ids).To(ConsistOf("bash-0:5.1-1.fc32.x86_64", "glibc-0:2.31-1.fc32.x86_64"))
+	g.Expect(lock.Trees).To(HaveKeyWithValue("b", []string{"glibc-0:2.31-1.fc32.x86_64"}))
+
+	for _, pkg := range lock.Packages {
+		if pkg.Name == "bash" {
+			g.Expect(pkg.URLs).To(Equal([]string{"https://a.example.com/fedora/Packages/bash-5.1-1.fc32.x86_64.rpm"}))
+			g.Expect(pkg.Repository).To(Equal("fedora"))
+			g.Expect(pkg.Checksum).To(Equal("sha256:bash-5.1-sum"))
+		}
+	}
+}
+
+func TestNewLockedPackageWithForeignLocation(t *testing.T) {
+	g := NewGomegaWithT(t)
+	pkg := newPackage("bash", "5.0")
+	pkg.Repository.Mirrors = append(pkg.Repository.Mirrors, "https://b.example.com/fedora/")
+	pkg.Checksum.Type = "sha"
+
+	locked, err := NewLockedPackage(pkg)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(locked.URLs).To(Equal([]string{
+		"https://a.example.com/fedora/Packages/bash-5.0-1.fc32.x86_64.rpm",
+		"https://b.example.com/fedora/Packages/bash-5.0-1.fc32.x86_64.rpm",
+	}))
+	g.Expect(locked.Checksum).To(Equal("sha1:bash-5.0-sum"))
+
+	pkg.Location.Href = "https://cdn.example.com/signed/bash-5.0-1.fc32.x86_64.rpm?token=abc"
+	locked, err = NewLockedPackage(pkg)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(locked.URLs).To(Equal([]string{"https://cdn.example.com/signed/bash-5.0-1.fc32.x86_64.rpm?token=abc"}))
+	g.Expect(locked.Repository).To(Equal("fedora"))
+}
+
+func TestTreePackages(t *testing.T) {
+	g := NewGomegaWithT(t)
+	lock := &bazeldnf.Lockfile{}
+	g.Expect(SetTree(lock, "a", []*api.Package{newPackage("bash", "5.0"), newPackage("glibc", "2.31")})).To(Succeed())
+	g.Expect(SetTree(lock, "b", []*api.Package{newPackage("glibc", "2.31")})).To(Succeed())
+
+	pkgs, exists := TreePackages(lock, "b")
+	g.Expect(exists).To(BeTrue())
+	g.Expect(pkgs).To(HaveLen(1))
+	g.Expect(pkgs[0].ID()).To(Equal("glibc-0:2.31-1.fc32.x86_64"))
+	_, exists = TreePackages(lock, "c")
+	g.Expect(exists).To(BeFalse())
+}
+
+func TestSetRepositories(t *testing.T) {
+	g := NewGomegaWithT(t)
+	lock := &bazeldnf.Lockfile{}
+	first := &bazeldnf.LockedRepository{Name: "fedora", Revision: "1", Timestamp: 10, Data: []bazeldnf.LockedData{{Type: "primary", Checksum: "sha256:1234"}}}
+	g.Expect(SetRepositories(lock, []*bazeldnf.LockedRepository{first})).To(BeEmpty())
+	g.Expect(SetRepositories(lock, []*bazeldnf.LockedRepository{first})).To(BeEmpty())
+
+	second := &bazeldnf.LockedRepository{Name: "fedora", Revision: "2", Timestamp: 20, Data: []bazeldnf.LockedData{{Type: "primary", Checksum: "sha256:5678"}}}
+	changes := SetRepositories(lock, []*bazeldnf.LockedRepository{second})
+	g.Expect(changes).To(HaveLen(1))
+	g.Expect(changes[0].Locked.Revision).To(Equal("1"))
+	g.Expect(changes[0].Current.Revision).To(Equal("2"))
+	g.Expect(lock.Repositories).To(Equal([]bazeldnf.LockedRepository{*second}))
+}
+
+func TestWriteAndLoad(t *testing.T) {
+	g := NewGomegaWithT(t)
+	path := filepath.Join(t.TempDir(), "bazeldnf-lock.json")
+
+	lock, err := LoadOrCreate(path)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(lock.Packages).To(BeEmpty())
+
+	g.Expect(SetTree(lock, "a", []*api.Package{newPackage("glibc", "2.31"), newPackage("bash", "5.0")})).To(Succeed())
+	g.Expect(Write(path, lock)).To(Succeed())
+
+	loaded, err := Load(path)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(loaded.Packages).To(HaveLen(2))
+	g.Expect(loaded.Packages[0].Name).To(Equal("bash"))
+	g.Expect(loaded.Trees["a"]).To(Equal([]string{"bash-0:5.0-1.fc32.x86_64", "glibc-0:2.31-1.fc32.x86_64"}))
+}
+
+func TestRewriteTreeURLs(t *testing.T) {
+	g := NewGomegaWithT(t)
+	lock := &bazeldnf.Lockfile{}
+	g.Expect(SetTree(lock, "a", []*api.Package{newPackage("bash", "5.0")})).To(Succeed())
+	g.Expect(SetTree(lock, "b", []*api.Package{newPackage("glibc", "2.31")})).To(Succeed())
+	RewriteTreeURLs(lock, "b", func(url string) string {
+		return strings.Replace(url, "https://a.example.com/", "https://mirror.example.com/", 1)
+	})
+	urls := map[string][]string{}
+	for _, pkg := range lock.Packages {
+		urls[pkg.Name] = pkg.URLs
+	}
+	g.Expect(urls).To(Equal(map[string][]string{
+		"bash":  {"https://a.example.com/fedora/Packages/bash-5.0-1.fc32.x86_64.rpm"},
+		"glibc": {"https://mirror.example.com/fedora/Packages/glibc-2.31-1.fc32.x86_64.rpm"},
+	}))
+}
@@ -0,0 +1,165 @@
+package lockfile
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// CurrentVersion is the lockfile format version written by this version of bazeldnf
+const CurrentVersion = 2
+
+// migrations[i] migrates a lockfile from version i to version i+1. Lockfiles without a version field have
+// version 0, which is the `{"name": ..., "rpms": [...]}` lock file read by the bzlmod extension.
+var migrations = []func(lock map[string]interface{}) error{
+	migrateV0ToV1,
+	migrateV1ToV2,
+}
+
+// digestVersion is the first format version which requires a digest
+const digestVersion = 2
+
+// migrate brings the raw lockfile data to the current format version and returns the original version. name is
+// the default name of the proxy repository of bzlmod lock files.
+func migrate(data []byte, name string) ([]byte, int, error) {
+	lock := map[string]interface{}{}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, 0, err
+	}
+	original, err := formatVersion(lock)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, exists := lock["name"]; !exists && lock["rpms"] != nil {
+		lock["name"] = name
+	}
+	if original > CurrentVersion {
+		return nil, 0, fmt.Errorf("lockfile format version %d is newer than the supported version %d, please update bazeldnf", original, CurrentVersion)
+	}
+	if original == CurrentVersion {
+		return data, original, nil
+	}
+	for version := original; version < CurrentVersion; version++ {
+		if err := migrations[version](lock); err != nil {
+			return nil, 0, fmt.Errorf("failed to migrate lockfile from version %d to %d: %v", version, version+1, err)
+		}
+	}
+	lock["version"] = CurrentVersion
+	data, err = json.Marshal(lock)
+	return data, original, err
+}
+
+func formatVersion(lock map[string]interface{}) (int, error) {
+	raw, exists := lock["version"]
+	if !exists {
+		return 0, nil
+	}
+	version, ok := raw.(float64)
+	if !ok || version < 0 || version != float64(int(version)) {
+		return 0, fmt.Errorf("invalid lockfile format version %v", raw)
+	}
+	return int(version), nil
+}
+
+// rpmFileName matches the name-version-release.arch.rpm file names of RPMs
+var rpmFileName = regexp.MustCompile(`^(.+)-([^-]+)-([^-]+)\.([^.]+)\.rpm$`)
+
+// migrateV0ToV1 converts the rpms entries of a bzlmod lock file to locked packages in an rpmtree named like the
+// proxy repository. Name, version, release and arch are taken from the file name of the first URL, the epoch from
+// the entry name if it has the name-epoch__version-release.arch form which bazeldnf generates. Entry names
+// which differ from that form are kept as alias, so that labels of the proxy repository don't change.
+func migrateV0ToV1(lock map[string]interface{}) error {
+	rpms, exists := lock["rpms"].([]interface{})
+	if !exists {
+		return nil
+	}
+	delete(lock, "rpms")
+	packages := []interface{}{}
+	ids := []interface{}{}
+	for _, r := range rpms {
+		rpm, ok := r.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("unexpected rpms entry %v", r)
+		}
+		urls, _ := rpm["urls"].([]interface{})
+		if len(urls) == 0 {
+			return fmt.Errorf("rpms entry %v has no urls", rpm["name"])
+		}
+		first, _ := urls[0].(string)
+		u, err := url.Parse(first)
+		if err != nil {
+			return fmt.Errorf("rpms entry %v has an invalid url: %v", rpm["name"], err)
+		}
+		match := rpmFileName.FindStringSubmatch(path.Base(u.Path))
+		if match == nil {
+			return fmt.Errorf("can't determine name, version, release and arch of rpms entry %v from %s", rpm["name"], first)
+		}
+		checksum, err := bzlmodChecksum(rpm)
+		if err != nil {
+			return fmt.Errorf("rpms entry %v: %v", rpm["name"], err)
+		}
+		pkg := map[string]interface{}{
+			"name":     match[1],
+			"version":  match[2],
+			"release":  match[3],
+			"arch":     match[4],
+			"checksum": checksum,
+			"urls":     urls,
+		}
+		epoch := "0"
+		name, _ := rpm["name"].(string)
+		if name == "" {
+			// the bzlmod extension names entries without a name after the last segment of their first URL
+			name = first[strings.LastIndex(first, "/")+1:]
+			pkg["alias"] = name
+		} else {
+			epochPattern := regexp.MustCompile(`^` + regexp.QuoteMeta(sanitize(match[1]+"-")) + `(\d+)__` + regexp.QuoteMeta(sanitize(fmt.Sprintf("%s-%s.%s", match[2], match[3], match[4]))) + `$`)
+			if epochMatch := epochPattern.FindStringSubmatch(name); epochMatch != nil {
+				epoch = epochMatch[1]
+			} else {
+				pkg["alias"] = name
+			}
+		}
+		if epoch != "0" {
+			pkg["epoch"] = epoch
+		}
+		packages = append(packages, pkg)
+		ids = append(ids, fmt.Sprintf("%s-%s:%s-%s.%s", match[1], epoch, match[2], match[3], match[4]))
+	}
+	lock["packages"] = packages
+	lock["trees"] = map[string]interface{}{fmt.Sprint(lock["name"]): ids}
+	return nil
+}
+
+// bzlmodChecksum converts the sha256 or integrity field of a bzlmod rpms entry to a checksum prefixed with its
+// algorithm
+func bzlmodChecksum(rpm map[string]interface{}) (string, error) {
+	if sum, _ := rpm["sha256"].(string); sum != "" {
+		return "sha256:" + sum, nil
+	}
+	integrity, _ := rpm["integrity"].(string)
+	algorithm, encoded, found := strings.Cut(integrity, "-")
+	if !found {
+		return "", fmt.Errorf("neither sha256 nor integrity is set")
+	}
+	sum, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid integrity %s: %v", integrity, err)
+	}
+	return algorithm + ":" + hex.EncodeToString(sum), nil
+}
+
+// sanitize mirrors the bzlmod extension, which turns package IDs into valid repository names
+func sanitize(name string) string {
+	return strings.NewReplacer(":", "__", "+", "__plus__", "~", "__tilde__", "^", "__caret__").Replace(name)
+}
+
+// migrateV1ToV2 does not change the content, version 2 only starts to require a digest
+func migrateV1ToV2(lock map[string]interface{}) error {
+	return nil
+}
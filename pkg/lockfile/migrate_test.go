@@ -0,0 +1,62 @@
+package lockfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func TestLoadMigratesBzlmodLockFiles(t *testing.T) {
+	g := NewGomegaWithT(t)
+	path := filepath.Join(t.TempDir(), "rpms.json")
+	g.Expect(os.WriteFile(path, []byte(`{
+  "rpms": [
+    {"name": "bash-0__5.0-1.fc32.x86_64", "urls": ["https://example.com/bash-5.0-1.fc32.x86_64.rpm"], "sha256": "1234"},
+    {"name": "openssl-1__1.1.1g-1.fc32.x86_64", "urls": ["https://example.com/openssl-1.1.1g-1.fc32.x86_64.rpm"], "sha256": "5678"},
+    {"name": "libstdc++", "urls": ["https://example.com/libstdc++-10.0.1-1.fc32.x86_64.rpm"], "integrity": "sha512-EjQ="},
+    {"urls": ["https://example.com/zlib-1.2.11-21.fc32.x86_64.rpm"], "sha256": "9abc"}
+  ]
+}`), 0666)).To(Succeed())
+
+	lock, err := Load(path)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(lock.Version).To(Equal(CurrentVersion))
+	g.Expect(lock.Name).To(Equal("rpms"))
+	g.Expect(lock.Packages).To(Equal([]bazeldnf.LockedPackage{
+		{Name: "bash", Version: "5.0", Release: "1.fc32", Arch: "x86_64", Checksum: "sha256:1234", URLs: []string{"https://example.com/bash-5.0-1.fc32.x86_64.rpm"}},
+		{Name: "openssl", Epoch: "1", Version: "1.1.1g", Release: "1.fc32", Arch: "x86_64", Checksum: "sha256:5678", URLs: []string{"https://example.com/openssl-1.1.1g-1.fc32.x86_64.rpm"}},
+		{Name: "libstdc++", Version: "10.0.1", Release: "1.fc32", Arch: "x86_64", Checksum: "sha512:1234", URLs: []string{"https://example.com/libstdc++-10.0.1-1.fc32.x86_64.rpm"}, Alias: "libstdc++"},
+		{Name: "zlib", Version: "1.2.11", Release: "21.fc32", Arch: "x86_64", Checksum: "sha256:9abc", URLs: []string{"https://example.com/zlib-1.2.11-21.fc32.x86_64.rpm"}, Alias: "zlib-1.2.11-21.fc32.x86_64.rpm"},
+	}))
+	g.Expect(lock.Trees).To(Equal(map[string][]string{"rpms": {
+		"bash-0:5.0-1.fc32.x86_64",
+		"openssl-1:1.1.1g-1.fc32.x86_64",
+		"libstdc++-0:10.0.1-1.fc32.x86_64",
+		"zlib-0:1.2.11-21.fc32.x86_64",
+	}}))
+
+	g.Expect(os.WriteFile(path, []byte(`{"name": "custom", "rpms": [{"name": "broken", "urls": ["https://example.com/broken.rpm"], "sha256": "1234"}]}`), 0666)).To(Succeed())
+	_, err = Load(path)
+	g.Expect(err).To(MatchError(ContainSubstring("can't determine name, version, release and arch of rpms entry broken")))
+}
+
+func TestLoadRejectsNewerVersions(t *testing.T) {
+	g := NewGomegaWithT(t)
+	path := filepath.Join(t.TempDir(), "bazeldnf-lock.json")
+	g.Expect(os.WriteFile(path, []byte(`{"version": 1000, "packages": []}`), 0666)).To(Succeed())
+
+	_, err := Load(path)
+	g.Expect(err).To(MatchError(ContainSubstring("lockfile format version 1000 is newer than the supported version")))
+}
+
+func TestLoadRejectsInvalidVersions(t *testing.T) {
+	g := NewGomegaWithT(t)
+	path := filepath.Join(t.TempDir(), "bazeldnf-lock.json")
+	g.Expect(os.WriteFile(path, []byte(`{"version": "one"}`), 0666)).To(Succeed())
+
+	_, err := Load(path)
+	g.Expect(err).To(MatchError(ContainSubstring("invalid lockfile format version one")))
+}
@@ -0,0 +1,63 @@
+package lockfile
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+// ProvidesMap maps everything the locked packages provide to the ids of the packages providing it, so that
+// tooling can validate its assumptions about the locked packages without reading repository metadata
+type ProvidesMap struct {
+	// Capabilities are the names of all provides, e.g. `openssl-libs` or `libcrypto.so.3()(64bit)`
+	Capabilities map[string][]string `json:"capabilities"`
+	// Sonames are the shared libraries among the capabilities, e.g. `libcrypto.so.3`
+	Sonames map[string][]string `json:"sonames"`
+	// Files are the absolute paths of all files and directories
+	Files map[string][]string `json:"files"`
+}
+
+func NewProvidesMap() *ProvidesMap {
+	return &ProvidesMap{
+		Capabilities: map[string][]string{},
+		Sonames:      map[string][]string{},
+		Files:        map[string][]string{},
+	}
+}
+
+// Add records the capabilities and files of the locked package
+func (m *ProvidesMap) Add(pkg bazeldnf.LockedPackage, provides []string, files []string) {
+	for _, capability := range provides {
+		addProvider(m.Capabilities, capability, pkg.ID())
+		if soname, ok := Soname(capability); ok {
+			addProvider(m.Sonames, soname, pkg.ID())
+		}
+	}
+	for _, file := range files {
+		addProvider(m.Files, file, pkg.ID())
+	}
+}
+
+// Soname returns the shared library name of capabilities like `libcrypto.so.3()(64bit)` or
+// `libcrypto.so.3(OPENSSL_3.0.0)(64bit)`
+func Soname(capability string) (string, bool) {
+	name, _, found := strings.Cut(capability, "(")
+	if !found || !strings.Contains(name, ".so") || strings.HasPrefix(name, "/") {
+		return "", false
+	}
+	return name, true
+}
+
+// addProvider adds the package to the sorted providers of the key, unless it is already one of them
+func addProvider(providers map[string][]string, key string, id string) {
+	ids := providers[key]
+	i := sort.SearchStrings(ids, id)
+	if i < len(ids) && ids[i] == id {
+		return
+	}
+	ids = append(ids, "")
+	copy(ids[i+1:], ids[i:])
+	ids[i] = id
+	providers[key] = ids
+}
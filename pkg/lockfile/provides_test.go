@@ -0,0 +1,43 @@
+package lockfile
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func TestProvidesMap(t *testing.T) {
+	g := NewGomegaWithT(t)
+	openssl := bazeldnf.LockedPackage{Name: "openssl-libs", Epoch: "1", Version: "3.0.7", Release: "1.fc38", Arch: "x86_64"}
+	compat := bazeldnf.LockedPackage{Name: "openssl-compat", Version: "3.0.7", Release: "1.fc38", Arch: "x86_64"}
+
+	m := NewProvidesMap()
+	m.Add(openssl, []string{"openssl-libs", "libcrypto.so.3()(64bit)", "libcrypto.so.3(OPENSSL_3.0.0)(64bit)", "config(openssl-libs)"},
+		[]string{"/usr/lib64/libcrypto.so.3", "/usr/lib64/libcrypto.so.3.0.7"})
+	m.Add(compat, []string{"libcrypto.so.3()(64bit)"}, []string{"/usr/lib64/libcrypto.so.3"})
+
+	g.Expect(m.Capabilities).To(HaveKeyWithValue("openssl-libs", []string{"openssl-libs-1:3.0.7-1.fc38.x86_64"}))
+	g.Expect(m.Capabilities).To(HaveKeyWithValue("libcrypto.so.3()(64bit)", []string{"openssl-compat-0:3.0.7-1.fc38.x86_64", "openssl-libs-1:3.0.7-1.fc38.x86_64"}))
+	g.Expect(m.Sonames).To(Equal(map[string][]string{
+		"libcrypto.so.3": {"openssl-compat-0:3.0.7-1.fc38.x86_64", "openssl-libs-1:3.0.7-1.fc38.x86_64"},
+	}))
+	g.Expect(m.Files).To(HaveKeyWithValue("/usr/lib64/libcrypto.so.3.0.7", []string{"openssl-libs-1:3.0.7-1.fc38.x86_64"}))
+	g.Expect(m.Files["/usr/lib64/libcrypto.so.3"]).To(HaveLen(2))
+}
+
+func TestSoname(t *testing.T) {
+	g := NewGomegaWithT(t)
+	for capability, expected := range map[string]string{
+		"libc.so.6(GLIBC_2.34)(64bit)":  "libc.so.6",
+		"ld-linux-x86-64.so.2()(64bit)": "ld-linux-x86-64.so.2",
+		"libfoo.so":                     "",
+		"bash":                          "",
+		"config(openssl-libs)":          "",
+		"/usr/lib64/libfoo.so.1":        "",
+	} {
+		soname, ok := Soname(capability)
+		g.Expect(soname).To(Equal(expected), capability)
+		g.Expect(ok).To(Equal(expected != ""), capability)
+	}
+}
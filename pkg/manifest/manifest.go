@@ -0,0 +1,94 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"sigs.k8s.io/yaml"
+)
+
+// Load reads a YAML or JSON manifest file. Annotations are written as comments to bazel files, so line breaks in
+// them are rejected.
+func Load(path string) (*bazeldnf.Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &bazeldnf.Manifest{}
+	if err := yaml.UnmarshalStrict(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %v", path, err)
+	}
+	packages, err := targetPackages(manifest, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range packages {
+		values := append([]string{pkg.Owner, pkg.Reason}, pkg.Tags...)
+		if strings.ContainsAny(strings.Join(values, ""), "\r\n") {
+			return nil, fmt.Errorf("invalid manifest %s: the annotations of %s contain a line break", path, pkg.Name)
+		}
+	}
+	return manifest, nil
+}
+
+// Packages returns the packages which are requested for the target, starting with the packages shared by all
+// targets. Without a target the packages of all targets are returned. Duplicates are removed.
+func Packages(manifest *bazeldnf.Manifest, target string) ([]string, error) {
+	packages, err := targetPackages(manifest, target)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, pkg := range packages {
+		if !seen[pkg.Name] {
+			seen[pkg.Name] = true
+			unique = append(unique, pkg.Name)
+		}
+	}
+	return unique, nil
+}
+
+// Annotations returns the annotations of the packages requested for the target by package name. If a package
+// is annotated more than once, the annotations of the target win over the shared ones.
+func Annotations(manifest *bazeldnf.Manifest, target string) (map[string]bazeldnf.PackageAnnotations, error) {
+	packages, err := targetPackages(manifest, target)
+	if err != nil {
+		return nil, err
+	}
+	annotations := map[string]bazeldnf.PackageAnnotations{}
+	for _, pkg := range packages {
+		if !pkg.PackageAnnotations.Empty() {
+			annotations[pkg.Name] = pkg.PackageAnnotations
+		}
+	}
+	return annotations, nil
+}
+
+func targetPackages(manifest *bazeldnf.Manifest, target string) ([]bazeldnf.ManifestPackage, error) {
+	packages := append([]bazeldnf.ManifestPackage{}, manifest.Packages...)
+	if target != "" {
+		t, exists := manifest.Targets[target]
+		if !exists {
+			return nil, fmt.Errorf("manifest has no target %s, known are %s", target, strings.Join(Targets(manifest), ", "))
+		}
+		return append(packages, t.Packages...), nil
+	}
+	for _, name := range Targets(manifest) {
+		packages = append(packages, manifest.Targets[name].Packages...)
+	}
+	return packages, nil
+}
+
+// Targets returns the sorted names of all targets of the manifest
+func Targets(manifest *bazeldnf.Manifest) []string {
+	names := []string{}
+	for name := range manifest.Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,73 @@
+package manifest
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func TestPackages(t *testing.T) {
+	g := NewGomegaWithT(t)
+	file := path.Join(t.TempDir(), "packages.yaml")
+	g.Expect(os.WriteFile(file, []byte(`# shared by all images
+packages:
+- ca-certificates
+targets:
+  bashtree:
+    packages:
+    - name: bash
+      owner: team-runtime
+      reason: used by the entrypoint script
+      tags: [shell]
+    - ca-certificates
+  libvirttree:
+    packages:
+    - libvirt-devel
+`), 0666)).To(Succeed())
+	manifest, err := Load(file)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	pkgs, err := Packages(manifest, "bashtree")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pkgs).To(Equal([]string{"ca-certificates", "bash"}))
+
+	pkgs, err = Packages(manifest, "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pkgs).To(Equal([]string{"ca-certificates", "bash", "libvirt-devel"}))
+
+	_, err = Packages(manifest, "missing")
+	g.Expect(err).To(MatchError("manifest has no target missing, known are bashtree, libvirttree"))
+
+	annotations, err := Annotations(manifest, "bashtree")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(annotations).To(Equal(map[string]bazeldnf.PackageAnnotations{
+		"bash": {Owner: "team-runtime", Reason: "used by the entrypoint script", Tags: []string{"shell"}},
+	}))
+	annotations, err = Annotations(manifest, "libvirttree")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(annotations).To(BeEmpty())
+}
+
+func TestLoadJSON(t *testing.T) {
+	g := NewGomegaWithT(t)
+	file := path.Join(t.TempDir(), "packages.json")
+	g.Expect(os.WriteFile(file, []byte(`{"targets": {"bashtree": {"packages": ["bash"]}}}`), 0666)).To(Succeed())
+	manifest, err := Load(file)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(manifest.Targets["bashtree"].Packages).To(Equal([]bazeldnf.ManifestPackage{{Name: "bash"}}))
+
+	g.Expect(os.WriteFile(file, []byte(`{"target": {"bashtree": {"packages": ["bash"]}}}`), 0666)).To(Succeed())
+	_, err = Load(file)
+	g.Expect(err).To(MatchError(ContainSubstring("failed to parse manifest")))
+
+	g.Expect(os.WriteFile(file, []byte(`{"packages": [{"name": "bash", "ownr": "team-runtime"}]}`), 0666)).To(Succeed())
+	_, err = Load(file)
+	g.Expect(err).To(MatchError(ContainSubstring(`unknown field "ownr"`)))
+
+	g.Expect(os.WriteFile(file, []byte(`{"packages": [{"name": "bash", "reason": "shell\nrpm(name = \"evil\")"}]}`), 0666)).To(Succeed())
+	_, err = Load(file)
+	g.Expect(err).To(MatchError(ContainSubstring("the annotations of bash contain a line break")))
+}
@@ -0,0 +1,150 @@
+package pkgconfig
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var variableRegex = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// Package contains the information of a pkg-config .pc file with all variables expanded
+type Package struct {
+	Name        string
+	Version     string
+	Description string
+	Cflags      []string
+	Libs        []string
+	// Requires contains the names of all public pkg-config dependencies without version constraints
+	Requires []string
+}
+
+// Defines returns the preprocessor definitions from the Cflags, without the -D prefix
+func (p *Package) Defines() []string {
+	return flagValues(p.Cflags, "-D")
+}
+
+// IncludeDirs returns the include directories from the Cflags, without the -I prefix
+func (p *Package) IncludeDirs() []string {
+	return flagValues(p.Cflags, "-I")
+}
+
+// LinkLibraries returns the names of all libraries referenced with -l in Libs
+func (p *Package) LinkLibraries() []string {
+	return flagValues(p.Libs, "-l")
+}
+
+// Linkopts returns all flags in Libs which are not -l or -L flags
+func (p *Package) Linkopts() (opts []string) {
+	for _, flag := range p.Libs {
+		if strings.HasPrefix(flag, "-l") || strings.HasPrefix(flag, "-L") {
+			continue
+		}
+		opts = append(opts, flag)
+	}
+	return opts
+}
+
+// ParseFile parses the .pc file at the given location. The name of the package is the file name without the
+// .pc suffix.
+func ParseFile(path string) (*Package, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	pkg, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	pkg.Name = strings.TrimSuffix(filepath.Base(path), ".pc")
+	return pkg, nil
+}
+
+// Parse parses the content of a .pc file
+func Parse(reader io.Reader) (*Package, error) {
+	pkg := &Package{}
+	variables := map[string]string{}
+	scanner := bufio.NewScanner(reader)
+	line := ""
+	for scanner.Scan() {
+		line += scanner.Text()
+		if strings.HasSuffix(line, "\\") {
+			line = strings.TrimSuffix(line, "\\")
+			continue
+		}
+		current := strings.TrimSpace(stripComment(line))
+		line = ""
+		if current == "" {
+			continue
+		}
+		keywordIdx := strings.Index(current, ":")
+		variableIdx := strings.Index(current, "=")
+		if variableIdx > 0 && (keywordIdx < 0 || variableIdx < keywordIdx) {
+			name := strings.TrimSpace(current[:variableIdx])
+			variables[name] = expand(strings.TrimSpace(current[variableIdx+1:]), variables)
+			continue
+		}
+		if keywordIdx <= 0 {
+			return nil, fmt.Errorf("invalid line %q", current)
+		}
+		value := expand(strings.TrimSpace(current[keywordIdx+1:]), variables)
+		switch strings.TrimSpace(current[:keywordIdx]) {
+		case "Description":
+			pkg.Description = value
+		case "Version":
+			pkg.Version = value
+		case "Cflags":
+			pkg.Cflags = strings.Fields(value)
+		case "Libs":
+			pkg.Libs = strings.Fields(value)
+		case "Requires":
+			pkg.Requires = parseRequires(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// parseRequires extracts the module names from a requirement list like `glib-2.0 >= 2.50, gobject-2.0`
+func parseRequires(value string) (requires []string) {
+	fields := strings.Fields(strings.ReplaceAll(value, ",", " "))
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "=", "<", ">", "<=", ">=", "!=":
+			// skip the operator and the version
+			i++
+			continue
+		}
+		requires = append(requires, fields[i])
+	}
+	return requires
+}
+
+func expand(value string, variables map[string]string) string {
+	return variableRegex.ReplaceAllStringFunc(value, func(match string) string {
+		return variables[variableRegex.FindStringSubmatch(match)[1]]
+	})
+}
+
+func stripComment(line string) string {
+	if idx := strings.Index(line, "#"); idx >= 0 {
+		return line[:idx]
+	}
+	return line
+}
+
+func flagValues(flags []string, prefix string) (values []string) {
+	for _, flag := range flags {
+		if strings.HasPrefix(flag, prefix) && len(flag) > len(prefix) {
+			values = append(values, strings.TrimPrefix(flag, prefix))
+		}
+	}
+	return values
+}
@@ -0,0 +1,43 @@
+package pkgconfig
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+const libvirtPC = `prefix=/usr
+exec_prefix=/usr
+libdir=/usr/lib64
+includedir=/usr/include
+datarootdir=${prefix}/share
+
+# a comment
+Name: libvirt
+Version: 6.1.0
+Description: libvirt library
+Requires: glib-2.0 >= 2.48, \
+  gobject-2.0
+Libs: -L${libdir} -lvirt -pthread
+Cflags: -I${includedir}/libvirt -DLIBVIRT_ENABLED
+`
+
+func TestParse(t *testing.T) {
+	g := NewGomegaWithT(t)
+	pkg, err := Parse(strings.NewReader(libvirtPC))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pkg.Version).To(Equal("6.1.0"))
+	g.Expect(pkg.Description).To(Equal("libvirt library"))
+	g.Expect(pkg.Requires).To(Equal([]string{"glib-2.0", "gobject-2.0"}))
+	g.Expect(pkg.IncludeDirs()).To(Equal([]string{"/usr/include/libvirt"}))
+	g.Expect(pkg.Defines()).To(Equal([]string{"LIBVIRT_ENABLED"}))
+	g.Expect(pkg.LinkLibraries()).To(Equal([]string{"virt"}))
+	g.Expect(pkg.Linkopts()).To(Equal([]string{"-pthread"}))
+}
+
+func TestParseInvalid(t *testing.T) {
+	g := NewGomegaWithT(t)
+	_, err := Parse(strings.NewReader("this is not a pc file"))
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,145 @@
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Types of the events which are emitted
+const (
+	FetchStarted     = "fetch_started"
+	FetchFinished    = "fetch_finished"
+	DownloadStarted  = "download_started"
+	DownloadProgress = "download_progress"
+	DownloadFinished = "download_finished"
+	PhaseStarted     = "phase_started"
+	PhaseFinished    = "phase_finished"
+	RulesWritten     = "rules_written"
+)
+
+// progressInterval is the number of bytes after which another download_progress event is emitted
+const progressInterval = 1024 * 1024
+
+// Event is a single line of the progress event stream
+type Event struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"`
+	Repository string    `json:"repository,omitempty"`
+	URL        string    `json:"url,omitempty"`
+	File       string    `json:"file,omitempty"`
+	Phase      string    `json:"phase,omitempty"`
+	// Bytes is the number of bytes downloaded so far
+	Bytes int64 `json:"bytes,omitempty"`
+	// Total is the expected size of a download, if it is known
+	Total int64 `json:"total,omitempty"`
+	// Count is the number of rules written to a file
+	Count int    `json:"count,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+var sink struct {
+	lock    sync.Mutex
+	encoder *json.Encoder
+}
+
+// SetOutput makes all following events to be written to the writer as newline-delimited JSON. A nil writer
+// disables the event stream again.
+func SetOutput(writer io.Writer) {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	if writer == nil {
+		sink.encoder = nil
+		return
+	}
+	sink.encoder = json.NewEncoder(writer)
+}
+
+// Open opens the target of the event stream, which is either a file descriptor number like `3` or a file
+func Open(target string) (io.WriteCloser, error) {
+	if fd, err := strconv.ParseUint(target, 10, 32); err == nil {
+		file := os.NewFile(uintptr(fd), "fd"+target)
+		// os.NewFile accepts any number, only fstat tells whether the descriptor is open
+		if _, err := file.Stat(); err != nil {
+			return nil, fmt.Errorf("invalid file descriptor %s for progress events: %v", target, err)
+		}
+		return file, nil
+	}
+	file, err := os.Create(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s for progress events: %v", target, err)
+	}
+	return file, nil
+}
+
+// Emit writes the event to the event stream, if one is configured
+func Emit(event Event) {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	if sink.encoder == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	// progress reporting must never break the actual work, so write errors are ignored
+	_ = sink.encoder.Encode(&event)
+}
+
+// Phase emits a phase_started event, runs the phase and emits the matching phase_finished event, including the
+// error the phase failed with
+func Phase(phase string, run func() error) (err error) {
+	Emit(Event{Type: PhaseStarted, Phase: phase})
+	defer func() {
+		event := Event{Type: PhaseFinished, Phase: phase}
+		if err != nil {
+			event.Error = err.Error()
+		}
+		Emit(event)
+	}()
+	return run()
+}
+
+// Download reports the progress of reading a download. It emits download_progress events while it is read and
+// a download_finished event once Finish is called.
+type Download struct {
+	reader     io.Reader
+	repository string
+	url        string
+	bytes      int64
+	total      int64
+	reported   int64
+}
+
+// NewDownload emits a download_started event and returns a reader which reports the progress of reading body.
+// Total is the expected size or 0 if it is unknown.
+func NewDownload(repository string, url string, total int64, body io.Reader) *Download {
+	if total < 0 {
+		total = 0
+	}
+	Emit(Event{Type: DownloadStarted, Repository: repository, URL: url, Total: total})
+	return &Download{reader: body, repository: repository, url: url, total: total}
+}
+
+func (d *Download) Read(p []byte) (int, error) {
+	n, err := d.reader.Read(p)
+	d.bytes += int64(n)
+	if d.bytes-d.reported >= progressInterval {
+		d.reported = d.bytes
+		Emit(Event{Type: DownloadProgress, Repository: d.repository, URL: d.url, Bytes: d.bytes, Total: d.total})
+	}
+	return n, err
+}
+
+// Finish emits the download_finished event, including the error the download failed with
+func (d *Download) Finish(err error) {
+	event := Event{Type: DownloadFinished, Repository: d.repository, URL: d.url, Bytes: d.bytes, Total: d.total}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	Emit(event)
+}
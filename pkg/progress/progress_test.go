@@ -0,0 +1,76 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func decodeEvents(g *WithT, output *bytes.Buffer) []Event {
+	events := []Event{}
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		event := Event{}
+		g.Expect(json.Unmarshal([]byte(line), &event)).To(Succeed())
+		g.Expect(event.Time.IsZero()).To(BeFalse())
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestEvents(t *testing.T) {
+	g := NewGomegaWithT(t)
+	output := &bytes.Buffer{}
+	SetOutput(output)
+	defer SetOutput(nil)
+
+	g.Expect(Phase("solve", func() error { return nil })).To(Succeed())
+	download := NewDownload("fedora", "https://example.com/primary.xml.gz", -1, bytes.NewReader(make([]byte, 2*progressInterval+10)))
+	_, err := io.Copy(io.Discard, download)
+	g.Expect(err).ToNot(HaveOccurred())
+	download.Finish(fmt.Errorf("checksum mismatch"))
+
+	types := []string{}
+	for _, event := range decodeEvents(g, output) {
+		types = append(types, event.Type)
+	}
+	g.Expect(types).To(Equal([]string{PhaseStarted, PhaseFinished, DownloadStarted, DownloadProgress, DownloadProgress, DownloadFinished}))
+	events := decodeEvents(g, output)
+	g.Expect(events[2].Total).To(BeZero())
+	g.Expect(events[5].Bytes).To(Equal(int64(2*progressInterval + 10)))
+	g.Expect(events[5].Error).To(Equal("checksum mismatch"))
+	g.Expect(events[5].Repository).To(Equal("fedora"))
+}
+
+func TestDisabled(t *testing.T) {
+	g := NewGomegaWithT(t)
+	SetOutput(nil)
+	Emit(Event{Type: RulesWritten})
+	download := NewDownload("fedora", "https://example.com/repomd.xml", 3, strings.NewReader("xml"))
+	data, err := io.ReadAll(download)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(data)).To(Equal("xml"))
+}
+
+func TestFailedPhase(t *testing.T) {
+	g := NewGomegaWithT(t)
+	output := &bytes.Buffer{}
+	SetOutput(output)
+	defer SetOutput(nil)
+
+	g.Expect(Phase("load", func() error { return fmt.Errorf("no metadata") })).To(MatchError("no metadata"))
+	events := decodeEvents(g, output)
+	g.Expect(events).To(HaveLen(2))
+	g.Expect(events[1].Type).To(Equal(PhaseFinished))
+	g.Expect(events[1].Error).To(Equal("no metadata"))
+}
+
+func TestOpenInvalidFileDescriptor(t *testing.T) {
+	g := NewGomegaWithT(t)
+	_, err := Open("9999")
+	g.Expect(err).To(MatchError(ContainSubstring("invalid file descriptor 9999")))
+}
@@ -0,0 +1,148 @@
+/*
+Package provenance creates in-toto statements with SLSA provenance predicates for artifacts generated by bazeldnf.
+*/
+package provenance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	StatementType   = "https://in-toto.io/Statement/v1"
+	PredicateType   = "https://slsa.dev/provenance/v1"
+	BuilderID       = "https://github.com/rmohr/bazeldnf"
+	buildTypeBase   = "https://github.com/rmohr/bazeldnf/"
+	buildTypeSchema = "@v1"
+)
+
+type Statement struct {
+	Type          string     `json:"_type"`
+	Subject       []Subject  `json:"subject"`
+	PredicateType string     `json:"predicateType"`
+	Predicate     Provenance `json:"predicate"`
+}
+
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+type Provenance struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+type BuildDefinition struct {
+	BuildType            string                 `json:"buildType"`
+	ExternalParameters   map[string]interface{} `json:"externalParameters"`
+	ResolvedDependencies []ResourceDescriptor   `json:"resolvedDependencies,omitempty"`
+}
+
+type ResourceDescriptor struct {
+	Name   string            `json:"name,omitempty"`
+	URI    string            `json:"uri,omitempty"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+type RunDetails struct {
+	Builder  Builder  `json:"builder"`
+	Metadata Metadata `json:"metadata"`
+}
+
+type Builder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version,omitempty"`
+}
+
+type Metadata struct {
+	StartedOn  string `json:"startedOn,omitempty"`
+	FinishedOn string `json:"finishedOn,omitempty"`
+}
+
+// NewStatement creates a statement for the given bazeldnf command. It carries no timestamps unless Start is called,
+// so that statements written by Bazel actions are reproducible.
+func NewStatement(command string, parameters map[string]interface{}) *Statement {
+	return &Statement{
+		Type:          StatementType,
+		PredicateType: PredicateType,
+		Predicate: Provenance{
+			BuildDefinition: BuildDefinition{
+				BuildType:          buildTypeBase + command + buildTypeSchema,
+				ExternalParameters: parameters,
+			},
+			RunDetails: RunDetails{
+				Builder: Builder{
+					ID:      BuilderID,
+					Version: map[string]string{"bazeldnf": Version()},
+				},
+			},
+		},
+	}
+}
+
+// Start records the current time as the start of the build. Write then records when the build finished.
+func (s *Statement) Start() {
+	s.Predicate.RunDetails.Metadata.StartedOn = time.Now().UTC().Format(time.RFC3339)
+}
+
+// AddSubject adds the file at the given path as produced artifact
+func (s *Statement) AddSubject(path string) error {
+	digest, err := FileDigest(path)
+	if err != nil {
+		return err
+	}
+	s.Subject = append(s.Subject, Subject{Name: filepath.Base(path), Digest: digest})
+	return nil
+}
+
+// AddDependency records an input which was used to produce the artifacts
+func (s *Statement) AddDependency(name string, uri string, digest map[string]string) {
+	s.Predicate.BuildDefinition.ResolvedDependencies = append(s.Predicate.BuildDefinition.ResolvedDependencies, ResourceDescriptor{
+		Name:   name,
+		URI:    uri,
+		Digest: digest,
+	})
+}
+
+// Write marks started statements as finished and writes the statement to the given path
+func (s *Statement) Write(path string) error {
+	if s.Predicate.RunDetails.Metadata.StartedOn != "" {
+		s.Predicate.RunDetails.Metadata.FinishedOn = time.Now().UTC().Format(time.RFC3339)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal provenance: %v", err)
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0666)
+}
+
+// FileDigest returns the sha256 digest of the file at the given path in the in-toto digest format
+func FileDigest(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+	sha := sha256.New()
+	if _, err := io.Copy(sha, f); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return map[string]string{"sha256": hex.EncodeToString(sha.Sum(nil))}, nil
+}
+
+// Version returns the version of the running bazeldnf binary
+func Version() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
@@ -0,0 +1,48 @@
+package provenance
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestStatement(t *testing.T) {
+	g := NewGomegaWithT(t)
+	dir := t.TempDir()
+	artifact := filepath.Join(dir, "tree.tar")
+	g.Expect(ioutil.WriteFile(artifact, []byte("content"), 0666)).To(Succeed())
+
+	statement := NewStatement("rpm2tar", map[string]interface{}{"arguments": []string{"rpm2tar"}})
+	g.Expect(statement.AddSubject(artifact)).To(Succeed())
+	statement.AddDependency("bash-0:5.0.17-1.fc32.x86_64", "https://example.com/bash.rpm", map[string]string{"sha256": "1234"})
+	g.Expect(statement.Write(filepath.Join(dir, "provenance.json"))).To(Succeed())
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "provenance.json"))
+	g.Expect(err).ToNot(HaveOccurred())
+	read := &Statement{}
+	g.Expect(json.Unmarshal(data, read)).To(Succeed())
+	g.Expect(read.Type).To(Equal(StatementType))
+	g.Expect(read.Subject).To(Equal([]Subject{{
+		Name:   "tree.tar",
+		Digest: map[string]string{"sha256": "ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73"},
+	}}))
+	g.Expect(read.Predicate.BuildDefinition.BuildType).To(Equal("https://github.com/rmohr/bazeldnf/rpm2tar@v1"))
+	g.Expect(read.Predicate.BuildDefinition.ResolvedDependencies).To(HaveLen(1))
+	g.Expect(read.Predicate.RunDetails.Metadata).To(Equal(Metadata{}))
+
+	// statements without timestamps are reproducible
+	g.Expect(statement.Write(filepath.Join(dir, "again.json"))).To(Succeed())
+	g.Expect(ioutil.ReadFile(filepath.Join(dir, "again.json"))).To(Equal(data))
+
+	statement.Start()
+	g.Expect(statement.Write(filepath.Join(dir, "provenance.json"))).To(Succeed())
+	data, err = ioutil.ReadFile(filepath.Join(dir, "provenance.json"))
+	g.Expect(err).ToNot(HaveOccurred())
+	read = &Statement{}
+	g.Expect(json.Unmarshal(data, read)).To(Succeed())
+	g.Expect(read.Predicate.RunDetails.Metadata.StartedOn).ToNot(BeEmpty())
+	g.Expect(read.Predicate.RunDetails.Metadata.FinishedOn).ToNot(BeEmpty())
+}
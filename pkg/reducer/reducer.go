@@ -9,6 +9,7 @@ import (
 	"github.com/rmohr/bazeldnf/pkg/api"
 	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
 	"github.com/rmohr/bazeldnf/pkg/repo"
+	"github.com/rmohr/bazeldnf/pkg/rpmarch"
 	"github.com/sirupsen/logrus"
 )
 
@@ -22,6 +23,20 @@ type RepoReducer struct {
 	architectures    []string
 	repos            *bazeldnf.Repositories
 	cacheHelper      *repo.CacheHelper
+	weakDeps         bool
+	ignoreWeakDeps   []bazeldnf.WeakDepsFilter
+}
+
+// SetWeakDeps makes the reducer follow the recommendations of packages, except for the ignored ones
+func (r *RepoReducer) SetWeakDeps(ignore []bazeldnf.WeakDepsFilter) {
+	r.weakDeps = true
+	r.ignoreWeakDeps = ignore
+}
+
+// SetAllowForeignArch makes the reducer consider the multilib compatibility packages which repositories of the
+// target architecture carry, like i686 packages in x86_64 repositories. They are dropped by default.
+func (r *RepoReducer) SetAllowForeignArch() {
+	r.architectures = append(r.architectures, rpmarch.CompatArches(r.arch)...)
 }
 
 func (r *RepoReducer) Load() error {
@@ -103,7 +118,7 @@ func (r *RepoReducer) Resolve(packages []string) (matched []string, involved []*
 			return nil, nil, fmt.Errorf("Package %s does not exist", req)
 		}
 		for i, p := range candidates {
-			discovered[p.String()] = candidates[i]
+			discovered[p.ID()] = candidates[i]
 		}
 
 		if len(candidates) > 0 {
@@ -122,9 +137,9 @@ func (r *RepoReducer) Resolve(packages []string) (matched []string, involved []*
 		}
 		for _, p := range current {
 			for _, newFound := range r.requires(discovered[p]) {
-				if _, exists := discovered[newFound.String()]; !exists {
+				if _, exists := discovered[newFound.ID()]; !exists {
 					if _, exists := pinned[newFound.Name]; !exists {
-						discovered[newFound.String()] = newFound
+						discovered[newFound.ID()] = newFound
 					} else {
 						logrus.Debugf("excluding %s because of pinned dependency %s", newFound.String(), pinned[newFound.Name].String())
 					}
@@ -141,6 +156,9 @@ func (r *RepoReducer) Resolve(packages []string) (matched []string, involved []*
 		for _, req := range pkg.Format.Requires.Entries {
 			required[req.Name] = struct{}{}
 		}
+		for _, req := range r.recommends(pkg) {
+			required[req.Name] = struct{}{}
+		}
 		involved = append(involved, discovered[i])
 	}
 	// remove all provides which are not required in the reduced set
@@ -171,9 +189,39 @@ func (r *RepoReducer) requires(p *api.Package) (wants []*api.Package) {
 			logrus.Debugf("%s requires %v which can't be satisfied\n", p.Name, requires)
 		}
 	}
+	for _, recommends := range r.recommends(p) {
+		if val, exists := r.provides[recommends.Name]; exists {
+			logrus.Debugf("%s recommends %v\n", p.Name, recommends)
+			wants = append(wants, val...)
+		}
+	}
 	return wants
 }
 
+// recommends returns the weak dependencies of the package which should be followed
+func (r *RepoReducer) recommends(p *api.Package) (recommends []api.Entry) {
+	if !r.weakDeps {
+		return nil
+	}
+	for _, entry := range p.Format.Recommends.Entries {
+		if strings.HasPrefix(entry.Name, "(") || IgnoresWeakDep(r.ignoreWeakDeps, p.Name, entry.Name) {
+			continue
+		}
+		recommends = append(recommends, entry)
+	}
+	return recommends
+}
+
+// IgnoresWeakDep returns true if any of the filters ignores the weak dependency of the package on the capability
+func IgnoresWeakDep(filters []bazeldnf.WeakDepsFilter, pkg string, capability string) bool {
+	for _, f := range filters {
+		if f.Ignores(pkg, capability) {
+			return true
+		}
+	}
+	return false
+}
+
 func NewRepoReducer(repos *bazeldnf.Repositories, repoFiles []string, lang string, baseSystem string, arch string, cachDir string) *RepoReducer {
 	return &RepoReducer{
 		packages:         nil,
@@ -1,13 +1,14 @@
 package repo
 
 import (
-	"compress/gzip"
+	"crypto/sha256"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/rmohr/bazeldnf/pkg/api"
@@ -27,7 +28,11 @@ func (r *CacheHelper) LoadMetaLink(repo *bazeldnf.Repository) (*api.Metalink, er
 	return metalink, nil
 }
 
-func (r *CacheHelper) WriteToRepoDir(repo *bazeldnf.Repository, body io.Reader, name string) error {
+// WriteToRepoDir writes body to a temporary file in the cache directory of the repository and atomically
+// moves it in place. If verify is not nil, it is called with the path of the temporary file after the body
+// was written completely and the file is only moved in place if verify succeeds. This way a crash or a
+// checksum mismatch never leaves a corrupt file behind.
+func (r *CacheHelper) WriteToRepoDir(repo *bazeldnf.Repository, body io.Reader, name string, verify func(tmpFile string) error) error {
 	dir := filepath.Join(r.CacheDir, repo.Name)
 	file := filepath.Join(dir, name)
 
@@ -35,15 +40,30 @@ func (r *CacheHelper) WriteToRepoDir(repo *bazeldnf.Repository, body io.Reader,
 	if err != nil && !os.IsExist(err) {
 		return fmt.Errorf("failed to create cache directory for %s: %v", repo.Name, err)
 	}
-	f, err := os.Create(file)
+	f, err := os.CreateTemp(dir, "."+name+".tmp-*")
 	if err != nil {
-		return fmt.Errorf("failed to open file %s: %v", file, err)
+		return fmt.Errorf("failed to open temporary file for %s: %v", file, err)
 	}
+	defer os.Remove(f.Name())
 	defer f.Close()
 	_, err = io.Copy(f, body)
 	if err != nil {
 		return fmt.Errorf("failed to write file %s: %v", file, err)
 	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write file %s: %v", file, err)
+	}
+	if verify != nil {
+		if err := verify(f.Name()); err != nil {
+			return err
+		}
+	}
+	if err := os.Chmod(f.Name(), 0660); err != nil {
+		return fmt.Errorf("failed to set permissions of %s: %v", file, err)
+	}
+	if err := os.Rename(f.Name(), file); err != nil {
+		return fmt.Errorf("failed to move %s in place: %v", file, err)
+	}
 	return nil
 }
 
@@ -57,6 +77,20 @@ func (r *CacheHelper) OpenFromRepoDir(repo *bazeldnf.Repository, name string) (i
 	return f, err
 }
 
+// RepomdSHA256 returns the sha256 sum of the cached repomd.xml file of the repository
+func (r *CacheHelper) RepomdSHA256(repo *bazeldnf.Repository) (string, error) {
+	reader, err := r.OpenFromRepoDir(repo, "repomd.xml")
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	sha := sha256.New()
+	if _, err := io.Copy(sha, reader); err != nil {
+		return "", fmt.Errorf("failed to read repomd.xml of %s: %v", repo.Name, err)
+	}
+	return toHex(sha), nil
+}
+
 func (r *CacheHelper) UnmarshalFromRepoDir(repo *bazeldnf.Repository, name string, obj interface{}) error {
 	reader, err := r.OpenFromRepoDir(repo, name)
 	if err != nil {
@@ -72,14 +106,17 @@ func (r *CacheHelper) CurrentPrimary(repo *bazeldnf.Repository) (*api.Repository
 		return nil, err
 	}
 	primary := repomd.File(api.PrimaryFileType)
-	primaryName := filepath.Base(primary.Location.Href)
+	if primary == nil {
+		return nil, fmt.Errorf("no primary file referenced in repomd.xml of %s", repo.Name)
+	}
+	primaryName := primary.Location.FileName()
 	file, err := r.OpenFromRepoDir(repo, primaryName)
 	if err != nil {
 		return nil, err
 	}
 
 	defer file.Close()
-	reader, err := gzip.NewReader(file)
+	reader, err := decompress(primaryName, file)
 	if err != nil {
 		return nil, err
 	}
@@ -91,30 +128,53 @@ func (r *CacheHelper) CurrentPrimary(repo *bazeldnf.Repository) (*api.Repository
 		return nil, err
 	}
 
-	if len(repo.Mirrors) == 0 && repo.Metalink != "" {
+	if err := r.ResolveMirrors(repo); err != nil {
+		return nil, err
+	}
+
+	for i, _ := range repository.Packages {
+		repository.Packages[i].Repository = repo
+	}
+	return repository, nil
+}
+
+// ResolveMirrors sets the mirrors of the repository from which its RPMs are downloaded if they are not configured
+// explicitly. Mirrors of metalinks and mirror lists are taken from the cache.
+func (r *CacheHelper) ResolveMirrors(repo *bazeldnf.Repository) error {
+	if len(repo.Mirrors) == 0 && repo.Koji != nil {
+		repo.Mirrors = []string{kojiPackagesURL(repo.Koji)}
+	} else if len(repo.Mirrors) == 0 && repo.Metalink != "" {
 		metalink, err := r.LoadMetaLink(repo)
 		if err == nil {
+			filter := bazeldnf.MetalinkFilter{}
+			if repo.MetalinkFilter != nil {
+				filter = *repo.MetalinkFilter
+			}
+			if filter.MaxMirrors == 0 {
+				filter.MaxMirrors = 4
+			}
+			mirrors, err := FilterMirrors(metalink.Repomod().Resources.URLs, &filter)
+			if err != nil {
+				return err
+			}
 			urls := []string{}
-			for _, url := range metalink.Repomod().Resources.URLs {
-				if url.Type == "https" {
-					urls = append(urls, strings.TrimSuffix(url.Text, "repodata/repomd.xml"))
-				}
-				if len(urls) == 4 {
-					break
-				}
+			for _, mirror := range mirrors {
+				urls = append(urls, strings.TrimSuffix(mirror, "repodata/repomd.xml"))
 			}
 			repo.Mirrors = urls
 		} else if !os.IsNotExist(err) {
-			return nil, err
+			return err
 		}
-	} else if len(repo.Mirrors) == 0 && repo.Baseurl != "" {
-		repo.Mirrors = []string{repo.Baseurl}
-	}
-
-	for i, _ := range repository.Packages {
-		repository.Packages[i].Repository = repo
+	} else if len(repo.Mirrors) == 0 && repo.Mirrorlist != "" {
+		baseurls, err := r.LoadMirrorlist(repo)
+		if err != nil {
+			return err
+		}
+		repo.Mirrors = baseurls
+	} else if len(repo.Mirrors) == 0 && len(repo.Baseurl) > 0 {
+		repo.Mirrors = repo.Baseurl
 	}
-	return repository, nil
+	return nil
 }
 
 func (r *CacheHelper) CurrentFilelistsForPackages(repo *bazeldnf.Repository, arches []string, packages []*api.Package) (filelistpkgs []*api.FileListPackage, remaining []*api.Package, err error) {
@@ -123,14 +183,14 @@ func (r *CacheHelper) CurrentFilelistsForPackages(repo *bazeldnf.Repository, arc
 		return nil, nil, err
 	}
 	filelists := repomd.File(api.FilelistsFileType)
-	filelistsName := filepath.Base(filelists.Location.Href)
+	filelistsName := filelists.Location.FileName()
 	file, err := r.OpenFromRepoDir(repo, filelistsName)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	defer file.Close()
-	reader, err := gzip.NewReader(file)
+	reader, err := decompress(filelistsName, file)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -212,7 +272,7 @@ func (r *CacheHelper) CurrentFilelistsForPackages(repo *bazeldnf.Repository, arc
 
 func (r *CacheHelper) CurrentPrimaries(repos *bazeldnf.Repositories, arch string) (primaries []*api.Repository, err error) {
 	for i, repo := range repos.Repositories {
-		if repo.Arch != arch {
+		if !repo.ServesArch(arch) {
 			continue
 		}
 		primary, err := r.CurrentPrimary(&repos.Repositories[i])
@@ -223,3 +283,143 @@ func (r *CacheHelper) CurrentPrimaries(repos *bazeldnf.Repositories, arch string
 	}
 	return primaries, err
 }
+
+func unmarshalFile(file string, obj interface{}) error {
+	reader, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return xml.NewDecoder(reader).Decode(obj)
+}
+
+// Snapshot describes the state of the cached repository metadata, so that it can be recorded in a lockfile
+func (r *CacheHelper) Snapshot(repo *bazeldnf.Repository) (*bazeldnf.LockedRepository, error) {
+	repomd := &api.Repomd{}
+	if err := r.UnmarshalFromRepoDir(repo, "repomd.xml", repomd); err != nil {
+		return nil, err
+	}
+	snapshot := &bazeldnf.LockedRepository{
+		Name:     repo.Name,
+		Revision: strings.TrimSpace(repomd.Revision),
+	}
+	for _, data := range repomd.Data {
+		if timestamp, err := strconv.ParseInt(strings.TrimSpace(data.Timestamp), 10, 64); err == nil && timestamp > snapshot.Timestamp {
+			snapshot.Timestamp = timestamp
+		}
+		snapshot.Data = append(snapshot.Data, bazeldnf.LockedData{
+			Type:     data.Type,
+			Checksum: data.Checksum.Type + ":" + strings.TrimSpace(data.Checksum.Text),
+		})
+	}
+	sort.Slice(snapshot.Data, func(i, j int) bool {
+		return snapshot.Data[i].Type < snapshot.Data[j].Type
+	})
+	return snapshot, nil
+}
+
+func (r *CacheHelper) CurrentSnapshots(repos *bazeldnf.Repositories, arch string) (snapshots []*bazeldnf.LockedRepository, err error) {
+	for i, repo := range repos.Repositories {
+		if !repo.ServesArch(arch) {
+			continue
+		}
+		snapshot, err := r.Snapshot(&repos.Repositories[i])
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// CurrentUpdateinfo returns the cached advisories of the repository. If the repository does not provide
+// advisories, an empty Updateinfo is returned.
+func (r *CacheHelper) CurrentUpdateinfo(repo *bazeldnf.Repository) (*api.Updateinfo, error) {
+	repomd := &api.Repomd{}
+	if err := r.UnmarshalFromRepoDir(repo, "repomd.xml", repomd); err != nil {
+		return nil, err
+	}
+	data := repomd.File(api.UpdateinfoFileType)
+	if data == nil {
+		return &api.Updateinfo{}, nil
+	}
+	name := data.Location.FileName()
+	file, err := r.OpenFromRepoDir(repo, name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader, err := decompress(name, file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	updateinfo := &api.Updateinfo{}
+	if err := xml.NewDecoder(reader).Decode(updateinfo); err != nil {
+		return nil, fmt.Errorf("failed to parse advisories of %s: %v", repo.Name, err)
+	}
+	return updateinfo, nil
+}
+
+// FileOwners maps each of the given paths to the packages of the repository which contain it, reading the
+// cached filelists file of the repository only once. Paths without owner are not part of the result.
+func (r *CacheHelper) FileOwners(repo *bazeldnf.Repository, arches []string, paths []string) (map[string][]*api.FileListPackage, error) {
+	repomd := &api.Repomd{}
+	if err := r.UnmarshalFromRepoDir(repo, "repomd.xml", repomd); err != nil {
+		return nil, err
+	}
+	filelists := repomd.File(api.FilelistsFileType)
+	if filelists == nil {
+		return nil, fmt.Errorf("repository %s has no filelists", repo.Name)
+	}
+	filelistsName := filelists.Location.FileName()
+	file, err := r.OpenFromRepoDir(repo, filelistsName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader, err := decompress(filelistsName, file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	wanted := map[string]bool{}
+	for _, path := range paths {
+		wanted[path] = true
+	}
+	owners := map[string][]*api.FileListPackage{}
+	d := xml.NewDecoder(reader)
+	for {
+		tok, err := d.Token()
+		if tok == nil || err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("Error decoding token: %s", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "package" {
+			continue
+		}
+		pkg := &api.FileListPackage{}
+		if err = d.DecodeElement(pkg, &start); err != nil {
+			return nil, fmt.Errorf("Error decoding item: %s", err)
+		}
+		validArch := false
+		for _, a := range arches {
+			if pkg.Arch == a {
+				validArch = true
+			}
+		}
+		if !validArch {
+			continue
+		}
+		for _, f := range pkg.File {
+			if wanted[f.Text] {
+				owners[f.Text] = append(owners[f.Text], pkg)
+			}
+		}
+	}
+	return owners, nil
+}
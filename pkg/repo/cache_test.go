@@ -0,0 +1,119 @@
+package repo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func TestWriteToRepoDir(t *testing.T) {
+	g := NewGomegaWithT(t)
+	helper := &CacheHelper{CacheDir: t.TempDir()}
+	repo := &bazeldnf.Repository{Name: "test"}
+	file := filepath.Join(helper.CacheDir, "test", "repomd.xml")
+
+	g.Expect(helper.WriteToRepoDir(repo, strings.NewReader("good"), "repomd.xml", nil)).To(Succeed())
+	g.Expect(os.ReadFile(file)).To(Equal([]byte("good")))
+
+	err := helper.WriteToRepoDir(repo, strings.NewReader("corrupt"), "repomd.xml", func(tmpFile string) error {
+		data, err := os.ReadFile(tmpFile)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(data).To(Equal([]byte("corrupt")))
+		return fmt.Errorf("checksum mismatch")
+	})
+	g.Expect(err).To(MatchError("checksum mismatch"))
+	g.Expect(os.ReadFile(file)).To(Equal([]byte("good")))
+
+	entries, err := os.ReadDir(filepath.Dir(file))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(entries).To(HaveLen(1))
+}
+
+func TestSnapshot(t *testing.T) {
+	g := NewGomegaWithT(t)
+	helper := &CacheHelper{CacheDir: t.TempDir()}
+	repo := &bazeldnf.Repository{Name: "test"}
+	repomd := `<?xml version="1.0" encoding="UTF-8"?>
+<repomd xmlns="http://linux.duke.edu/metadata/repo">
+  <revision>1587426453</revision>
+  <data type="primary">
+    <checksum type="sha256">1234</checksum>
+    <timestamp>1587426400</timestamp>
+  </data>
+  <data type="filelists">
+    <checksum type="sha256">5678</checksum>
+    <timestamp>1587426410</timestamp>
+  </data>
+</repomd>
+`
+	g.Expect(helper.WriteToRepoDir(repo, strings.NewReader(repomd), "repomd.xml", nil)).To(Succeed())
+	snapshot, err := helper.Snapshot(repo)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(snapshot).To(Equal(&bazeldnf.LockedRepository{
+		Name:      "test",
+		Revision:  "1587426453",
+		Timestamp: 1587426410,
+		Data: []bazeldnf.LockedData{
+			{Type: "filelists", Checksum: "sha256:5678"},
+			{Type: "primary", Checksum: "sha256:1234"},
+		},
+	}))
+}
+
+func TestCurrentUpdateinfo(t *testing.T) {
+	g := NewGomegaWithT(t)
+	helper := &CacheHelper{CacheDir: t.TempDir()}
+	repo := &bazeldnf.Repository{Name: "test"}
+
+	g.Expect(helper.WriteToRepoDir(repo, strings.NewReader(`<repomd><data type="primary"><location href="repodata/primary.xml.gz"/></data></repomd>`), "repomd.xml", nil)).To(Succeed())
+	updateinfo, err := helper.CurrentUpdateinfo(repo)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(updateinfo.Advisories).To(BeEmpty())
+
+	g.Expect(helper.WriteToRepoDir(repo, strings.NewReader(`<repomd><data type="updateinfo"><location href="repodata/updateinfo.xml.gz"/></data></repomd>`), "repomd.xml", nil)).To(Succeed())
+	_, err = helper.CurrentUpdateinfo(repo)
+	g.Expect(err).To(HaveOccurred())
+
+	compressed := &bytes.Buffer{}
+	gz := gzip.NewWriter(compressed)
+	_, err = gz.Write([]byte(`<updates><update type="security"><id>FEDORA-2020-0001</id></update></updates>`))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(gz.Close()).To(Succeed())
+	g.Expect(helper.WriteToRepoDir(repo, compressed, "updateinfo.xml.gz", nil)).To(Succeed())
+	updateinfo, err = helper.CurrentUpdateinfo(repo)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(updateinfo.Advisories).To(HaveLen(1))
+	g.Expect(updateinfo.Advisories[0].ID).To(Equal("FEDORA-2020-0001"))
+}
+
+func TestFileOwners(t *testing.T) {
+	g := NewGomegaWithT(t)
+	helper := &CacheHelper{CacheDir: t.TempDir()}
+	repo := &bazeldnf.Repository{Name: "test"}
+
+	g.Expect(helper.WriteToRepoDir(repo, strings.NewReader(`<repomd><data type="filelists"><location href="repodata/filelists.xml.gz"/></data></repomd>`), "repomd.xml", nil)).To(Succeed())
+	compressed := &bytes.Buffer{}
+	gz := gzip.NewWriter(compressed)
+	_, err := gz.Write([]byte(`<filelists>
+<package pkgid="1" name="glibc" arch="x86_64"><version epoch="0" ver="2.32" rel="1"/><file>/usr/lib64/libc.so.6</file><file>/usr/lib64/libm.so.6</file></package>
+<package pkgid="2" name="glibc" arch="i686"><version epoch="0" ver="2.32" rel="1"/><file>/usr/lib64/libc.so.6</file></package>
+<package pkgid="3" name="bash" arch="x86_64"><version epoch="0" ver="5.0" rel="1"/><file>/usr/bin/bash</file></package>
+</filelists>`))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(gz.Close()).To(Succeed())
+	g.Expect(helper.WriteToRepoDir(repo, compressed, "filelists.xml.gz", nil)).To(Succeed())
+
+	owners, err := helper.FileOwners(repo, []string{"x86_64", "noarch"}, []string{"/usr/lib64/libc.so.6", "/usr/bin/bash", "/usr/bin/zsh"})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(owners).To(HaveLen(2))
+	g.Expect(owners["/usr/lib64/libc.so.6"]).To(HaveLen(1))
+	g.Expect(owners["/usr/lib64/libc.so.6"][0].String()).To(Equal("glibc-0:2.32-1"))
+	g.Expect(owners["/usr/bin/bash"][0].Name).To(Equal("bash"))
+}
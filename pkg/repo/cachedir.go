@@ -0,0 +1,32 @@
+package repo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+// CacheDirEnv is the environment variable which can be used to override the cache location
+const CacheDirEnv = "BAZELDNF_CACHE_DIR"
+
+// ResolveCacheDir determines the cache location. An explicitly given directory has precedence over the
+// BAZELDNF_CACHE_DIR environment variable, which has precedence over the cacheDir of the repository files.
+// If none of them is set, $XDG_CACHE_HOME/bazeldnf is used.
+func ResolveCacheDir(dir string, repos *bazeldnf.Repositories) (string, error) {
+	if dir != "" {
+		return dir, nil
+	}
+	if env := os.Getenv(CacheDirEnv); env != "" {
+		return env, nil
+	}
+	if repos != nil && repos.CacheDir != "" {
+		return repos.CacheDir, nil
+	}
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine the cache directory, consider setting %s: %v", CacheDirEnv, err)
+	}
+	return filepath.Join(userCacheDir, "bazeldnf"), nil
+}
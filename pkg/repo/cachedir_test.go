@@ -0,0 +1,40 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func TestResolveCacheDir(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", "/xdg")
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		t.Fatalf("failed to determine user cache directory: %v", err)
+	}
+	repos := &bazeldnf.Repositories{CacheDir: "/config"}
+	tests := []struct {
+		name  string
+		flag  string
+		env   string
+		repos *bazeldnf.Repositories
+		want  string
+	}{
+		{name: "flag wins", flag: "/flag", env: "/env", repos: repos, want: "/flag"},
+		{name: "env var wins over config", env: "/env", repos: repos, want: "/env"},
+		{name: "config", repos: repos, want: "/config"},
+		{name: "default", repos: nil, want: filepath.Join(userCacheDir, "bazeldnf")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			t.Setenv(CacheDirEnv, tt.env)
+			dir, err := ResolveCacheDir(tt.flag, tt.repos)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(dir).To(Equal(tt.want))
+		})
+	}
+}
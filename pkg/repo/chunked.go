@@ -0,0 +1,315 @@
+package repo
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultChunkSize is the size of the byte ranges a ChunkingGetter downloads in parallel
+const DefaultChunkSize = 16 * 1024 * 1024
+
+// RangeGetter is implemented by Getters which can download a byte range of a file
+type RangeGetter interface {
+	// GetRange requests the bytes from start up to and including end
+	GetRange(url string, start int64, end int64) (resp *http.Response, err error)
+}
+
+// MirrorGetter is implemented by Getters which can download a file which is available on multiple mirrors from
+// all of them at once
+type MirrorGetter interface {
+	GetFromMirrors(urls []string) (resp *http.Response, err error)
+}
+
+// getFromMirrors downloads the file from the first URL. MirrorGetters can download parts of it from the other
+// URLs at the same time.
+func getFromMirrors(getter Getter, urls []string) (*http.Response, error) {
+	if mirrorGetter, ok := getter.(MirrorGetter); ok {
+		return mirrorGetter.GetFromMirrors(urls)
+	}
+	return getter.Get(urls[0])
+}
+
+// ChunkingGetter downloads files which are larger than one chunk in parallel byte ranges and reassembles them in
+// a temporary file, which cuts download times on high-latency links. The file is handed out while the chunks are
+// still downloaded, reading it follows the download and fails if a chunk can't be downloaded. Servers which don't
+// support ranges get a plain download. Callers still verify the checksum of the reassembled file.
+type ChunkingGetter struct {
+	Getter Getter
+	// ChunkSize is the size of the byte ranges, it defaults to DefaultChunkSize
+	ChunkSize int64
+	// Connections is the number of chunks which are downloaded at the same time
+	Connections int
+	// TempDir is the directory of the temporary files, it defaults to the temporary directory of the system
+	TempDir string
+}
+
+func (g *ChunkingGetter) Get(url string) (*http.Response, error) {
+	return g.GetFromMirrors([]string{url})
+}
+
+// GetFromMirrors downloads the file from the first URL, spreading the remaining chunks over all mirrors. A chunk
+// which fails to download is tried on the other mirrors.
+func (g *ChunkingGetter) GetFromMirrors(urls []string) (*http.Response, error) {
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no URLs to download from")
+	}
+	ranger, ok := g.Getter.(RangeGetter)
+	if !ok || g.Connections < 2 {
+		return g.Getter.Get(urls[0])
+	}
+	chunkSize := g.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	resp, err := ranger.GetRange(urls[0], 0, chunkSize-1)
+	if err != nil {
+		return nil, err
+	}
+	total, ranged := contentRangeTotal(resp)
+	if resp.StatusCode != http.StatusPartialContent || !ranged {
+		// the server sent the whole file or an error
+		return resp, nil
+	}
+	if total <= chunkSize {
+		resp.Status = "200 OK"
+		resp.StatusCode = http.StatusOK
+		resp.ContentLength = total
+		resp.Header.Del("Content-Range")
+		return resp, nil
+	}
+
+	f, err := os.CreateTemp(g.TempDir, ".bazeldnf-chunked-*")
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to create temporary file for %s: %v", urls[0], err)
+	}
+	err = writeChunk(f, resp, 0, chunkSize-1)
+	resp.Body.Close()
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("failed to download %s in chunks: %v", urls[0], err)
+	}
+	log.Debugf("Downloading %s in %d chunks", urls[0], (total+chunkSize-1)/chunkSize)
+	body := newChunkedBody(f, chunkSize, total)
+	body.finished(0, nil)
+	go g.downloadChunks(ranger, urls, body)
+	header := resp.Header.Clone()
+	header.Del("Content-Range")
+	header.Set("Content-Length", strconv.FormatInt(total, 10))
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Header:        header,
+		ContentLength: total,
+		Body:          body,
+		Request:       resp.Request,
+	}, nil
+}
+
+// downloadChunks downloads all chunks after the first one with parallel connections into the file of the body.
+// The chunks are started in order, so that the body can be read while the later ones are still downloaded.
+func (g *ChunkingGetter) downloadChunks(ranger RangeGetter, urls []string, body *chunkedBody) {
+	chunks := make(chan int64)
+	for i := 0; i < g.Connections; i++ {
+		go func() {
+			for start := range chunks {
+				if body.canceled() {
+					// drain the remaining chunks, the body failed or was closed anyway
+					continue
+				}
+				end := start + body.chunkSize - 1
+				if end >= body.total {
+					end = body.total - 1
+				}
+				chunk := start / body.chunkSize
+				if err := downloadChunk(ranger, urls, body.file, start, end, int(chunk)); err != nil {
+					body.finished(chunk, fmt.Errorf("failed to download %s in chunks: %v", urls[0], err))
+					continue
+				}
+				body.finished(chunk, nil)
+			}
+		}()
+	}
+	for start := body.chunkSize; start < body.total; start += body.chunkSize {
+		chunks <- start
+	}
+	close(chunks)
+}
+
+// downloadChunk downloads one byte range, starting with a different mirror for every chunk
+func downloadChunk(ranger RangeGetter, urls []string, f *os.File, start int64, end int64, chunk int) (err error) {
+	for i := range urls {
+		u := urls[(chunk+i)%len(urls)]
+		var resp *http.Response
+		resp, err = ranger.GetRange(u, start, end)
+		if err != nil {
+			log.Warningf("Failed to download bytes %d-%d of %s: %v", start, end, u, err)
+			continue
+		}
+		err = writeChunk(f, resp, start, end)
+		resp.Body.Close()
+		if err == nil {
+			return nil
+		}
+		log.Warningf("Failed to download bytes %d-%d of %s: %v", start, end, u, err)
+	}
+	return err
+}
+
+// writeChunk writes the body of a range response to its position in the file
+func writeChunk(f *os.File, resp *http.Response, start int64, end int64) error {
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("status : %v", resp.StatusCode)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-%d/", start, end)) {
+		return fmt.Errorf("unexpected content range %q", resp.Header.Get("Content-Range"))
+	}
+	buf := make([]byte, 32*1024)
+	offset := start
+	for offset <= end {
+		n, err := resp.Body.Read(buf)
+		if int64(n) > end-offset+1 {
+			n = int(end - offset + 1)
+		}
+		if n > 0 {
+			if _, err := f.WriteAt(buf[:n], offset); err != nil {
+				return err
+			}
+			offset += int64(n)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if offset != end+1 {
+		return fmt.Errorf("expected %d bytes, but got %d", end-start+1, offset-start)
+	}
+	return nil
+}
+
+// contentRangeTotal returns the complete size of the file from the Content-Range header of a range response
+func contentRangeTotal(resp *http.Response) (int64, bool) {
+	contentRange := resp.Header.Get("Content-Range")
+	_, total, found := strings.Cut(contentRange, "/")
+	if !strings.HasPrefix(contentRange, "bytes ") || !found {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return size, true
+}
+
+// chunkedBody hands out the temporary file of a chunked download while its chunks are still downloaded. Reads
+// block until the chunk at the read offset is complete. The file is removed once the body is closed.
+type chunkedBody struct {
+	file      *os.File
+	chunkSize int64
+	total     int64
+	offset    int64
+
+	lock     sync.Mutex
+	changed  *sync.Cond
+	complete map[int64]bool
+	err      error
+	closed   bool
+}
+
+func newChunkedBody(f *os.File, chunkSize int64, total int64) *chunkedBody {
+	body := &chunkedBody{file: f, chunkSize: chunkSize, total: total, complete: map[int64]bool{}}
+	body.changed = sync.NewCond(&body.lock)
+	return body
+}
+
+// finished marks the chunk as complete, or fails the body with the error the chunk failed with
+func (b *chunkedBody) finished(chunk int64, err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if err != nil && b.err == nil {
+		b.err = err
+	} else if err == nil {
+		b.complete[chunk] = true
+	}
+	b.changed.Broadcast()
+}
+
+// canceled returns true if the remaining chunks don't need to be downloaded anymore
+func (b *chunkedBody) canceled() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.err != nil || b.closed
+}
+
+func (b *chunkedBody) Read(p []byte) (int, error) {
+	if b.offset >= b.total {
+		return 0, io.EOF
+	}
+	chunk := b.offset / b.chunkSize
+	b.lock.Lock()
+	for !b.complete[chunk] && b.err == nil && !b.closed {
+		b.changed.Wait()
+	}
+	complete, err := b.complete[chunk], b.err
+	if b.closed {
+		err = os.ErrClosed
+	}
+	b.lock.Unlock()
+	if !complete {
+		return 0, err
+	}
+	end := (chunk + 1) * b.chunkSize
+	if end > b.total {
+		end = b.total
+	}
+	if int64(len(p)) > end-b.offset {
+		p = p[:end-b.offset]
+	}
+	n, err := b.file.ReadAt(p, b.offset)
+	b.offset += int64(n)
+	if err == io.EOF && n == len(p) {
+		err = nil
+	}
+	return n, err
+}
+
+func (b *chunkedBody) Close() error {
+	b.lock.Lock()
+	b.closed = true
+	b.changed.Broadcast()
+	b.lock.Unlock()
+	err := b.file.Close()
+	os.Remove(b.file.Name())
+	return err
+}
+
+func (g *ChunkingGetter) WithProxy(proxy string) (Getter, error) {
+	proxyGetter, ok := g.Getter.(ProxyGetter)
+	if !ok {
+		return g, nil
+	}
+	getter, err := proxyGetter.WithProxy(proxy)
+	if err != nil {
+		return nil, err
+	}
+	return &ChunkingGetter{Getter: getter, ChunkSize: g.ChunkSize, Connections: g.Connections, TempDir: g.TempDir}, nil
+}
+
+func (g *ChunkingGetter) Post(url string, contentType string, body io.Reader) (*http.Response, error) {
+	poster, ok := g.Getter.(Poster)
+	if !ok {
+		return nil, fmt.Errorf("%T can't send POST requests", g.Getter)
+	}
+	return poster.Post(url, contentType, body)
+}
@@ -0,0 +1,123 @@
+package repo
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestChunkingGetter(t *testing.T) {
+	g := NewGomegaWithT(t)
+	content := bytes.Repeat([]byte("0123456789abcdef"), 1000)
+	lock := sync.Mutex{}
+	ranges := map[string]int{}
+	serve := func(name string) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			if r.Header.Get("Range") != "" {
+				ranges[name]++
+			}
+			lock.Unlock()
+			switch r.URL.Path {
+			case "/broken":
+				if r.Header.Get("Range") != "bytes=0-4095" {
+					rw.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+			case "/plain":
+				rw.Header().Set("Content-Length", strconv.Itoa(len(content)))
+				rw.Write(content)
+				return
+			}
+			http.ServeContent(rw, r, "file", time.Time{}, bytes.NewReader(content))
+		})
+	}
+	mirror1 := httptest.NewServer(serve("mirror1"))
+	defer mirror1.Close()
+	mirror2 := httptest.NewServer(serve("mirror2"))
+	defer mirror2.Close()
+	getter := &ChunkingGetter{Getter: NewGetter(), ChunkSize: 4096, Connections: 3, TempDir: t.TempDir()}
+
+	read := func(resp *http.Response) []byte {
+		defer resp.Body.Close()
+		g.Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		data, err := io.ReadAll(resp.Body)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(resp.ContentLength).To(BeEquivalentTo(len(data)))
+		return data
+	}
+
+	// the chunks are spread over all mirrors
+	resp, err := getter.GetFromMirrors([]string{mirror1.URL + "/file", mirror2.URL + "/file"})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(read(resp)).To(Equal(content))
+	g.Expect(ranges["mirror1"]).To(Equal(2))
+	g.Expect(ranges["mirror2"]).To(Equal(2))
+
+	// the temporary file is removed once the body is closed
+	entries, err := os.ReadDir(getter.TempDir)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(entries).To(BeEmpty())
+
+	// chunks which fail on one mirror are downloaded from the other one
+	resp, err = getter.GetFromMirrors([]string{mirror1.URL + "/broken", mirror2.URL + "/file"})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(read(resp)).To(Equal(content))
+
+	// chunks which fail on all mirrors fail reading the download
+	resp, err = getter.GetFromMirrors([]string{mirror1.URL + "/broken"})
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = io.ReadAll(resp.Body)
+	g.Expect(err).To(MatchError(ContainSubstring("failed to download " + mirror1.URL + "/broken in chunks")))
+	resp.Body.Close()
+	entries, err = os.ReadDir(getter.TempDir)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(entries).To(BeEmpty())
+
+	// servers which ignore ranges send the whole file
+	resp, err = getter.Get(mirror1.URL + "/plain")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(read(resp)).To(Equal(content))
+
+	// files which fit into one chunk are downloaded with a single request
+	small := &ChunkingGetter{Getter: NewGetter(), ChunkSize: int64(len(content)), Connections: 3}
+	resp, err = small.Get(mirror2.URL + "/file")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(read(resp)).To(Equal(content))
+	g.Expect(resp.Header.Get("Content-Range")).To(BeEmpty())
+}
+
+func TestChunkingGetterStreams(t *testing.T) {
+	g := NewGomegaWithT(t)
+	content := bytes.Repeat([]byte("0123456789abcdef"), 512)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") == "bytes=4096-8191" {
+			// the last chunk is held back until the first one was read
+			<-release
+		}
+		http.ServeContent(rw, r, "file", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+	getter := &ChunkingGetter{Getter: NewGetter(), ChunkSize: 4096, Connections: 2, TempDir: t.TempDir()}
+
+	resp, err := getter.Get(server.URL + "/file")
+	g.Expect(err).ToNot(HaveOccurred())
+	defer resp.Body.Close()
+	first := make([]byte, 4096)
+	_, err = io.ReadFull(resp.Body, first)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(first).To(Equal(content[:4096]))
+	close(release)
+	rest, err := io.ReadAll(resp.Body)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(rest).To(Equal(content[4096:]))
+}
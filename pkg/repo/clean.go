@@ -0,0 +1,172 @@
+package repo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/bytesize"
+	log "github.com/sirupsen/logrus"
+)
+
+// CleanFilter selects the cached files which are removed. If neither Metadata nor Packages is set, both are
+// removed.
+type CleanFilter struct {
+	// Metadata selects repository metadata like repomd.xml, primary.xml and updateinfo.xml
+	Metadata bool
+	// Packages selects the data cached for single packages, like the rehashed sha256 sums of RPMs
+	Packages bool
+	// Repos restricts cleaning to the repositories with the given names
+	Repos []string
+	// OlderThan restricts cleaning to repositories whose metadata was not refreshed for at least this long
+	OlderThan time.Duration
+}
+
+// CleanResult summarizes what was removed from the cache
+type CleanResult struct {
+	Files int
+	Bytes int64
+}
+
+func (r *CleanResult) String() string {
+	return fmt.Sprintf("%d files (%s)", r.Files, bytesize.Format(r.Bytes))
+}
+
+// ParseAge parses an age like `30d`, `12h` or `90m`. Besides the units of time.ParseDuration, `d` for days is
+// supported.
+func ParseAge(age string) (time.Duration, error) {
+	if days := strings.TrimSuffix(age, "d"); days != age {
+		n, err := strconv.ParseUint(days, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %s, expected something like 30d or 12h", age)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	duration, err := time.ParseDuration(age)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid age %s, expected something like 30d or 12h", age)
+	}
+	return duration, nil
+}
+
+// Clean removes the files selected by the filter from the cache directories of all repositories, including the
+// ones of repository snapshots. The cache directory of each repository is locked while it is cleaned.
+func Clean(cacheDir string, filter CleanFilter) (*CleanResult, error) {
+	result := &CleanResult{}
+	entries, err := os.ReadDir(cacheDir)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory %s: %v", cacheDir, err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if entry.Name() != "snapshots" {
+			if err := cleanRepoDir(&CacheHelper{CacheDir: cacheDir}, entry.Name(), filter, result); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		snapshots, err := os.ReadDir(filepath.Join(cacheDir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot cache directory: %v", err)
+		}
+		for _, snapshot := range snapshots {
+			if !snapshot.IsDir() {
+				continue
+			}
+			snapshotDir := filepath.Join(cacheDir, entry.Name(), snapshot.Name())
+			repos, err := os.ReadDir(snapshotDir)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read snapshot cache directory %s: %v", snapshotDir, err)
+			}
+			for _, repo := range repos {
+				if !repo.IsDir() {
+					continue
+				}
+				if err := cleanRepoDir(&CacheHelper{CacheDir: snapshotDir}, repo.Name(), filter, result); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// cleanRepoDir removes the cached files of one repository which the filter selects. The metadata files reference
+// each other, so they are only removed together.
+func cleanRepoDir(cacheHelper *CacheHelper, name string, filter CleanFilter, result *CleanResult) error {
+	if len(filter.Repos) > 0 && !contains(filter.Repos, name) {
+		return nil
+	}
+	unlock, err := cacheHelper.LockRepoDir(&bazeldnf.Repository{Name: name})
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	dir := filepath.Join(cacheHelper.CacheDir, name)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read cache directory of %s: %v", name, err)
+	}
+	if filter.OlderThan > 0 {
+		if refreshed, known := lastRefresh(dir); known && refreshed.After(time.Now().Add(-filter.OlderThan)) {
+			return nil
+		}
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || entry.Name() == ".lock" {
+			continue
+		}
+		if filter.Metadata || filter.Packages {
+			if isPackageData(entry.Name()) && !filter.Packages || !isPackageData(entry.Name()) && !filter.Metadata {
+				continue
+			}
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		file := filepath.Join(dir, entry.Name())
+		if err := os.Remove(file); err != nil {
+			return fmt.Errorf("failed to remove %s: %v", file, err)
+		}
+		log.Debugf("Removed %s", file)
+		result.Files++
+		result.Bytes += info.Size()
+	}
+	return nil
+}
+
+// lastRefresh returns when the metadata in the cache directory was completely fetched the last time. Caches
+// written before the fetched marker existed fall back to the age of repomd.xml. Directories with neither hold no
+// usable metadata and have no known age.
+func lastRefresh(dir string) (time.Time, bool) {
+	for _, name := range []string{fetchedMarker, "repomd.xml"} {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return info.ModTime(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// isPackageData returns true for cached files which belong to single packages instead of the repository metadata
+func isPackageData(name string) bool {
+	return strings.HasPrefix(name, rehashedPrefix) || strings.HasSuffix(name, ".rpm")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
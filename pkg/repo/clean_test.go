@@ -0,0 +1,95 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func writeCacheFile(g *WithT, dir string, name string, age time.Duration) string {
+	file := filepath.Join(dir, name)
+	g.Expect(os.MkdirAll(filepath.Dir(file), 0770)).To(Succeed())
+	g.Expect(os.WriteFile(file, []byte("content"), 0660)).To(Succeed())
+	modified := time.Now().Add(-age)
+	g.Expect(os.Chtimes(file, modified, modified)).To(Succeed())
+	return file
+}
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    CleanFilter
+		remaining []string
+	}{
+		{
+			name:      "everything",
+			remaining: []string{},
+		},
+		{
+			name:      "repository",
+			filter:    CleanFilter{Repos: []string{"updates"}},
+			remaining: []string{"fedora/.fetched", "fedora/repomd.xml", "fedora/primary.xml.gz", "fedora/rehashed-md5-abcd", "legacy/repomd.xml", "legacy/primary.xml.gz", "partial/primary.xml.gz"},
+		},
+		{
+			name:      "metadata",
+			filter:    CleanFilter{Metadata: true},
+			remaining: []string{"fedora/rehashed-md5-abcd"},
+		},
+		{
+			name:      "packages",
+			filter:    CleanFilter{Packages: true},
+			remaining: []string{"fedora/.fetched", "fedora/repomd.xml", "fedora/primary.xml.gz", "updates/.fetched", "updates/repomd.xml", "legacy/repomd.xml", "legacy/primary.xml.gz", "partial/primary.xml.gz", "snapshots/2024-11-01/updates/repomd.xml"},
+		},
+		{
+			// fedora was refreshed recently, its old primary.xml.gz was just not updated
+			name:      "older than",
+			filter:    CleanFilter{OlderThan: 30 * 24 * time.Hour},
+			remaining: []string{"fedora/.fetched", "fedora/repomd.xml", "fedora/primary.xml.gz", "fedora/rehashed-md5-abcd"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			dir := t.TempDir()
+			writeCacheFile(g, dir, "fedora/.fetched", time.Hour)
+			writeCacheFile(g, dir, "fedora/repomd.xml", time.Hour)
+			writeCacheFile(g, dir, "fedora/primary.xml.gz", 40*24*time.Hour)
+			writeCacheFile(g, dir, "fedora/rehashed-md5-abcd", 40*24*time.Hour)
+			writeCacheFile(g, dir, "updates/.fetched", 40*24*time.Hour)
+			writeCacheFile(g, dir, "updates/repomd.xml", time.Hour)
+			writeCacheFile(g, dir, "legacy/repomd.xml", 40*24*time.Hour)
+			writeCacheFile(g, dir, "legacy/primary.xml.gz", time.Hour)
+			writeCacheFile(g, dir, "partial/primary.xml.gz", time.Hour)
+			writeCacheFile(g, dir, "snapshots/2024-11-01/updates/repomd.xml", 40*24*time.Hour)
+			files := 10
+
+			result, err := Clean(dir, tt.filter)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result.Files).To(Equal(files - len(tt.remaining)))
+			g.Expect(result.Bytes).To(Equal(int64(len("content") * (files - len(tt.remaining)))))
+			for _, file := range tt.remaining {
+				g.Expect(filepath.Join(dir, file)).To(BeAnExistingFile())
+			}
+		})
+	}
+}
+
+func TestCleanMissingCache(t *testing.T) {
+	g := NewGomegaWithT(t)
+	result, err := Clean(filepath.Join(t.TempDir(), "missing"), CleanFilter{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.Files).To(BeZero())
+}
+
+func TestParseAge(t *testing.T) {
+	g := NewGomegaWithT(t)
+	g.Expect(ParseAge("30d")).To(Equal(30 * 24 * time.Hour))
+	g.Expect(ParseAge("12h")).To(Equal(12 * time.Hour))
+	_, err := ParseAge("-1d")
+	g.Expect(err).To(HaveOccurred())
+	_, err = ParseAge("a month")
+	g.Expect(err).To(MatchError(ContainSubstring("invalid age a month")))
+}
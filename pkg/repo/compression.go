@@ -0,0 +1,31 @@
+package repo
+
+import (
+	"compress/gzip"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/xi2/xz"
+)
+
+// decompress wraps the reader of a metadata file with a decompressor matching the file extension
+func decompress(name string, reader io.Reader) (io.ReadCloser, error) {
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		return gzip.NewReader(reader)
+	case strings.HasSuffix(name, ".xz"):
+		xzReader, err := xz.NewReader(reader, 0)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(xzReader), nil
+	case strings.HasSuffix(name, ".zst"):
+		zstdReader, err := zstd.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		return zstdReader.IOReadCloser(), nil
+	}
+	return io.NopCloser(reader), nil
+}
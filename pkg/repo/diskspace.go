@@ -0,0 +1,45 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rmohr/bazeldnf/pkg/bytesize"
+)
+
+// errDiskSpaceUnsupported is returned by availableSpace on platforms where free space can't be determined
+var errDiskSpaceUnsupported = errors.New("determining free disk space is not supported on this platform")
+
+// CheckDiskSpace returns an error if the filesystem which holds dir has less than required bytes available.
+// dir does not need to exist yet, the check is done on its closest existing parent.
+func CheckDiskSpace(dir string, required uint64) error {
+	if required == 0 {
+		return nil
+	}
+	existing, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	for {
+		if _, err := os.Stat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+	available, err := availableSpace(existing)
+	if errors.Is(err, errDiskSpaceUnsupported) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to determine free disk space of %s: %v", existing, err)
+	}
+	if available < required {
+		return fmt.Errorf("not enough disk space in %s: %s required, but only %s available", dir, bytesize.Format(int64(required)), bytesize.Format(int64(available)))
+	}
+	return nil
+}
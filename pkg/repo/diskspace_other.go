@@ -0,0 +1,7 @@
+//go:build !linux && !darwin && !freebsd
+
+package repo
+
+func availableSpace(dir string) (uint64, error) {
+	return 0, errDiskSpaceUnsupported
+}
@@ -0,0 +1,24 @@
+package repo
+
+import (
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestCheckDiskSpace(t *testing.T) {
+	g := NewGomegaWithT(t)
+	tmpDir := t.TempDir()
+	dir := filepath.Join(tmpDir, "does", "not", "exist")
+	g.Expect(CheckDiskSpace(dir, 0)).To(Succeed())
+	g.Expect(CheckDiskSpace(dir, 1)).To(Succeed())
+
+	available, err := availableSpace(tmpDir)
+	if err == errDiskSpaceUnsupported {
+		t.Skip("free disk space can't be determined on this platform")
+	}
+	g.Expect(err).ToNot(HaveOccurred())
+	err = CheckDiskSpace(dir, available+1000*1000*1000*1000)
+	g.Expect(err).To(MatchError(ContainSubstring("not enough disk space in " + dir)))
+}
@@ -1,7 +1,10 @@
 package repo
 
 import (
+	"bytes"
+	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
 	"hash"
@@ -11,13 +14,23 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rmohr/bazeldnf/pkg/api"
 	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/progress"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/openpgp"
 )
 
+// NoProxy can be used as proxy of a repository to bypass the proxy configured in the environment
+const NoProxy = "none"
+
+// metalinkAttempts is how often the metalink is requested when the server throttles us
+const metalinkAttempts = 3
+
 type RepoFetcher interface {
 	Fetch() error
 }
@@ -26,61 +39,238 @@ type RepoFetcherImpl struct {
 	Getter      Getter
 	Repos       []bazeldnf.Repository
 	CacheHelper *CacheHelper
+	// FileTypes lists additional metadata files which are fetched next to primary.xml if the repository has them
+	FileTypes []string
+
+	proxyGetters map[string]Getter
 }
 
 func (r *RepoFetcherImpl) Fetch() (err error) {
 	for _, repo := range r.Repos {
-		sha256sum := []string{}
-		var repomdURLs = []string{}
-		if repo.Metalink != "" {
-			var metalink *api.Metalink
-			metalink, repomdURLs, err = r.resolveMetaLink(&repo)
-			if err != nil {
-				return fmt.Errorf("failed to resolve metalink for %s: %v", repo.Name, err)
-			}
-			sha256sum, err = metalink.Repomod().SHA256()
-			if err != nil {
-				return fmt.Errorf("failed to get sha256sum of repomd file: %v", err)
+		started := time.Now()
+		unlock, err := r.CacheHelper.LockRepoDir(&repo)
+		if err != nil {
+			return err
+		}
+		progress.Emit(progress.Event{Type: progress.FetchStarted, Repository: repo.Name})
+		if r.CacheHelper.updatedSince(&repo, started) {
+			log.Infof("Cache of %s was refreshed by another process, reusing it", repo.Name)
+		} else {
+			if err = r.fetchRepository(&repo); err == nil {
+				err = r.CacheHelper.markFetched(&repo)
 			}
-		} else if repo.Baseurl != "" {
-			repomdURLs = append(repomdURLs, strings.TrimSuffix(repo.Baseurl, "/")+"/repodata/repomd.xml")
 		}
-		repomd, mirror, err := r.resolveRepomd(&repo, repomdURLs, sha256sum)
+		finished := progress.Event{Type: progress.FetchFinished, Repository: repo.Name}
 		if err != nil {
-			return fmt.Errorf("failed to fetch repomd.xml for %s: %v", repo.Name, err)
+			finished.Error = err.Error()
+		}
+		progress.Emit(finished)
+		if unlockErr := unlock(); err == nil && unlockErr != nil {
+			err = fmt.Errorf("failed to unlock cache directory for %s: %v", repo.Name, unlockErr)
 		}
-		err = r.fetchFile(api.PrimaryFileType, &repo, repomd, mirror)
 		if err != nil {
-			return fmt.Errorf("failed to fetch primary.xml for %s: %v", repo.Name, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// FetchReferencedFiles fetches the given metadata files of each repository which its cached repomd.xml references
+// and which are not cached yet. Neither repomd.xml nor any other cached file is refreshed, so the result matches
+// the cached metadata. Repositories without cached metadata have to be fetched first.
+func (r *RepoFetcherImpl) FetchReferencedFiles(fileTypes ...string) error {
+	for _, repo := range r.Repos {
+		if repo.Koji != nil {
+			continue
+		}
+		unlock, err := r.CacheHelper.LockRepoDir(&repo)
+		if err != nil {
+			return err
+		}
+		err = r.fetchReferencedFiles(&repo, fileTypes)
+		if unlockErr := unlock(); err == nil && unlockErr != nil {
+			err = fmt.Errorf("failed to unlock cache directory for %s: %v", repo.Name, unlockErr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RepoFetcherImpl) fetchReferencedFiles(repo *bazeldnf.Repository, fileTypes []string) error {
+	if _, err := os.Stat(filepath.Join(r.CacheHelper.CacheDir, repo.Name, "repomd.xml")); os.IsNotExist(err) {
+		return fmt.Errorf("no metadata of %s is cached, run bazeldnf fetch first", repo.Name)
+	}
+	repomd := &api.Repomd{}
+	if err := r.CacheHelper.UnmarshalFromRepoDir(repo, "repomd.xml", repomd); err != nil {
+		return err
+	}
+	missing := []string{}
+	for _, fileType := range fileTypes {
+		file := repomd.File(fileType)
+		if file == nil {
+			continue
 		}
-		/* not used right now, save some bandwidth
-		err = r.fetchFile(api.FilelistsFileType, &repo, repomd, mirror)
+		if f, err := r.CacheHelper.OpenFromRepoDir(repo, file.Location.FileName()); err == nil {
+			f.Close()
+			continue
+		}
+		missing = append(missing, fileType)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	resolved := *repo
+	if err := r.CacheHelper.ResolveMirrors(&resolved); err != nil {
+		return err
+	}
+	mirrors := []*url.URL{}
+	for _, mirror := range resolved.Mirrors {
+		u, err := url.Parse(mirror)
 		if err != nil {
-			return fmt.Errorf("failed to fetch filelists.xml for %s: %v", repo.Name, err)
+			log.Warningf("Ignoring invalid mirror %s: %v", mirror, err)
+			continue
+		}
+		mirrors = append(mirrors, u)
+	}
+	if len(mirrors) == 0 {
+		return fmt.Errorf("no mirrors of %s are known, run bazeldnf fetch first", repo.Name)
+	}
+	if err := CheckDiskSpace(r.CacheHelper.CacheDir, metadataSize(repomd, missing)); err != nil {
+		return err
+	}
+	for _, fileType := range missing {
+		if err := r.fetchFile(fileType, repo, repomd, mirrors); err != nil {
+			return fmt.Errorf("failed to fetch %s file for %s, the cached metadata may be outdated, run bazeldnf fetch: %v", fileType, repo.Name, err)
 		}
-		*/
 	}
 	return nil
 }
 
+func (r *RepoFetcherImpl) fetchRepository(repo *bazeldnf.Repository) (err error) {
+	if repo.Koji != nil {
+		return r.fetchKojiRepository(repo)
+	}
+	sha256sum := []string{}
+	var repomdURLs = []string{}
+	baseurls := []string(repo.Baseurl)
+	if repo.Metalink != "" {
+		var metalink *api.Metalink
+		metalink, repomdURLs, err = r.resolveMetaLink(repo)
+		if err != nil {
+			return fmt.Errorf("failed to resolve metalink for %s: %v", repo.Name, err)
+		}
+		sha256sum, err = metalink.Repomod().SHA256()
+		if err != nil {
+			return fmt.Errorf("failed to get sha256sum of repomd file: %v", err)
+		}
+	} else {
+		if repo.Mirrorlist != "" {
+			baseurls, err = r.resolveMirrorlist(repo)
+			if err != nil {
+				return fmt.Errorf("failed to resolve mirror list for %s: %v", repo.Name, err)
+			}
+		}
+		for _, baseurl := range baseurls {
+			repomdURLs = append(repomdURLs, strings.TrimSuffix(baseurl, "/")+"/repodata/repomd.xml")
+		}
+	}
+	keyring, err := r.repomdKeyring(repo)
+	if err != nil {
+		return err
+	}
+	repomd, mirror, err := r.resolveRepomd(repo, repomdURLs, sha256sum, keyring)
+	if err != nil {
+		return fmt.Errorf("failed to fetch repomd.xml for %s: %v", repo.Name, err)
+	}
+	if err := CheckDiskSpace(r.CacheHelper.CacheDir, metadataSize(repomd, append([]string{api.PrimaryFileType}, r.FileTypes...))); err != nil {
+		return err
+	}
+	mirrors := fallbackMirrors(mirror, baseurls)
+	err = r.fetchFile(api.PrimaryFileType, repo, repomd, mirrors)
+	if err != nil {
+		return fmt.Errorf("failed to fetch primary.xml for %s: %v", repo.Name, err)
+	}
+	for _, fileType := range r.FileTypes {
+		if repomd.File(fileType) == nil {
+			continue
+		}
+		if err := r.fetchFile(fileType, repo, repomd, mirrors); err != nil {
+			return fmt.Errorf("failed to fetch %s file for %s: %v", fileType, repo.Name, err)
+		}
+	}
+	/* not used right now, save some bandwidth
+	err = r.fetchFile(api.FilelistsFileType, repo, repomd, mirrors)
+	if err != nil {
+		return fmt.Errorf("failed to fetch filelists.xml for %s: %v", repo.Name, err)
+	}
+	*/
+	return nil
+}
+
+// metadataSize sums the sizes which repomd declares for the given file types
+func metadataSize(repomd *api.Repomd, fileTypes []string) (size uint64) {
+	for _, fileType := range fileTypes {
+		if file := repomd.File(fileType); file != nil {
+			if fileSize, err := strconv.ParseUint(file.Size, 10, 64); err == nil {
+				size += fileSize
+			}
+		}
+	}
+	return size
+}
+
 func NewRemoteRepoFetcher(repos []bazeldnf.Repository, cacheDir string) RepoFetcher {
 	return &RepoFetcherImpl{
 		Repos:       repos,
-		Getter:      &getterImpl{},
+		Getter:      NewGetter(),
 		CacheHelper: &CacheHelper{CacheDir: cacheDir},
 	}
 }
 
+// getter returns the Getter which should be used for the repository, taking its proxy settings into account
+func (r *RepoFetcherImpl) getter(repo *bazeldnf.Repository) (Getter, error) {
+	proxyGetter, ok := r.Getter.(ProxyGetter)
+	if repo.Proxy == "" || !ok {
+		return r.Getter, nil
+	}
+	if getter, exists := r.proxyGetters[repo.Proxy]; exists {
+		return getter, nil
+	}
+	getter, err := proxyGetter.WithProxy(repo.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure proxy for %s: %v", repo.Name, err)
+	}
+	if r.proxyGetters == nil {
+		r.proxyGetters = map[string]Getter{}
+	}
+	r.proxyGetters[repo.Proxy] = getter
+	return getter, nil
+}
+
 func (r *RepoFetcherImpl) resolveMetaLink(repo *bazeldnf.Repository) (*api.Metalink, []string, error) {
-	resp, err := r.Getter.Get(repo.Metalink)
+	// there is no alternative to the metalink URL, so retry it if we are throttled
+	var resp *http.Response
+	getter, err := r.getter(repo)
 	if err != nil {
 		return nil, nil, err
 	}
+	for attempt := 0; attempt < metalinkAttempts; attempt++ {
+		resp, err = getter.Get(repo.Metalink)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !isThrottled(resp) || attempt == metalinkAttempts-1 {
+			break
+		}
+		resp.Body.Close()
+	}
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		return nil, nil, fmt.Errorf("Failed to download %s: %v ", repo.Metalink, fmt.Errorf("status : %v", resp.StatusCode))
 	}
-	if err := r.CacheHelper.WriteToRepoDir(repo, resp.Body, "metalink"); err != nil {
+	if err := r.CacheHelper.WriteToRepoDir(repo, resp.Body, "metalink", nil); err != nil {
 		return nil, nil, err
 	}
 
@@ -95,26 +285,65 @@ func (r *RepoFetcherImpl) resolveMetaLink(repo *bazeldnf.Repository) (*api.Metal
 		return nil, nil, fmt.Errorf("Metalink file contains no reference to repod.xml")
 	}
 
-	urls := []string{}
-	for _, u := range repomod.Resources.URLs {
-		if u.Protocol != "https" {
-			continue
-		}
-		urls = append(urls, u.Text)
+	urls, err := FilterMirrors(repomod.Resources.URLs, repo.MetalinkFilter)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	if len(urls) == 0 {
-		return metalink, nil, fmt.Errorf("Metalink contains no https url to a rpomd.xml file")
+		return metalink, nil, fmt.Errorf("Metalink contains no matching url to a rpomd.xml file")
 	}
 
 	return metalink, urls, nil
 }
 
-func (r *RepoFetcherImpl) resolveRepomd(repo *bazeldnf.Repository, repomdURLs []string, sha256sums []string) (repomd *api.Repomd, mirror *url.URL, err error) {
+// resolveMirrorlist downloads the mirror list of the repository to the cache and returns the baseurls listed in it
+func (r *RepoFetcherImpl) resolveMirrorlist(repo *bazeldnf.Repository) ([]string, error) {
+	getter, err := r.getter(repo)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Resolving mirror list from %s", repo.Mirrorlist)
+	resp, err := getter.Get(repo.Mirrorlist)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("Failed to download %s: %v ", repo.Mirrorlist, fmt.Errorf("status : %v", resp.StatusCode))
+	}
+	if err := r.CacheHelper.WriteToRepoDir(repo, resp.Body, "mirrorlist", func(tmpFile string) error {
+		f, err := os.Open(tmpFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = ParseMirrorlist(f)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	baseurls, err := r.CacheHelper.LoadMirrorlist(repo)
+	if err != nil {
+		return nil, err
+	}
+	if len(baseurls) == 0 {
+		return nil, fmt.Errorf("mirror list %s contains no baseurls", repo.Mirrorlist)
+	}
+	return baseurls, nil
+}
+
+// resolveRepomd downloads repomd.xml from the first mirror which has the expected version. If a keyring is given,
+// repomd.xml is only accepted if its detached signature was made with one of the keys.
+func (r *RepoFetcherImpl) resolveRepomd(repo *bazeldnf.Repository, repomdURLs []string, sha256sums []string, keyring openpgp.EntityList) (repomd *api.Repomd, mirror *url.URL, err error) {
+	getter, err := r.getter(repo)
+	if err != nil {
+		return nil, nil, err
+	}
 	for _, u := range repomdURLs {
 		sha := sha256.New()
 		log.Infof("Resolving repomd.xml from %s", u)
-		resp, err := r.Getter.Get(u)
+		resp, err := getter.Get(u)
 		if err != nil {
 			log.Errorf("Failed to resolve repomd.xml from %s: %v", u, err)
 			continue
@@ -124,35 +353,46 @@ func (r *RepoFetcherImpl) resolveRepomd(repo *bazeldnf.Repository, repomdURLs []
 			log.Warningf("Failed to download %s: %v ", u, fmt.Errorf("status : %v", resp.StatusCode))
 			continue
 		}
-		body := io.TeeReader(resp.Body, sha)
-		err = r.CacheHelper.WriteToRepoDir(repo, body, "repomd.xml")
-		if err != nil {
-			log.Errorf("Failed to save repomd.xml from %s: %v", u, err)
-			continue
-		}
-		if len(sha256sums) > 0 {
-			matched := false
-			for _, sum := range sha256sums {
-				if toHex(sha) != sum {
-					log.Warnf("Expected repomd.xml sha256 sum %s, but got %s", sum, toHex(sha))
-				} else {
-					log.Infof("Matched repmod.xml with sha256 sum %s", toHex(sha))
-					matched = true
-					break
+		download := progress.NewDownload(repo.Name, u, resp.ContentLength, resp.Body)
+		body := io.TeeReader(download, sha)
+		file := &api.Repomd{}
+		var signature []byte
+		err = r.CacheHelper.WriteToRepoDir(repo, body, "repomd.xml", func(tmpFile string) error {
+			if len(sha256sums) > 0 {
+				matched := false
+				for _, sum := range sha256sums {
+					if toHex(sha) != sum {
+						log.Warnf("Expected repomd.xml sha256 sum %s, but got %s", sum, toHex(sha))
+					} else {
+						log.Infof("Matched repmod.xml with sha256 sum %s", toHex(sha))
+						matched = true
+						break
+					}
+				}
+				if !matched {
+					return fmt.Errorf("Mirror has no expected repomd.xml version: %v", u)
 				}
 			}
-			if !matched {
-				log.Warningf("Mirror has no expected repomd.xml version: %v", u)
-				continue
+			if keyring != nil {
+				if signature, err = r.fetchRepomdSignature(repo, keyring, u, tmpFile); err != nil {
+					return err
+				}
 			}
-		}
-
-		file := &api.Repomd{}
-		err = r.CacheHelper.UnmarshalFromRepoDir(repo, "repomd.xml", file)
+			return unmarshalFile(tmpFile, file)
+		})
+		download.Finish(err)
 		if err != nil {
-			log.Errorf("Failed to decode repomd.xml from %s: %v", u, err)
+			log.Errorf("Failed to save repomd.xml from %s: %v", u, err)
 			continue
 		}
+		if signature != nil {
+			if err := r.CacheHelper.WriteToRepoDir(repo, bytes.NewReader(signature), "repomd.xml.asc", nil); err != nil {
+				return nil, nil, err
+			}
+		} else if err := os.Remove(filepath.Join(r.CacheHelper.CacheDir, repo.Name, "repomd.xml.asc")); err != nil && !os.IsNotExist(err) {
+			// a signature of an earlier repomd.xml would claim that the new one is signed
+			return nil, nil, fmt.Errorf("failed to remove the outdated signature of %s: %v", repo.Name, err)
+		}
 		repomd = file
 		mirror, err = url.Parse(u)
 		if err != nil {
@@ -168,7 +408,25 @@ func (r *RepoFetcherImpl) resolveRepomd(repo *bazeldnf.Repository, repomdURLs []
 	return repomd, mirror, nil
 }
 
-func (r *RepoFetcherImpl) fetchFile(fileType string, repo *bazeldnf.Repository, repomd *api.Repomd, mirror *url.URL) (err error) {
+// fallbackMirrors returns the mirror which served repomd.xml, followed by all other baseurls of the repository
+// which can be tried if downloading metadata files from the first mirror fails.
+func fallbackMirrors(mirror *url.URL, baseurls []string) []*url.URL {
+	mirrors := []*url.URL{mirror}
+	for _, baseurl := range baseurls {
+		u, err := url.Parse(baseurl)
+		if err != nil {
+			log.Warningf("Ignoring invalid baseurl %s: %v", baseurl, err)
+			continue
+		}
+		if strings.TrimSuffix(u.String(), "/") == strings.TrimSuffix(mirror.String(), "/") {
+			continue
+		}
+		mirrors = append(mirrors, u)
+	}
+	return mirrors
+}
+
+func (r *RepoFetcherImpl) fetchFile(fileType string, repo *bazeldnf.Repository, repomd *api.Repomd, mirrors []*url.URL) (err error) {
 	file := repomd.File(fileType)
 	if file == nil {
 		return fmt.Errorf("No 'file' file referenced in repomd")
@@ -176,35 +434,62 @@ func (r *RepoFetcherImpl) fetchFile(fileType string, repo *bazeldnf.Repository,
 	if file.Location.Href == "" {
 		return fmt.Errorf("The 'file' file has no href associated")
 	}
+	for i, mirror := range mirrors {
+		// the other mirrors can serve chunks of the file too
+		rotated := append(append([]*url.URL{}, mirrors[i:]...), mirrors[:i]...)
+		err = r.fetchFileFromMirror(fileType, repo, file, rotated)
+		if err == nil {
+			return nil
+		}
+		log.Warningf("Failed to fetch %s file from %s: %v", fileType, mirror, err)
+	}
+	return err
+}
 
-	fileURL := file.Location.Href
-	fileName := filepath.Base(file.Location.Href)
-	if !path.IsAbs(file.Location.Href) {
-		mirrorCopy := *mirror
-		mirrorCopy.Path = path.Join(mirror.Path, file.Location.Href)
-		fileURL = mirrorCopy.String()
+// fetchFileFromMirror downloads the file from the first of the mirrors
+func (r *RepoFetcherImpl) fetchFileFromMirror(fileType string, repo *bazeldnf.Repository, file *api.Data, mirrors []*url.URL) (err error) {
+	fileName := file.Location.FileName()
+	fileURLs := []string{file.Location.Href}
+	if !file.Location.IsURL() {
+		fileURLs = nil
+		for _, mirror := range mirrors {
+			mirrorCopy := *mirror
+			mirrorCopy.Path = path.Join(mirror.Path, file.Location.Href)
+			fileURLs = append(fileURLs, mirrorCopy.String())
+		}
+	}
+	fileURL := fileURLs[0]
+	getter, err := r.getter(repo)
+	if err != nil {
+		return err
 	}
 	log.Infof("Loading %s file from %s", fileType, fileURL)
-	resp, err := r.Getter.Get(fileURL)
+	resp, err := getFromMirrors(getter, fileURLs)
 	if err != nil {
 		return fmt.Errorf("Failed to load primary repository file from %s: %v", fileURL, err)
 	}
-	sha := sha256.New()
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		return fmt.Errorf("Failed to download %s: %v ", fileURL, fmt.Errorf("status : %v", resp.StatusCode))
 	}
-	body := io.TeeReader(resp.Body, sha)
-	err = r.CacheHelper.WriteToRepoDir(repo, body, fileName)
+	checksumType := file.Checksum.Type
+	checksum := strings.TrimSpace(file.Checksum.Text)
+	hasher, err := NewChecksumHash(checksumType)
 	if err != nil {
-		return fmt.Errorf("Failed to write file.xml from %s to file: %v", fileURL, err)
+		return fmt.Errorf("failed to verify %s file: %v", fileType, err)
 	}
-	sha256sum, err := file.SHA256()
+	size, _ := strconv.ParseInt(file.Size, 10, 64)
+	download := progress.NewDownload(repo.Name, fileURL, size, resp.Body)
+	body := io.TeeReader(download, hasher)
+	err = r.CacheHelper.WriteToRepoDir(repo, body, fileName, func(string) error {
+		if checksum != toHex(hasher) {
+			return fmt.Errorf("Expected %s sum %s, but got %s", checksumType, checksum, toHex(hasher))
+		}
+		return nil
+	})
+	download.Finish(err)
 	if err != nil {
-		return fmt.Errorf("failed to get sha256sum of file: %v", err)
-	}
-	if sha256sum != toHex(sha) {
-		return fmt.Errorf("Expected sha256 sum %s, but got %s", sha256sum, toHex(sha))
+		return fmt.Errorf("Failed to write file.xml from %s to file: %v", fileURL, err)
 	}
 	return nil
 }
@@ -213,7 +498,61 @@ type Getter interface {
 	Get(url string) (resp *http.Response, err error)
 }
 
-type getterImpl struct{}
+// ProxyGetter is implemented by Getters which can send the requests of a repository through a specific proxy
+type ProxyGetter interface {
+	WithProxy(proxy string) (Getter, error)
+}
+
+// Poster is implemented by Getters which can also send POST requests, like the XML-RPC calls to a Koji hub
+type Poster interface {
+	Post(url string, contentType string, body io.Reader) (resp *http.Response, err error)
+}
+
+type getterImpl struct {
+	// throttle is shared with the getters created by WithProxy, since they contact the same mirrors
+	throttle    *throttle
+	client      *http.Client
+	middlewares []Middleware
+}
+
+// NewGetter returns a Getter which supports file:// URLs and backs off from mirrors which throttle requests.
+// All http requests pass the given middlewares.
+func NewGetter(middlewares ...Middleware) Getter {
+	return newGetterImpl(http.DefaultTransport, middlewares, &throttle{})
+}
+
+// NewProxyGetter returns a Getter like NewGetter, which sends all http requests through the given proxy instead
+// of the one configured in the environment. The special value `none` disables proxies.
+func NewProxyGetter(proxy string, middlewares ...Middleware) (Getter, error) {
+	transport, err := proxyTransport(proxy)
+	if err != nil {
+		return nil, err
+	}
+	return newGetterImpl(transport, middlewares, &throttle{}), nil
+}
+
+// proxyTransport returns a transport which sends all requests through the given proxy, or through none for `none`
+func proxyTransport(proxy string) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy == NoProxy {
+		transport.Proxy = nil
+	} else {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %v", proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return transport, nil
+}
+
+func newGetterImpl(transport http.RoundTripper, middlewares []Middleware, throttle *throttle) *getterImpl {
+	return &getterImpl{
+		throttle:    throttle,
+		client:      &http.Client{Transport: Chain(transport, middlewares...)},
+		middlewares: middlewares,
+	}
+}
 
 func fileGet(filename string) (*http.Response, error) {
 	fp, err := os.Open(filename)
@@ -229,7 +568,27 @@ func fileGet(filename string) (*http.Response, error) {
 	return resp, nil
 }
 
-func (*getterImpl) Get(rawURL string) (*http.Response, error) {
+// WithProxy returns a getter with its own connection pool which sends the requests through the proxy. It backs off
+// from the same throttling mirrors as g. Every call creates a new connection pool, so callers create one getter
+// per repository and reuse it.
+func (g *getterImpl) WithProxy(proxy string) (Getter, error) {
+	transport, err := proxyTransport(proxy)
+	if err != nil {
+		return nil, err
+	}
+	return newGetterImpl(transport, g.middlewares, g.throttle), nil
+}
+
+func (g *getterImpl) Get(rawURL string) (*http.Response, error) {
+	return g.get(rawURL, "")
+}
+
+func (g *getterImpl) GetRange(rawURL string, start int64, end int64) (*http.Response, error) {
+	return g.get(rawURL, fmt.Sprintf("bytes=%d-%d", start, end))
+}
+
+// get sends a GET request with the given Range header, if any, and observes whether the mirror throttles us
+func (g *getterImpl) get(rawURL string, byteRange string) (*http.Response, error) {
 	u, err := url.Parse(rawURL)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to parse URL: %w", err)
@@ -237,7 +596,60 @@ func (*getterImpl) Get(rawURL string) (*http.Response, error) {
 	if u.Scheme == "file" {
 		return fileGet(u.Path)
 	}
-	return http.Get(rawURL)
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+	g.throttle.wait(u.Host)
+	client := g.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	g.throttle.observe(u.Host, resp)
+	return resp, nil
+}
+
+func (g *getterImpl) Post(rawURL string, contentType string, body io.Reader) (*http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URL: %w", err)
+	}
+	g.throttle.wait(u.Host)
+	client := g.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Post(rawURL, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	g.throttle.observe(u.Host, resp)
+	return resp, nil
+}
+
+// NewChecksumHash returns a hash matching a checksum type of repomd.xml or primary.xml. Besides sha256, openSUSE
+// and SLE repositories may use "sha" and "sha1" and newer repositories sometimes use sha384 or sha512.
+func NewChecksumHash(checksumType string) (hash.Hash, error) {
+	switch strings.ToLower(checksumType) {
+	case "sha", "sha1":
+		return sha1.New(), nil
+	case "sha224":
+		return sha256.New224(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha384":
+		return sha512.New384(), nil
+	case "sha512":
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported checksum type %q", checksumType)
 }
 
 func toHex(hasher hash.Hash) string {
@@ -2,12 +2,23 @@ package repo
 
 import (
 	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path"
 	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"sigs.k8s.io/yaml"
 )
 
 func TestGetter(t *testing.T) {
@@ -61,3 +72,322 @@ func TestGetter(t *testing.T) {
 		})
 	}
 }
+
+const testPrimary = `<?xml version="1.0" encoding="UTF-8"?>
+<metadata xmlns="http://linux.duke.edu/metadata/common" xmlns:rpm="http://linux.duke.edu/metadata/rpm" packages="1">
+<package type="rpm">
+  <name>bash</name>
+  <arch>x86_64</arch>
+  <version epoch="0" ver="5.0.17" rel="1.fc32"/>
+  <checksum type="sha256" pkgid="YES">1234</checksum>
+  <location href="Packages/b/bash-5.0.17-1.fc32.x86_64.rpm"/>
+</package>
+</metadata>
+`
+
+// newRepoServer serves a minimal repository with a gzipped primary.xml file
+func newRepoServer(t *testing.T) *httptest.Server {
+	primary := &bytes.Buffer{}
+	gz := gzip.NewWriter(primary)
+	if _, err := gz.Write([]byte(testPrimary)); err != nil {
+		t.Fatalf("failed to compress primary.xml: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("failed to compress primary.xml: %v", err)
+	}
+	sum := sha256.Sum256(primary.Bytes())
+	repomd := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<repomd xmlns="http://linux.duke.edu/metadata/repo">
+  <revision>1</revision>
+  <data type="primary">
+    <checksum type="sha256">%s</checksum>
+    <location href="repodata/primary.xml.gz"/>
+  </data>
+</repomd>
+`, hex.EncodeToString(sum[:]))
+
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repo/repodata/repomd.xml":
+			rw.Write([]byte(repomd))
+		case "/repo/repodata/primary.xml.gz":
+			rw.Write(primary.Bytes())
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestFetchWithMultipleBaseurls(t *testing.T) {
+	g := NewGomegaWithT(t)
+	s := newRepoServer(t)
+	repo := bazeldnf.Repository{
+		Name:    "test",
+		Arch:    "x86_64",
+		Baseurl: bazeldnf.URLs{s.URL + "/missing/", s.URL + "/repo/"},
+	}
+	cacheDir := t.TempDir()
+	g.Expect(NewRemoteRepoFetcher([]bazeldnf.Repository{repo}, cacheDir).Fetch()).To(Succeed())
+
+	primary, err := (&CacheHelper{CacheDir: cacheDir}).CurrentPrimary(&repo)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(primary.Packages).To(HaveLen(1))
+	g.Expect(primary.Packages[0].Repository.Mirrors).To(Equal([]string{s.URL + "/missing/", s.URL + "/repo/"}))
+}
+
+func TestBaseurlFormats(t *testing.T) {
+	g := NewGomegaWithT(t)
+	repos := &bazeldnf.Repositories{}
+	g.Expect(yaml.Unmarshal([]byte(`repositories:
+- name: single
+  baseurl: https://a.example.com/
+- name: multiple
+  baseurl:
+  - https://a.example.com/
+  - https://b.example.com/
+`), repos)).To(Succeed())
+	g.Expect(repos.Repositories[0].Baseurl).To(Equal(bazeldnf.URLs{"https://a.example.com/"}))
+	g.Expect(repos.Repositories[1].Baseurl).To(Equal(bazeldnf.URLs{"https://a.example.com/", "https://b.example.com/"}))
+
+	data, err := yaml.Marshal(repos)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(data)).To(ContainSubstring("baseurl: https://a.example.com/\n"))
+}
+
+func TestFetchWithProxy(t *testing.T) {
+	g := NewGomegaWithT(t)
+	proxy := newRepoServer(t)
+	repo := bazeldnf.Repository{
+		Name:    "test",
+		Arch:    "x86_64",
+		Baseurl: bazeldnf.URLs{"http://repo.example.invalid/repo/"},
+		Proxy:   proxy.URL,
+	}
+	cacheDir := t.TempDir()
+	g.Expect(NewRemoteRepoFetcher([]bazeldnf.Repository{repo}, cacheDir).Fetch()).To(Succeed())
+
+	repo.Proxy = "http://[invalid"
+	g.Expect(NewRemoteRepoFetcher([]bazeldnf.Repository{repo}, cacheDir).Fetch()).To(MatchError(ContainSubstring("failed to configure proxy for test")))
+}
+
+func TestFetchSUSERepository(t *testing.T) {
+	g := NewGomegaWithT(t)
+	primary := &bytes.Buffer{}
+	zw, err := zstd.NewWriter(primary)
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = zw.Write([]byte(testPrimary))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(zw.Close()).To(Succeed())
+	sum := sha1.Sum(primary.Bytes())
+	repomd := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<repomd xmlns="http://linux.duke.edu/metadata/repo" xmlns:rpm="http://linux.duke.edu/metadata/rpm" xmlns:suse="http://novell.com/package/metadata/suse/common">
+  <revision>1700000000</revision>
+  <tags>
+    <repo>obsrepository://build.opensuse.org/openSUSE:Tumbleweed/standard</repo>
+  </tags>
+  <data type="susedata">
+    <checksum type="sha256">0000</checksum>
+    <location href="repodata/susedata.xml.zst"/>
+  </data>
+  <data type="appdata">
+    <checksum type="sha256">0000</checksum>
+    <location href="repodata/appdata.xml.gz"/>
+  </data>
+  <data type="primary">
+    <checksum type="sha">%s</checksum>
+    <open-checksum type="sha">0000</open-checksum>
+    <location href="repodata/primary.xml.zst"/>
+  </data>
+</repomd>
+`, hex.EncodeToString(sum[:]))
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repo/repodata/repomd.xml":
+			rw.Write([]byte(repomd))
+		case "/repo/repodata/primary.xml.zst":
+			rw.Write(primary.Bytes())
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	repo := bazeldnf.Repository{
+		Name:    "tumbleweed",
+		Arch:    "x86_64",
+		Baseurl: bazeldnf.URLs{s.URL + "/repo/"},
+	}
+	cacheDir := t.TempDir()
+	g.Expect(NewRemoteRepoFetcher([]bazeldnf.Repository{repo}, cacheDir).Fetch()).To(Succeed())
+
+	repository, err := (&CacheHelper{CacheDir: cacheDir}).CurrentPrimary(&repo)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repository.Packages).To(HaveLen(1))
+	g.Expect(repository.Packages[0].Name).To(Equal("bash"))
+}
+
+func TestNewChecksumHash(t *testing.T) {
+	g := NewGomegaWithT(t)
+	for checksumType, size := range map[string]int{"sha": 20, "sha1": 20, "sha224": 28, "sha256": 32, "SHA384": 48, "sha512": 64} {
+		hasher, err := NewChecksumHash(checksumType)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(hasher.Size()).To(Equal(size))
+	}
+	_, err := NewChecksumHash("md5")
+	g.Expect(err).To(MatchError(ContainSubstring(`unsupported checksum type "md5"`)))
+}
+
+func TestFetchWithMirrorlist(t *testing.T) {
+	g := NewGomegaWithT(t)
+	s := newRepoServer(t)
+	mirrorlist := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(rw, "# region-local mirrors\n%s/missing/\n\n%s/repo/\n", s.URL, s.URL)
+	}))
+	defer mirrorlist.Close()
+	repo := bazeldnf.Repository{
+		Name:       "al2023",
+		Arch:       "x86_64",
+		Mirrorlist: mirrorlist.URL + "/mirror.list",
+	}
+	cacheDir := t.TempDir()
+	g.Expect(NewRemoteRepoFetcher([]bazeldnf.Repository{repo}, cacheDir).Fetch()).To(Succeed())
+
+	primary, err := (&CacheHelper{CacheDir: cacheDir}).CurrentPrimary(&repo)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(primary.Packages).To(HaveLen(1))
+	g.Expect(primary.Packages[0].Repository.Mirrors).To(Equal([]string{s.URL + "/missing/", s.URL + "/repo/"}))
+}
+
+func TestFetchWithMetalink4(t *testing.T) {
+	g := NewGomegaWithT(t)
+	s := newRepoServer(t)
+	resp, err := http.Get(s.URL + "/repo/repodata/repomd.xml")
+	g.Expect(err).ToNot(HaveOccurred())
+	repomd, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	g.Expect(err).ToNot(HaveOccurred())
+	sum := sha256.Sum256(repomd)
+	metalink := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(rw, `<?xml version="1.0" encoding="UTF-8"?>
+<metalink xmlns="urn:ietf:params:xml:ns:metalink">
+  <file name="repomd.xml">
+    <hash type="sha-256">%s</hash>
+    <url location="de" priority="2">%s/missing/repodata/repomd.xml</url>
+    <url location="de" priority="1">%s/repo/repodata/repomd.xml</url>
+  </file>
+</metalink>
+`, hex.EncodeToString(sum[:]), s.URL, s.URL)
+	}))
+	defer metalink.Close()
+	repo := bazeldnf.Repository{
+		Name:           "fedora",
+		Arch:           "x86_64",
+		Metalink:       metalink.URL + "/metalink",
+		MetalinkFilter: &bazeldnf.MetalinkFilter{Protocols: []string{"http"}},
+	}
+	cacheDir := t.TempDir()
+	g.Expect(NewRemoteRepoFetcher([]bazeldnf.Repository{repo}, cacheDir).Fetch()).To(Succeed())
+
+	primary, err := (&CacheHelper{CacheDir: cacheDir}).CurrentPrimary(&repo)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(primary.Packages).To(HaveLen(1))
+	g.Expect(primary.Packages[0].Repository.Mirrors).To(Equal([]string{s.URL + "/repo/", s.URL + "/missing/"}))
+}
+
+func TestFetchWithForeignPrimaryLocation(t *testing.T) {
+	g := NewGomegaWithT(t)
+	primary := &bytes.Buffer{}
+	zw, err := zstd.NewWriter(primary)
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = zw.Write([]byte(testPrimary))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(zw.Close()).To(Succeed())
+	sum := sha256.Sum256(primary.Bytes())
+	cdn := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/signed/primary.xml.zst" || r.URL.Query().Get("token") != "abc" {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rw.Write(primary.Bytes())
+	}))
+	defer cdn.Close()
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repo/repodata/repomd.xml" {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(rw, `<?xml version="1.0" encoding="UTF-8"?>
+<repomd xmlns="http://linux.duke.edu/metadata/repo">
+  <revision>1700000000</revision>
+  <data type="primary">
+    <checksum type="sha256">%s</checksum>
+    <location href="%s/signed/primary.xml.zst?token=abc"/>
+  </data>
+</repomd>
+`, hex.EncodeToString(sum[:]), cdn.URL)
+	}))
+	defer s.Close()
+
+	repo := bazeldnf.Repository{
+		Name:    "vendor",
+		Arch:    "x86_64",
+		Baseurl: bazeldnf.URLs{s.URL + "/repo/"},
+	}
+	cacheDir := t.TempDir()
+	g.Expect(NewRemoteRepoFetcher([]bazeldnf.Repository{repo}, cacheDir).Fetch()).To(Succeed())
+
+	repository, err := (&CacheHelper{CacheDir: cacheDir}).CurrentPrimary(&repo)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repository.Packages).To(HaveLen(1))
+	g.Expect(repository.Packages[0].Name).To(Equal("bash"))
+}
+
+func TestFetchReferencedFiles(t *testing.T) {
+	g := NewGomegaWithT(t)
+	primary := []byte(testPrimary)
+	updateinfo := []byte(`<updates><update type="security"><id>FEDORA-2020-1</id></update></updates>`)
+	primarySum := sha256.Sum256(primary)
+	updateinfoSum := sha256.Sum256(updateinfo)
+	repomd := fmt.Sprintf(`<repomd>
+  <revision>1</revision>
+  <data type="primary"><checksum type="sha256">%s</checksum><location href="repodata/primary.xml"/></data>
+  <data type="updateinfo"><checksum type="sha256">%s</checksum><location href="repodata/updateinfo.xml"/></data>
+</repomd>`, hex.EncodeToString(primarySum[:]), hex.EncodeToString(updateinfoSum[:]))
+	requests := []string{}
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		switch r.URL.Path {
+		case "/repo/repodata/repomd.xml":
+			rw.Write([]byte(repomd))
+		case "/repo/repodata/primary.xml":
+			rw.Write(primary)
+		case "/repo/repodata/updateinfo.xml":
+			rw.Write(updateinfo)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	repo := bazeldnf.Repository{Name: "test", Arch: "x86_64", Baseurl: bazeldnf.URLs{s.URL + "/repo/"}}
+	fetcher := &RepoFetcherImpl{
+		Repos:       []bazeldnf.Repository{repo},
+		Getter:      NewGetter(),
+		CacheHelper: &CacheHelper{CacheDir: t.TempDir()},
+	}
+	g.Expect(fetcher.FetchReferencedFiles(api.UpdateinfoFileType)).To(MatchError(ContainSubstring("run bazeldnf fetch first")))
+	g.Expect(fetcher.Fetch()).To(Succeed())
+
+	requests = nil
+	g.Expect(fetcher.FetchReferencedFiles(api.UpdateinfoFileType)).To(Succeed())
+	g.Expect(requests).To(Equal([]string{"/repo/repodata/updateinfo.xml"}))
+	updates, err := fetcher.CacheHelper.CurrentUpdateinfo(&repo)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(updates.Advisories).To(HaveLen(1))
+
+	requests = nil
+	g.Expect(fetcher.FetchReferencedFiles(api.UpdateinfoFileType, api.FilelistsFileType)).To(Succeed())
+	g.Expect(requests).To(BeEmpty())
+}
@@ -0,0 +1,189 @@
+package repo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// fixture describes a recorded response, its body is stored in a separate file next to it
+type fixture struct {
+	URL        string      `json:"url"`
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+}
+
+// fixtureName returns the base name of the fixture files of the nth request with the given key
+func fixtureName(key string, n int) string {
+	sum := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(sum[:]), n)
+}
+
+// postKey identifies a POST request by its URL and body, since XML-RPC calls all go to the same URL
+func postKey(rawURL string, body []byte) string {
+	return "POST " + rawURL + "\n" + string(body)
+}
+
+// requestCounter counts how often each URL was requested, so that repeated requests can be told apart
+type requestCounter struct {
+	lock   sync.Mutex
+	counts map[string]int
+}
+
+func (c *requestCounter) next(rawURL string) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.counts == nil {
+		c.counts = map[string]int{}
+	}
+	n := c.counts[rawURL]
+	c.counts[rawURL] = n + 1
+	return n
+}
+
+// RecordingGetter forwards all requests to another Getter and records the responses in a fixture directory,
+// which can be replayed with a ReplayGetter
+type RecordingGetter struct {
+	Getter   Getter
+	Dir      string
+	requests requestCounter
+}
+
+func (g *RecordingGetter) Get(rawURL string) (*http.Response, error) {
+	return g.record(rawURL, rawURL, func() (*http.Response, error) { return g.Getter.Get(rawURL) })
+}
+
+func (g *RecordingGetter) Post(rawURL string, contentType string, body io.Reader) (*http.Response, error) {
+	return g.recordPost(g.Getter, rawURL, contentType, body)
+}
+
+func (g *RecordingGetter) recordPost(getter Getter, rawURL string, contentType string, body io.Reader) (*http.Response, error) {
+	poster, ok := getter.(Poster)
+	if !ok {
+		return nil, fmt.Errorf("%T can't send POST requests", getter)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	return g.record(rawURL, postKey(rawURL, data), func() (*http.Response, error) {
+		return poster.Post(rawURL, contentType, bytes.NewReader(data))
+	})
+}
+
+// record sends the request and stores its response under the key of the request
+func (g *RecordingGetter) record(rawURL string, key string, request func() (*http.Response, error)) (*http.Response, error) {
+	resp, err := request()
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %v", rawURL, err)
+	}
+	name := filepath.Join(g.Dir, fixtureName(key, g.requests.next(key)))
+	if err := os.MkdirAll(g.Dir, 0770); err != nil {
+		return nil, fmt.Errorf("failed to create fixture directory: %v", err)
+	}
+	meta, err := json.MarshalIndent(&fixture{URL: rawURL, StatusCode: resp.StatusCode, Header: resp.Header}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(name+".json", meta, 0660); err != nil {
+		return nil, fmt.Errorf("failed to record response from %s: %v", rawURL, err)
+	}
+	if err := os.WriteFile(name+".body", body, 0660); err != nil {
+		return nil, fmt.Errorf("failed to record response from %s: %v", rawURL, err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+func (g *RecordingGetter) WithProxy(proxy string) (Getter, error) {
+	proxyGetter, ok := g.Getter.(ProxyGetter)
+	if !ok {
+		return g, nil
+	}
+	getter, err := proxyGetter.WithProxy(proxy)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingProxyGetter{recorder: g, getter: getter}, nil
+}
+
+// recordingProxyGetter records into the fixture directory of its RecordingGetter, so that the requests of all
+// repositories share one request sequence
+type recordingProxyGetter struct {
+	recorder *RecordingGetter
+	getter   Getter
+}
+
+func (g *recordingProxyGetter) Get(rawURL string) (*http.Response, error) {
+	return g.recorder.record(rawURL, rawURL, func() (*http.Response, error) { return g.getter.Get(rawURL) })
+}
+
+func (g *recordingProxyGetter) Post(rawURL string, contentType string, body io.Reader) (*http.Response, error) {
+	return g.recorder.recordPost(g.getter, rawURL, contentType, body)
+}
+
+// ReplayGetter serves all requests from a fixture directory written by a RecordingGetter and never touches the
+// network. If a URL is requested more often than it was recorded, the last recorded response is served again.
+type ReplayGetter struct {
+	Dir      string
+	requests requestCounter
+}
+
+func (g *ReplayGetter) Get(rawURL string) (*http.Response, error) {
+	return g.replay(rawURL, rawURL)
+}
+
+func (g *ReplayGetter) Post(rawURL string, contentType string, body io.Reader) (*http.Response, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	return g.replay(rawURL, postKey(rawURL, data))
+}
+
+// replay serves the recorded response of the request with the given key
+func (g *ReplayGetter) replay(rawURL string, key string) (*http.Response, error) {
+	name := ""
+	for n := g.requests.next(key); n >= 0; n-- {
+		name = filepath.Join(g.Dir, fixtureName(key, n))
+		if _, err := os.Stat(name + ".json"); err == nil {
+			break
+		}
+	}
+	data, err := os.ReadFile(name + ".json")
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no recorded response for %s in %s", rawURL, g.Dir)
+	} else if err != nil {
+		return nil, err
+	}
+	recorded := &fixture{}
+	if err := json.Unmarshal(data, recorded); err != nil {
+		return nil, fmt.Errorf("failed to parse recorded response for %s: %v", rawURL, err)
+	}
+	body, err := os.Open(name + ".body")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recorded response body for %s: %v", rawURL, err)
+	}
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode: recorded.StatusCode,
+		Header:     recorded.Header,
+		Body:       body,
+	}, nil
+}
+
+func (g *ReplayGetter) WithProxy(proxy string) (Getter, error) {
+	return g, nil
+}
@@ -0,0 +1,77 @@
+package repo
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	g := NewGomegaWithT(t)
+	upstream := newRepoServer(t)
+	repo := bazeldnf.Repository{Name: "test", Arch: "x86_64", Baseurl: bazeldnf.URLs{upstream.URL + "/missing/", upstream.URL + "/repo/"}}
+	fixtures := t.TempDir()
+
+	recorder := &RepoFetcherImpl{
+		Repos:       []bazeldnf.Repository{repo},
+		Getter:      &RecordingGetter{Getter: NewGetter(), Dir: fixtures},
+		CacheHelper: &CacheHelper{CacheDir: t.TempDir()},
+	}
+	g.Expect(recorder.Fetch()).To(Succeed())
+	upstream.Close()
+
+	replayed := &RepoFetcherImpl{
+		Repos:       []bazeldnf.Repository{repo},
+		Getter:      &ReplayGetter{Dir: fixtures},
+		CacheHelper: &CacheHelper{CacheDir: t.TempDir()},
+	}
+	g.Expect(replayed.Fetch()).To(Succeed())
+	primary, err := replayed.CacheHelper.CurrentPrimary(&repo)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(primary.Packages).To(HaveLen(1))
+}
+
+func TestReplayRepeatsLastResponse(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fixtures := t.TempDir()
+	calls := 0
+	recorder := &RecordingGetter{Getter: getterFunc(func(url string) (*http.Response, error) {
+		calls++
+		if calls == 1 {
+			return newResponse(http.StatusTooManyRequests, "slow down"), nil
+		}
+		return newResponse(http.StatusOK, "content"), nil
+	}), Dir: fixtures}
+	for i := 0; i < 2; i++ {
+		resp, err := recorder.Get("https://example.com/file")
+		g.Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+	}
+
+	replay := &ReplayGetter{Dir: fixtures}
+	for _, expected := range []string{"slow down", "content", "content"} {
+		resp, err := replay.Get("https://example.com/file")
+		g.Expect(err).ToNot(HaveOccurred())
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(body)).To(Equal(expected))
+	}
+
+	_, err := replay.Get("https://example.com/unknown")
+	g.Expect(err).To(MatchError(ContainSubstring("no recorded response for https://example.com/unknown")))
+}
+
+type getterFunc func(url string) (*http.Response, error)
+
+func (f getterFunc) Get(url string) (*http.Response, error) {
+	return f(url)
+}
+
+func newResponse(status int, body string) *http.Response {
+	return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+}
@@ -0,0 +1,111 @@
+package repo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/openpgp"
+)
+
+// LoadGPGKeys downloads the armored public keys referenced by the gpgkey of the repository, taking its proxy
+// settings into account. Like in dnf, multiple key URLs can be separated by whitespace.
+func LoadGPGKeys(getter Getter, repo *bazeldnf.Repository) (openpgp.EntityList, error) {
+	if proxyGetter, ok := getter.(ProxyGetter); ok && repo.Proxy != "" {
+		var err error
+		if getter, err = proxyGetter.WithProxy(repo.Proxy); err != nil {
+			return nil, fmt.Errorf("failed to configure proxy for %s: %v", repo.Name, err)
+		}
+	}
+	keyring := openpgp.EntityList{}
+	for _, key := range strings.Fields(repo.GPGKey) {
+		keys, err := loadGPGKey(getter, key)
+		if err != nil {
+			return nil, err
+		}
+		keyring = append(keyring, keys...)
+	}
+	return keyring, nil
+}
+
+func loadGPGKey(getter Getter, key string) (openpgp.EntityList, error) {
+	resp, err := getter.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch gpgkey %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("could not fetch gpgkey %s: status : %v", key, resp.StatusCode)
+	}
+	keys, err := openpgp.ReadArmoredKeyRing(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not load gpgkey %s: %w", key, err)
+	}
+	return keys, nil
+}
+
+// RequiresSignedRPMs returns true if gpgcheck is enabled for the repository, in which case every RPM has to be
+// signed by one of its keys
+func RequiresSignedRPMs(repo *bazeldnf.Repository) bool {
+	return repo.GPGCheck != nil && *repo.GPGCheck
+}
+
+// ChecksRPMSignatures returns false if gpgcheck is explicitly disabled for the repository
+func ChecksRPMSignatures(repo *bazeldnf.Repository) bool {
+	return repo.GPGCheck == nil || *repo.GPGCheck
+}
+
+// repomdKeyring returns the keys repomd.xml of the repository has to be signed with, or nil if repo_gpgcheck is
+// not enabled for the repository
+func (r *RepoFetcherImpl) repomdKeyring(repo *bazeldnf.Repository) (openpgp.EntityList, error) {
+	if !repo.RepoGPGCheck {
+		return nil, nil
+	}
+	if strings.TrimSpace(repo.GPGKey) == "" {
+		return nil, fmt.Errorf("repo_gpgcheck is enabled for %s, but no gpgkey is configured", repo.Name)
+	}
+	keyring, err := LoadGPGKeys(r.Getter, repo)
+	if err != nil {
+		return nil, err
+	}
+	if len(keyring) == 0 {
+		return nil, fmt.Errorf("repo_gpgcheck is enabled for %s, but its gpgkey contains no keys", repo.Name)
+	}
+	return keyring, nil
+}
+
+// fetchRepomdSignature downloads the detached signature of repomd.xml and verifies the downloaded repomd.xml
+// with it
+func (r *RepoFetcherImpl) fetchRepomdSignature(repo *bazeldnf.Repository, keyring openpgp.EntityList, repomdURL string, repomdFile string) ([]byte, error) {
+	getter, err := r.getter(repo)
+	if err != nil {
+		return nil, err
+	}
+	signatureURL := repomdURL + ".asc"
+	log.Infof("Verifying repomd.xml with %s", signatureURL)
+	resp, err := getter.Get(signatureURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %v", signatureURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("failed to download %s: status : %v", signatureURL, resp.StatusCode)
+	}
+	signature, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %v", signatureURL, err)
+	}
+	repomd, err := os.Open(repomdFile)
+	if err != nil {
+		return nil, err
+	}
+	defer repomd.Close()
+	if _, err := openpgp.CheckArmoredDetachedSignature(keyring, repomd, bytes.NewReader(signature)); err != nil {
+		return nil, fmt.Errorf("repomd.xml of %s is not signed with its gpgkey: %v", repo.Name, err)
+	}
+	return signature, nil
+}
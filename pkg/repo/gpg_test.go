@@ -0,0 +1,89 @@
+package repo
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func TestFetchWithRepoGPGCheck(t *testing.T) {
+	g := NewGomegaWithT(t)
+	entity, err := openpgp.NewEntity("bazeldnf", "test", "bazeldnf@example.com", nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	publicKey := &bytes.Buffer{}
+	w, err := armor.Encode(publicKey, openpgp.PublicKeyType, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(entity.Serialize(w)).To(Succeed())
+	g.Expect(w.Close()).To(Succeed())
+
+	upstream := newRepoServer(t)
+	resp, err := http.Get(upstream.URL + "/repo/repodata/repomd.xml")
+	g.Expect(err).ToNot(HaveOccurred())
+	repomd, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	g.Expect(err).ToNot(HaveOccurred())
+	signature := &bytes.Buffer{}
+	g.Expect(openpgp.ArmoredDetachSign(signature, entity, bytes.NewReader(repomd), nil)).To(Succeed())
+	tampered := &bytes.Buffer{}
+	g.Expect(openpgp.ArmoredDetachSign(tampered, entity, bytes.NewReader(append(repomd, ' ')), nil)).To(Succeed())
+
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/RPM-GPG-KEY":
+			rw.Write(publicKey.Bytes())
+		case "/repo/repodata/repomd.xml.asc":
+			rw.Write(signature.Bytes())
+		case "/tampered/repodata/repomd.xml.asc":
+			rw.Write(tampered.Bytes())
+		case "/tampered/repodata/repomd.xml":
+			rw.Write(repomd)
+		default:
+			http.Redirect(rw, r, upstream.URL+r.URL.Path, http.StatusFound)
+		}
+	}))
+	defer s.Close()
+
+	repo := bazeldnf.Repository{
+		Name:         "signed",
+		Arch:         "x86_64",
+		Baseurl:      bazeldnf.URLs{s.URL + "/tampered/", s.URL + "/repo/"},
+		GPGKey:       s.URL + "/RPM-GPG-KEY",
+		RepoGPGCheck: true,
+	}
+	cacheDir := t.TempDir()
+	g.Expect(NewRemoteRepoFetcher([]bazeldnf.Repository{repo}, cacheDir).Fetch()).To(Succeed())
+	g.Expect(os.ReadFile(filepath.Join(cacheDir, "signed", "repomd.xml.asc"))).To(Equal(signature.Bytes()))
+
+	// refreshing the repository without repo_gpgcheck drops the signature of the old repomd.xml
+	unsigned := repo
+	unsigned.RepoGPGCheck = false
+	g.Expect(NewRemoteRepoFetcher([]bazeldnf.Repository{unsigned}, cacheDir).Fetch()).To(Succeed())
+	g.Expect(filepath.Join(cacheDir, "signed", "repomd.xml.asc")).ToNot(BeAnExistingFile())
+
+	repo.Baseurl = bazeldnf.URLs{s.URL + "/tampered/"}
+	g.Expect(NewRemoteRepoFetcher([]bazeldnf.Repository{repo}, t.TempDir()).Fetch()).ToNot(Succeed())
+
+	repo.GPGKey = ""
+	err = NewRemoteRepoFetcher([]bazeldnf.Repository{repo}, t.TempDir()).Fetch()
+	g.Expect(err).To(MatchError(ContainSubstring("repo_gpgcheck is enabled for signed, but no gpgkey is configured")))
+}
+
+func TestGPGCheck(t *testing.T) {
+	g := NewGomegaWithT(t)
+	enabled, disabled := true, false
+	g.Expect(RequiresSignedRPMs(&bazeldnf.Repository{})).To(BeFalse())
+	g.Expect(ChecksRPMSignatures(&bazeldnf.Repository{})).To(BeTrue())
+	g.Expect(RequiresSignedRPMs(&bazeldnf.Repository{GPGCheck: &enabled})).To(BeTrue())
+	g.Expect(ChecksRPMSignatures(&bazeldnf.Repository{GPGCheck: &enabled})).To(BeTrue())
+	g.Expect(RequiresSignedRPMs(&bazeldnf.Repository{GPGCheck: &disabled})).To(BeFalse())
+	g.Expect(ChecksRPMSignatures(&bazeldnf.Repository{GPGCheck: &disabled})).To(BeFalse())
+}
@@ -1,21 +1,36 @@
 package repo
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/catalog"
+	"github.com/rmohr/bazeldnf/pkg/rpmarch"
+	log "github.com/sirupsen/logrus"
+	yamlv3 "gopkg.in/yaml.v3"
 	"sigs.k8s.io/yaml"
 )
 
+// RepoFileVersion is the repository file format version written by this version of bazeldnf. Files without a
+// version have version 0, which is compatible with version 1.
+const RepoFileVersion = 1
+
 type RepoInit struct {
 	OS                 string
 	Arch               string
 	PrimaryMetaLinkURL string
 	UpdateMetaLinkURL  string
 	RepoFile           string
+	MetalinkFilter     *bazeldnf.MetalinkFilter
 }
 
 func (r *RepoInit) Init() error {
@@ -25,20 +40,21 @@ func (r *RepoInit) Init() error {
 		return fmt.Errorf("repository file %s already exists.", r.RepoFile)
 	}
 	repos := &bazeldnf.Repositories{
+		Version: RepoFileVersion,
 		Repositories: []bazeldnf.Repository{
 			{
-				Name:     fmt.Sprintf("%s-%s-primary-repo", r.OS, r.Arch),
-				Disabled: false,
-				Metalink: r.PrimaryMetaLinkURL,
-				Baseurl:  "",
-				Arch:     r.Arch,
+				Name:           fmt.Sprintf("%s-%s-primary-repo", r.OS, r.Arch),
+				Disabled:       false,
+				Metalink:       r.PrimaryMetaLinkURL,
+				Arch:           r.Arch,
+				MetalinkFilter: r.MetalinkFilter,
 			},
 			{
-				Name:     fmt.Sprintf("%s-%s-update-repo", r.OS, r.Arch),
-				Disabled: false,
-				Metalink: r.UpdateMetaLinkURL,
-				Baseurl:  "",
-				Arch:     r.Arch,
+				Name:           fmt.Sprintf("%s-%s-update-repo", r.OS, r.Arch),
+				Disabled:       false,
+				Metalink:       r.UpdateMetaLinkURL,
+				Arch:           r.Arch,
+				MetalinkFilter: r.MetalinkFilter,
 			},
 		},
 	}
@@ -70,17 +86,257 @@ func LoadRepoFile(file string) (*bazeldnf.Repositories, error) {
 	if err != nil {
 		return nil, err
 	}
+	if repos.Version > RepoFileVersion {
+		return nil, fmt.Errorf("repository file %s has format version %d which is newer than the supported version %d, please update bazeldnf", file, repos.Version, RepoFileVersion)
+	}
+	if err := validateWeakDepsFilters(repos.IgnoreWeakDeps); err != nil {
+		return nil, fmt.Errorf("repository file %s: %v", file, err)
+	}
+	for i := range repos.Repositories {
+		repos.Repositories[i].Arch = rpmarch.Normalize(repos.Repositories[i].Arch)
+		for j := range repos.Repositories[i].Arches {
+			repos.Repositories[i].Arches[j] = rpmarch.Normalize(repos.Repositories[i].Arches[j])
+		}
+	}
 	return repos, err
 }
 
+// validateWeakDepsFilters checks the globs of the filters, invalid globs would silently match nothing
+func validateWeakDepsFilters(filters []bazeldnf.WeakDepsFilter) error {
+	for _, filter := range filters {
+		for _, glob := range append([]string{filter.Package}, filter.Recommends...) {
+			if _, err := path.Match(glob, ""); err != nil {
+				return fmt.Errorf("invalid glob %q in ignoreWeakDeps: %v", glob, err)
+			}
+		}
+	}
+	return nil
+}
+
+// LoadRepoFiles loads and merges the repository files in order. Directories like `repos.d/` contribute all their
+// .yaml, .yml and .json files in lexical order. A repository overrides the one with the same name from an earlier
+// file in place, within a file its repositories override the ones of its distroRepos. Preferences and the
+// cacheDir and awsRegion of later files win as well, ignored weak dependencies are accumulated.
 func LoadRepoFiles(files []string) (*bazeldnf.Repositories, error) {
+	files, err := expandRepoFiles(files)
+	if err != nil {
+		return nil, err
+	}
 	repos := &bazeldnf.Repositories{}
 	for i, _ := range files {
 		tmp, err := LoadRepoFile(files[i])
 		if err != nil {
 			return nil, err
 		}
-		repos.Repositories = append(repos.Repositories, tmp.Repositories...)
+		if tmp.AWSRegion != "" {
+			repos.AWSRegion = tmp.AWSRegion
+		}
+		distroRepos, err := distroRepositories(tmp.DistroRepos, repos.AWSRegion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load repository file %s: %v", files[i], err)
+		}
+		layer := tmp.Repositories
+		for _, distroRepo := range distroRepos {
+			if indexOfRepo(layer, distroRepo.Name) < 0 {
+				layer = append(layer, distroRepo)
+			}
+		}
+		mergeRepositories(repos, layer, files[i])
+		for capability, pkg := range tmp.Preferences {
+			if repos.Preferences == nil {
+				repos.Preferences = map[string]string{}
+			}
+			repos.Preferences[capability] = pkg
+		}
+		if tmp.CacheDir != "" {
+			repos.CacheDir = tmp.CacheDir
+		}
+		repos.IgnoreWeakDeps = append(repos.IgnoreWeakDeps, tmp.IgnoreWeakDeps...)
+	}
+	return repos, nil
+}
+
+// expandRepoFiles replaces directories with the repository files they contain
+func expandRepoFiles(files []string) ([]string, error) {
+	expanded := []string{}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil || !info.IsDir() {
+			expanded = append(expanded, file)
+			continue
+		}
+		entries, err := os.ReadDir(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read repository directory %s: %v", file, err)
+		}
+		for _, entry := range entries {
+			switch filepath.Ext(entry.Name()) {
+			case ".yaml", ".yml", ".json":
+				if !entry.IsDir() {
+					expanded = append(expanded, filepath.Join(file, entry.Name()))
+				}
+			}
+		}
+	}
+	return expanded, nil
+}
+
+// LastRepoFile returns the repository file which is loaded last, with directories expanded to their files. Its
+// preferences override the ones of all other files. It is empty if no repository file exists.
+func LastRepoFile(files []string) (string, error) {
+	files, err := expandRepoFiles(files)
+	if err != nil {
+		return "", err
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		if info, err := os.Stat(files[i]); err == nil && info.Mode().IsRegular() {
+			return files[i], nil
+		}
+	}
+	return "", nil
+}
+
+// mergeRepositories adds the repositories, replacing the ones with the same name in place
+func mergeRepositories(repos *bazeldnf.Repositories, added []bazeldnf.Repository, source string) {
+	for _, r := range added {
+		if i := indexOfRepo(repos.Repositories, r.Name); i >= 0 {
+			log.Debugf("Repository %s from %s overrides the one defined before", r.Name, source)
+			repos.Repositories[i] = r
+			continue
+		}
+		repos.Repositories = append(repos.Repositories, r)
+	}
+}
+
+func indexOfRepo(repos []bazeldnf.Repository, name string) int {
+	for i := range repos {
+		if repos[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddDistroRepos adds the repositories of the built-in catalog with the given ids, replacing configured
+// repositories with the same names. The AWS region defaults to the one of the repository files.
+func AddDistroRepos(repos *bazeldnf.Repositories, ids []string, awsRegion string) error {
+	if awsRegion == "" {
+		awsRegion = repos.AWSRegion
+	}
+	distroRepos, err := distroRepositories(ids, awsRegion)
+	if err != nil {
+		return err
+	}
+	mergeRepositories(repos, distroRepos, "the distribution catalog")
+	return nil
+}
+
+// distroRepositories returns the repositories of the built-in catalog with the given ids
+func distroRepositories(ids []string, awsRegion string) ([]bazeldnf.Repository, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	c, err := catalog.Load()
+	if err != nil {
+		return nil, err
+	}
+	repos := []bazeldnf.Repository{}
+	for _, id := range ids {
+		distroRepos, err := c.Repositories(id, awsRegion)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, distroRepos...)
 	}
 	return repos, nil
 }
+
+// AddPreferences persists the given capability preferences in the repository file. YAML files are edited in
+// place, so that comments and the order of keys are kept.
+func AddPreferences(file string, preferences map[string]string) error {
+	if filepath.Ext(file) == ".json" {
+		return addJSONPreferences(file, preferences)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	doc := &yamlv3.Node{}
+	if err := yamlv3.Unmarshal(data, doc); err != nil {
+		return fmt.Errorf("failed to parse repository file %s: %v", file, err)
+	}
+	if len(doc.Content) == 0 {
+		doc = &yamlv3.Node{Kind: yamlv3.DocumentNode, Content: []*yamlv3.Node{{Kind: yamlv3.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yamlv3.MappingNode {
+		return fmt.Errorf("repository file %s does not contain a mapping", file)
+	}
+	setMappingValue(root, "version", &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!int", Value: strconv.Itoa(RepoFileVersion)})
+	prefs := mappingValue(root, "preferences")
+	if prefs == nil || prefs.Kind != yamlv3.MappingNode {
+		prefs = &yamlv3.Node{Kind: yamlv3.MappingNode, Tag: "!!map"}
+		setMappingValue(root, "preferences", prefs)
+	}
+	capabilities := []string{}
+	for capability := range preferences {
+		capabilities = append(capabilities, capability)
+	}
+	sort.Strings(capabilities)
+	for _, capability := range capabilities {
+		setMappingValue(prefs, capability, &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: preferences[capability]})
+	}
+	buf := &bytes.Buffer{}
+	encoder := yamlv3.NewEncoder(buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(file, buf.Bytes(), 0660)
+}
+
+// addJSONPreferences persists the preferences in a repository file in JSON format, which has no comments to keep
+func addJSONPreferences(file string, preferences map[string]string) error {
+	repos, err := LoadRepoFile(file)
+	if err != nil {
+		return err
+	}
+	if repos.Preferences == nil {
+		repos.Preferences = map[string]string{}
+	}
+	for capability, pkg := range preferences {
+		repos.Preferences[capability] = pkg
+	}
+	repos.Version = RepoFileVersion
+	data, err := json.MarshalIndent(repos, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0660)
+}
+
+// mappingValue returns the value of the key in a YAML mapping or nil
+func mappingValue(mapping *yamlv3.Node, key string) *yamlv3.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue replaces the value of the key in a YAML mapping in place or appends the key
+func setMappingValue(mapping *yamlv3.Node, key string, value *yamlv3.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			value.HeadComment = mapping.Content[i+1].HeadComment
+			value.LineComment = mapping.Content[i+1].LineComment
+			mapping.Content[i+1] = value
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content, &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: key}, value)
+}
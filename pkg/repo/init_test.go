@@ -0,0 +1,146 @@
+package repo
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func TestRepoFileVersion(t *testing.T) {
+	g := NewGomegaWithT(t)
+	file := path.Join(t.TempDir(), "repo.yaml")
+	g.Expect(os.WriteFile(file, []byte("version: 1000\nrepositories: []\n"), 0666)).To(Succeed())
+	_, err := LoadRepoFile(file)
+	g.Expect(err).To(MatchError(ContainSubstring("format version 1000 which is newer than the supported version")))
+
+	g.Expect(os.WriteFile(file, []byte("repositories:\n- name: test\n  arch: x86_64\n"), 0666)).To(Succeed())
+	repos, err := LoadRepoFile(file)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repos.Repositories).To(HaveLen(1))
+}
+
+func TestDistroRepos(t *testing.T) {
+	g := NewGomegaWithT(t)
+	file := path.Join(t.TempDir(), "repo.yaml")
+	g.Expect(os.WriteFile(file, []byte("distroRepos:\n- fedora-41/x86_64\nrepositories:\n- name: test\n  arch: x86_64\n"), 0666)).To(Succeed())
+	repos, err := LoadRepoFiles([]string{file})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repos.Repositories).To(HaveLen(3))
+	g.Expect(repos.Repositories[1].Name).To(Equal("fedora-41-x86_64-primary-repo"))
+
+	g.Expect(os.WriteFile(file, []byte("distroRepos:\n- unknown-1\n"), 0666)).To(Succeed())
+	_, err = LoadRepoFiles([]string{file})
+	g.Expect(err).To(MatchError(ContainSubstring("unknown distribution unknown")))
+
+	g.Expect(os.WriteFile(file, []byte("distroRepos:\n- amazonlinux-2/x86_64\n"), 0666)).To(Succeed())
+	_, err = LoadRepoFiles([]string{file})
+	g.Expect(err).To(MatchError(ContainSubstring("an AWS region is required")))
+	g.Expect(os.WriteFile(file, []byte("awsRegion: eu-central-1\ndistroRepos:\n- amazonlinux-2/x86_64\n"), 0666)).To(Succeed())
+	repos, err = LoadRepoFiles([]string{file})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repos.Repositories[0].Mirrorlist).To(Equal("https://amazonlinux.eu-central-1.amazonaws.com/2/core/latest/x86_64/mirror.list"))
+}
+
+func TestLoadRepoFilesMerge(t *testing.T) {
+	g := NewGomegaWithT(t)
+	dir := t.TempDir()
+	org := path.Join(dir, "org.yaml")
+	g.Expect(os.WriteFile(org, []byte("cacheDir: /org\npreferences:\n  curl: curl\nrepositories:\n- name: base\n  arch: x86_64\n  baseurl: https://org.example.com/base/\n- name: tools\n  arch: x86_64\n  baseurl: https://org.example.com/tools/\n"), 0666)).To(Succeed())
+	reposD := path.Join(dir, "repos.d")
+	g.Expect(os.Mkdir(reposD, 0777)).To(Succeed())
+	g.Expect(os.WriteFile(path.Join(reposD, "20-project.yaml"), []byte("preferences:\n  curl: curl-minimal\nrepositories:\n- name: base\n  arch: x86_64\n  baseurl: https://mirror.example.com/base/\n- name: project\n  arch: x86_64\n"), 0666)).To(Succeed())
+	g.Expect(os.WriteFile(path.Join(reposD, "10-disable.yml"), []byte("repositories:\n- name: tools\n  arch: x86_64\n  disabled: true\n"), 0666)).To(Succeed())
+	g.Expect(os.WriteFile(path.Join(reposD, "README.md"), []byte("not a repository file"), 0666)).To(Succeed())
+
+	repos, err := LoadRepoFiles([]string{org, reposD})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repos.Repositories).To(HaveLen(3))
+	g.Expect(repos.Repositories[0].Name).To(Equal("base"))
+	g.Expect(repos.Repositories[0].Baseurl).To(Equal(bazeldnf.URLs{"https://mirror.example.com/base/"}))
+	g.Expect(repos.Repositories[1].Name).To(Equal("tools"))
+	g.Expect(repos.Repositories[1].Disabled).To(BeTrue())
+	g.Expect(repos.Repositories[2].Name).To(Equal("project"))
+	g.Expect(repos.Preferences).To(Equal(map[string]string{"curl": "curl-minimal"}))
+	g.Expect(repos.CacheDir).To(Equal("/org"))
+	g.Expect(LastRepoFile([]string{org, reposD})).To(Equal(path.Join(reposD, "20-project.yaml")))
+	g.Expect(LastRepoFile([]string{reposD, org})).To(Equal(org))
+	g.Expect(LastRepoFile([]string{path.Join(dir, "missing.yaml")})).To(BeEmpty())
+
+	// repositories of a file override its distribution repositories
+	override := path.Join(dir, "override.yaml")
+	g.Expect(os.WriteFile(override, []byte("distroRepos:\n- fedora-41/x86_64\nrepositories:\n- name: fedora-41-x86_64-primary-repo\n  arch: x86_64\n  baseurl: https://mirror.example.com/fedora/\n"), 0666)).To(Succeed())
+	repos, err = LoadRepoFiles([]string{override})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repos.Repositories).To(HaveLen(2))
+	g.Expect(repos.Repositories[0].Baseurl).To(Equal(bazeldnf.URLs{"https://mirror.example.com/fedora/"}))
+}
+
+func TestRepoFileArchAliases(t *testing.T) {
+	g := NewGomegaWithT(t)
+	file := path.Join(t.TempDir(), "repo.yaml")
+	g.Expect(os.WriteFile(file, []byte("repositories:\n- name: amd\n  arch: amd64\n- name: arm\n  arch: arm64\n"), 0666)).To(Succeed())
+	repos, err := LoadRepoFile(file)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repos.Repositories[0].Arch).To(Equal("x86_64"))
+	g.Expect(repos.Repositories[1].Arch).To(Equal("aarch64"))
+}
+
+func TestRepoArchOverride(t *testing.T) {
+	g := NewGomegaWithT(t)
+	file := path.Join(t.TempDir(), "repo.yaml")
+	g.Expect(os.WriteFile(file, []byte("repositories:\n- name: internal\n  arch: noarch\n- name: tree\n  arch: amd64\n  arches:\n  - arm64\n- name: fedora\n  arch: x86_64\n"), 0666)).To(Succeed())
+	repos, err := LoadRepoFile(file)
+	g.Expect(err).ToNot(HaveOccurred())
+	internal, tree, fedora := repos.Repositories[0], repos.Repositories[1], repos.Repositories[2]
+	g.Expect(tree.Arches).To(Equal([]string{"aarch64"}))
+	g.Expect(internal.ServesArch("x86_64")).To(BeTrue())
+	g.Expect(internal.ServesArch("s390x")).To(BeTrue())
+	g.Expect(tree.ServesArch("x86_64")).To(BeTrue())
+	g.Expect(tree.ServesArch("aarch64")).To(BeTrue())
+	g.Expect(tree.ServesArch("s390x")).To(BeFalse())
+	g.Expect(fedora.ServesArch("aarch64")).To(BeFalse())
+}
+
+func TestRepoFileWeakDepsGlobs(t *testing.T) {
+	g := NewGomegaWithT(t)
+	file := path.Join(t.TempDir(), "repo.yaml")
+	g.Expect(os.WriteFile(file, []byte("ignoreWeakDeps:\n- package: systemd\n  recommends:\n  - systemd-*\n"), 0666)).To(Succeed())
+	repos, err := LoadRepoFile(file)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repos.IgnoreWeakDeps[0].Ignores("systemd", "systemd-networkd")).To(BeTrue())
+
+	g.Expect(os.WriteFile(file, []byte("ignoreWeakDeps:\n- package: systemd\n  recommends:\n  - systemd-[\n"), 0666)).To(Succeed())
+	_, err = LoadRepoFile(file)
+	g.Expect(err).To(MatchError(ContainSubstring(`invalid glob "systemd-[" in ignoreWeakDeps`)))
+
+	g.Expect(os.WriteFile(file, []byte("ignoreWeakDeps:\n- package: \"[\"\n"), 0666)).To(Succeed())
+	_, err = LoadRepoFile(file)
+	g.Expect(err).To(MatchError(ContainSubstring(`invalid glob "["`)))
+}
+
+func TestAddPreferences(t *testing.T) {
+	g := NewGomegaWithT(t)
+	file := path.Join(t.TempDir(), "repo.yaml")
+	g.Expect(os.WriteFile(file, []byte("# shared repositories\nrepositories:\n- name: test # the test repo\n  arch: x86_64\npreferences:\n  curl: curl-minimal\n"), 0666)).To(Succeed())
+	g.Expect(AddPreferences(file, map[string]string{"curl": "curl", "libcurl": "libcurl-minimal"})).To(Succeed())
+
+	data, err := os.ReadFile(file)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(data)).To(HavePrefix("# shared repositories\nrepositories:"))
+	g.Expect(string(data)).To(ContainSubstring("name: test # the test repo"))
+	repos, err := LoadRepoFile(file)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repos.Version).To(Equal(RepoFileVersion))
+	g.Expect(repos.Repositories).To(HaveLen(1))
+	g.Expect(repos.Preferences).To(Equal(map[string]string{"curl": "curl", "libcurl": "libcurl-minimal"}))
+
+	file = path.Join(t.TempDir(), "repo.json")
+	g.Expect(os.WriteFile(file, []byte(`{"repositories": [{"name": "test", "arch": "x86_64"}]}`), 0666)).To(Succeed())
+	g.Expect(AddPreferences(file, map[string]string{"curl": "curl"})).To(Succeed())
+	repos, err = LoadRepoFile(file)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repos.Preferences).To(Equal(map[string]string{"curl": "curl"}))
+}
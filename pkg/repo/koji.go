@@ -0,0 +1,554 @@
+package repo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/progress"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultKojiHub is the Koji hub which is used if a Koji repository doesn't configure one
+	DefaultKojiHub = "https://koji.fedoraproject.org/kojihub"
+	// DefaultKojiTopURL is the Koji package storage which is used if a Koji repository doesn't configure one
+	DefaultKojiTopURL = "https://kojipkgs.fedoraproject.org"
+	// KojiBuildPrefix marks package sources which reference Koji builds, like `koji:bash-5.2.26-1.fc40`
+	KojiBuildPrefix = "koji:"
+
+	// kojiBuildComplete is the state of successfully finished Koji builds
+	kojiBuildComplete = 1
+)
+
+// kojiDependencyTypes maps the dependency types of the Koji API to the dependency lists of a package
+var kojiDependencyTypes = map[int64]func(pkg *api.Package) *api.Dependencies{
+	0: func(pkg *api.Package) *api.Dependencies { return &pkg.Format.Requires },
+	1: func(pkg *api.Package) *api.Dependencies { return &pkg.Format.Provides },
+	2: func(pkg *api.Package) *api.Dependencies { return &pkg.Format.Obsoletes },
+	3: func(pkg *api.Package) *api.Dependencies { return &pkg.Format.Conflicts },
+	4: func(pkg *api.Package) *api.Dependencies { return &pkg.Format.Suggests },
+	5: func(pkg *api.Package) *api.Dependencies { return &pkg.Format.Enhances },
+	6: func(pkg *api.Package) *api.Dependencies { return &pkg.Format.Supplements },
+	7: func(pkg *api.Package) *api.Dependencies { return &pkg.Format.Recommends },
+}
+
+// AddKojiBuilds adds a repository with the RPMs of the given Koji builds for every architecture of the enabled
+// repositories. The repositories are named koji-<arch> and use the Fedora Koji.
+func AddKojiBuilds(repos *bazeldnf.Repositories, builds []string) error {
+	if len(builds) == 0 {
+		return nil
+	}
+	arches := []string{}
+	seen := map[string]bool{}
+	for _, repo := range repos.Repositories {
+		if repo.Disabled {
+			continue
+		}
+		for _, arch := range append([]string{repo.Arch}, repo.Arches...) {
+			if arch == "noarch" || seen[arch] {
+				continue
+			}
+			seen[arch] = true
+			arches = append(arches, arch)
+		}
+	}
+	if len(arches) == 0 {
+		return fmt.Errorf("koji builds need at least one repository to determine the architectures")
+	}
+	for _, arch := range arches {
+		repos.Repositories = append(repos.Repositories, bazeldnf.Repository{
+			Name: "koji-" + arch,
+			Arch: arch,
+			Koji: &bazeldnf.KojiSource{Builds: builds},
+		})
+	}
+	return nil
+}
+
+// kojiHub returns the hub URL of a Koji repository
+func kojiHub(koji *bazeldnf.KojiSource) string {
+	if koji.Hub == "" {
+		return DefaultKojiHub
+	}
+	return koji.Hub
+}
+
+// kojiPackagesURL returns the URL below which the RPMs of a Koji repository are stored
+func kojiPackagesURL(koji *bazeldnf.KojiSource) string {
+	topURL := koji.TopURL
+	if topURL == "" {
+		topURL = DefaultKojiTopURL
+	}
+	return strings.TrimSuffix(topURL, "/") + "/packages/"
+}
+
+// fetchKojiRepository queries the Koji hub for the RPMs of the configured builds and stores a primary.xml
+// describing them in the cache. RPMs whose sha256 sums the hub doesn't know are downloaded once to determine them.
+func (r *RepoFetcherImpl) fetchKojiRepository(repo *bazeldnf.Repository) error {
+	getter, err := r.getter(repo)
+	if err != nil {
+		return err
+	}
+	client := &kojiClient{getter: getter, hub: kojiHub(repo.Koji)}
+	repository := &api.Repository{
+		Xmlns: "http://linux.duke.edu/metadata/common",
+		Rpm:   "http://linux.duke.edu/metadata/rpm",
+	}
+	for _, nvr := range repo.Koji.Builds {
+		nvr = strings.TrimPrefix(nvr, KojiBuildPrefix)
+		log.Infof("Loading Koji build %s for %s", nvr, repo.Arch)
+		pkgs, err := r.kojiPackages(client, getter, repo, nvr)
+		if err != nil {
+			return fmt.Errorf("failed to load koji build %s: %v", nvr, err)
+		}
+		if len(pkgs) == 0 {
+			log.Warnf("Koji build %s has no RPMs for %s", nvr, repo.Arch)
+		}
+		repository.Packages = append(repository.Packages, pkgs...)
+	}
+	repository.PackageCount = strconv.Itoa(len(repository.Packages))
+	return r.writeKojiMetadata(repo, repository)
+}
+
+// kojiPackages returns the binary RPMs of a build which match the architecture of the repository
+func (r *RepoFetcherImpl) kojiPackages(client *kojiClient, getter Getter, repo *bazeldnf.Repository, nvr string) ([]api.Package, error) {
+	result, err := client.call("getBuild", nvr)
+	if err != nil {
+		return nil, err
+	}
+	build, ok := result.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("build not found")
+	}
+	if state := kojiInt(build, "state"); state != kojiBuildComplete {
+		return nil, fmt.Errorf("build is not complete, its state is %d", state)
+	}
+	result, err = client.call("listRPMs", kojiInt(build, "id"))
+	if err != nil {
+		return nil, err
+	}
+	rpms, _ := result.([]interface{})
+	pkgs := []api.Package{}
+	for _, entry := range rpms {
+		rpm, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, arch := kojiString(rpm, "name"), kojiString(rpm, "arch")
+		if arch != repo.Arch && arch != "noarch" {
+			continue
+		}
+		if strings.HasSuffix(name, "-debuginfo") || strings.HasSuffix(name, "-debugsource") {
+			continue
+		}
+		pkg, err := kojiPackage(client, build, rpm)
+		if err != nil {
+			return nil, err
+		}
+		sum := kojiSHA256(client, kojiInt(rpm, "id"))
+		if sum == "" {
+			if sum, err = downloadSHA256(getter, repo.Name, kojiPackagesURL(repo.Koji)+pkg.Location.Href); err != nil {
+				return nil, err
+			}
+		}
+		pkg.Checksum = api.Checksum{Type: "sha256", Pkgid: "YES", Text: sum}
+		pkgs = append(pkgs, *pkg)
+	}
+	return pkgs, nil
+}
+
+// kojiPackage converts an RPM of the Koji API into the package of a primary.xml, including its dependencies and
+// files
+func kojiPackage(client *kojiClient, build map[string]interface{}, rpm map[string]interface{}) (*api.Package, error) {
+	name, arch := kojiString(rpm, "name"), kojiString(rpm, "arch")
+	pkg := &api.Package{
+		Type: "rpm",
+		Name: name,
+		Arch: arch,
+		Version: api.Version{
+			Epoch: kojiString(rpm, "epoch"),
+			Ver:   kojiString(rpm, "version"),
+			Rel:   kojiString(rpm, "release"),
+		},
+	}
+	if pkg.Version.Epoch == "" {
+		pkg.Version.Epoch = "0"
+	}
+	pkg.Size.Package = int(kojiInt(rpm, "size"))
+	pkg.Time.Build = kojiString(rpm, "buildtime")
+	pkg.Format.Sourcerpm = kojiString(build, "nvr") + ".src.rpm"
+	pkg.Location.Href = strings.Join([]string{
+		kojiString(build, "name"),
+		kojiString(build, "version"),
+		kojiString(build, "release"),
+		arch,
+		kojiString(rpm, "nvr") + "." + arch + ".rpm",
+	}, "/")
+
+	rpmID := kojiInt(rpm, "id")
+	result, err := client.call("getRPMDeps", rpmID)
+	if err != nil {
+		return nil, err
+	}
+	deps, _ := result.([]interface{})
+	for _, entry := range deps {
+		dep, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		list, ok := kojiDependencyTypes[kojiInt(dep, "type")]
+		if !ok {
+			continue
+		}
+		depName := kojiString(dep, "name")
+		if strings.HasPrefix(depName, "rpmlib(") {
+			continue
+		}
+		dependencies := list(pkg)
+		dependencies.Entries = append(dependencies.Entries, kojiEntry(depName, kojiString(dep, "version"), kojiInt(dep, "flags")))
+	}
+
+	result, err = client.call("listRPMFiles", rpmID)
+	if err != nil {
+		return nil, err
+	}
+	files, _ := result.([]interface{})
+	for _, entry := range files {
+		if file, ok := entry.(map[string]interface{}); ok {
+			pkg.Format.Files = append(pkg.Format.Files, api.ProvidedFile{Text: kojiString(file, "name")})
+		}
+	}
+	sort.Slice(pkg.Format.Files, func(i, j int) bool {
+		return pkg.Format.Files[i].Text < pkg.Format.Files[j].Text
+	})
+	return pkg, nil
+}
+
+// kojiEntry converts a dependency of the Koji API with an `[epoch:]version[-release]` and RPMSENSE flags into an
+// entry of a primary.xml
+func kojiEntry(name string, version string, flags int64) api.Entry {
+	entry := api.Entry{Name: name}
+	switch flags & (2 | 4 | 8) {
+	case 2:
+		entry.Flags = "LT"
+	case 4:
+		entry.Flags = "GT"
+	case 8:
+		entry.Flags = "EQ"
+	case 2 | 8:
+		entry.Flags = "LE"
+	case 4 | 8:
+		entry.Flags = "GE"
+	}
+	if entry.Flags == "" || version == "" {
+		return entry
+	}
+	entry.Epoch = "0"
+	if i := strings.Index(version, ":"); i >= 0 {
+		entry.Epoch, version = version[:i], version[i+1:]
+	}
+	if i := strings.LastIndex(version, "-"); i >= 0 {
+		version, entry.Rel = version[:i], version[i+1:]
+	}
+	entry.Ver = version
+	return entry
+}
+
+// kojiSHA256 returns the sha256 sum of the unsigned RPM as stored below the packages URL, or an empty string if
+// the hub doesn't know it. Hubs older than Koji 1.29 don't offer getRPMChecksums at all.
+func kojiSHA256(client *kojiClient, rpmID int64) string {
+	result, err := client.call("getRPMChecksums", rpmID)
+	if err != nil {
+		log.Debugf("Koji hub doesn't provide the checksums of RPM %d: %v", rpmID, err)
+		return ""
+	}
+	sigkeys, _ := result.(map[string]interface{})
+	checksums, _ := sigkeys[""].(map[string]interface{})
+	return kojiString(checksums, "sha256")
+}
+
+// downloadSHA256 downloads a file and returns its sha256 sum
+func downloadSHA256(getter Getter, repository string, url string) (string, error) {
+	resp, err := getter.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("Failed to download %s: %v ", url, fmt.Errorf("status : %v", resp.StatusCode))
+	}
+	hash := sha256.New()
+	download := progress.NewDownload(repository, url, resp.ContentLength, resp.Body)
+	_, err = io.Copy(hash, download)
+	download.Finish(err)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %v", url, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// writeKojiMetadata stores the primary.xml of a Koji repository and a repomd.xml referencing it in the cache
+func (r *RepoFetcherImpl) writeKojiMetadata(repo *bazeldnf.Repository, repository *api.Repository) error {
+	primary := &bytes.Buffer{}
+	writer := gzip.NewWriter(primary)
+	if _, err := io.WriteString(writer, xml.Header); err != nil {
+		return err
+	}
+	if err := xml.NewEncoder(writer).Encode(repository); err != nil {
+		return fmt.Errorf("failed to encode primary.xml for %s: %v", repo.Name, err)
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(primary.Bytes())
+
+	data := api.Data{Type: api.PrimaryFileType}
+	data.Checksum.Type = "sha256"
+	data.Checksum.Text = hex.EncodeToString(sum[:])
+	data.Location.Href = "repodata/primary.xml.gz"
+	data.Size = strconv.Itoa(primary.Len())
+	repomd := &api.Repomd{
+		Xmlns:    "http://linux.duke.edu/metadata/repo",
+		Rpm:      "http://linux.duke.edu/metadata/rpm",
+		Revision: strconv.FormatInt(time.Now().Unix(), 10),
+		Data:     []api.Data{data},
+	}
+	repomdXML, err := xml.MarshalIndent(repomd, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode repomd.xml for %s: %v", repo.Name, err)
+	}
+	if err := r.CacheHelper.WriteToRepoDir(repo, primary, "primary.xml.gz", nil); err != nil {
+		return err
+	}
+	return r.CacheHelper.WriteToRepoDir(repo, strings.NewReader(xml.Header+string(repomdXML)), "repomd.xml", nil)
+}
+
+// kojiClient calls the XML-RPC API of a Koji hub
+type kojiClient struct {
+	getter Getter
+	hub    string
+}
+
+// call invokes a method of the hub with string and integer parameters and returns the decoded result. Structs
+// are returned as maps, arrays as slices and integers as int64.
+func (c *kojiClient) call(method string, params ...interface{}) (interface{}, error) {
+	poster, ok := c.getter.(Poster)
+	if !ok {
+		return nil, fmt.Errorf("%T can't call the koji hub", c.getter)
+	}
+	body := &bytes.Buffer{}
+	body.WriteString(xml.Header + "<methodCall><methodName>" + method + "</methodName><params>")
+	for _, param := range params {
+		body.WriteString("<param><value>")
+		switch value := param.(type) {
+		case string:
+			body.WriteString("<string>")
+			if err := xml.EscapeText(body, []byte(value)); err != nil {
+				return nil, err
+			}
+			body.WriteString("</string>")
+		case int64:
+			body.WriteString("<int>" + strconv.FormatInt(value, 10) + "</int>")
+		default:
+			return nil, fmt.Errorf("unsupported parameter type %T", param)
+		}
+		body.WriteString("</value></param>")
+	}
+	body.WriteString("</params></methodCall>")
+
+	resp, err := poster.Post(c.hub, "text/xml", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("koji call %s failed: %v", method, fmt.Errorf("status : %v", resp.StatusCode))
+	}
+	return decodeMethodResponse(method, resp.Body)
+}
+
+// decodeMethodResponse decodes the result of an XML-RPC call or returns the fault it contains as error
+func decodeMethodResponse(method string, reader io.Reader) (interface{}, error) {
+	decoder := xml.NewDecoder(reader)
+	fault := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("koji call %s returned no result", method)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode result of koji call %s: %v", method, err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "fault":
+			fault = true
+		case "value":
+			value, err := decodeValue(decoder)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode result of koji call %s: %v", method, err)
+			}
+			if fault {
+				details, _ := value.(map[string]interface{})
+				return nil, fmt.Errorf("koji call %s failed: %s", method, kojiString(details, "faultString"))
+			}
+			return value, nil
+		}
+	}
+}
+
+// decodeValue decodes the content of an XML-RPC <value> element up to its end
+func decodeValue(decoder *xml.Decoder) (interface{}, error) {
+	text := ""
+	var value interface{}
+	typed := false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.CharData:
+			text += string(t)
+		case xml.StartElement:
+			typed = true
+			if value, err = decodeTypedValue(decoder, t); err != nil {
+				return nil, err
+			}
+		case xml.EndElement:
+			if !typed {
+				// values without a type are strings
+				return text, nil
+			}
+			return value, nil
+		}
+	}
+}
+
+func decodeTypedValue(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	switch start.Name.Local {
+	case "nil":
+		return nil, decoder.Skip()
+	case "struct":
+		members := map[string]interface{}{}
+		name := ""
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch t := token.(type) {
+			case xml.StartElement:
+				switch t.Name.Local {
+				case "name":
+					if name, err = decodeText(decoder); err != nil {
+						return nil, err
+					}
+				case "value":
+					if members[name], err = decodeValue(decoder); err != nil {
+						return nil, err
+					}
+				}
+			case xml.EndElement:
+				if t.Name.Local == "struct" {
+					return members, nil
+				}
+			}
+		}
+	case "array":
+		values := []interface{}{}
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch t := token.(type) {
+			case xml.StartElement:
+				if t.Name.Local == "value" {
+					value, err := decodeValue(decoder)
+					if err != nil {
+						return nil, err
+					}
+					values = append(values, value)
+				}
+			case xml.EndElement:
+				if t.Name.Local == "array" {
+					return values, nil
+				}
+			}
+		}
+	}
+	text, err := decodeText(decoder)
+	if err != nil {
+		return nil, err
+	}
+	switch start.Name.Local {
+	case "int", "i4", "i8":
+		return strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+	case "boolean":
+		return strings.TrimSpace(text) == "1", nil
+	case "double":
+		return strconv.ParseFloat(strings.TrimSpace(text), 64)
+	}
+	return text, nil
+}
+
+// decodeText returns the text of an element without children up to its end
+func decodeText(decoder *xml.Decoder) (string, error) {
+	text := ""
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		switch t := token.(type) {
+		case xml.CharData:
+			text += string(t)
+		case xml.StartElement:
+			return "", fmt.Errorf("unexpected element %s", t.Name.Local)
+		case xml.EndElement:
+			return text, nil
+		}
+	}
+}
+
+// kojiString returns a member of a struct of the Koji API as string, missing and nil members are empty
+func kojiString(members map[string]interface{}, name string) string {
+	switch value := members[name].(type) {
+	case string:
+		return value
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float64:
+		return strconv.FormatInt(int64(value), 10)
+	}
+	return ""
+}
+
+// kojiInt returns a member of a struct of the Koji API as integer, missing and nil members are 0
+func kojiInt(members map[string]interface{}, name string) int64 {
+	switch value := members[name].(type) {
+	case int64:
+		return value
+	case float64:
+		return int64(value)
+	case string:
+		i, _ := strconv.ParseInt(value, 10, 64)
+		return i
+	}
+	return 0
+}
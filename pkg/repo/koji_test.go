@@ -0,0 +1,239 @@
+package repo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+const kojiBuildResponse = `<?xml version='1.0'?>
+<methodResponse><params><param><value><struct>
+<member><name>id</name><value><int>2406500</int></value></member>
+<member><name>name</name><value><string>bash</string></value></member>
+<member><name>version</name><value><string>5.2.26</string></value></member>
+<member><name>release</name><value><string>1.fc40</string></value></member>
+<member><name>epoch</name><value><nil/></value></member>
+<member><name>nvr</name><value><string>bash-5.2.26-1.fc40</string></value></member>
+<member><name>state</name><value><int>1</int></value></member>
+</struct></value></param></params></methodResponse>`
+
+const kojiRPMsResponse = `<?xml version='1.0'?>
+<methodResponse><params><param><value><array><data>
+<value><struct>
+<member><name>id</name><value><int>1</int></value></member>
+<member><name>name</name><value><string>bash</string></value></member>
+<member><name>version</name><value><string>5.2.26</string></value></member>
+<member><name>release</name><value><string>1.fc40</string></value></member>
+<member><name>epoch</name><value><nil/></value></member>
+<member><name>arch</name><value><string>x86_64</string></value></member>
+<member><name>nvr</name><value><string>bash-5.2.26-1.fc40</string></value></member>
+<member><name>size</name><value><int>12</int></value></member>
+</struct></value>
+<value><struct>
+<member><name>id</name><value><int>2</int></value></member>
+<member><name>name</name><value><string>bash</string></value></member>
+<member><name>arch</name><value><string>aarch64</string></value></member>
+</struct></value>
+<value><struct>
+<member><name>id</name><value><int>3</int></value></member>
+<member><name>name</name><value><string>bash-debuginfo</string></value></member>
+<member><name>arch</name><value><string>x86_64</string></value></member>
+</struct></value>
+</data></array></value></param></params></methodResponse>`
+
+const kojiDepsResponse = `<?xml version='1.0'?>
+<methodResponse><params><param><value><array><data>
+<value><struct>
+<member><name>name</name><value><string>bash</string></value></member>
+<member><name>version</name><value><string>5.2.26-1.fc40</string></value></member>
+<member><name>flags</name><value><int>8</int></value></member>
+<member><name>type</name><value><int>1</int></value></member>
+</struct></value>
+<value><struct>
+<member><name>name</name><value><string>libc.so.6()(64bit)</string></value></member>
+<member><name>version</name><value><string></string></value></member>
+<member><name>flags</name><value><int>16384</int></value></member>
+<member><name>type</name><value><int>0</int></value></member>
+</struct></value>
+<value><struct>
+<member><name>name</name><value><string>rpmlib(CompressedFileNames)</string></value></member>
+<member><name>version</name><value><string>3.0.4-1</string></value></member>
+<member><name>flags</name><value><int>16777226</int></value></member>
+<member><name>type</name><value><int>0</int></value></member>
+</struct></value>
+</data></array></value></param></params></methodResponse>`
+
+const kojiFilesResponse = `<?xml version='1.0'?>
+<methodResponse><params><param><value><array><data>
+<value><struct><member><name>name</name><value><string>/usr/bin/sh</string></value></member></struct></value>
+<value><struct><member><name>name</name><value><string>/usr/bin/bash</string></value></member></struct></value>
+</data></array></value></param></params></methodResponse>`
+
+// newKojiServer serves a Koji hub with a single bash build. If sum is set, the hub knows the sha256 sum of the RPM.
+func newKojiServer(t *testing.T, rpm []byte, sum string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			if r.URL.Path != "/packages/bash/5.2.26/1.fc40/x86_64/bash-5.2.26-1.fc40.x86_64.rpm" {
+				rw.WriteHeader(http.StatusNotFound)
+				return
+			}
+			rw.Write(rpm)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case strings.Contains(string(body), "<methodName>getBuild</methodName>"):
+			if !strings.Contains(string(body), "<string>bash-5.2.26-1.fc40</string>") {
+				fmt.Fprint(rw, `<methodResponse><params><param><value><nil/></value></param></params></methodResponse>`)
+				return
+			}
+			fmt.Fprint(rw, kojiBuildResponse)
+		case strings.Contains(string(body), "<methodName>listRPMs</methodName>"):
+			fmt.Fprint(rw, kojiRPMsResponse)
+		case strings.Contains(string(body), "<methodName>getRPMDeps</methodName>"):
+			fmt.Fprint(rw, kojiDepsResponse)
+		case strings.Contains(string(body), "<methodName>listRPMFiles</methodName>"):
+			fmt.Fprint(rw, kojiFilesResponse)
+		case strings.Contains(string(body), "<methodName>getRPMChecksums</methodName>") && sum != "":
+			fmt.Fprintf(rw, `<methodResponse><params><param><value><struct>
+<member><name></name><value><struct><member><name>sha256</name><value><string>%s</string></value></member></struct></value></member>
+</struct></value></param></params></methodResponse>`, sum)
+		default:
+			fmt.Fprint(rw, `<methodResponse><fault><value><struct>
+<member><name>faultCode</name><value><int>1000</int></value></member>
+<member><name>faultString</name><value><string>Invalid method</string></value></member>
+</struct></value></fault></methodResponse>`)
+		}
+	}))
+}
+
+func TestFetchKojiBuild(t *testing.T) {
+	g := NewGomegaWithT(t)
+	rpm := []byte("bash payload")
+	s := newKojiServer(t, rpm, "")
+	defer s.Close()
+	repo := bazeldnf.Repository{
+		Name: "koji-x86_64",
+		Arch: "x86_64",
+		Koji: &bazeldnf.KojiSource{Hub: s.URL + "/kojihub", TopURL: s.URL, Builds: []string{"koji:bash-5.2.26-1.fc40"}},
+	}
+	cacheDir := t.TempDir()
+	g.Expect(NewRemoteRepoFetcher([]bazeldnf.Repository{repo}, cacheDir).Fetch()).To(Succeed())
+
+	primary, err := (&CacheHelper{CacheDir: cacheDir}).CurrentPrimary(&repo)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(primary.Packages).To(HaveLen(1))
+	pkg := primary.Packages[0]
+	sum := sha256.Sum256(rpm)
+	g.Expect(pkg.String()).To(Equal("bash-0:5.2.26-1.fc40"))
+	g.Expect(pkg.Checksum.Text).To(Equal(hex.EncodeToString(sum[:])))
+	g.Expect(pkg.Format.Sourcerpm).To(Equal("bash-5.2.26-1.fc40.src.rpm"))
+	g.Expect(pkg.Format.Provides.Entries).To(Equal([]api.Entry{{Name: "bash", Flags: "EQ", Epoch: "0", Ver: "5.2.26", Rel: "1.fc40"}}))
+	g.Expect(pkg.Format.Requires.Entries).To(Equal([]api.Entry{{Name: "libc.so.6()(64bit)"}}))
+	g.Expect(pkg.Format.Files).To(Equal([]api.ProvidedFile{{Text: "/usr/bin/bash"}, {Text: "/usr/bin/sh"}}))
+	g.Expect(pkg.Repository.Mirrors).To(Equal([]string{s.URL + "/packages/"}))
+	g.Expect(pkg.Location.Href).To(Equal("bash/5.2.26/1.fc40/x86_64/bash-5.2.26-1.fc40.x86_64.rpm"))
+}
+
+func TestFetchKojiBuildChecksumsFromHub(t *testing.T) {
+	g := NewGomegaWithT(t)
+	// the RPM can't be downloaded, so its checksum has to come from the hub
+	s := newKojiServer(t, nil, "aaaa")
+	defer s.Close()
+	repo := bazeldnf.Repository{
+		Name: "koji-x86_64",
+		Arch: "x86_64",
+		Koji: &bazeldnf.KojiSource{Hub: s.URL + "/kojihub", TopURL: s.URL + "/unreachable", Builds: []string{"bash-5.2.26-1.fc40"}},
+	}
+	cacheDir := t.TempDir()
+	g.Expect(NewRemoteRepoFetcher([]bazeldnf.Repository{repo}, cacheDir).Fetch()).To(Succeed())
+	primary, err := (&CacheHelper{CacheDir: cacheDir}).CurrentPrimary(&repo)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(primary.Packages).To(HaveLen(1))
+	g.Expect(primary.Packages[0].Checksum.Text).To(Equal("aaaa"))
+}
+
+func TestRecordAndReplayKojiBuild(t *testing.T) {
+	g := NewGomegaWithT(t)
+	s := newKojiServer(t, []byte("bash payload"), "")
+	repo := bazeldnf.Repository{
+		Name: "koji-x86_64",
+		Arch: "x86_64",
+		Koji: &bazeldnf.KojiSource{Hub: s.URL + "/kojihub", TopURL: s.URL, Builds: []string{"bash-5.2.26-1.fc40"}},
+	}
+	fixtures := t.TempDir()
+	recorder := &RepoFetcherImpl{
+		Repos:       []bazeldnf.Repository{repo},
+		Getter:      &RecordingGetter{Getter: NewGetter(), Dir: fixtures},
+		CacheHelper: &CacheHelper{CacheDir: t.TempDir()},
+	}
+	g.Expect(recorder.Fetch()).To(Succeed())
+	s.Close()
+
+	replayed := &RepoFetcherImpl{
+		Repos:       []bazeldnf.Repository{repo},
+		Getter:      &ReplayGetter{Dir: fixtures},
+		CacheHelper: &CacheHelper{CacheDir: t.TempDir()},
+	}
+	g.Expect(replayed.Fetch()).To(Succeed())
+	primary, err := replayed.CacheHelper.CurrentPrimary(&repo)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(primary.Packages).To(HaveLen(1))
+	g.Expect(primary.Packages[0].Format.Files).To(HaveLen(2))
+}
+
+func TestFetchUnknownKojiBuild(t *testing.T) {
+	g := NewGomegaWithT(t)
+	s := newKojiServer(t, nil, "")
+	defer s.Close()
+	repo := bazeldnf.Repository{
+		Name: "koji-x86_64",
+		Arch: "x86_64",
+		Koji: &bazeldnf.KojiSource{Hub: s.URL + "/kojihub", TopURL: s.URL, Builds: []string{"bash-0.0.1-1.fc40"}},
+	}
+	err := NewRemoteRepoFetcher([]bazeldnf.Repository{repo}, t.TempDir()).Fetch()
+	g.Expect(err).To(MatchError(ContainSubstring("koji build bash-0.0.1-1.fc40: build not found")))
+}
+
+func TestKojiCallFault(t *testing.T) {
+	g := NewGomegaWithT(t)
+	s := newKojiServer(t, nil, "")
+	defer s.Close()
+	_, err := (&kojiClient{getter: NewGetter(), hub: s.URL + "/kojihub"}).call("unknownMethod", int64(1))
+	g.Expect(err).To(MatchError("koji call unknownMethod failed: Invalid method"))
+}
+
+func TestKojiEntry(t *testing.T) {
+	g := NewGomegaWithT(t)
+	g.Expect(kojiEntry("glibc", "2:2.39-5.fc40", 4|8)).To(Equal(api.Entry{Name: "glibc", Flags: "GE", Epoch: "2", Ver: "2.39", Rel: "5.fc40"}))
+	g.Expect(kojiEntry("glibc", "2.39", 2)).To(Equal(api.Entry{Name: "glibc", Flags: "LT", Epoch: "0", Ver: "2.39"}))
+	g.Expect(kojiEntry("/bin/sh", "", 0)).To(Equal(api.Entry{Name: "/bin/sh"}))
+}
+
+func TestAddKojiBuilds(t *testing.T) {
+	g := NewGomegaWithT(t)
+	repos := &bazeldnf.Repositories{Repositories: []bazeldnf.Repository{
+		{Name: "fedora", Arch: "x86_64"},
+		{Name: "updates", Arch: "x86_64"},
+		{Name: "fedora-arm", Arch: "aarch64"},
+		{Name: "disabled", Arch: "s390x", Disabled: true},
+	}}
+	g.Expect(AddKojiBuilds(repos, []string{"koji:bash-5.2.26-1.fc40"})).To(Succeed())
+	g.Expect(repos.Repositories[4:]).To(Equal([]bazeldnf.Repository{
+		{Name: "koji-x86_64", Arch: "x86_64", Koji: &bazeldnf.KojiSource{Builds: []string{"koji:bash-5.2.26-1.fc40"}}},
+		{Name: "koji-aarch64", Arch: "aarch64", Koji: &bazeldnf.KojiSource{Builds: []string{"koji:bash-5.2.26-1.fc40"}}},
+	}))
+	g.Expect(AddKojiBuilds(&bazeldnf.Repositories{}, []string{"bash-5.2.26-1.fc40"})).To(MatchError(ContainSubstring("at least one repository")))
+}
@@ -0,0 +1,62 @@
+package repo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	log "github.com/sirupsen/logrus"
+)
+
+// LockRepoDir takes an exclusive advisory lock on the cache directory of the repository, waiting for other
+// bazeldnf processes which hold it. The returned function releases the lock.
+func (r *CacheHelper) LockRepoDir(repo *bazeldnf.Repository) (unlock func() error, err error) {
+	dir := filepath.Join(r.CacheDir, repo.Name)
+	if err := os.MkdirAll(dir, 0770); err != nil && !os.IsExist(err) {
+		return nil, fmt.Errorf("failed to create cache directory for %s: %v", repo.Name, err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, ".lock"), os.O_CREATE|os.O_RDWR, 0660)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file for %s: %v", repo.Name, err)
+	}
+	locked, err := tryLockFile(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock cache directory for %s: %v", repo.Name, err)
+	}
+	if !locked {
+		log.Infof("Waiting for another process to finish updating the cache of %s", repo.Name)
+		if err := lockFile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock cache directory for %s: %v", repo.Name, err)
+		}
+	}
+	return func() error {
+		defer f.Close()
+		return unlockFile(f)
+	}, nil
+}
+
+// fetchedMarker is written into the cache directory of a repository after all of its metadata files are in place
+const fetchedMarker = ".fetched"
+
+// markFetched records that the metadata of the repository was completely fetched
+func (r *CacheHelper) markFetched(repo *bazeldnf.Repository) error {
+	if err := os.WriteFile(filepath.Join(r.CacheDir, repo.Name, fetchedMarker), nil, 0660); err != nil {
+		return fmt.Errorf("failed to mark the cache of %s as complete: %v", repo.Name, err)
+	}
+	return nil
+}
+
+// updatedSince returns true if the metadata of the repository was completely fetched after the given time, which
+// means that another process refreshed the cache while we were waiting for the lock. repomd.xml alone says nothing,
+// since it is written before the files it references.
+func (r *CacheHelper) updatedSince(repo *bazeldnf.Repository, since time.Time) bool {
+	stat, err := os.Stat(filepath.Join(r.CacheDir, repo.Name, fetchedMarker))
+	if err != nil {
+		return false
+	}
+	return stat.ModTime().After(since)
+}
@@ -0,0 +1,19 @@
+//go:build !linux && !darwin && !freebsd
+
+package repo
+
+import "os"
+
+// advisory locking is not supported on this platform, concurrent invocations are not synchronized
+
+func tryLockFile(f *os.File) (bool, error) {
+	return true, nil
+}
+
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,56 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func TestLockRepoDir(t *testing.T) {
+	g := NewGomegaWithT(t)
+	helper := &CacheHelper{CacheDir: t.TempDir()}
+	repo := &bazeldnf.Repository{Name: "test"}
+
+	unlock, err := helper.LockRepoDir(repo)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	f, err := os.Open(filepath.Join(helper.CacheDir, "test", ".lock"))
+	g.Expect(err).ToNot(HaveOccurred())
+	defer f.Close()
+	if locked, _ := tryLockFile(f); locked {
+		t.Skip("advisory file locking is not supported on this platform")
+	}
+
+	acquired := make(chan error, 1)
+	go func() {
+		unlock, err := helper.LockRepoDir(repo)
+		if err == nil {
+			err = unlock()
+		}
+		acquired <- err
+	}()
+	g.Consistently(acquired, 100*time.Millisecond).ShouldNot(Receive())
+	g.Expect(unlock()).To(Succeed())
+	var lockErr error
+	g.Eventually(acquired).Should(Receive(&lockErr))
+	g.Expect(lockErr).ToNot(HaveOccurred())
+}
+
+func TestUpdatedSince(t *testing.T) {
+	g := NewGomegaWithT(t)
+	helper := &CacheHelper{CacheDir: t.TempDir()}
+	repo := &bazeldnf.Repository{Name: "test"}
+
+	started := time.Now().Add(-time.Minute)
+	g.Expect(helper.updatedSince(repo, started)).To(BeFalse())
+	g.Expect(os.MkdirAll(filepath.Join(helper.CacheDir, "test"), 0770)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(helper.CacheDir, "test", "repomd.xml"), []byte("<repomd/>"), 0660)).To(Succeed())
+	g.Expect(helper.updatedSince(repo, started)).To(BeFalse())
+	g.Expect(helper.markFetched(repo)).To(Succeed())
+	g.Expect(helper.updatedSince(repo, started)).To(BeTrue())
+	g.Expect(helper.updatedSince(repo, time.Now().Add(time.Minute))).To(BeFalse())
+}
@@ -0,0 +1,136 @@
+package repo
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/progress"
+	log "github.com/sirupsen/logrus"
+)
+
+// DownloadLocked downloads the RPMs of the locked packages into the directory and verifies them against their
+// locked checksums. No repository metadata is involved, so the result only depends on the lockfile. RPMs which
+// are already present with the right checksum are not downloaded again. Before downloading anything, the free
+// disk space is checked against the sizes of the missing RPMs. If verify is given, it additionally checks every
+// RPM, e.g. its signature, before it is accepted. It returns the paths of all RPMs.
+func DownloadLocked(getter Getter, pkgs []bazeldnf.LockedPackage, dir string, verify func(pkg bazeldnf.LockedPackage, file string) error) ([]string, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %v", dir, err)
+	}
+	files := []string{}
+	missing := []int{}
+	var required uint64
+	for i, pkg := range pkgs {
+		if len(pkg.URLs) == 0 {
+			return nil, fmt.Errorf("locked package %s has no urls", pkg.ID())
+		}
+		u, err := url.Parse(pkg.URLs[0])
+		if err != nil {
+			return nil, fmt.Errorf("locked package %s has an invalid url: %v", pkg.ID(), err)
+		}
+		file := filepath.Join(dir, path.Base(u.Path))
+		files = append(files, file)
+		if err := verifyLocked(pkg, file); err == nil {
+			log.Debugf("%s is already downloaded", pkg.ID())
+			if verify != nil {
+				if err := verify(pkg, file); err != nil {
+					return nil, fmt.Errorf("failed to verify %s: %v", file, err)
+				}
+			}
+			continue
+		}
+		missing = append(missing, i)
+		if pkg.Size > 0 {
+			required += uint64(pkg.Size)
+		}
+	}
+	if err := CheckDiskSpace(dir, required); err != nil {
+		return nil, err
+	}
+	for _, i := range missing {
+		pkg := pkgs[i]
+		var err error
+		for j, rpmURL := range pkg.URLs {
+			// the other mirrors can serve chunks of the RPM too
+			mirrors := append(append([]string{}, pkg.URLs[j:]...), pkg.URLs[:j]...)
+			if err = downloadLocked(getter, pkg, mirrors, files[i], verify); err == nil {
+				break
+			}
+			log.Warningf("Failed to download %s from %s: %v", pkg.ID(), rpmURL, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to download %s: %v", pkg.ID(), err)
+		}
+	}
+	return files, nil
+}
+
+func downloadLocked(getter Getter, pkg bazeldnf.LockedPackage, mirrors []string, file string, verify func(pkg bazeldnf.LockedPackage, file string) error) error {
+	rpmURL := mirrors[0]
+	log.Infof("Downloading %s", rpmURL)
+	resp, err := getFromMirrors(getter, mirrors)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status : %v", resp.StatusCode)
+	}
+	f, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to open temporary file for %s: %v", file, err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	download := progress.NewDownload(pkg.Repository, rpmURL, resp.ContentLength, resp.Body)
+	_, err = io.Copy(f, download)
+	download.Finish(err)
+	if err != nil {
+		return fmt.Errorf("failed to write file %s: %v", file, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write file %s: %v", file, err)
+	}
+	if err := verifyLocked(pkg, f.Name()); err != nil {
+		return err
+	}
+	if verify != nil {
+		if err := verify(pkg, f.Name()); err != nil {
+			return err
+		}
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to set permissions of %s: %v", file, err)
+	}
+	return os.Rename(f.Name(), file)
+}
+
+// verifyLocked checks the file against the checksum of the locked package
+func verifyLocked(pkg bazeldnf.LockedPackage, file string) error {
+	checksumType, checksum, found := strings.Cut(pkg.Checksum, ":")
+	if !found {
+		return fmt.Errorf("locked package %s has an invalid checksum %s", pkg.ID(), pkg.Checksum)
+	}
+	hasher, err := NewChecksumHash(checksumType)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return fmt.Errorf("failed to read %s: %v", file, err)
+	}
+	if toHex(hasher) != checksum {
+		return fmt.Errorf("expected %s sum %s, but got %s", checksumType, checksum, toHex(hasher))
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package repo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func TestDownloadLocked(t *testing.T) {
+	g := NewGomegaWithT(t)
+	content := []byte("not really an rpm")
+	sum := sha256.Sum256(content)
+	requests := 0
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/good/bash-5.0-1.fc32.x86_64.rpm" {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rw.Write(content)
+	}))
+	defer s.Close()
+
+	pkg := bazeldnf.LockedPackage{
+		Name:     "bash",
+		Version:  "5.0",
+		Release:  "1.fc32",
+		Arch:     "x86_64",
+		Checksum: "sha256:" + hex.EncodeToString(sum[:]),
+		URLs:     []string{s.URL + "/bad/bash-5.0-1.fc32.x86_64.rpm", s.URL + "/good/bash-5.0-1.fc32.x86_64.rpm"},
+	}
+	dir := t.TempDir()
+	files, err := DownloadLocked(&getterImpl{}, []bazeldnf.LockedPackage{pkg}, dir, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(files).To(Equal([]string{filepath.Join(dir, "bash-5.0-1.fc32.x86_64.rpm")}))
+	g.Expect(os.ReadFile(files[0])).To(Equal(content))
+	g.Expect(requests).To(Equal(2))
+
+	_, err = DownloadLocked(&getterImpl{}, []bazeldnf.LockedPackage{pkg}, dir, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(requests).To(Equal(2))
+
+	rejected := t.TempDir()
+	_, err = DownloadLocked(&getterImpl{}, []bazeldnf.LockedPackage{pkg}, rejected, func(pkg bazeldnf.LockedPackage, file string) error {
+		return fmt.Errorf("the RPM %s is not signed", pkg.ID())
+	})
+	g.Expect(err).To(MatchError(ContainSubstring("is not signed")))
+	g.Expect(os.ReadDir(rejected)).To(BeEmpty())
+
+	pkg.Checksum = "sha256:0000"
+	_, err = DownloadLocked(&getterImpl{}, []bazeldnf.LockedPackage{pkg}, t.TempDir(), nil)
+	g.Expect(err).To(MatchError(ContainSubstring("expected sha256 sum 0000")))
+
+	pkg.Size = 1000 * 1000 * 1000 * 1000 * 1000
+	requests = 0
+	_, err = DownloadLocked(&getterImpl{}, []bazeldnf.LockedPackage{pkg}, t.TempDir(), nil)
+	if _, spaceErr := availableSpace(dir); spaceErr != errDiskSpaceUnsupported {
+		g.Expect(err).To(MatchError(ContainSubstring("not enough disk space")))
+		g.Expect(requests).To(BeZero())
+	}
+}
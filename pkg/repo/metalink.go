@@ -0,0 +1,69 @@
+package repo
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+// FilterMirrors returns the mirror URLs of a metalink file which match the filter. Mirrors matching one of the
+// preferred patterns are moved to the front, otherwise the order of the metalink file is kept.
+func FilterMirrors(urls []api.URL, filter *bazeldnf.MetalinkFilter) ([]string, error) {
+	if filter == nil {
+		filter = &bazeldnf.MetalinkFilter{}
+	}
+	protocols := filter.Protocols
+	if len(protocols) == 0 {
+		protocols = []string{"https"}
+	}
+	preferred := []*regexp.Regexp{}
+	for _, pattern := range filter.Prefer {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid preferred mirror pattern %q: %v", pattern, err)
+		}
+		preferred = append(preferred, re)
+	}
+
+	mirrors := []string{}
+	for _, u := range urls {
+		if !containsFold(protocols, u.Protocol) {
+			continue
+		}
+		if len(filter.Countries) > 0 && !containsFold(filter.Countries, u.Location) {
+			continue
+		}
+		mirrors = append(mirrors, u.Text)
+	}
+
+	sort.SliceStable(mirrors, func(i, j int) bool {
+		return matchesAny(preferred, mirrors[i]) && !matchesAny(preferred, mirrors[j])
+	})
+
+	if filter.MaxMirrors > 0 && len(mirrors) > filter.MaxMirrors {
+		mirrors = mirrors[:filter.MaxMirrors]
+	}
+	return mirrors, nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(patterns []*regexp.Regexp, value string) bool {
+	for _, re := range patterns {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
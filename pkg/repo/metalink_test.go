@@ -0,0 +1,121 @@
+package repo
+
+import (
+	"encoding/xml"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func TestFilterMirrors(t *testing.T) {
+	urls := []api.URL{
+		{Text: "https://a.example.com/repomd.xml", Protocol: "https", Location: "US"},
+		{Text: "http://b.example.com/repomd.xml", Protocol: "http", Location: "DE"},
+		{Text: "https://c.example.com/repomd.xml", Protocol: "https", Location: "DE"},
+		{Text: "https://mirror.uni.example.edu/repomd.xml", Protocol: "https", Location: "DE"},
+		{Text: "rsync://d.example.com/repomd.xml", Protocol: "rsync", Location: "DE"},
+	}
+	tests := []struct {
+		name    string
+		filter  *bazeldnf.MetalinkFilter
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "defaults to https in file order",
+			filter: nil,
+			want: []string{
+				"https://a.example.com/repomd.xml",
+				"https://c.example.com/repomd.xml",
+				"https://mirror.uni.example.edu/repomd.xml",
+			},
+		},
+		{
+			name:   "filters by country and protocol",
+			filter: &bazeldnf.MetalinkFilter{Countries: []string{"de"}, Protocols: []string{"https", "http"}},
+			want: []string{
+				"http://b.example.com/repomd.xml",
+				"https://c.example.com/repomd.xml",
+				"https://mirror.uni.example.edu/repomd.xml",
+			},
+		},
+		{
+			name:   "prefers matching mirrors and limits the count",
+			filter: &bazeldnf.MetalinkFilter{Prefer: []string{`\.edu/`}, MaxMirrors: 2},
+			want: []string{
+				"https://mirror.uni.example.edu/repomd.xml",
+				"https://a.example.com/repomd.xml",
+			},
+		},
+		{
+			name:    "rejects invalid patterns",
+			filter:  &bazeldnf.MetalinkFilter{Prefer: []string{"("}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			got, err := FilterMirrors(urls, tt.filter)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+const metalink3 = `<?xml version="1.0" encoding="utf-8"?>
+<metalink version="3.0" xmlns="http://www.metalinker.org/" type="dynamic">
+ <files>
+  <file name="repomd.xml">
+   <size>5970</size>
+   <verification>
+    <hash type="sha256">3e5c6d0b2a6b7f0b6e8c1d1c1f2f1c1d1e1f1a1b1c1d1e1f1a1b1c1d1e1f1a1b</hash>
+   </verification>
+   <resources maxconnections="1">
+    <url protocol="https" type="https" location="DE" preference="100">https://a.example.com/repodata/repomd.xml</url>
+    <url protocol="http" type="http" location="US" preference="99">http://b.example.com/repodata/repomd.xml</url>
+   </resources>
+  </file>
+ </files>
+</metalink>
+`
+
+const metalink4 = `<?xml version="1.0" encoding="UTF-8"?>
+<metalink xmlns="urn:ietf:params:xml:ns:metalink">
+  <published>2024-11-01T00:00:00Z</published>
+  <file name="repomd.xml">
+    <size>5970</size>
+    <hash type="sha-256">3e5c6d0b2a6b7f0b6e8c1d1c1f2f1c1d1e1f1a1b1c1d1e1f1a1b1c1d1e1f1a1b</hash>
+    <url location="us" priority="2">http://b.example.com/repodata/repomd.xml</url>
+    <url location="de" priority="1">
+      https://a.example.com/repodata/repomd.xml
+    </url>
+  </file>
+</metalink>
+`
+
+func TestMetalinkFormats(t *testing.T) {
+	for name, document := range map[string]string{"metalink3": metalink3, "metalink4": metalink4} {
+		t.Run(name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			metalink := &api.Metalink{}
+			g.Expect(xml.Unmarshal([]byte(document), metalink)).To(Succeed())
+			repomd := metalink.Repomod()
+			g.Expect(repomd).ToNot(BeNil())
+			g.Expect(repomd.SHA256()).To(Equal([]string{"3e5c6d0b2a6b7f0b6e8c1d1c1f2f1c1d1e1f1a1b1c1d1e1f1a1b1c1d1e1f1a1b"}))
+			g.Expect(FilterMirrors(repomd.Resources.URLs, &bazeldnf.MetalinkFilter{Protocols: []string{"https", "http"}})).To(Equal([]string{
+				"https://a.example.com/repodata/repomd.xml",
+				"http://b.example.com/repodata/repomd.xml",
+			}))
+			g.Expect(FilterMirrors(repomd.Resources.URLs, &bazeldnf.MetalinkFilter{Countries: []string{"us"}, Protocols: []string{"http"}})).To(Equal([]string{
+				"http://b.example.com/repodata/repomd.xml",
+			}))
+		})
+	}
+}
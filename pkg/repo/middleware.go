@@ -0,0 +1,156 @@
+package repo
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Middleware wraps the http.RoundTripper which sends the requests of a Getter. Middlewares can inject logging,
+// metrics, authentication or caching into all requests without replacing the whole Getter.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to a http.RoundTripper
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Chain wraps the transport with the middlewares. The first middleware is the outermost one, it sees the
+// requests first and the responses last.
+func Chain(transport http.RoundTripper, middlewares ...Middleware) http.RoundTripper {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		transport = middlewares[i](transport)
+	}
+	return transport
+}
+
+// RetryMiddleware retries GET and HEAD requests which failed with a network error or a server error up to the
+// given number of attempts in total, doubling the backoff after every attempt. Throttling responses are not
+// retried, the Getter backs off from the throttling mirror instead.
+func RetryMiddleware(attempts int, backoff time.Duration) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method != http.MethodGet && req.Method != http.MethodHead {
+				return next.RoundTrip(req)
+			}
+			wait := backoff
+			for attempt := 1; ; attempt++ {
+				resp, err := next.RoundTrip(req)
+				if attempt >= attempts || req.Context().Err() != nil || !retryable(resp, err) {
+					return resp, err
+				}
+				if err != nil {
+					log.Warningf("Request to %s failed, retrying in %v: %v", req.URL, wait, err)
+				} else {
+					log.Warningf("Request to %s failed with status %v, retrying in %v", req.URL, resp.StatusCode, wait)
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+				select {
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				case <-time.After(wait):
+				}
+				wait *= 2
+			}
+		})
+	}
+}
+
+// retryable returns true if a request which ended like this can succeed when it is sent again
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= 500 && !isThrottled(resp)
+}
+
+// ChecksumMiddleware verifies response bodies against the digests which servers announce in the Repr-Digest
+// (RFC 9530), Digest (RFC 3230) or X-Checksum-Sha256 headers. Reading a body which does not match the digest
+// fails instead of returning io.EOF, so that corrupted downloads are never mistaken for complete ones.
+func ChecksumMiddleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			// the digests describe the whole representation as it was sent, which partial and transparently
+			// decompressed bodies are not
+			if err != nil || resp.StatusCode != http.StatusOK || resp.Uncompressed || req.Method == http.MethodHead {
+				return resp, err
+			}
+			algorithm, expected, found := announcedDigest(resp.Header)
+			if !found {
+				return resp, nil
+			}
+			hasher, err := NewChecksumHash(algorithm)
+			if err != nil {
+				return resp, nil
+			}
+			resp.Body = &verifyingBody{ReadCloser: resp.Body, url: req.URL.String(), algorithm: algorithm, hash: hasher, expected: expected}
+			return resp, nil
+		})
+	}
+}
+
+// announcedDigest returns the first digest of the response headers with an algorithm which can be verified
+func announcedDigest(header http.Header) (algorithm string, sum []byte, found bool) {
+	for _, field := range strings.Split(header.Get("Repr-Digest"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		if algorithm, sum, found = parseDigest(name, strings.Trim(value, ":")); found {
+			return algorithm, sum, true
+		}
+	}
+	for _, field := range strings.Split(header.Get("Digest"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		if algorithm, sum, found = parseDigest(name, value); found {
+			return algorithm, sum, true
+		}
+	}
+	if value := header.Get("X-Checksum-Sha256"); value != "" {
+		if sum, err := hex.DecodeString(strings.TrimSpace(value)); err == nil {
+			return "sha256", sum, true
+		}
+	}
+	return "", nil, false
+}
+
+// parseDigest parses a base64 encoded digest with an algorithm name like `sha-256`
+func parseDigest(name string, value string) (algorithm string, sum []byte, found bool) {
+	algorithm = strings.ReplaceAll(strings.ToLower(name), "-", "")
+	if _, err := NewChecksumHash(algorithm); err != nil {
+		return "", nil, false
+	}
+	sum, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", nil, false
+	}
+	return algorithm, sum, true
+}
+
+// verifyingBody hashes a response body while it is read and fails at its end if it does not match the expected
+// digest
+type verifyingBody struct {
+	io.ReadCloser
+	url       string
+	algorithm string
+	hash      hash.Hash
+	expected  []byte
+}
+
+func (b *verifyingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.hash.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(b.hash.Sum(nil), b.expected) {
+		return n, fmt.Errorf("%s sum of %s is %s, but the server announced %s", b.algorithm, b.url, hex.EncodeToString(b.hash.Sum(nil)), hex.EncodeToString(b.expected))
+	}
+	return n, err
+}
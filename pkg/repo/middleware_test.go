@@ -0,0 +1,120 @@
+package repo
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestChain(t *testing.T) {
+	g := NewGomegaWithT(t)
+	order := []string{}
+	tracing := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("ok"))
+	}))
+	defer s.Close()
+
+	getter := NewGetter(tracing("outer"), tracing("inner"))
+	resp, err := getter.Get(s.URL)
+	g.Expect(err).ToNot(HaveOccurred())
+	resp.Body.Close()
+	g.Expect(order).To(Equal([]string{"outer", "inner"}))
+
+	proxied, err := getter.(ProxyGetter).WithProxy(NoProxy)
+	g.Expect(err).ToNot(HaveOccurred())
+	resp, err = proxied.Get(s.URL)
+	g.Expect(err).ToNot(HaveOccurred())
+	resp.Body.Close()
+	g.Expect(order).To(Equal([]string{"outer", "inner", "outer", "inner"}))
+}
+
+func TestRetryMiddleware(t *testing.T) {
+	g := NewGomegaWithT(t)
+	requests := map[string]int{}
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		requests[r.Method+" "+r.URL.Path]++
+		switch {
+		case r.URL.Path == "/flaky" && requests[r.Method+" "+r.URL.Path] < 3:
+			rw.WriteHeader(http.StatusBadGateway)
+		case r.URL.Path == "/throttled":
+			rw.WriteHeader(http.StatusTooManyRequests)
+		case r.URL.Path == "/broken":
+			rw.WriteHeader(http.StatusInternalServerError)
+		default:
+			rw.Write([]byte("ok"))
+		}
+	}))
+	defer s.Close()
+	client := &http.Client{Transport: Chain(http.DefaultTransport, RetryMiddleware(3, 0))}
+
+	resp, err := client.Get(s.URL + "/flaky")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	resp.Body.Close()
+	g.Expect(requests["GET /flaky"]).To(Equal(3))
+
+	resp, err = client.Get(s.URL + "/broken")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+	resp.Body.Close()
+	g.Expect(requests["GET /broken"]).To(Equal(3))
+
+	resp, err = client.Get(s.URL + "/throttled")
+	g.Expect(err).ToNot(HaveOccurred())
+	resp.Body.Close()
+	g.Expect(requests["GET /throttled"]).To(Equal(1))
+
+	resp, err = client.Post(s.URL+"/broken", "text/plain", strings.NewReader("body"))
+	g.Expect(err).ToNot(HaveOccurred())
+	resp.Body.Close()
+	g.Expect(requests["POST /broken"]).To(Equal(1))
+}
+
+func TestChecksumMiddleware(t *testing.T) {
+	g := NewGomegaWithT(t)
+	content := []byte("not really an rpm")
+	sum := sha256.Sum256(content)
+	other := sha256.Sum256([]byte("something else"))
+	headers := map[string][2]string{
+		"/repr-digest": {"Repr-Digest", "md5=:AAAA:, sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"},
+		"/digest":      {"Digest", "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])},
+		"/artifactory": {"X-Checksum-Sha256", hex.EncodeToString(sum[:])},
+		"/corrupt":     {"Digest", "SHA-256=" + base64.StdEncoding.EncodeToString(other[:])},
+		"/none":        {"X-Unrelated", "value"},
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		header := headers[r.URL.Path]
+		rw.Header().Set(header[0], header[1])
+		rw.Write(content)
+	}))
+	defer s.Close()
+	getter := NewGetter(ChecksumMiddleware())
+
+	for _, path := range []string{"/repr-digest", "/digest", "/artifactory", "/none"} {
+		resp, err := getter.Get(s.URL + path)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(io.ReadAll(resp.Body)).To(Equal(content), path)
+		resp.Body.Close()
+	}
+
+	resp, err := getter.Get(s.URL + "/corrupt")
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	g.Expect(err).To(MatchError(ContainSubstring("but the server announced " + hex.EncodeToString(other[:]))))
+}
@@ -0,0 +1,42 @@
+package repo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+// ParseMirrorlist returns the baseurls of a plain mirror list like the mirror.list files of Amazon Linux, which
+// contain one baseurl per line. Empty lines and comments are skipped.
+func ParseMirrorlist(reader io.Reader) ([]string, error) {
+	baseurls := []string{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		u, err := url.Parse(line)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid baseurl %q in mirror list", line)
+		}
+		baseurls = append(baseurls, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read mirror list: %v", err)
+	}
+	return baseurls, nil
+}
+
+func (r *CacheHelper) LoadMirrorlist(repo *bazeldnf.Repository) ([]string, error) {
+	reader, err := r.OpenFromRepoDir(repo, "mirrorlist")
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ParseMirrorlist(reader)
+}
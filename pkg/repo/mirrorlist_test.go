@@ -0,0 +1,18 @@
+package repo
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParseMirrorlist(t *testing.T) {
+	g := NewGomegaWithT(t)
+	baseurls, err := ParseMirrorlist(strings.NewReader("https://cdn.amazonlinux.com/al2023/core/guids/1234/x86_64/\n"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(baseurls).To(Equal([]string{"https://cdn.amazonlinux.com/al2023/core/guids/1234/x86_64/"}))
+
+	_, err = ParseMirrorlist(strings.NewReader("<html>not found</html>\n"))
+	g.Expect(err).To(MatchError(ContainSubstring("invalid baseurl")))
+}
@@ -0,0 +1,144 @@
+package repo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/progress"
+	log "github.com/sirupsen/logrus"
+)
+
+// RehashSHA256 replaces the checksums of all packages which are not sha256 sums with the sha256 sum of their RPM.
+// Every such RPM is downloaded from the mirrors of its repository and verified against the checksum declared in
+// primary.xml before it is rehashed. The sha256 sums are cached in the cache directories of the repositories,
+// keyed by the declared checksum, so that every RPM is only downloaded once. A nil cacheHelper disables the cache.
+func RehashSHA256(getter Getter, cacheHelper *CacheHelper, pkgs []*api.Package) error {
+	// the getters of repositories which override the proxy, created once per repository
+	proxied := map[string]Getter{}
+	for _, pkg := range pkgs {
+		if pkg.Checksum.Algorithm() == "sha256" {
+			continue
+		}
+		if sum := cachedSHA256(cacheHelper, pkg); sum != "" {
+			log.Debugf("Using cached sha256 %s of %s", sum, pkg.String())
+			pkg.Checksum = api.Checksum{Type: "sha256", Pkgid: pkg.Checksum.Pkgid, Text: sum}
+			continue
+		}
+		declared := pkg.Checksum
+		urls, err := pkg.URLs()
+		if err != nil {
+			return err
+		}
+		if len(urls) == 0 {
+			return fmt.Errorf("package %s has no mirrors to download it from", pkg.String())
+		}
+		pkgGetter := getter
+		if proxyGetter, ok := getter.(ProxyGetter); ok && pkg.Repository != nil && pkg.Repository.Proxy != "" {
+			if pkgGetter = proxied[pkg.Repository.Name]; pkgGetter == nil {
+				if pkgGetter, err = proxyGetter.WithProxy(pkg.Repository.Proxy); err != nil {
+					return fmt.Errorf("failed to configure proxy for %s: %v", pkg.Repository.Name, err)
+				}
+				proxied[pkg.Repository.Name] = pkgGetter
+			}
+		}
+		for _, rpmURL := range urls {
+			var sum string
+			if sum, err = rehashPackage(pkgGetter, pkg, rpmURL); err == nil {
+				log.Debugf("Rehashed %s from %s to sha256 %s", pkg.String(), pkg.Checksum.Algorithm(), sum)
+				pkg.Checksum = api.Checksum{Type: "sha256", Pkgid: pkg.Checksum.Pkgid, Text: sum}
+				cacheSHA256(cacheHelper, pkg.Repository, declared, sum)
+				break
+			}
+			log.Warningf("Failed to rehash %s from %s: %v", pkg.String(), rpmURL, err)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to rehash %s: %v", pkg.String(), err)
+		}
+	}
+	return nil
+}
+
+// rehashedPrefix starts the names of the files holding the sha256 sums of single RPMs
+const rehashedPrefix = "rehashed-"
+
+// rehashedName returns the name of the file in the cache directory of the repository which holds the sha256 sum
+// of the RPM with the given checksum, or an empty string for checksums which can't be part of a file name
+func rehashedName(checksum api.Checksum) string {
+	sum := strings.ToLower(strings.TrimSpace(checksum.Text))
+	if _, err := hex.DecodeString(sum); err != nil || sum == "" {
+		return ""
+	}
+	return rehashedPrefix + checksum.Algorithm() + "-" + sum
+}
+
+// cachedSHA256 returns the cached sha256 sum of the RPM of the package, or an empty string if it is not cached
+func cachedSHA256(cacheHelper *CacheHelper, pkg *api.Package) string {
+	name := rehashedName(pkg.Checksum)
+	if cacheHelper == nil || pkg.Repository == nil || name == "" {
+		return ""
+	}
+	reader, err := cacheHelper.OpenFromRepoDir(pkg.Repository, name)
+	if err != nil {
+		return ""
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return ""
+	}
+	sum := strings.TrimSpace(string(data))
+	if decoded, err := hex.DecodeString(sum); err != nil || len(decoded) != sha256.Size {
+		return ""
+	}
+	return sum
+}
+
+// cacheSHA256 stores the sha256 sum of the RPM with the declared checksum, failures only cost another download
+func cacheSHA256(cacheHelper *CacheHelper, repo *bazeldnf.Repository, declared api.Checksum, sum string) {
+	name := rehashedName(declared)
+	if cacheHelper == nil || repo == nil || name == "" {
+		return
+	}
+	if err := cacheHelper.WriteToRepoDir(repo, strings.NewReader(sum+"\n"), name, nil); err != nil {
+		log.Warningf("Failed to cache the sha256 sum of %s: %v", name, err)
+	}
+}
+
+// rehashPackage downloads the RPM of the package, verifies it against the declared checksum and returns its
+// sha256 sum
+func rehashPackage(getter Getter, pkg *api.Package, rpmURL string) (string, error) {
+	declared, err := NewChecksumHash(pkg.Checksum.Algorithm())
+	if err != nil {
+		return "", err
+	}
+	log.Infof("Downloading %s", rpmURL)
+	resp, err := getter.Get(rpmURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("status : %v", resp.StatusCode)
+	}
+	sha := sha256.New()
+	repository := ""
+	if pkg.Repository != nil {
+		repository = pkg.Repository.Name
+	}
+	download := progress.NewDownload(repository, rpmURL, resp.ContentLength, resp.Body)
+	_, err = io.Copy(io.MultiWriter(declared, sha), download)
+	download.Finish(err)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %v", rpmURL, err)
+	}
+	expected := strings.ToLower(strings.TrimSpace(pkg.Checksum.Text))
+	if toHex(declared) != expected {
+		return "", fmt.Errorf("expected %s sum %s, but got %s", pkg.Checksum.Algorithm(), expected, toHex(declared))
+	}
+	return toHex(sha), nil
+}
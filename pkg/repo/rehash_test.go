@@ -0,0 +1,104 @@
+package repo
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func TestRehashSHA256(t *testing.T) {
+	g := NewGomegaWithT(t)
+	content := []byte("not really an rpm")
+	sha1sum := sha1.Sum(content)
+	sha256sum := sha256.Sum256(content)
+	requests := 0
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/good/Packages/bash-5.0-1.x86_64.rpm" {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rw.Write(content)
+	}))
+	defer s.Close()
+
+	legacy := &api.Package{Name: "bash"}
+	legacy.Checksum = api.Checksum{Type: "sha", Pkgid: "YES", Text: hex.EncodeToString(sha1sum[:])}
+	legacy.Location.Href = "Packages/bash-5.0-1.x86_64.rpm"
+	legacy.Repository = &bazeldnf.Repository{Name: "legacy", Mirrors: []string{s.URL + "/bad", s.URL + "/good"}}
+	current := &api.Package{Name: "glibc"}
+	current.Checksum = api.Checksum{Type: "sha256", Text: "1234"}
+
+	g.Expect(RehashSHA256(&getterImpl{}, nil, []*api.Package{legacy, current})).To(Succeed())
+	g.Expect(legacy.Checksum).To(Equal(api.Checksum{Type: "sha256", Pkgid: "YES", Text: hex.EncodeToString(sha256sum[:])}))
+	g.Expect(current.Checksum.Text).To(Equal("1234"))
+	g.Expect(requests).To(Equal(2))
+
+	// the sha256 sum is cached, keyed by the declared checksum
+	cacheHelper := &CacheHelper{CacheDir: t.TempDir()}
+	newLegacy := func() *api.Package {
+		pkg := &api.Package{Name: "bash"}
+		pkg.Checksum = api.Checksum{Type: "sha", Pkgid: "YES", Text: hex.EncodeToString(sha1sum[:])}
+		pkg.Location.Href = "Packages/bash-5.0-1.x86_64.rpm"
+		pkg.Repository = &bazeldnf.Repository{Name: "legacy", Mirrors: []string{s.URL + "/good"}}
+		return pkg
+	}
+	requests = 0
+	for i := 0; i < 2; i++ {
+		pkg := newLegacy()
+		g.Expect(RehashSHA256(&getterImpl{}, cacheHelper, []*api.Package{pkg})).To(Succeed())
+		g.Expect(pkg.Checksum.Text).To(Equal(hex.EncodeToString(sha256sum[:])))
+	}
+	g.Expect(requests).To(Equal(1))
+
+	tampered := &api.Package{Name: "bash"}
+	tampered.Checksum = api.Checksum{Type: "sha512", Text: "0000"}
+	tampered.Location.Href = "Packages/bash-5.0-1.x86_64.rpm"
+	tampered.Repository = &bazeldnf.Repository{Name: "legacy", Mirrors: []string{s.URL + "/good"}}
+	g.Expect(RehashSHA256(&getterImpl{}, nil, []*api.Package{tampered})).To(MatchError(ContainSubstring("expected sha512 sum 0000")))
+}
+
+// countingProxyGetter counts how often a proxied getter is created
+type countingProxyGetter struct {
+	getterImpl
+	proxied int
+}
+
+func (g *countingProxyGetter) WithProxy(proxy string) (Getter, error) {
+	g.proxied++
+	return g.getterImpl.WithProxy(proxy)
+}
+
+func TestRehashSHA256CreatesProxiedGettersOncePerRepository(t *testing.T) {
+	g := NewGomegaWithT(t)
+	content := []byte("not really an rpm")
+	sha1sum := sha1.Sum(content)
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write(content)
+	}))
+	defer s.Close()
+
+	repo := &bazeldnf.Repository{Name: "proxied", Proxy: NoProxy, Mirrors: []string{s.URL}}
+	pkgs := []*api.Package{}
+	for _, name := range []string{"bash", "glibc", "zlib"} {
+		pkg := &api.Package{Name: name}
+		pkg.Checksum = api.Checksum{Type: "sha", Text: hex.EncodeToString(sha1sum[:])}
+		pkg.Location.Href = "Packages/" + name + ".rpm"
+		pkg.Repository = repo
+		pkgs = append(pkgs, pkg)
+	}
+	getter := &countingProxyGetter{getterImpl: getterImpl{throttle: &throttle{}}}
+	g.Expect(RehashSHA256(getter, nil, pkgs)).To(Succeed())
+	g.Expect(getter.proxied).To(Equal(1))
+
+	proxied, err := getter.getterImpl.WithProxy(NoProxy)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(proxied.(*getterImpl).throttle).To(BeIdenticalTo(getter.throttle))
+}
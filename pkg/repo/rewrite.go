@@ -0,0 +1,123 @@
+package repo
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+// RewriteRulesEnv is the environment variable which can point to a file with URL rewrite rules
+const RewriteRulesEnv = "BAZELDNF_REWRITE_RULES"
+
+// URLRewriter applies the first matching rewrite rule to URLs. Prefixes and regexes are matched at the start of the
+// URL and only the matched part is replaced. A nil URLRewriter leaves all URLs untouched.
+type URLRewriter struct {
+	rules   []bazeldnf.RewriteRule
+	regexes []*regexp.Regexp
+}
+
+// NewURLRewriter validates the given rules
+func NewURLRewriter(rules []bazeldnf.RewriteRule) (*URLRewriter, error) {
+	rewriter := &URLRewriter{}
+	for _, rule := range rules {
+		if (rule.Prefix == "") == (rule.Regex == "") {
+			return nil, fmt.Errorf("rewrite rule to %s needs either a prefix or a regex", rule.Replacement)
+		}
+		var rex *regexp.Regexp
+		if rule.Regex != "" {
+			var err error
+			if rex, err = regexp.Compile(`^(?:` + rule.Regex + `)`); err != nil {
+				return nil, fmt.Errorf("invalid rewrite regex %s: %v", rule.Regex, err)
+			}
+		}
+		rewriter.rules = append(rewriter.rules, rule)
+		rewriter.regexes = append(rewriter.regexes, rex)
+	}
+	return rewriter, nil
+}
+
+// LoadURLRewriter reads the rewrite rules from the given file, or from the file in BAZELDNF_REWRITE_RULES if no
+// file is given. Without any file, nil is returned.
+func LoadURLRewriter(file string) (*URLRewriter, error) {
+	if file == "" {
+		file = os.Getenv(RewriteRulesEnv)
+	}
+	if file == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	config := &bazeldnf.RewriteConfig{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse rewrite rules %s: %v", file, err)
+	}
+	return NewURLRewriter(config.Rewrites)
+}
+
+// Rewrite returns the URL after applying the first matching rule
+func (r *URLRewriter) Rewrite(url string) string {
+	if r == nil {
+		return url
+	}
+	for i, rule := range r.rules {
+		if rex := r.regexes[i]; rex != nil {
+			if match := rex.FindStringSubmatchIndex(url); match != nil {
+				return string(rex.ExpandString(nil, rule.Replacement, url, match)) + url[match[1]:]
+			}
+		} else if strings.HasPrefix(url, rule.Prefix) {
+			return rule.Replacement + strings.TrimPrefix(url, rule.Prefix)
+		}
+	}
+	return url
+}
+
+// RewritingGetter rewrites all URLs before passing the requests on to another Getter
+type RewritingGetter struct {
+	Getter   Getter
+	Rewriter *URLRewriter
+}
+
+func (g *RewritingGetter) Get(url string) (*http.Response, error) {
+	rewritten := g.Rewriter.Rewrite(url)
+	if rewritten != url {
+		logrus.Debugf("Rewrote %s to %s", url, rewritten)
+	}
+	return g.Getter.Get(rewritten)
+}
+
+func (g *RewritingGetter) Post(url string, contentType string, body io.Reader) (*http.Response, error) {
+	poster, ok := g.Getter.(Poster)
+	if !ok {
+		return nil, fmt.Errorf("%T can't send POST requests", g.Getter)
+	}
+	return poster.Post(g.Rewriter.Rewrite(url), contentType, body)
+}
+
+func (g *RewritingGetter) GetRange(url string, start int64, end int64) (*http.Response, error) {
+	ranger, ok := g.Getter.(RangeGetter)
+	if !ok {
+		return g.Get(url)
+	}
+	return ranger.GetRange(g.Rewriter.Rewrite(url), start, end)
+}
+
+func (g *RewritingGetter) WithProxy(proxy string) (Getter, error) {
+	proxyGetter, ok := g.Getter.(ProxyGetter)
+	if !ok {
+		return g, nil
+	}
+	getter, err := proxyGetter.WithProxy(proxy)
+	if err != nil {
+		return nil, err
+	}
+	return &RewritingGetter{Getter: getter, Rewriter: g.Rewriter}, nil
+}
@@ -0,0 +1,59 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func TestURLRewriter(t *testing.T) {
+	g := NewGomegaWithT(t)
+	rewriter, err := NewURLRewriter([]bazeldnf.RewriteRule{
+		{Prefix: "https://dl.fedoraproject.org/pub/", Replacement: "https://artifactory.example.com/fedora/"},
+		{Regex: `^https?://[^/]+/(centos|fedora)/`, Replacement: "https://artifactory.example.com/$1/"},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(rewriter.Rewrite("https://dl.fedoraproject.org/pub/fedora/linux/a.rpm")).To(Equal("https://artifactory.example.com/fedora/fedora/linux/a.rpm"))
+	g.Expect(rewriter.Rewrite("http://mirror.example.com/centos/8/a.rpm")).To(Equal("https://artifactory.example.com/centos/8/a.rpm"))
+	g.Expect(rewriter.Rewrite("https://other.example.com/a.rpm")).To(Equal("https://other.example.com/a.rpm"))
+
+	// regexes are anchored at the start of the URL and replace only the first match
+	rewriter, err = NewURLRewriter([]bazeldnf.RewriteRule{{Regex: `mirror\.example\.com`, Replacement: "artifactory.example.com"}})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(rewriter.Rewrite("https://mirror.example.com/a.rpm")).To(Equal("https://mirror.example.com/a.rpm"))
+	rewriter, err = NewURLRewriter([]bazeldnf.RewriteRule{{Regex: `https://(mirror)\.example\.com/`, Replacement: "https://artifactory.example.com/$1/"}})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(rewriter.Rewrite("https://mirror.example.com/https://mirror.example.com/a.rpm")).To(Equal("https://artifactory.example.com/mirror/https://mirror.example.com/a.rpm"))
+
+	var nilRewriter *URLRewriter
+	g.Expect(nilRewriter.Rewrite("https://dl.fedoraproject.org/pub/")).To(Equal("https://dl.fedoraproject.org/pub/"))
+
+	_, err = NewURLRewriter([]bazeldnf.RewriteRule{{Replacement: "https://artifactory.example.com/"}})
+	g.Expect(err).To(HaveOccurred())
+	_, err = NewURLRewriter([]bazeldnf.RewriteRule{{Regex: "(", Replacement: "https://artifactory.example.com/"}})
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestFetchWithRewrites(t *testing.T) {
+	g := NewGomegaWithT(t)
+	s := newRepoServer(t)
+	rulesFile := filepath.Join(t.TempDir(), "rewrites.yaml")
+	g.Expect(os.WriteFile(rulesFile, []byte("rewrites:\n- prefix: http://repo.example.invalid/\n  replacement: "+s.URL+"/\n"), 0666)).To(Succeed())
+	rewriter, err := LoadURLRewriter(rulesFile)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	repo := bazeldnf.Repository{
+		Name:    "test",
+		Arch:    "x86_64",
+		Baseurl: bazeldnf.URLs{"http://repo.example.invalid/repo/"},
+	}
+	fetcher := &RepoFetcherImpl{
+		Repos:       []bazeldnf.Repository{repo},
+		Getter:      &RewritingGetter{Getter: NewGetter(), Rewriter: rewriter},
+		CacheHelper: &CacheHelper{CacheDir: t.TempDir()},
+	}
+	g.Expect(fetcher.Fetch()).To(Succeed())
+}
@@ -0,0 +1,85 @@
+package repo
+
+import (
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+// CacheServer serves cached repository metadata and RPMs from a local directory with the same paths as the
+// original repositories, so that it can be used as baseurl of the repositories.
+type CacheServer struct {
+	CacheDir string
+	// RPMDir contains RPM files which are served for every RPM path with a matching file name
+	RPMDir string
+	Repos  []bazeldnf.Repository
+}
+
+func (s *CacheServer) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cleaned := path.Clean("/" + r.URL.Path)
+	if cleaned == "/repo.yaml" {
+		s.serveRepoFile(rw, r)
+		return
+	}
+	parts := strings.SplitN(strings.TrimPrefix(cleaned, "/"), "/", 2)
+	if len(parts) != 2 || !s.known(parts[0]) {
+		http.NotFound(rw, r)
+		return
+	}
+	name, file := parts[0], parts[1]
+	switch {
+	case strings.HasPrefix(file, "repodata/"):
+		http.ServeFile(rw, r, filepath.Join(s.CacheDir, name, path.Base(file)))
+	case strings.HasSuffix(file, ".rpm") && s.RPMDir != "":
+		http.ServeFile(rw, r, filepath.Join(s.RPMDir, path.Base(file)))
+	default:
+		http.NotFound(rw, r)
+	}
+}
+
+func (s *CacheServer) known(name string) bool {
+	for _, repo := range s.Repos {
+		if repo.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// serveRepoFile serves a repository file which points all repositories to this server
+func (s *CacheServer) serveRepoFile(rw http.ResponseWriter, r *http.Request) {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	repos := &bazeldnf.Repositories{Version: RepoFileVersion}
+	for _, repo := range s.Repos {
+		repos.Repositories = append(repos.Repositories, bazeldnf.Repository{
+			Name:    repo.Name,
+			Arch:    repo.Arch,
+			Arches:  repo.Arches,
+			Baseurl: bazeldnf.URLs{scheme + "://" + r.Host + "/" + repo.Name + "/"},
+			GPGKey:  repo.GPGKey,
+			// signed repomd.xml files are cached together with their signature, so the checks keep working
+			GPGCheck:     repo.GPGCheck,
+			RepoGPGCheck: repo.RepoGPGCheck,
+		})
+	}
+	data, err := yaml.Marshal(repos)
+	if err != nil {
+		log.Errorf("Failed to render repository file: %v", err)
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/yaml")
+	rw.Write(data)
+}
@@ -0,0 +1,51 @@
+package repo
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"sigs.k8s.io/yaml"
+)
+
+func TestCacheServer(t *testing.T) {
+	g := NewGomegaWithT(t)
+	upstream := newRepoServer(t)
+	repo := bazeldnf.Repository{Name: "test", Arch: "x86_64", Baseurl: bazeldnf.URLs{upstream.URL + "/repo/"}}
+	cacheDir := t.TempDir()
+	g.Expect(NewRemoteRepoFetcher([]bazeldnf.Repository{repo}, cacheDir).Fetch()).To(Succeed())
+
+	rpmDir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(rpmDir, "bash-5.0.17-1.fc32.x86_64.rpm"), []byte("rpm"), 0666)).To(Succeed())
+	s := httptest.NewServer(&CacheServer{CacheDir: cacheDir, RPMDir: rpmDir, Repos: []bazeldnf.Repository{repo}})
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/repo.yaml")
+	g.Expect(err).ToNot(HaveOccurred())
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	g.Expect(err).ToNot(HaveOccurred())
+	served := &bazeldnf.Repositories{}
+	g.Expect(yaml.Unmarshal(data, served)).To(Succeed())
+	g.Expect(served.Repositories).To(HaveLen(1))
+	g.Expect(served.Repositories[0].Baseurl).To(Equal(bazeldnf.URLs{s.URL + "/test/"}))
+
+	g.Expect(NewRemoteRepoFetcher(served.Repositories, t.TempDir()).Fetch()).To(Succeed())
+
+	for path, status := range map[string]int{
+		"/test/Packages/b/bash-5.0.17-1.fc32.x86_64.rpm": http.StatusOK,
+		"/test/Packages/b/zsh-5.8-1.fc32.x86_64.rpm":     http.StatusNotFound,
+		"/unknown/repodata/repomd.xml":                   http.StatusNotFound,
+		"/test/../../etc/passwd":                         http.StatusNotFound,
+	} {
+		resp, err := http.Get(s.URL + path)
+		g.Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+		g.Expect(resp.StatusCode).To(Equal(status), path)
+	}
+}
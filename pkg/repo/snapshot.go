@@ -0,0 +1,49 @@
+package repo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+// SnapshotDateFormat is the format of the dates which select repository snapshots, e.g. `2024-11-01`
+const SnapshotDateFormat = "2006-01-02"
+
+// ApplySnapshot points all enabled repositories to their snapshot of the given date. The `snapshot` URL of a
+// repository becomes its only baseurl, with $snapshot replaced by the date as given and $year, $month and $day
+// by its parts. Repositories without a snapshot URL can't be pinned and cause an error, Koji repositories are
+// left untouched.
+func ApplySnapshot(repos *bazeldnf.Repositories, date string) error {
+	day, err := time.Parse(SnapshotDateFormat, date)
+	if err != nil {
+		return fmt.Errorf("invalid snapshot date %s, expected a date like 2024-11-01", date)
+	}
+	replacer := strings.NewReplacer(
+		"$snapshot", date,
+		"$year", day.Format("2006"),
+		"$month", day.Format("01"),
+		"$day", day.Format("02"),
+	)
+	unpinned := []string{}
+	for i := range repos.Repositories {
+		repo := &repos.Repositories[i]
+		if repo.Disabled || repo.Koji != nil {
+			// koji builds never change, so they don't need to be pinned
+			continue
+		}
+		if repo.Snapshot == "" {
+			unpinned = append(unpinned, repo.Name)
+			continue
+		}
+		repo.Baseurl = bazeldnf.URLs{replacer.Replace(repo.Snapshot)}
+		repo.Metalink = ""
+		repo.Mirrorlist = ""
+		repo.Mirrors = nil
+	}
+	if len(unpinned) > 0 {
+		return fmt.Errorf("repositories %s have no snapshot URL and can't be pinned to %s, configure one by overriding them in a repository file", strings.Join(unpinned, ", "), date)
+	}
+	return nil
+}
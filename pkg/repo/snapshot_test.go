@@ -0,0 +1,41 @@
+package repo
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func TestApplySnapshot(t *testing.T) {
+	g := NewGomegaWithT(t)
+	repos := &bazeldnf.Repositories{Repositories: []bazeldnf.Repository{
+		{
+			Name:     "fedora",
+			Metalink: "https://mirrors.fedoraproject.org/metalink?repo=fedora-41&arch=x86_64",
+			Snapshot: "https://snapshots.example.com/fedora/$year$month$day/41/x86_64/",
+		},
+		{
+			Name:     "koji",
+			Baseurl:  bazeldnf.URLs{"https://kojipkgs.example.com/repos/f41-build/latest/x86_64/"},
+			Snapshot: "https://kojipkgs.example.com/repos/f41-build/$snapshot/x86_64/",
+		},
+		{
+			Name:     "disabled",
+			Disabled: true,
+		},
+		{
+			Name: "koji-x86_64",
+			Koji: &bazeldnf.KojiSource{Builds: []string{"bash-5.2.26-1.fc40"}},
+		},
+	}}
+	g.Expect(ApplySnapshot(repos, "2024-11-01")).To(Succeed())
+	g.Expect(repos.Repositories[0].Metalink).To(BeEmpty())
+	g.Expect(repos.Repositories[0].Baseurl).To(Equal(bazeldnf.URLs{"https://snapshots.example.com/fedora/20241101/41/x86_64/"}))
+	g.Expect(repos.Repositories[1].Baseurl).To(Equal(bazeldnf.URLs{"https://kojipkgs.example.com/repos/f41-build/2024-11-01/x86_64/"}))
+	g.Expect(repos.Repositories[3].Baseurl).To(BeEmpty())
+
+	repos.Repositories = append(repos.Repositories, bazeldnf.Repository{Name: "updates"})
+	g.Expect(ApplySnapshot(repos, "2024-11-01")).To(MatchError("repositories updates have no snapshot URL and can't be pinned to 2024-11-01, configure one by overriding them in a repository file"))
+	g.Expect(ApplySnapshot(repos, "yesterday")).To(MatchError(ContainSubstring("invalid snapshot date yesterday")))
+}
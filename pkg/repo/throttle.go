@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultRetryAfter is used when a mirror throttles us without telling us for how long
+	defaultRetryAfter = 5 * time.Second
+	// maxRetryAfter caps how long a single Retry-After header can make us wait
+	maxRetryAfter = 5 * time.Minute
+)
+
+// throttle remembers until when hosts asked us to back off. Requests to a throttled host are delayed until the
+// backoff expired, while callers are free to rotate to other mirrors in the meantime. A nil throttle never backs off.
+type throttle struct {
+	lock  sync.Mutex
+	until map[string]time.Time
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+func (t *throttle) wait(host string) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	until, exists := t.until[host]
+	t.lock.Unlock()
+	if !exists {
+		return
+	}
+	if d := until.Sub(t.clock()); d > 0 {
+		log.Infof("Waiting %v before contacting throttling mirror %s again", d.Round(time.Second), host)
+		if t.sleep != nil {
+			t.sleep(d)
+		} else {
+			time.Sleep(d)
+		}
+	}
+}
+
+func (t *throttle) observe(host string, resp *http.Response) {
+	if t == nil {
+		return
+	}
+	if !isThrottled(resp) {
+		t.lock.Lock()
+		delete(t.until, host)
+		t.lock.Unlock()
+		return
+	}
+	now := t.clock()
+	d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now)
+	if !ok {
+		d = defaultRetryAfter
+	}
+	if d > maxRetryAfter {
+		d = maxRetryAfter
+	}
+	log.Warningf("Mirror %s throttles requests with status %v, backing off for %v", host, resp.StatusCode, d)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.until == nil {
+		t.until = map[string]time.Time{}
+	}
+	t.until[host] = now.Add(d)
+}
+
+func (t *throttle) clock() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
+
+// parseRetryAfter understands both forms of the Retry-After header, delay seconds and an HTTP date
+func parseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(header)
+	if err != nil {
+		return 0, false
+	}
+	if d := date.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// isThrottled returns true if the response asks us to slow down
+func isThrottled(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+}
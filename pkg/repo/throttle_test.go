@@ -0,0 +1,59 @@
+package repo
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+		wantOk bool
+	}{
+		{name: "empty", header: "", wantOk: false},
+		{name: "seconds", header: "120", want: 2 * time.Minute, wantOk: true},
+		{name: "negative seconds", header: "-1", wantOk: false},
+		{name: "http date", header: "Fri, 01 Jan 2021 12:00:30 GMT", want: 30 * time.Second, wantOk: true},
+		{name: "http date in the past", header: "Fri, 01 Jan 2021 11:00:00 GMT", want: 0, wantOk: true},
+		{name: "garbage", header: "soon", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			got, ok := parseRetryAfter(tt.header, now)
+			g.Expect(ok).To(Equal(tt.wantOk))
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	g := NewGomegaWithT(t)
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	slept := []time.Duration{}
+	th := &throttle{
+		now:   func() time.Time { return now },
+		sleep: func(d time.Duration) { slept = append(slept, d) },
+	}
+
+	th.wait("a.example.com")
+	g.Expect(slept).To(BeEmpty())
+
+	th.observe("a.example.com", &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"10"}}})
+	th.observe("b.example.com", &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{"Retry-After": []string{"86400"}}})
+	th.observe("c.example.com", &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}})
+	th.wait("a.example.com")
+	th.wait("b.example.com")
+	th.wait("c.example.com")
+	g.Expect(slept).To(Equal([]time.Duration{10 * time.Second, maxRetryAfter, defaultRetryAfter}))
+
+	th.observe("a.example.com", &http.Response{StatusCode: http.StatusOK})
+	th.wait("a.example.com")
+	g.Expect(slept).To(HaveLen(3))
+}
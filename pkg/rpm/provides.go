@@ -0,0 +1,30 @@
+package rpm
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/sassoftware/go-rpmutils"
+)
+
+// ReadProvides returns the capabilities and the file paths the RPM provides. Only the header is read, the
+// payload is not decompressed.
+func ReadProvides(rpmReader io.Reader) (provides []string, files []string, err error) {
+	header, err := rpmutils.ReadHeader(rpmReader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read rpm header: %s", err)
+	}
+	if header.HasTag(rpmutils.PROVIDENAME) {
+		if provides, err = header.GetStrings(rpmutils.PROVIDENAME); err != nil {
+			return nil, nil, fmt.Errorf("failed to read provides: %s", err)
+		}
+	}
+	fileInfos, err := header.GetFiles()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read files: %s", err)
+	}
+	for _, fileInfo := range fileInfos {
+		files = append(files, fileInfo.Name())
+	}
+	return provides, files, nil
+}
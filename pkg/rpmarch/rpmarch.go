@@ -0,0 +1,89 @@
+package rpmarch
+
+import "strings"
+
+// rpmArches maps architecture names used by Go, Bazel and docker to the matching RPM architecture
+var rpmArches = map[string]string{
+	"amd64":    "x86_64",
+	"x86-64":   "x86_64",
+	"x64":      "x86_64",
+	"arm64":    "aarch64",
+	"arm64/v8": "aarch64",
+	"386":      "i686",
+	"i386":     "i686",
+	"x86_32":   "i686",
+	"arm":      "armv7hl",
+	"arm/v7":   "armv7hl",
+	"armv7":    "armv7hl",
+	"armhf":    "armv7hl",
+	"ppc64el":  "ppc64le",
+}
+
+// goArches maps RPM architectures to the matching GOARCH, which is also used by docker and OCI image platforms
+var goArches = map[string]string{
+	"x86_64":  "amd64",
+	"aarch64": "arm64",
+	"i686":    "386",
+	"i586":    "386",
+	"i386":    "386",
+	"armv7hl": "arm",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+	"riscv64": "riscv64",
+}
+
+// compatArches maps 64-bit RPM architectures to the 32-bit architectures of the compatibility packages which
+// their repositories carry for multilib installations
+var compatArches = map[string][]string{
+	"x86_64":  {"i686", "i586", "i386"},
+	"aarch64": {"armv7hl"},
+}
+
+// Normalize returns the RPM architecture for an architecture name like `amd64`, `arm64` or `linux/arm64/v8`.
+// RPM architectures and unknown names are returned unchanged.
+func Normalize(arch string) string {
+	arch = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(arch)), "linux/")
+	if rpmArch, exists := rpmArches[arch]; exists {
+		return rpmArch
+	}
+	return arch
+}
+
+// GOARCH returns the GOARCH for an architecture, e.g. `amd64` for `x86_64`. Architectures without a Go
+// equivalent like `noarch` are returned unchanged.
+func GOARCH(arch string) string {
+	arch = Normalize(arch)
+	if goArch, exists := goArches[arch]; exists {
+		return goArch
+	}
+	return arch
+}
+
+// IsKnown returns true for `noarch`, the RPM architectures and the names which Normalize translates to them
+func IsKnown(arch string) bool {
+	arch = Normalize(arch)
+	if arch == "noarch" {
+		return true
+	}
+	_, exists := goArches[arch]
+	return exists
+}
+
+// CompatArches returns the architectures of the multilib compatibility packages which repositories of the given
+// architecture carry, e.g. `i686` for `x86_64`
+func CompatArches(arch string) []string {
+	return compatArches[Normalize(arch)]
+}
+
+// IsCompat returns true if packages of the architecture are multilib compatibility packages in the repositories
+// of some other architecture
+func IsCompat(arch string) bool {
+	for _, compat := range compatArches {
+		for _, a := range compat {
+			if a == arch {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package rpmarch
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestNormalize(t *testing.T) {
+	g := NewGomegaWithT(t)
+	for arch, expected := range map[string]string{
+		"amd64":          "x86_64",
+		"x86_64":         "x86_64",
+		"ARM64":          "aarch64",
+		"linux/arm64/v8": "aarch64",
+		"linux/arm/v7":   "armv7hl",
+		"386":            "i686",
+		"ppc64le":        "ppc64le",
+		"noarch":         "noarch",
+	} {
+		g.Expect(Normalize(arch)).To(Equal(expected), arch)
+	}
+}
+
+func TestGOARCH(t *testing.T) {
+	g := NewGomegaWithT(t)
+	for arch, expected := range map[string]string{
+		"x86_64":  "amd64",
+		"amd64":   "amd64",
+		"aarch64": "arm64",
+		"i686":    "386",
+		"armv7hl": "arm",
+		"s390x":   "s390x",
+		"noarch":  "noarch",
+	} {
+		g.Expect(GOARCH(arch)).To(Equal(expected), arch)
+	}
+}
+
+func TestIsKnown(t *testing.T) {
+	g := NewGomegaWithT(t)
+	g.Expect(IsKnown("x86_64")).To(BeTrue())
+	g.Expect(IsKnown("arm64")).To(BeTrue())
+	g.Expect(IsKnown("noarch")).To(BeTrue())
+	g.Expect(IsKnown("x86")).To(BeFalse())
+	g.Expect(IsKnown("")).To(BeFalse())
+}
+
+func TestCompatArches(t *testing.T) {
+	g := NewGomegaWithT(t)
+	g.Expect(CompatArches("amd64")).To(ConsistOf("i686", "i586", "i386"))
+	g.Expect(CompatArches("aarch64")).To(ConsistOf("armv7hl"))
+	g.Expect(CompatArches("s390x")).To(BeEmpty())
+	g.Expect(IsCompat("i686")).To(BeTrue())
+	g.Expect(IsCompat("armv7hl")).To(BeTrue())
+	g.Expect(IsCompat("x86_64")).To(BeFalse())
+	g.Expect(IsCompat("noarch")).To(BeFalse())
+}
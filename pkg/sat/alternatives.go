@@ -0,0 +1,90 @@
+package sat
+
+import (
+	"sort"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+)
+
+// Alternative describes a required capability which can be provided by more than one package, like `curl`
+// which is provided by `curl` and `curl-minimal`.
+type Alternative struct {
+	Capability string
+	Packages   []string
+}
+
+// FindAlternatives returns all capabilities which are required by the given packages or explicitly requested
+// and which can be satisfied by more than one distinct package. Capabilities which already have a preference
+// are skipped.
+func FindAlternatives(packages []*api.Package, requested []string, preferences map[string]string) []Alternative {
+	required := map[string]struct{}{}
+	for _, req := range requested {
+		required[req] = struct{}{}
+	}
+	for _, pkg := range packages {
+		for _, req := range pkg.Format.Requires.Entries {
+			required[req.Name] = struct{}{}
+		}
+	}
+	return alternativesOf(packages, required, preferences)
+}
+
+// FindReachableAlternatives returns the capabilities with more than one distinct provider which the given packages
+// require, directly or through the providers of their requirements. Preferences are not skipped, since they may
+// be the reason why resolving failed.
+func FindReachableAlternatives(packages []*api.Package, from []*api.Package) []Alternative {
+	providers := map[string][]*api.Package{}
+	for _, pkg := range packages {
+		for _, prov := range pkg.Format.Provides.Entries {
+			providers[prov.Name] = append(providers[prov.Name], pkg)
+		}
+	}
+	required := map[string]struct{}{}
+	visited := map[*api.Package]struct{}{}
+	queue := append([]*api.Package{}, from...)
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+		if _, exists := visited[pkg]; exists {
+			continue
+		}
+		visited[pkg] = struct{}{}
+		for _, req := range pkg.Format.Requires.Entries {
+			required[req.Name] = struct{}{}
+			queue = append(queue, providers[req.Name]...)
+		}
+	}
+	return alternativesOf(packages, required, nil)
+}
+
+// alternativesOf returns the required capabilities without preference which more than one distinct package provides
+func alternativesOf(packages []*api.Package, required map[string]struct{}, preferences map[string]string) (alternatives []Alternative) {
+	providers := map[string]map[string]struct{}{}
+	for _, pkg := range packages {
+		for _, prov := range pkg.Format.Provides.Entries {
+			if providers[prov.Name] == nil {
+				providers[prov.Name] = map[string]struct{}{}
+			}
+			providers[prov.Name][pkg.Name] = struct{}{}
+		}
+	}
+
+	for capability := range required {
+		if _, exists := preferences[capability]; exists {
+			continue
+		}
+		if len(providers[capability]) < 2 {
+			continue
+		}
+		alternative := Alternative{Capability: capability}
+		for name := range providers[capability] {
+			alternative.Packages = append(alternative.Packages, name)
+		}
+		sort.Strings(alternative.Packages)
+		alternatives = append(alternatives, alternative)
+	}
+	sort.SliceStable(alternatives, func(i, j int) bool {
+		return alternatives[i].Capability < alternatives[j].Capability
+	})
+	return alternatives
+}
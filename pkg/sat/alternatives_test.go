@@ -0,0 +1,56 @@
+package sat
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+)
+
+func TestFindAlternatives(t *testing.T) {
+	g := NewGomegaWithT(t)
+	packages := []*api.Package{
+		newPkg("app", "1", []string{}, []string{"curl"}, []string{}),
+		newPkg("curl", "1", []string{}, []string{}, []string{}),
+		newPkg("curl-minimal", "1", []string{"curl"}, []string{}, []string{}),
+	}
+	g.Expect(FindAlternatives(packages, []string{"app"}, nil)).To(Equal([]Alternative{
+		{Capability: "curl", Packages: []string{"curl", "curl-minimal"}},
+	}))
+	g.Expect(FindAlternatives(packages, []string{"app"}, map[string]string{"curl": "curl"})).To(BeEmpty())
+}
+
+func TestFindReachableAlternatives(t *testing.T) {
+	g := NewGomegaWithT(t)
+	packages := []*api.Package{
+		newPkg("app", "1", []string{}, []string{"curl"}, []string{}),
+		newPkg("curl", "1", []string{}, []string{"libcurl"}, []string{}),
+		newPkg("curl-minimal", "1", []string{"curl"}, []string{}, []string{}),
+		newPkg("libcurl", "1", []string{}, []string{}, []string{}),
+		newPkg("libcurl-minimal", "1", []string{"libcurl"}, []string{}, []string{}),
+		newPkg("other", "1", []string{}, []string{"editor"}, []string{}),
+		newPkg("vim", "1", []string{"editor"}, []string{}, []string{}),
+		newPkg("nano", "1", []string{"editor"}, []string{}, []string{}),
+	}
+	g.Expect(FindReachableAlternatives(packages, packages[:1])).To(Equal([]Alternative{
+		{Capability: "curl", Packages: []string{"curl", "curl-minimal"}},
+		{Capability: "libcurl", Packages: []string{"libcurl", "libcurl-minimal"}},
+	}))
+	g.Expect(FindReachableAlternatives(packages, packages[3:4])).To(BeEmpty())
+}
+
+func TestPreferences(t *testing.T) {
+	g := NewGomegaWithT(t)
+	packages := []*api.Package{
+		newPkg("app", "1", []string{}, []string{"curl"}, []string{}),
+		newPkg("curl", "1", []string{}, []string{}, []string{"curl-minimal"}),
+		newPkg("curl-minimal", "1", []string{"curl"}, []string{}, []string{"curl"}),
+	}
+	resolver := NewResolver(false)
+	resolver.SetPreferences(map[string]string{"curl": "curl-minimal"})
+	g.Expect(resolver.LoadInvolvedPackages(packages, nil)).To(Succeed())
+	g.Expect(resolver.ConstructRequirements([]string{"app"})).To(Succeed())
+	install, _, _, err := resolver.Resolve()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pkgToString(install)).To(ConsistOf("app-0:1", "curl-minimal-0:1"))
+}
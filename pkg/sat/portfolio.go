@@ -0,0 +1,143 @@
+package sat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/crillab/gophersat/maxsat"
+	"github.com/crillab/gophersat/solver"
+	"github.com/sirupsen/logrus"
+)
+
+type portfolioResult struct {
+	configuration int
+	result        solver.Result
+	err           error
+}
+
+// errCanceled is returned by configurations which were canceled because another one finished first
+var errCanceled = errors.New("canceled")
+
+// solvePortfolio solves the weighted CNF problem with the given number of configurations in parallel and returns
+// the first result. The first configuration solves the unmodified problem, all others renumber the variables and
+// reorder the clauses, which changes the decisions of the solver. The remaining configurations are canceled.
+func solvePortfolio(wcnf []byte, configurations int) (solver.Result, error) {
+	results := make(chan portfolioResult, configurations)
+	cancel := make(chan struct{})
+	defer close(cancel)
+	for i := 0; i < configurations; i++ {
+		go func(configuration int) {
+			result, err := solveShuffled(wcnf, int64(configuration), cancel)
+			results <- portfolioResult{configuration: configuration, result: result, err: err}
+		}(i)
+	}
+	first := <-results
+	if first.err != nil {
+		return solver.Result{}, first.err
+	}
+	logrus.Infof("Solver configuration %d of %d finished first.", first.configuration+1, configurations)
+	return first.result, nil
+}
+
+// solveShuffled solves the problem after shuffling it with the given seed and maps the model back to the
+// original variables. Seed 0 leaves the problem untouched. The solver itself can't be interrupted, so once cancel
+// is closed the solver is abandoned: it stops at the next improved solution, which nobody receives anymore.
+func solveShuffled(wcnf []byte, seed int64, cancel <-chan struct{}) (solver.Result, error) {
+	shuffled, perm, err := shuffleWCNF(wcnf, seed)
+	if err != nil {
+		return solver.Result{}, err
+	}
+	s, err := maxsat.ParseWCNF(bytes.NewReader(shuffled))
+	if err != nil {
+		return solver.Result{}, err
+	}
+	improved := make(chan solver.Result)
+	go s.Optimal(improved, nil)
+	var result solver.Result
+	for done := false; !done; {
+		select {
+		case res, ok := <-improved:
+			if ok {
+				result = res
+			}
+			done = !ok
+		case <-cancel:
+			return solver.Result{}, errCanceled
+		}
+	}
+	if result.Status == solver.Sat && perm != nil {
+		model := make([]bool, len(result.Model))
+		for v := 1; v < len(perm) && v <= len(model); v++ {
+			model[v-1] = result.Model[perm[v]-1]
+		}
+		result.Model = model
+	}
+	return result, nil
+}
+
+// shuffleWCNF renumbers the variables and reorders the clauses of the weighted CNF problem. The returned
+// permutation maps the original variables to the new ones.
+func shuffleWCNF(wcnf []byte, seed int64) ([]byte, []int, error) {
+	if seed == 0 {
+		return wcnf, nil, nil
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	var header string
+	var clauses [][]string
+	nbVars := 0
+	for _, line := range strings.Split(string(wcnf), "\n") {
+		if line == "" || strings.HasPrefix(line, "c") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if strings.HasPrefix(line, "p") {
+			if len(fields) < 4 {
+				return nil, nil, fmt.Errorf("invalid problem line %q", line)
+			}
+			n, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid number of variables in %q: %v", line, err)
+			}
+			header = line
+			nbVars = n
+			continue
+		}
+		clauses = append(clauses, fields)
+	}
+	perm := make([]int, nbVars+1)
+	for i, v := range rnd.Perm(nbVars) {
+		perm[i+1] = v + 1
+	}
+	rnd.Shuffle(len(clauses), func(i, j int) {
+		clauses[i], clauses[j] = clauses[j], clauses[i]
+	})
+
+	out := &bytes.Buffer{}
+	fmt.Fprintln(out, header)
+	for _, clause := range clauses {
+		// the first field is the weight, the last one the terminating 0
+		for i, field := range clause {
+			if i > 0 && i < len(clause)-1 {
+				lit, err := strconv.Atoi(field)
+				if err != nil {
+					return nil, nil, fmt.Errorf("invalid literal %q: %v", field, err)
+				}
+				if lit < 0 {
+					field = strconv.Itoa(-perm[-lit])
+				} else {
+					field = strconv.Itoa(perm[lit])
+				}
+			}
+			if i > 0 {
+				out.WriteByte(' ')
+			}
+			out.WriteString(field)
+		}
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), perm, nil
+}
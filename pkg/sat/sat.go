@@ -12,9 +12,12 @@ import (
 	"github.com/crillab/gophersat/bf"
 	"github.com/crillab/gophersat/explain"
 	"github.com/crillab/gophersat/maxsat"
+	"github.com/crillab/gophersat/solver"
 	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
 	"github.com/rmohr/bazeldnf/pkg/reducer"
 	"github.com/rmohr/bazeldnf/pkg/rpm"
+	"github.com/rmohr/bazeldnf/pkg/rpmarch"
 	"github.com/sirupsen/logrus"
 )
 
@@ -26,10 +29,19 @@ const (
 	VarTypeFile     = "File"
 )
 
+// weakDepWeight is the weight of the soft rule for every recommendation, lower than the weight of preferring
+// the newest version of a package
+const weakDepWeight = 500
+
+// lockedWeight is the weight of the soft rules which prefer locked versions, higher than the weight of preferring
+// the newest version of a package
+const lockedWeight = 1950
+
 // VarContext contains all information to create a unique identifyable hash key which can be traced back to a package
 // for every resource in a yum repo
 type VarContext struct {
 	Package  string
+	Arch     string
 	Provides string
 	Version  api.Version
 }
@@ -64,7 +76,7 @@ type Resolver struct {
 	varsCount int
 	// provides allows accessing variables which can resolve unversioned requirement to build proper clauses
 	provides map[string][]*Var
-	// packages contains a map which contains all pkg vars which can be looked up by package name
+	// packages contains a map which contains all pkg vars which can be looked up by package name and architecture
 	// useful for creating soft clauses
 	packages map[string][]*Var
 	// pkgProvides allows accessing all variables which get pulled in if a specific package get's pulled in
@@ -78,6 +90,15 @@ type Resolver struct {
 	unresolvable                []unresolvable
 	forceIgnoreWithDependencies map[string]*api.Package
 	nobest                      bool
+	// preferences maps a capability to the name of the package which should be picked to provide it
+	preferences map[string]string
+	// weakDeps enables satisfying recommendations of packages if possible
+	weakDeps       bool
+	ignoreWeakDeps []bazeldnf.WeakDepsFilter
+	// locked maps package names to the version which should be kept if possible
+	locked map[string]string
+	// portfolio is the number of solver configurations which are run in parallel
+	portfolio int
 }
 
 type unresolvable struct {
@@ -96,9 +117,65 @@ func NewResolver(nobest bool) *Resolver {
 		nobest:                      nobest,
 		bestPackages:                map[string]*api.Package{},
 		forceIgnoreWithDependencies: map[string]*api.Package{},
+		preferences:                 map[string]string{},
+		locked:                      map[string]string{},
+	}
+}
+
+// SetPreferences pins capabilities to specific providing packages. If the preferred package is not among the
+// candidates of a capability, the preference is ignored for that capability. It has to be called before
+// LoadInvolvedPackages to take effect.
+func (r *Resolver) SetPreferences(preferences map[string]string) {
+	for capability, pkgName := range preferences {
+		r.preferences[capability] = pkgName
 	}
 }
 
+// SetWeakDeps makes the resolver try to satisfy the recommendations of the installed packages, except for the
+// ignored ones. Recommendations which can't be satisfied don't prevent a solution.
+func (r *Resolver) SetWeakDeps(ignore []bazeldnf.WeakDepsFilter) {
+	r.weakDeps = true
+	r.ignoreWeakDeps = ignore
+}
+
+// SetLockedVersions makes the resolver prefer keeping the given versions, formatted as epoch:version-release,
+// over newer versions of the packages. Locked versions are only replaced if the requirements can't be satisfied
+// otherwise. It has to be called before LoadInvolvedPackages to take effect.
+func (r *Resolver) SetLockedVersions(locked map[string]string) {
+	for name, version := range locked {
+		r.locked[name] = version
+	}
+}
+
+// SetPortfolio runs the given number of differently shuffled solver configurations in parallel and takes the
+// solution of the first one which finishes. This bounds the solving time on instances where a single
+// configuration gets stuck, but the solution may differ between runs if several solutions have the same weight.
+func (r *Resolver) SetPortfolio(configurations int) {
+	r.portfolio = configurations
+}
+
+// Unresolvable describes the requirements of the loaded packages which none of the candidates satisfy, like
+// `bash-5.2.26-1.fc40 requires libc.so.6(GLIBC_2.38)(64bit)`
+func (r *Resolver) Unresolvable() (requirements []string) {
+	for _, u := range r.unresolvable {
+		requirements = append(requirements, fmt.Sprintf("%s requires %s", u.Package, u.Requirement.Name))
+	}
+	sort.Strings(requirements)
+	return requirements
+}
+
+// UnresolvablePackages returns the packages with requirements which can't be satisfied
+func (r *Resolver) UnresolvablePackages() (packages []*api.Package) {
+	seen := map[*api.Package]struct{}{}
+	for _, u := range r.unresolvable {
+		if _, exists := seen[u.Package]; !exists {
+			seen[u.Package] = struct{}{}
+			packages = append(packages, u.Package)
+		}
+	}
+	return packages
+}
+
 func (r *Resolver) ticket() string {
 	r.varsCount++
 	return "x" + strconv.Itoa(r.varsCount)
@@ -111,23 +188,23 @@ func (r *Resolver) LoadInvolvedPackages(packages []*api.Package, ignoreRegex []s
 	// Deduplicate and detect excludes
 	deduplicated := map[string]*api.Package{}
 	for i, pkg := range packages {
-		if _, exists := deduplicated[pkg.String()]; exists {
-			logrus.Infof("Removing duplicate of  %v.", pkg.String())
-		}
 		fullName := pkg.String()
-		if _, exists := deduplicated[fullName]; !exists {
-			for _, rex := range ignoreRegex {
-				if match, err := regexp.MatchString(rex, fullName); err != nil {
-					return fmt.Errorf("failed to match package with regex '%v': %v", rex, err)
-				} else if match {
-					packages[i].Format.Requires.Entries = nil
-					logrus.Warnf("Package %v is forcefully ignored by regex '%v'.", pkg.String(), rex)
-					r.forceIgnoreWithDependencies[pkg.String()] = packages[i]
-					break
-				}
+		// multilib packages share name and version with the native ones, so the architecture tells them apart
+		if _, exists := deduplicated[pkg.ID()]; exists {
+			logrus.Infof("Removing duplicate of  %v.", pkg.ID())
+			continue
+		}
+		for _, rex := range ignoreRegex {
+			if match, err := regexp.MatchString(rex, fullName); err != nil {
+				return fmt.Errorf("failed to match package with regex '%v': %v", rex, err)
+			} else if match {
+				packages[i].Format.Requires.Entries = nil
+				logrus.Warnf("Package %v is forcefully ignored by regex '%v'.", pkg.String(), rex)
+				r.forceIgnoreWithDependencies[pkg.String()] = packages[i]
+				break
 			}
-			deduplicated[pkg.String()] = packages[i]
 		}
+		deduplicated[pkg.ID()] = packages[i]
 	}
 	packages = nil
 	for k, _ := range deduplicated {
@@ -137,23 +214,30 @@ func (r *Resolver) LoadInvolvedPackages(packages []*api.Package, ignoreRegex []s
 
 	// Create an index to pick the best candidates
 	for _, pkg := range packages {
-		if r.bestPackages[pkg.Name] == nil {
-			r.bestPackages[pkg.Name] = pkg
-		} else if rpm.Compare(pkg.Version, r.bestPackages[pkg.Name].Version) == 1 {
-			r.bestPackages[pkg.Name] = pkg
+		if r.bestPackages[pkg.NameArch()] == nil {
+			r.bestPackages[pkg.NameArch()] = pkg
+		} else if rpm.Compare(pkg.Version, r.bestPackages[pkg.NameArch()].Version) == 1 {
+			r.bestPackages[pkg.NameArch()] = pkg
 		}
 	}
 
 	if !r.nobest {
-		packages = nil
+		candidates := []*api.Package{}
 		for _, v := range r.bestPackages {
-			packages = append(packages, v)
+			candidates = append(candidates, v)
 		}
+		// keep locked versions as candidates, even if they are not the best ones anymore
+		for _, pkg := range packages {
+			if r.isLocked(pkg) && r.bestPackages[pkg.NameArch()] != pkg {
+				candidates = append(candidates, pkg)
+			}
+		}
+		packages = candidates
 	}
 	// Generate variables
 	for _, pkg := range packages {
 		pkgVar, resourceVars := r.explodePackageToVars(pkg)
-		r.packages[pkg.Name] = append(r.packages[pkg.Name], pkgVar)
+		r.packages[pkg.NameArch()] = append(r.packages[pkg.NameArch()], pkgVar)
 		r.pkgProvides[pkgVar.Context] = resourceVars
 		for _, v := range resourceVars {
 			r.provides[v.Context.Provides] = append(r.provides[v.Context.Provides], v)
@@ -194,6 +278,11 @@ func (r *Resolver) ConstructRequirements(packages []string) error {
 		if err != nil {
 			return err
 		}
+		if versions := r.lockedAlternatives(req); versions != nil {
+			logrus.Infof("Selecting %s: %v, preferring the locked version", pkgName, req.Package.Name)
+			r.ands = append(r.ands, bf.Or(toBFVars(versions)...))
+			continue
+		}
 		logrus.Infof("Selecting %s: %v", pkgName, req.Package)
 		r.ands = append(r.ands, bf.Var(req.satVarName))
 	}
@@ -251,6 +340,10 @@ func (res *Resolver) Resolve() (install []*api.Package, excluded []*api.Package,
 		}
 		// write soft rules. We don't want to install any package
 		for _, pkgs := range res.packages {
+			if err := res.writeLocked(pwMaxSatWriter, pkgs, vars); err != nil {
+				pwMaxSatErrChan <- err
+				return
+			}
 			weight := 1901
 			fmt.Fprintf(pwMaxSatWriter, "c prefer %s\n", pkgs[len(pkgs)-1].Package.String())
 			if len(pkgs) > 1 {
@@ -266,12 +359,29 @@ func (res *Resolver) Resolve() (install []*api.Package, excluded []*api.Package,
 				}
 			}
 		}
+		if err := res.writeWeakDeps(pwMaxSatWriter, vars); err != nil {
+			pwMaxSatErrChan <- err
+		}
 	}()
 
 	logrus.Info("Loading the Partial weighted MAXSAT problem.")
-	s, err := maxsat.ParseWCNF(pwMaxSatReader)
-	if err != nil {
-		return nil, nil, nil, err
+	var solve func() (solver.Result, error)
+	if res.portfolio > 1 {
+		wcnf, err := io.ReadAll(pwMaxSatReader)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		solve = func() (solver.Result, error) {
+			return solvePortfolio(wcnf, res.portfolio)
+		}
+	} else {
+		s, err := maxsat.ParseWCNF(pwMaxSatReader)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		solve = func() (solver.Result, error) {
+			return s.Optimal(nil, nil), nil
+		}
 	}
 	if err := <-satErrChan; err != nil {
 		return nil, nil, nil, err
@@ -282,7 +392,10 @@ func (res *Resolver) Resolve() (install []*api.Package, excluded []*api.Package,
 	satVars := <-varsChan
 
 	logrus.Info("Solving the Partial weighted MAXSAT problem.")
-	solution := s.Optimal(nil, nil)
+	solution, err := solve()
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
 	if solution.Status.String() == "SAT" {
 		logrus.Infof("Solution with weight %v found.", solution.Weight)
@@ -305,8 +418,8 @@ func (res *Resolver) Resolve() (install []*api.Package, excluded []*api.Package,
 			}
 		}
 		for _, v := range installMap {
-			if rpm.Compare(res.bestPackages[v.Name].Version, v.Version) != 0 {
-				logrus.Infof("Picking %v instead of best candiate %v", v, res.bestPackages[v.Name])
+			if rpm.Compare(res.bestPackages[v.NameArch()].Version, v.Version) != 0 {
+				logrus.Infof("Picking %v instead of best candiate %v", v, res.bestPackages[v.NameArch()])
 			}
 			install = append(install, v)
 		}
@@ -323,6 +436,82 @@ func (res *Resolver) Resolve() (install []*api.Package, excluded []*api.Package,
 	return nil, nil, nil, fmt.Errorf("no solution found")
 }
 
+// lockedAlternatives returns all versions of the package if one of them is locked. The soft rules then decide
+// which one is picked.
+func (r *Resolver) lockedAlternatives(req *Var) []*Var {
+	versions := r.packages[req.Package.NameArch()]
+	for _, v := range versions {
+		if r.isLocked(v.Package) {
+			return versions
+		}
+	}
+	return nil
+}
+
+// isLocked returns true if the package has the version which should be kept
+func (res *Resolver) isLocked(pkg *api.Package) bool {
+	version, exists := res.locked[pkg.Name]
+	return exists && version == pkg.Version.String()
+}
+
+// writeLocked writes soft rules which prefer the locked version of a package over all other versions. They
+// outweigh the rules preferring newer versions.
+func (res *Resolver) writeLocked(w io.Writer, pkgs []*Var, vars ConversionVars) error {
+	found := false
+	for _, pkg := range pkgs {
+		if res.isLocked(pkg.Package) {
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	for _, pkg := range pkgs {
+		if res.isLocked(pkg.Package) {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "c keep locked version instead of %s\n%d -%s 0\n", pkg.Package.String(), lockedWeight, vars.pkgToSat[pkg.satVarName]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeWeakDeps writes soft rules which prefer solutions where the recommendations of installed packages are
+// installed too
+func (res *Resolver) writeWeakDeps(w io.Writer, vars ConversionVars) error {
+	if !res.weakDeps {
+		return nil
+	}
+	for _, pkgs := range res.packages {
+		for _, pkgVar := range pkgs {
+			for _, rec := range pkgVar.Package.Format.Recommends.Entries {
+				if strings.HasPrefix(rec.Name, "(") || reducer.IgnoresWeakDep(res.ignoreWeakDeps, pkgVar.Package.Name, rec.Name) {
+					continue
+				}
+				candidates, err := res.explodeSingleRequires(rec, res.provides[rec.Name])
+				if err != nil {
+					logrus.Debugf("Package %s recommends %s, which can't be satisfied", pkgVar.Package, rec.Name)
+					continue
+				}
+				clause := []string{"-" + vars.pkgToSat[pkgVar.satVarName]}
+				for _, c := range candidates {
+					if satVar, exists := vars.pkgToSat[c.satVarName]; exists {
+						clause = append(clause, satVar)
+					}
+				}
+				if len(clause) == 1 {
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "c %s recommends %s\n%d %s 0\n", pkgVar.Package.String(), rec.Name, weakDepWeight, strings.Join(clause, " ")); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func (res *Resolver) MUS() (mus *explain.Problem, err error) {
 	logrus.Info("No solution found.")
 	r, w := io.Pipe()
@@ -350,6 +539,7 @@ func (r *Resolver) explodePackageToVars(pkg *api.Package) (pkgVar *Var, resource
 				varType:    VarTypePackage,
 				Context: VarContext{
 					Package:  pkg.Name,
+					Arch:     pkg.Arch,
 					Provides: pkg.Name,
 					Version:  pkg.Version,
 				},
@@ -363,6 +553,7 @@ func (r *Resolver) explodePackageToVars(pkg *api.Package) (pkgVar *Var, resource
 				varType:    VarTypeResource,
 				Context: VarContext{
 					Package:  pkg.Name,
+					Arch:     pkg.Arch,
 					Provides: p.Name,
 					Version:  pkg.Version,
 				},
@@ -383,6 +574,7 @@ func (r *Resolver) explodePackageToVars(pkg *api.Package) (pkgVar *Var, resource
 			varType:    VarTypeFile,
 			Context: VarContext{
 				Package:  pkg.Name,
+				Arch:     pkg.Arch,
 				Provides: f.Text,
 				Version:  pkg.Version,
 			},
@@ -447,9 +639,11 @@ func (r *Resolver) resolveNewest(pkgName string) (*Var, error) {
 	if len(pkgs) == 0 {
 		return nil, fmt.Errorf("package %s does not exist", pkgName)
 	}
+	pkgs = r.applyPreference(pkgName, pkgs)
 	newest := pkgs[0]
 	for _, p := range pkgs {
-		if rpm.Compare(p.Package.Version, newest.Package.Version) == 1 {
+		// native packages win over multilib packages of the same version
+		if cmp := rpm.Compare(p.Package.Version, newest.Package.Version); cmp == 1 || cmp == 0 && rpmarch.IsCompat(newest.Package.Arch) && !rpmarch.IsCompat(p.Package.Arch) {
 			newest = p
 		}
 	}
@@ -533,7 +727,27 @@ func (r *Resolver) explodeSingleRequires(entry api.Entry, provides []*Var) (acce
 		return nil, fmt.Errorf("Nothing can satisfy %s", entry.Name)
 	}
 
-	return accepts, nil
+	return r.applyPreference(entry.Name, accepts), nil
+}
+
+// applyPreference reduces the candidates for a capability to the preferred package, if a preference exists
+// and the preferred package is one of the candidates.
+func (r *Resolver) applyPreference(capability string, candidates []*Var) []*Var {
+	preferred, exists := r.preferences[capability]
+	if !exists {
+		return candidates
+	}
+	var filtered []*Var
+	for _, c := range candidates {
+		if c.Package.Name == preferred {
+			filtered = append(filtered, c)
+		}
+	}
+	if len(filtered) == 0 {
+		logrus.Warnf("Preferred package %s does not provide %s, ignoring the preference.", preferred, capability)
+		return candidates
+	}
+	return filtered
 }
 
 type ConversionVars struct {
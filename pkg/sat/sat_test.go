@@ -8,6 +8,7 @@ import (
 
 	. "github.com/onsi/gomega"
 	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
 )
 
 func TestRecursive(t *testing.T) {
@@ -1382,6 +1383,88 @@ func TestNewResolver(t *testing.T) {
 	}
 }
 
+func TestWeakDeps(t *testing.T) {
+	g := NewGomegaWithT(t)
+	newPackages := func() []*api.Package {
+		systemd := newPkg("systemd", "1", []string{}, []string{}, []string{})
+		for _, rec := range []string{"systemd-networkd", "systemd-resolved", "kbd", "missing"} {
+			systemd.Format.Recommends.Entries = append(systemd.Format.Recommends.Entries, api.Entry{Name: rec})
+		}
+		return []*api.Package{
+			systemd,
+			newPkg("systemd-networkd", "1", []string{}, []string{}, []string{}),
+			newPkg("systemd-resolved", "1", []string{}, []string{}, []string{}),
+			newPkg("kbd", "1", []string{}, []string{}, []string{}),
+		}
+	}
+	resolve := func(resolver *Resolver) []string {
+		g.Expect(resolver.LoadInvolvedPackages(newPackages(), nil)).To(Succeed())
+		g.Expect(resolver.ConstructRequirements([]string{"systemd"})).To(Succeed())
+		install, _, _, err := resolver.Resolve()
+		g.Expect(err).ToNot(HaveOccurred())
+		return pkgToString(install)
+	}
+
+	g.Expect(resolve(NewResolver(false))).To(ConsistOf("systemd-0:1"))
+
+	resolver := NewResolver(false)
+	resolver.SetWeakDeps(nil)
+	g.Expect(resolve(resolver)).To(ConsistOf("systemd-0:1", "systemd-networkd-0:1", "systemd-resolved-0:1", "kbd-0:1"))
+
+	resolver = NewResolver(false)
+	resolver.SetWeakDeps([]bazeldnf.WeakDepsFilter{{Package: "systemd*", Recommends: []string{"systemd-*"}}})
+	g.Expect(resolve(resolver)).To(ConsistOf("systemd-0:1", "kbd-0:1"))
+}
+
+func TestLockedVersions(t *testing.T) {
+	g := NewGomegaWithT(t)
+	resolve := func(locked map[string]string, required ...string) []string {
+		return resolveVersioned(g, func(resolver *Resolver) { resolver.SetLockedVersions(locked) }, required...)
+	}
+
+	g.Expect(resolve(nil, "app", "tool")).To(ConsistOf("app-0:2", "lib-0:2", "tool-0:2"))
+	g.Expect(resolve(map[string]string{"app": "0:1", "lib": "0:1", "tool": "0:1"}, "app", "tool")).To(ConsistOf("app-0:1", "lib-0:1", "tool-0:1"))
+	// lib has to move, since the new version of app requires it
+	g.Expect(resolve(map[string]string{"lib": "0:1", "tool": "0:1"}, "app", "tool")).To(ConsistOf("app-0:2", "lib-0:2", "tool-0:1"))
+}
+
+func TestPortfolio(t *testing.T) {
+	g := NewGomegaWithT(t)
+	for _, configurations := range []int{2, 4} {
+		install := resolveVersioned(g, func(resolver *Resolver) {
+			resolver.SetPortfolio(configurations)
+			resolver.SetLockedVersions(map[string]string{"lib": "0:1", "tool": "0:1"})
+		}, "app", "tool")
+		g.Expect(install).To(ConsistOf("app-0:2", "lib-0:2", "tool-0:1"))
+	}
+
+	// shuffled problems have to be mapped back to the original variables
+	wcnf := []byte("p wcnf 4 4 10\n10 1 0\n10 -1 2 0\n1 -3 0\n1 4 0\n")
+	for seed := int64(0); seed < 5; seed++ {
+		result, err := solveShuffled(wcnf, seed, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Model[:4]).To(Equal([]bool{true, true, false, true}))
+	}
+}
+
+func resolveVersioned(g *WithT, configure func(resolver *Resolver), required ...string) []string {
+	packages := []*api.Package{
+		newPkg("app", "1", []string{}, []string{"lib"}, []string{}),
+		newPkg("app", "2", []string{}, []string{"lib", "feature"}, []string{}),
+		newPkg("lib", "1", []string{}, []string{}, []string{}),
+		newPkg("lib", "2", []string{"feature"}, []string{}, []string{}),
+		newPkg("tool", "1", []string{}, []string{}, []string{}),
+		newPkg("tool", "2", []string{}, []string{}, []string{}),
+	}
+	resolver := NewResolver(false)
+	configure(resolver)
+	g.Expect(resolver.LoadInvolvedPackages(packages, nil)).To(Succeed())
+	g.Expect(resolver.ConstructRequirements(required)).To(Succeed())
+	install, _, _, err := resolver.Resolve()
+	g.Expect(err).ToNot(HaveOccurred())
+	return pkgToString(install)
+}
+
 func newPkg(name string, version string, provides []string, requires []string, conflicts []string) *api.Package {
 	pkg := &api.Package{}
 	pkg.Name = name
@@ -1421,3 +1504,33 @@ func pkgToString(given []*api.Package) (resolved []string) {
 	}
 	return
 }
+
+func TestNativeArchIsPreferred(t *testing.T) {
+	g := NewGomegaWithT(t)
+	compat := newPkg("glibc", "2", []string{}, []string{}, []string{})
+	compat.Arch = "i686"
+	native := newPkg("glibc", "2", []string{}, []string{}, []string{})
+	native.Arch = "x86_64"
+	resolver := NewResolver(false)
+	g.Expect(resolver.LoadInvolvedPackages([]*api.Package{compat, native}, nil)).To(Succeed())
+	g.Expect(resolver.ConstructRequirements([]string{"glibc"})).To(Succeed())
+	install, _, _, err := resolver.Resolve()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(install).To(ConsistOf(native))
+}
+
+func TestMultilibPackagesSideBySide(t *testing.T) {
+	g := NewGomegaWithT(t)
+	compat := newPkg("glibc", "2", []string{"libc.so.6"}, []string{}, []string{})
+	compat.Arch = "i686"
+	native := newPkg("glibc", "2", []string{"libc.so.6()(64bit)"}, []string{}, []string{})
+	native.Arch = "x86_64"
+	steam := newPkg("steam", "1", []string{}, []string{"libc.so.6"}, []string{})
+	steam.Arch = "i686"
+	resolver := NewResolver(false)
+	g.Expect(resolver.LoadInvolvedPackages([]*api.Package{compat, native, steam}, nil)).To(Succeed())
+	g.Expect(resolver.ConstructRequirements([]string{"glibc", "steam"})).To(Succeed())
+	install, _, _, err := resolver.Resolve()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(install).To(ConsistOf(native, compat, steam))
+}
@@ -0,0 +1,59 @@
+package sat
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/rmohr/bazeldnf/pkg/api"
+)
+
+// SkippedWeakDep describes a recommendation of an installed package which is not installed, like `bash`
+// recommending `bash-completion`.
+type SkippedWeakDep struct {
+	Package    string
+	Capability string
+}
+
+// FindSkippedWeakDeps returns the recommendations of the installed packages which none of the installed packages
+// satisfies, because weak dependencies were disabled, ignored or could not be satisfied. Rich dependencies are
+// not evaluated and never reported.
+func FindSkippedWeakDeps(installed []*api.Package) (skipped []SkippedWeakDep) {
+	provides := map[string][]*Var{}
+	for _, pkg := range installed {
+		for _, prov := range pkg.Format.Provides.Entries {
+			provides[prov.Name] = append(provides[prov.Name], &Var{
+				Package:         pkg,
+				ResourceVersion: &api.Version{Epoch: prov.Epoch, Ver: prov.Ver, Rel: prov.Rel},
+			})
+		}
+		for _, file := range pkg.Format.Files {
+			provides[file.Text] = append(provides[file.Text], &Var{Package: pkg, ResourceVersion: &api.Version{}})
+		}
+	}
+
+	seen := map[SkippedWeakDep]struct{}{}
+	for _, pkg := range installed {
+		for _, rec := range pkg.Format.Recommends.Entries {
+			if strings.HasPrefix(rec.Name, "(") {
+				continue
+			}
+			entryVer := api.Version{Epoch: rec.Epoch, Ver: rec.Ver, Rel: rec.Rel}
+			if accepts, err := compareRequires(entryVer, rec.Flags, provides[rec.Name]); err == nil && len(accepts) > 0 {
+				continue
+			}
+			dep := SkippedWeakDep{Package: pkg.Name, Capability: rec.Name}
+			if _, exists := seen[dep]; exists {
+				continue
+			}
+			seen[dep] = struct{}{}
+			skipped = append(skipped, dep)
+		}
+	}
+	sort.SliceStable(skipped, func(i, j int) bool {
+		if skipped[i].Package != skipped[j].Package {
+			return skipped[i].Package < skipped[j].Package
+		}
+		return skipped[i].Capability < skipped[j].Capability
+	})
+	return skipped
+}
@@ -0,0 +1,30 @@
+package sat
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+)
+
+func TestFindSkippedWeakDeps(t *testing.T) {
+	g := NewGomegaWithT(t)
+	bash := newPkg("bash", "5", []string{}, []string{}, []string{})
+	bash.Format.Recommends.Entries = []api.Entry{
+		{Name: "bash-completion"},
+		{Name: "coreutils", Flags: "GE", Ver: "9"},
+		{Name: "/usr/bin/less"},
+		{Name: "(vim if emacs)"},
+	}
+	coreutils := newPkg("coreutils", "8", []string{}, []string{}, []string{})
+	coreutils.Format.Recommends.Entries = []api.Entry{{Name: "bash-completion"}, {Name: "bash", Flags: "GE", Ver: "5"}}
+	less := newPkg("less", "1", []string{}, []string{}, []string{})
+	less.Format.Files = []api.ProvidedFile{{Text: "/usr/bin/less"}}
+
+	g.Expect(FindSkippedWeakDeps([]*api.Package{bash, coreutils, less})).To(Equal([]SkippedWeakDep{
+		{Package: "bash", Capability: "bash-completion"},
+		{Package: "bash", Capability: "coreutils"},
+		{Package: "coreutils", Capability: "bash-completion"},
+	}))
+	g.Expect(FindSkippedWeakDeps([]*api.Package{less})).To(BeEmpty())
+}
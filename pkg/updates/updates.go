@@ -0,0 +1,148 @@
+package updates
+
+import (
+	"sort"
+
+	"github.com/rmohr/bazeldnf/pkg/advisory"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+	"github.com/rmohr/bazeldnf/pkg/rpm"
+)
+
+// Update is an upgrade which is available for a locked package
+type Update struct {
+	Locked bazeldnf.LockedPackage
+	// Package is the newest available replacement, it has a different name if the locked package is obsoleted
+	Package *api.Package
+	// Obsoleted is true if the locked package is replaced by a package with a different name
+	Obsoleted bool
+	// Advisories lists the advisories which are fixed by the upgrade
+	Advisories []advisory.Match
+}
+
+// Find returns the available upgrades of the locked packages, sorted by package name. Packages which are
+// obsoleted by another package are replaced by the newest obsoleting package, advisories are attached if
+// updateinfos are given.
+func Find(locked []bazeldnf.LockedPackage, available []*api.Package, updateinfos map[string]*api.Updateinfo) []Update {
+	result := []Update{}
+	seen := map[string]bool{}
+	for _, pkg := range locked {
+		if seen[pkg.ID()] {
+			continue
+		}
+		seen[pkg.ID()] = true
+		version := withEpoch(api.Version{Epoch: pkg.Epoch, Ver: pkg.Version, Rel: pkg.Release})
+
+		update := Update{Locked: pkg}
+		if obsoleting := newestObsoleting(pkg, version, available); obsoleting != nil {
+			update.Package = obsoleting
+			update.Obsoleted = true
+			update.Advisories = advisory.Find(updateinfos, pkg.Name, pkg.Arch, &version)
+			result = append(result, update)
+			continue
+		}
+		newest := newestUpgrade(pkg, version, available)
+		if newest == nil {
+			continue
+		}
+		update.Package = newest
+		for _, match := range advisory.Find(updateinfos, pkg.Name, pkg.Arch, &version) {
+			if rpm.Compare(match.Fixed, withEpoch(newest.Version)) <= 0 {
+				update.Advisories = append(update.Advisories, match)
+			}
+		}
+		result = append(result, update)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Locked.ID() < result[j].Locked.ID()
+	})
+	return result
+}
+
+// newestUpgrade returns the newest package with the same name which is newer than the locked version
+func newestUpgrade(locked bazeldnf.LockedPackage, version api.Version, available []*api.Package) (newest *api.Package) {
+	for _, pkg := range available {
+		if pkg.Name != locked.Name || !compatibleArch(locked.Arch, pkg.Arch) {
+			continue
+		}
+		if rpm.Compare(withEpoch(pkg.Version), version) <= 0 {
+			continue
+		}
+		if newest == nil || rpm.Compare(withEpoch(pkg.Version), withEpoch(newest.Version)) > 0 {
+			newest = pkg
+		}
+	}
+	return newest
+}
+
+// newestObsoleting returns the newest package with a different name which obsoletes the locked version
+func newestObsoleting(locked bazeldnf.LockedPackage, version api.Version, available []*api.Package) (newest *api.Package) {
+	for _, pkg := range available {
+		if pkg.Name == locked.Name || !compatibleArch(locked.Arch, pkg.Arch) {
+			continue
+		}
+		if !obsoletes(pkg, locked.Name, version) {
+			continue
+		}
+		if newest == nil || newest.Name > pkg.Name ||
+			newest.Name == pkg.Name && rpm.Compare(withEpoch(pkg.Version), withEpoch(newest.Version)) > 0 {
+			newest = pkg
+		}
+	}
+	return newest
+}
+
+// obsoletes returns true if the package obsoletes the given version of the named package
+func obsoletes(pkg *api.Package, name string, version api.Version) bool {
+	for _, entry := range pkg.Format.Obsoletes.Entries {
+		if entry.Name != name {
+			continue
+		}
+		if entry.Flags == "" {
+			return true
+		}
+		// an obsoletes entry without release matches all releases of the version
+		entryVersion := withEpoch(api.Version{Epoch: entry.Epoch, Ver: entry.Ver, Rel: entry.Rel})
+		compared := version
+		if entryVersion.Rel == "" {
+			compared.Rel = ""
+		}
+		cmp := rpm.Compare(compared, entryVersion)
+		switch entry.Flags {
+		case "EQ":
+			if cmp == 0 {
+				return true
+			}
+		case "LE":
+			if cmp <= 0 {
+				return true
+			}
+		case "GE":
+			if cmp >= 0 {
+				return true
+			}
+		case "LT":
+			if cmp < 0 {
+				return true
+			}
+		case "GT":
+			if cmp > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compatibleArch returns true if a package of the given architecture can replace a locked package
+func compatibleArch(locked string, arch string) bool {
+	return locked == arch || locked == "noarch" || arch == "noarch"
+}
+
+// withEpoch returns the version with the implicit epoch 0 made explicit
+func withEpoch(version api.Version) api.Version {
+	if version.Epoch == "" {
+		version.Epoch = "0"
+	}
+	return version
+}
@@ -0,0 +1,63 @@
+package updates
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rmohr/bazeldnf/pkg/api"
+	"github.com/rmohr/bazeldnf/pkg/api/bazeldnf"
+)
+
+func newPackage(name string, arch string, ver string, rel string) *api.Package {
+	return &api.Package{Name: name, Arch: arch, Version: api.Version{Epoch: "0", Ver: ver, Rel: rel}}
+}
+
+func newAdvisory(id string, name string, ver string, rel string) api.Advisory {
+	return api.Advisory{ID: id, Packages: []api.AdvisoryPackage{{Name: name, Version: ver, Release: rel, Arch: "x86_64"}}}
+}
+
+func TestFind(t *testing.T) {
+	g := NewGomegaWithT(t)
+	locked := []bazeldnf.LockedPackage{
+		{Name: "bash", Version: "5.2.26", Release: "1.fc40", Arch: "x86_64"},
+		{Name: "bash", Version: "5.2.26", Release: "1.fc40", Arch: "x86_64"},
+		{Name: "zsh", Version: "5.9", Release: "1.fc40", Arch: "x86_64"},
+		{Name: "fakesystemd", Version: "1", Release: "1.fc40", Arch: "noarch"},
+	}
+	systemd := newPackage("systemd", "x86_64", "256", "1.fc40")
+	systemd.Format.Obsoletes.Entries = []api.Entry{{Name: "fakesystemd", Flags: "LT", Epoch: "0", Ver: "2"}}
+	available := []*api.Package{
+		newPackage("bash", "x86_64", "5.2.26", "1.fc40"),
+		newPackage("bash", "x86_64", "5.2.32", "1.fc40"),
+		newPackage("bash", "x86_64", "5.2.26", "3.fc40"),
+		newPackage("bash", "aarch64", "5.3.0", "1.fc40"),
+		newPackage("zsh", "x86_64", "5.9", "1.fc40"),
+		systemd,
+	}
+	updateinfos := map[string]*api.Updateinfo{"updates": {Advisories: []api.Advisory{
+		newAdvisory("FEDORA-2024-0001", "bash", "5.2.26", "3.fc40"),
+		newAdvisory("FEDORA-2024-0002", "bash", "5.3.0", "1.fc40"),
+	}}}
+
+	updates := Find(locked, available, updateinfos)
+	g.Expect(updates).To(HaveLen(2))
+	g.Expect(updates[0].Locked.Name).To(Equal("bash"))
+	g.Expect(updates[0].Package.Version.Ver).To(Equal("5.2.32"))
+	g.Expect(updates[0].Obsoleted).To(BeFalse())
+	g.Expect(updates[0].Advisories).To(HaveLen(1))
+	g.Expect(updates[0].Advisories[0].Advisory.ID).To(Equal("FEDORA-2024-0001"))
+	g.Expect(updates[1].Locked.Name).To(Equal("fakesystemd"))
+	g.Expect(updates[1].Package).To(Equal(systemd))
+	g.Expect(updates[1].Obsoleted).To(BeTrue())
+}
+
+func TestObsoletes(t *testing.T) {
+	g := NewGomegaWithT(t)
+	pkg := newPackage("systemd", "x86_64", "256", "1.fc40")
+	pkg.Format.Obsoletes.Entries = []api.Entry{{Name: "fakesystemd", Flags: "EQ", Epoch: "0", Ver: "1"}}
+	g.Expect(obsoletes(pkg, "fakesystemd", api.Version{Epoch: "0", Ver: "1", Rel: "5.fc40"})).To(BeTrue())
+	g.Expect(obsoletes(pkg, "fakesystemd", api.Version{Epoch: "0", Ver: "2", Rel: "1.fc40"})).To(BeFalse())
+	g.Expect(obsoletes(pkg, "other", api.Version{Epoch: "0", Ver: "1", Rel: "5.fc40"})).To(BeFalse())
+	pkg.Format.Obsoletes.Entries = []api.Entry{{Name: "fakesystemd"}}
+	g.Expect(obsoletes(pkg, "fakesystemd", api.Version{Epoch: "0", Ver: "2", Rel: "1.fc40"})).To(BeTrue())
+}